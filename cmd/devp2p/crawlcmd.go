@@ -0,0 +1,515 @@
+// Copyright 2022 The go-ETX Authors
+// This file is part of go-ETX.
+//
+// go-ETX is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ETX is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ETX. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ETX/go-ETX/common/hexutil"
+	"github.com/ETX/go-ETX/core/forkid"
+	"github.com/ETX/go-ETX/etxdb"
+	"github.com/ETX/go-ETX/etxdb/leveldb"
+	"github.com/ETX/go-ETX/log"
+	"github.com/ETX/go-ETX/p2p/discover"
+	"github.com/ETX/go-ETX/p2p/enode"
+	"github.com/urfave/cli/v2"
+)
+
+var (
+	crawlCommand = &cli.Command{
+		Name:  "crawl",
+		Usage: "Node crawler tools",
+		Subcommands: []*cli.Command{
+			crawlServeCommand,
+		},
+	}
+	crawlServeCommand = &cli.Command{
+		Name:      "serve",
+		Usage:     "Continuously crawls the discovery DHTs and serves network statistics",
+		ArgsUsage: "<database-dir>",
+		Action:    crawlServe,
+		Flags: []cli.Flag{
+			bootnodesFlag,
+			nodekeyFlag,
+			listenAddrFlag,
+			crawlV4Flag,
+			crawlV5Flag,
+			crawlHTTPFlag,
+			crawlOutputFlag,
+			crawlNetworkFlag,
+			crawlRevalidateFlag,
+		},
+	}
+	crawlV4Flag = &cli.BoolFlag{
+		Name:  "v4",
+		Usage: "Crawl the discovery v4 DHT",
+		Value: true,
+	}
+	crawlV5Flag = &cli.BoolFlag{
+		Name:  "v5",
+		Usage: "Crawl the discovery v5 DHT",
+		Value: true,
+	}
+	crawlHTTPFlag = &cli.StringFlag{
+		Name:  "http",
+		Usage: "Listening address of the HTTP statistics server",
+		Value: "127.0.0.1:8550",
+	}
+	crawlOutputFlag = &cli.StringFlag{
+		Name:  "output",
+		Usage: "DNS tree directory to periodically write the live nodes.json into",
+	}
+	crawlNetworkFlag = &cli.StringFlag{
+		Name:  "network",
+		Usage: "Network to check the fork IDs of nodes against (mainnet, goerli, ...)",
+	}
+	crawlRevalidateFlag = &cli.DurationFlag{
+		Name:  "revalidate",
+		Usage: "Time between checks of a node",
+		Value: 30 * time.Minute,
+	}
+)
+
+const (
+	crawlWorkers       = 16                 // concurrent node checks
+	crawlWriteInterval = 5 * time.Minute    // interval of nodes.json output
+	crawlLiveTime      = 24 * time.Hour     // nodes seen within this time count as live
+	crawlDialInterval  = 6 * time.Hour      // interval of RLPx handshakes with a node
+	crawlExpiry        = 7 * 24 * time.Hour // nodes not seen for this time are deleted
+)
+
+// crawlNodePrefix is the database key prefix of node histories.
+var crawlNodePrefix = []byte("n")
+
+// crawledNode is the history of a node recorded by the crawler service.
+type crawledNode struct {
+	N             *enode.Node `json:"record"`
+	Score         int         `json:"score"`
+	FirstResponse time.Time   `json:"firstResponse"`
+	LastResponse  time.Time   `json:"lastResponse"`
+	LastCheck     time.Time   `json:"lastCheck"`
+	Sources       []string    `json:"sources"`
+	ForkID        *forkid.ID  `json:"forkID,omitempty"`
+
+	// These are taken from the RLPx protocol handshake.
+	ClientName string    `json:"clientName,omitempty"`
+	Caps       []string  `json:"caps,omitempty"`
+	LastDial   time.Time `json:"lastDial,omitempty"`
+	DialError  string    `json:"dialError,omitempty"`
+}
+
+// live reports whetxer the node responded recently.
+func (n *crawledNode) live(now time.Time) bool {
+	return n.Score > 0 && now.Sub(n.LastResponse) < crawlLiveTime
+}
+
+// addSource records the DHT a node was found in.
+func (n *crawledNode) addSource(source string) {
+	for _, s := range n.Sources {
+		if s == source {
+			return
+		}
+	}
+	n.Sources = append(n.Sources, source)
+	sort.Strings(n.Sources)
+}
+
+// crawlService crawls the discovery DHTs, maintaining the node histories in its
+// database.
+type crawlService struct {
+	db         etxdb.KeyValueStore
+	filter     forkid.Filter // optional, checks fork readiness
+	revalidate time.Duration
+
+	mu      sync.Mutex
+	nodes   map[enode.ID]*crawledNode
+	pending map[enode.ID]bool // nodes being checked
+	updated time.Time
+}
+
+// crawlStats are the aggregated statistics of the live nodes.
+type crawlStats struct {
+	Updated    time.Time      `json:"updated"`
+	Nodes      int            `json:"nodes"`      // all known nodes
+	Live       int            `json:"live"`       // nodes responding in the last day
+	Dialable   int            `json:"dialable"`   // live nodes accepting RLPx connections
+	Compatible *int           `json:"compatible"` // live nodes with a compatible fork ID
+	Sources    map[string]int `json:"sources"`
+	IPVersions map[string]int `json:"ipVersions"`
+	Clients    map[string]int `json:"clients"`
+	Versions   map[string]int `json:"versions"`
+	Caps       map[string]int `json:"caps"`
+	ForkIDs    []forkIDStat   `json:"forkIDs"`
+}
+
+type forkIDStat struct {
+	Hash       hexutil.Bytes `json:"hash"`
+	Next       uint64        `json:"next"`
+	Count      int           `json:"count"`
+	Compatible *bool         `json:"compatible,omitempty"`
+}
+
+func crawlServe(ctx *cli.Context) error {
+	if ctx.NArg() < 1 {
+		return fmt.Errorf("need database directory as argument")
+	}
+	db, err := leveldb.New(ctx.Args().First(), 16, 16, "", false)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	s, err := newCrawlService(db, ctx.Duration(crawlRevalidateFlag.Name))
+	if err != nil {
+		return err
+	}
+	if network := ctx.String(crawlNetworkFlag.Name); network != "" {
+		if s.filter, err = networkForkFilter(network); err != nil {
+			return err
+		}
+	}
+	// Start the crawlers.
+	work := make(chan func(), crawlWorkers)
+	for i := 0; i < crawlWorkers; i++ {
+		go func() {
+			for fn := range work {
+				fn()
+			}
+		}()
+	}
+	if ctx.Bool(crawlV4Flag.Name) {
+		disc := startV4(ctx)
+		defer disc.Close()
+		go s.crawl("discv4", disc, disc.RandomNodes(), work)
+	}
+	if ctx.Bool(crawlV5Flag.Name) {
+		ln, config := makeDiscoveryConfig(ctx)
+		disc, err := discover.ListenV5(listen(ln, ""), ln, config)
+		if err != nil {
+			return err
+		}
+		defer disc.Close()
+		go s.crawl("discv5", disc, disc.RandomNodes(), work)
+	}
+	// Expire old nodes and write the DNS tree input periodically.
+	dir := ctx.String(crawlOutputFlag.Name)
+	if dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+	go func() {
+		for range time.Tick(crawlWriteInterval) {
+			s.expire()
+			if dir != "" {
+				_, nodesFile := treeDefinitionFiles(dir)
+				if err := saveNodesJSON(nodesFile, s.nodeSet()); err != nil {
+					log.Error("Failed to write crawled nodes", "file", nodesFile, "err", err)
+				}
+			}
+		}
+	}()
+	// Serve the statistics.
+	mux := http.NewServeMux()
+	mux.HandleFunc("/stats", func(w http.ResponseWriter, r *http.Request) {
+		serveJSON(w, s.stats())
+	})
+	mux.HandleFunc("/nodes", func(w http.ResponseWriter, r *http.Request) {
+		serveJSON(w, s.history())
+	})
+	mux.HandleFunc("/nodes.json", func(w http.ResponseWriter, r *http.Request) {
+		serveJSON(w, s.nodeSet())
+	})
+	log.Info("Serving crawler statistics", "addr", ctx.String(crawlHTTPFlag.Name))
+	return http.ListenAndServe(ctx.String(crawlHTTPFlag.Name), mux)
+}
+
+func serveJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", jsonIndent)
+	enc.Encode(v)
+}
+
+// newCrawlService creates a crawler service, loading the node histories from
+// the database.
+func newCrawlService(db etxdb.KeyValueStore, revalidate time.Duration) (*crawlService, error) {
+	s := &crawlService{
+		db:         db,
+		revalidate: revalidate,
+		nodes:      make(map[enode.ID]*crawledNode),
+		pending:    make(map[enode.ID]bool),
+	}
+	it := db.NewIterator(crawlNodePrefix, nil)
+	defer it.Release()
+
+	for it.Next() {
+		n := new(crawledNode)
+		if err := json.Unmarshal(it.Value(), n); err != nil {
+			return nil, fmt.Errorf("invalid node history %x: %v", it.Key(), err)
+		}
+		s.nodes[n.N.ID()] = n
+	}
+	log.Info("Loaded node histories", "nodes", len(s.nodes))
+	return s, it.Error()
+}
+
+// crawl checks the nodes found by a discovery iterator.
+func (s *crawlService) crawl(source string, disc resolver, it enode.Iterator, work chan<- func()) {
+	for it.Next() {
+		n := it.Node()
+		if !s.startCheck(source, n) {
+			continue
+		}
+		work <- func() { s.check(source, disc, n) }
+	}
+}
+
+// startCheck reports whetxer a node is due for a check, marking it pending.
+func (s *crawlService) startCheck(source string, n *enode.Node) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.pending[n.ID()] {
+		return false
+	}
+	if node := s.nodes[n.ID()]; node != nil {
+		node.addSource(source)
+		if time.Since(node.LastCheck) < s.revalidate {
+			return false
+		}
+	}
+	s.pending[n.ID()] = true
+	return true
+}
+
+// check requests the record of a node, and performs the RLPx handshake with it
+// from time to time.
+func (s *crawlService) check(source string, disc resolver, n *enode.Node) {
+	s.mu.Lock()
+	node := s.nodes[n.ID()]
+	if node == nil {
+		node = &crawledNode{N: n}
+	}
+	update := *node
+	update.Sources = append([]string(nil), node.Sources...)
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.pending, n.ID())
+		s.mu.Unlock()
+	}()
+
+	update.LastCheck = truncNow()
+	update.addSource(source)
+	nn, err := disc.RequestENR(n)
+	if err != nil {
+		if node.Score == 0 {
+			// Node doesn't implement EIP-868.
+			log.Debug("Skipping node", "id", n.ID())
+			return
+		}
+		update.Score /= 2
+	} else {
+		update.N = nn
+		update.Score++
+		if update.FirstResponse.IsZero() {
+			update.FirstResponse = update.LastCheck
+		}
+		update.LastResponse = update.LastCheck
+		update.ForkID = nil
+		if id, ok := enrForkID(nn); ok {
+			update.ForkID = &id
+		}
+		if nn.TCP() != 0 && time.Since(update.LastDial) >= crawlDialInterval {
+			update.LastDial = update.LastCheck
+			update.DialError = ""
+			if h, err := rlpxHello(nn); err != nil {
+				update.DialError = err.Error()
+			} else {
+				update.ClientName = h.Name
+				update.Caps = nil
+				for _, cap := range h.Caps {
+					update.Caps = append(update.Caps, cap.String())
+				}
+			}
+		}
+	}
+	log.Debug("Updating node", "id", n.ID(), "seq", update.N.Seq(), "score", update.Score, "client", update.ClientName)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.nodes[n.ID()] = &update
+	s.updated = time.Now()
+	if err := s.store(&update); err != nil {
+		log.Error("Failed to store node history", "id", n.ID(), "err", err)
+	}
+}
+
+// store writes a node history to the database.
+func (s *crawlService) store(n *crawledNode) error {
+	enc, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+	id := n.N.ID()
+	return s.db.Put(append(crawlNodePrefix, id[:]...), enc)
+}
+
+// expire deletes the nodes which haven't responded for a long time.
+func (s *crawlService) expire() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for id, n := range s.nodes {
+		if now.Sub(n.LastResponse) < crawlExpiry || s.pending[id] {
+			continue
+		}
+		delete(s.nodes, id)
+		if err := s.db.Delete(append(crawlNodePrefix, id[:]...)); err != nil {
+			log.Error("Failed to delete node history", "id", id, "err", err)
+		}
+	}
+}
+
+// history returns the histories of all known nodes, sorted by ID.
+func (s *crawlService) history() []*crawledNode {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	nodes := make([]*crawledNode, 0, len(s.nodes))
+	for _, n := range s.nodes {
+		nodes = append(nodes, n)
+	}
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].N.ID().String() < nodes[j].N.ID().String()
+	})
+	return nodes
+}
+
+// nodeSet returns the live nodes in the nodes.json format, as used by DNS trees.
+func (s *crawlService) nodeSet() nodeSet {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var (
+		now = time.Now()
+		set = make(nodeSet)
+	)
+	for id, n := range s.nodes {
+		if !n.live(now) {
+			continue
+		}
+		set[id] = nodeJSON{
+			Seq:           n.N.Seq(),
+			N:             n.N,
+			Score:         n.Score,
+			FirstResponse: n.FirstResponse,
+			LastResponse:  n.LastResponse,
+			LastCheck:     n.LastCheck,
+		}
+	}
+	return set
+}
+
+// stats aggregates the statistics of the live nodes.
+func (s *crawlService) stats() *crawlStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var (
+		now   = time.Now()
+		forks = make(map[forkid.ID]int)
+		stats = &crawlStats{
+			Updated:    s.updated,
+			Nodes:      len(s.nodes),
+			Sources:    make(map[string]int),
+			IPVersions: make(map[string]int),
+			Clients:    make(map[string]int),
+			Versions:   make(map[string]int),
+			Caps:       make(map[string]int),
+		}
+	)
+	if s.filter != nil {
+		stats.Compatible = new(int)
+	}
+	for _, n := range s.nodes {
+		if !n.live(now) {
+			continue
+		}
+		stats.Live++
+		stats.Sources[strings.Join(n.Sources, "+")]++
+		if n.N.IP().To4() != nil {
+			stats.IPVersions["ipv4"]++
+		} else if len(n.N.IP()) == net.IPv6len {
+			stats.IPVersions["ipv6"]++
+		}
+		if n.ClientName != "" && n.DialError == "" {
+			stats.Dialable++
+			client, version := splitClientName(n.ClientName)
+			stats.Clients[client]++
+			stats.Versions[client+"/"+version]++
+			for _, cap := range n.Caps {
+				stats.Caps[cap]++
+			}
+		}
+		if n.ForkID != nil {
+			forks[*n.ForkID]++
+			if s.filter != nil && s.filter(*n.ForkID) == nil {
+				*stats.Compatible++
+			}
+		}
+	}
+	for id, count := range forks {
+		stat := forkIDStat{Hash: id.Hash[:], Next: id.Next, Count: count}
+		if s.filter != nil {
+			compatible := s.filter(id) == nil
+			stat.Compatible = &compatible
+		}
+		stats.ForkIDs = append(stats.ForkIDs, stat)
+	}
+	sort.Slice(stats.ForkIDs, func(i, j int) bool {
+		return stats.ForkIDs[i].Count > stats.ForkIDs[j].Count
+	})
+	return stats
+}
+
+// splitClientName splits a client name like "Getx/v1.10.23-stable/linux-amd64/go1.18.5"
+// into the client and its version.
+func splitClientName(name string) (string, string) {
+	parts := strings.Split(name, "/")
+	client := parts[0]
+	if len(parts) > 1 && !strings.HasPrefix(parts[1], "v") && len(parts) > 2 {
+		// Custom node name, e.g. "Getx/mynode/v1.10.23-stable/..."
+		parts = parts[1:]
+	}
+	if len(parts) < 2 {
+		return client, "unknown"
+	}
+	return client, parts[1]
+}
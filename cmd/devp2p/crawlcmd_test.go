@@ -0,0 +1,108 @@
+// Copyright 2022 The go-ETX Authors
+// This file is part of go-ETX.
+//
+// go-ETX is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ETX is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ETX. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ETX/go-ETX/crypto"
+	"github.com/ETX/go-ETX/etxdb/memorydb"
+	"github.com/ETX/go-ETX/p2p/enode"
+	"github.com/ETX/go-ETX/p2p/enr"
+)
+
+func TestCrawlServiceStats(t *testing.T) {
+	db := memorydb.New()
+	s, err := newCrawlService(db, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	for i, n := range []*crawledNode{
+		{Score: 1, LastResponse: now, Sources: []string{"discv4"}, ClientName: "Getx/v1.10.23-stable/linux-amd64/go1.18.5", Caps: []string{"etx/66"}},
+		{Score: 2, LastResponse: now, Sources: []string{"discv4", "discv5"}, ClientName: "Getx/mynode/v1.10.23-stable/linux-amd64/go1.18.5", Caps: []string{"etx/66", "snap/1"}},
+		{Score: 1, LastResponse: now, Sources: []string{"discv5"}, DialError: "too many peers"},
+		{Score: 1, LastResponse: now.Add(-2 * crawlLiveTime), Sources: []string{"discv5"}},
+	} {
+		key, _ := crypto.GenerateKey()
+		var r enr.Record
+		r.Set(enr.IP(net.IP{10, 0, 0, byte(i)}))
+		if err := enode.SignV4(&r, key); err != nil {
+			t.Fatal(err)
+		}
+		if n.N, err = enode.New(enode.ValidSchemes, &r); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.store(n); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Reload the histories from the database.
+	if s, err = newCrawlService(db, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	stats := s.stats()
+	if stats.Nodes != 4 || stats.Live != 3 || stats.Dialable != 2 {
+		t.Fatalf("wrong node counts: nodes %d, live %d, dialable %d", stats.Nodes, stats.Live, stats.Dialable)
+	}
+	if stats.Clients["Getx"] != 2 || stats.Versions["Getx/v1.10.23-stable"] != 2 {
+		t.Errorf("wrong client stats: %v %v", stats.Clients, stats.Versions)
+	}
+	if stats.Caps["etx/66"] != 2 || stats.Caps["snap/1"] != 1 {
+		t.Errorf("wrong capability stats: %v", stats.Caps)
+	}
+	if stats.Sources["discv4+discv5"] != 1 || stats.Sources["discv5"] != 1 {
+		t.Errorf("wrong source stats: %v", stats.Sources)
+	}
+	if set := s.nodeSet(); len(set) != 3 {
+		t.Errorf("wrong node set size %d", len(set))
+	}
+}
+
+func TestSaveNodesJSON(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	var r enr.Record
+	if err := enode.SignV4(&r, key); err != nil {
+		t.Fatal(err)
+	}
+	n, err := enode.New(enode.ValidSchemes, &r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ns := nodeSet{n.ID(): {N: n, Seq: n.Seq(), Score: 1}}
+
+	dir := t.TempDir()
+	file := filepath.Join(dir, "nodes.json")
+	if err := saveNodesJSON(file, ns); err != nil {
+		t.Fatal(err)
+	}
+	if loaded := loadNodesJSON(file); len(loaded) != 1 || loaded[n.ID()].Score != 1 {
+		t.Fatalf("wrong node set loaded: %v", loaded)
+	}
+	// The temporary file must have been renamed.
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Fatalf("wrong number of files in output directory: %d", len(entries))
+	}
+	// Write errors must be reported instead of exiting.
+	if err := saveNodesJSON(filepath.Join(dir, "missing", "nodes.json"), ns); err == nil {
+		t.Fatal("expected error writing to missing directory")
+	}
+}
@@ -23,6 +23,7 @@ import (
 	"github.com/ETX/go-ETX/cmd/devp2p/internal/v5test"
 	"github.com/ETX/go-ETX/common"
 	"github.com/ETX/go-ETX/p2p/discover"
+	"github.com/ETX/go-ETX/p2p/enode"
 	"github.com/urfave/cli/v2"
 )
 
@@ -36,6 +37,8 @@ var (
 			discv5CrawlCommand,
 			discv5TestCommand,
 			discv5ListenCommand,
+			discv5TopicRegisterCommand,
+			discv5TopicSearchCommand,
 		},
 	}
 	discv5PingCommand = &cli.Command{
@@ -77,6 +80,30 @@ var (
 			listenAddrFlag,
 		},
 	}
+	discv5TopicRegisterCommand = &cli.Command{
+		Name:      "topic-register",
+		Usage:     "Runs a node advertising itself under a topic",
+		ArgsUsage: "<topic>",
+		Action:    discv5TopicRegister,
+		Flags: []cli.Flag{
+			bootnodesFlag,
+			nodekeyFlag,
+			nodedbFlag,
+			listenAddrFlag,
+		},
+	}
+	discv5TopicSearchCommand = &cli.Command{
+		Name:      "topic-search",
+		Usage:     "Prints the nodes advertised under a topic",
+		ArgsUsage: "<topic>",
+		Action:    discv5TopicSearch,
+		Flags:     []cli.Flag{bootnodesFlag, topicTimeoutFlag},
+	}
+	topicTimeoutFlag = &cli.DurationFlag{
+		Name:  "timeout",
+		Usage: "Time limit for the search.",
+		Value: time.Minute,
+	}
 )
 
 func discv5Ping(ctx *cli.Context) error {
@@ -134,6 +161,46 @@ func discv5Listen(ctx *cli.Context) error {
 	select {}
 }
 
+func discv5TopicRegister(ctx *cli.Context) error {
+	topic := getTopicArg(ctx)
+	disc := startV5(ctx)
+	defer disc.Close()
+
+	disc.RegisterTopic(topic)
+	fmt.Println(disc.Self())
+	select {}
+}
+
+func discv5TopicSearch(ctx *cli.Context) error {
+	topic := getTopicArg(ctx)
+	disc := startV5(ctx)
+	defer disc.Close()
+
+	it := disc.TopicNodes(topic)
+	defer it.Close()
+
+	var (
+		seen    = make(map[enode.ID]bool)
+		timeout = time.AfterFunc(ctx.Duration(topicTimeoutFlag.Name), it.Close)
+	)
+	defer timeout.Stop()
+	for it.Next() {
+		if n := it.Node(); !seen[n.ID()] {
+			seen[n.ID()] = true
+			fmt.Println(n)
+		}
+	}
+	return nil
+}
+
+// getTopicArg returns the topic named by the first command-line argument.
+func getTopicArg(ctx *cli.Context) discover.Topic {
+	if ctx.NArg() < 1 {
+		exit("missing topic as command-line argument")
+	}
+	return discover.NewTopic(ctx.Args().First())
+}
+
 // startV5 starts an ephemeral discovery v5 node.
 func startV5(ctx *cli.Context) *discover.UDPv5 {
 	ln, config := makeDiscoveryConfig(ctx)
@@ -22,6 +22,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/ETX/go-ETX/crypto"
 	"github.com/ETX/go-ETX/internal/utesting"
 	"github.com/ETX/go-ETX/p2p/discover/v5wire"
 	"github.com/ETX/go-ETX/p2p/enode"
@@ -55,6 +56,8 @@ func (s *Suite) AllTests() []utesting.Test {
 		{Name: "TalkRequest", Fn: s.TestTalkRequest},
 		{Name: "FindnodeZeroDistance", Fn: s.TestFindnodeZeroDistance},
 		{Name: "FindnodeResults", Fn: s.TestFindnodeResults},
+		{Name: "TopicRegistration", Fn: s.TestTopicRegistration},
+		{Name: "RegtopicInvalidTicket", Fn: s.TestRegtopicInvalidTicket},
 	}
 }
 
@@ -291,6 +294,70 @@ func (s *Suite) TestFindnodeResults(t *utesting.T) {
 	}
 }
 
+// TestTopicRegistration registers under a topic using a ticket and checks that the
+// registration is returned by TOPICQUERY.
+func (s *Suite) TestTopicRegistration(t *utesting.T) {
+	conn, l1 := s.listen1(t)
+	defer conn.close()
+	conn.setEndpoint(l1) // registrations need a reachable record
+
+	topic := crypto.Keccak256([]byte("v5test-topic"))
+	var ticket *v5wire.Ticket
+	switch resp := conn.reqresp(l1, &v5wire.RequestTicket{ReqID: conn.nextReqID(), Topic: topic}).(type) {
+	case *v5wire.Ticket:
+		ticket = resp
+	default:
+		t.Fatal("expected TICKET, got", resp.Name())
+	}
+	wait := time.Duration(ticket.WaitTime) * time.Millisecond
+	if wait > 10*time.Second {
+		t.Fatalf("waiting time %v too long for the test, the topic queue is probably full", wait)
+	}
+	time.Sleep(wait)
+
+	regtopic := &v5wire.Regtopic{ReqID: conn.nextReqID(), Ticket: ticket.Ticket, ENR: conn.localNode.Node().Record()}
+	switch resp := conn.reqresp(l1, regtopic).(type) {
+	case *v5wire.Regconfirmation:
+		if !bytes.Equal(resp.ReqID, regtopic.ReqID) {
+			t.Fatalf("wrong request ID %x in REGCONFIRMATION, want %x", resp.ReqID, regtopic.ReqID)
+		}
+		if !resp.Registered {
+			t.Fatal("registration with valid ticket rejected")
+		}
+	default:
+		t.Fatal("expected REGCONFIRMATION, got", resp.Name())
+	}
+
+	nodes, err := conn.topicQuery(l1, topic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, n := range nodes {
+		if n.ID() == conn.localNode.ID() {
+			return
+		}
+	}
+	t.Errorf("registered node missing in TOPICQUERY result of %d nodes", len(nodes))
+}
+
+// TestRegtopicInvalidTicket sends REGTOPIC with a forged ticket, which should be
+// rejected.
+func (s *Suite) TestRegtopicInvalidTicket(t *utesting.T) {
+	conn, l1 := s.listen1(t)
+	defer conn.close()
+	conn.setEndpoint(l1)
+
+	regtopic := &v5wire.Regtopic{ReqID: conn.nextReqID(), Ticket: make([]byte, 64), ENR: conn.localNode.Node().Record()}
+	switch resp := conn.reqresp(l1, regtopic).(type) {
+	case *v5wire.Regconfirmation:
+		if resp.Registered {
+			t.Fatal("registration with forged ticket accepted")
+		}
+	default:
+		t.Fatal("expected REGCONFIRMATION, got", resp.Name())
+	}
+}
+
 // A bystander is a node whose only purpose is filling a spot in the remote table.
 type bystander struct {
 	dest *enode.Node
@@ -52,6 +52,7 @@ func init() {
 		discv5Command,
 		dnsCommand,
 		nodesetCommand,
+		crawlCommand,
 		rlpxCommand,
 	}
 }
@@ -21,6 +21,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"path/filepath"
 	"sort"
 	"time"
 
@@ -57,17 +58,40 @@ func loadNodesJSON(file string) nodeSet {
 }
 
 func writeNodesJSON(file string, nodes nodeSet) {
+	if err := saveNodesJSON(file, nodes); err != nil {
+		exit(err)
+	}
+}
+
+// saveNodesJSON writes the node set to the given file, or to stdout if the file
+// is "-". The file is replaced atomically, so readers never see a partially
+// written node set.
+func saveNodesJSON(file string, nodes nodeSet) error {
 	nodesJSON, err := json.MarshalIndent(nodes, "", jsonIndent)
 	if err != nil {
-		exit(err)
+		return err
 	}
 	if file == "-" {
-		os.Stdout.Write(nodesJSON)
-		return
+		_, err := os.Stdout.Write(nodesJSON)
+		return err
 	}
-	if err := os.WriteFile(file, nodesJSON, 0644); err != nil {
-		exit(err)
+	tmp, err := os.CreateTemp(filepath.Dir(file), filepath.Base(file)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(nodesJSON); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
 	}
+	return os.Rename(tmp.Name(), file)
 }
 
 // nodes returns the node records contained in the set.
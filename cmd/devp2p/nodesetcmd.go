@@ -26,6 +26,7 @@ import (
 	"time"
 
 	"github.com/ETX/go-ETX/core/forkid"
+	"github.com/ETX/go-ETX/p2p/enode"
 	"github.com/ETX/go-ETX/p2p/enr"
 	"github.com/ETX/go-ETX/params"
 	"github.com/ETX/go-ETX/rlp"
@@ -225,33 +226,45 @@ func minAgeFilter(args []string) (nodeFilter, error) {
 }
 
 func etxFilter(args []string) (nodeFilter, error) {
-	var filter forkid.Filter
-	switch args[0] {
+	filter, err := networkForkFilter(args[0])
+	if err != nil {
+		return nil, err
+	}
+	f := func(n nodeJSON) bool {
+		id, ok := enrForkID(n.N)
+		return ok && filter(id) == nil
+	}
+	return f, nil
+}
+
+// networkForkFilter returns the fork ID filter of a known network.
+func networkForkFilter(network string) (forkid.Filter, error) {
+	switch network {
 	case "mainnet":
-		filter = forkid.NewStaticFilter(params.MainnetChainConfig, params.MainnetGenesisHash)
+		return forkid.NewStaticFilter(params.MainnetChainConfig, params.MainnetGenesisHash), nil
 	case "rinkeby":
-		filter = forkid.NewStaticFilter(params.RinkebyChainConfig, params.RinkebyGenesisHash)
+		return forkid.NewStaticFilter(params.RinkebyChainConfig, params.RinkebyGenesisHash), nil
 	case "goerli":
-		filter = forkid.NewStaticFilter(params.GoerliChainConfig, params.GoerliGenesisHash)
+		return forkid.NewStaticFilter(params.GoerliChainConfig, params.GoerliGenesisHash), nil
 	case "ropsten":
-		filter = forkid.NewStaticFilter(params.RopstenChainConfig, params.RopstenGenesisHash)
+		return forkid.NewStaticFilter(params.RopstenChainConfig, params.RopstenGenesisHash), nil
 	case "sepolia":
-		filter = forkid.NewStaticFilter(params.SepoliaChainConfig, params.SepoliaGenesisHash)
+		return forkid.NewStaticFilter(params.SepoliaChainConfig, params.SepoliaGenesisHash), nil
 	default:
-		return nil, fmt.Errorf("unknown network %q", args[0])
+		return nil, fmt.Errorf("unknown network %q", network)
 	}
+}
 
-	f := func(n nodeJSON) bool {
-		var etx struct {
-			ForkID forkid.ID
-			Tail   []rlp.RawValue `rlp:"tail"`
-		}
-		if n.N.Load(enr.WithEntry("etx", &etx)) != nil {
-			return false
-		}
-		return filter(etx.ForkID) == nil
+// enrForkID returns the fork ID in the etx entry of a node record.
+func enrForkID(n *enode.Node) (forkid.ID, bool) {
+	var etx struct {
+		ForkID forkid.ID
+		Tail   []rlp.RawValue `rlp:"tail"`
 	}
-	return f, nil
+	if n.Load(enr.WithEntry("etx", &etx)) != nil {
+		return forkid.ID{}, false
+	}
+	return etx.ForkID, true
 }
 
 func lesFilter(args []string) (nodeFilter, error) {
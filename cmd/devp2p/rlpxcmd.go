@@ -19,15 +19,20 @@ package main
 import (
 	"fmt"
 	"net"
+	"time"
 
 	"github.com/ETX/go-ETX/cmd/devp2p/internal/etxtest"
 	"github.com/ETX/go-ETX/crypto"
 	"github.com/ETX/go-ETX/p2p"
+	"github.com/ETX/go-ETX/p2p/enode"
 	"github.com/ETX/go-ETX/p2p/rlpx"
 	"github.com/ETX/go-ETX/rlp"
 	"github.com/urfave/cli/v2"
 )
 
+// rlpxTimeout is the time limit for dialing and handshaking with a node.
+const rlpxTimeout = 10 * time.Second
+
 var (
 	rlpxCommand = &cli.Command{
 		Name:  "rlpx",
@@ -66,38 +71,50 @@ var (
 )
 
 func rlpxPing(ctx *cli.Context) error {
-	n := getNodeArg(ctx)
-	fd, err := net.Dial("tcp", fmt.Sprintf("%v:%d", n.IP(), n.TCP()))
+	h, err := rlpxHello(getNodeArg(ctx))
 	if err != nil {
 		return err
 	}
+	fmt.Printf("%+v\n", *h)
+	return nil
+}
+
+// rlpxHello performs the RLPx handshake with a node and returns the protocol
+// handshake it sends.
+func rlpxHello(n *enode.Node) (*etxtest.Hello, error) {
+	fd, err := net.DialTimeout("tcp", fmt.Sprintf("%v:%d", n.IP(), n.TCP()), rlpxTimeout)
+	if err != nil {
+		return nil, err
+	}
+	defer fd.Close()
+
 	conn := rlpx.NewConn(fd, n.Pubkey())
+	conn.SetDeadline(time.Now().Add(rlpxTimeout))
 	ourKey, _ := crypto.GenerateKey()
 	_, err = conn.Handshake(ourKey)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	code, data, _, err := conn.Read()
 	if err != nil {
-		return err
+		return nil, err
 	}
 	switch code {
 	case 0:
 		var h etxtest.Hello
 		if err := rlp.DecodeBytes(data, &h); err != nil {
-			return fmt.Errorf("invalid handshake: %v", err)
+			return nil, fmt.Errorf("invalid handshake: %v", err)
 		}
-		fmt.Printf("%+v\n", h)
+		return &h, nil
 	case 1:
 		var msg []p2p.DiscReason
 		if rlp.DecodeBytes(data, &msg); len(msg) == 0 {
-			return fmt.Errorf("invalid disconnect message")
+			return nil, fmt.Errorf("invalid disconnect message")
 		}
-		return fmt.Errorf("received disconnect message: %v", msg[0])
+		return nil, fmt.Errorf("received disconnect message: %v", msg[0])
 	default:
-		return fmt.Errorf("invalid message code %d, expected handshake (code zero)", code)
+		return nil, fmt.Errorf("invalid message code %d, expected handshake (code zero)", code)
 	}
-	return nil
 }
 
 // rlpxetxTest runs the etx protocol test suite.
@@ -28,6 +28,7 @@ import (
 	"github.com/ETX/go-ETX/core"
 	"github.com/ETX/go-ETX/core/rawdb"
 	"github.com/ETX/go-ETX/core/state"
+	"github.com/ETX/go-ETX/core/tracing"
 	"github.com/ETX/go-ETX/core/types"
 	"github.com/ETX/go-ETX/core/vm"
 	"github.com/ETX/go-ETX/crypto"
@@ -250,9 +251,9 @@ func (pre *Prestate) Apply(vmConfig vm.Config, chainConfig *params.ChainConfig,
 			reward.Sub(reward, new(big.Int).SetUint64(ommer.Delta))
 			reward.Mul(reward, blockReward)
 			reward.Div(reward, big.NewInt(8))
-			statedb.AddBalance(ommer.Address, reward)
+			statedb.AddBalance(ommer.Address, reward, tracing.BalanceIncreaseRewardMineUncle)
 		}
-		statedb.AddBalance(pre.Env.Coinbase, minerReward)
+		statedb.AddBalance(pre.Env.Coinbase, minerReward, tracing.BalanceIncreaseRewardMineBlock)
 	}
 	// Commit block
 	root, err := statedb.Commit(chainConfig.IsEIP158(vmContext.BlockNumber))
@@ -48,11 +48,15 @@ var (
 		Name:      "init",
 		Usage:     "Bootstrap and initialize a new genesis block",
 		ArgsUsage: "<genesisPath>",
-		Flags:     utils.DatabasePathFlags,
+		Flags: flags.Merge([]cli.Flag{
+			utils.StateSchemeFlag,
+			utils.StateHistoryFlag,
+		}, utils.DatabasePathFlags),
 		Description: `
 The init command initializes a new genesis block and definition for the network.
 This is a destructive action and changes the network in which you will be
-participating.
+participating. The --state.scheme flag selects the scheme the state is stored
+in, which can't be changed afterwards without resyncing.
 
 It expects the genesis file as argument.`,
 	}
@@ -75,6 +79,8 @@ if one is set.  Otherwise it prints the genesis from the datadir.`,
 			utils.CacheFlag,
 			utils.SyncModeFlag,
 			utils.GCModeFlag,
+			utils.StateSchemeFlag,
+			utils.StateHistoryFlag,
 			utils.SnapshotFlag,
 			utils.CacheDatabaseFlag,
 			utils.CacheGCFlag,
@@ -116,6 +122,38 @@ Optional second and third arguments control the first and
 last block to write. In this mode, the file will be appended
 if already existing. If the file ends with .gz, the output will
 be gzipped.`,
+	}
+	exportHistoryCommand = &cli.Command{
+		Action:    exportHistory,
+		Name:      "export-history",
+		Usage:     "Export the chain history from the freezer into era1-style archives",
+		ArgsUsage: "<dir> <blockNumFirst> <blockNumLast>",
+		Flags: flags.Merge([]cli.Flag{
+			utils.SyncModeFlag,
+		}, utils.NetworkFlags, utils.DatabasePathFlags),
+		Description: `
+The export-history command writes the headers, bodies, receipts and total
+difficulties of the given frozen block range into era1-style archives, one
+per epoch of 8192 blocks, along with their sha256 checksums in checksums.txt.
+Every archive contains an accumulator root of its blocks and an index for
+random access.`,
+	}
+	importHistoryCommand = &cli.Command{
+		Action:    importHistory,
+		Name:      "import-history",
+		Usage:     "Import the chain history from era1-style archives",
+		ArgsUsage: "<dir>",
+		Flags: flags.Merge([]cli.Flag{
+			utils.CacheFlag,
+			utils.SyncModeFlag,
+			utils.TxLookupLimitFlag,
+		}, utils.NetworkFlags, utils.DatabasePathFlags),
+		Description: `
+The import-history command imports the archives listed in the checksums.txt of
+the given directory, as written by export-history. The archives are verified
+against their checksums and accumulators, the blocks against their headers and
+are written into the ancient store without being executed. To fill in history
+expired by --history.blocks on an existing chain, use "getx db restore-history".`,
 	}
 	importPreimagesCommand = &cli.Command{
 		Action:    importPreimages,
@@ -193,7 +231,8 @@ func initGenesis(ctx *cli.Context) error {
 		if err != nil {
 			utils.Fatalf("Failed to open database: %v", err)
 		}
-		_, hash, err := core.SetupGenesisBlock(chaindb, genesis)
+		triedb := utils.MakeTrieDatabase(ctx, chaindb, true, name == "lightchaindata")
+		_, hash, err := core.SetupGenesisBlockWithOverride(chaindb, triedb, genesis, nil)
 		if err != nil {
 			utils.Fatalf("Failed to write genesis block: %v", err)
 		}
@@ -357,6 +396,61 @@ func exportChain(ctx *cli.Context) error {
 	return nil
 }
 
+// exportHistory exports the frozen chain history into era1-style archives.
+func exportHistory(ctx *cli.Context) error {
+	if ctx.Args().Len() != 3 {
+		utils.Fatalf("Arguments %s are required", ctx.Command.ArgsUsage)
+	}
+	first, ferr := strconv.ParseUint(ctx.Args().Get(1), 10, 64)
+	last, lerr := strconv.ParseUint(ctx.Args().Get(2), 10, 64)
+	if ferr != nil || lerr != nil {
+		utils.Fatalf("Export error in parsing parameters: block number not an integer\n")
+	}
+	stack, _ := makeConfigNode(ctx)
+	defer stack.Close()
+
+	db := utils.MakeChainDatabase(ctx, stack, true)
+	defer db.Close()
+
+	start := time.Now()
+	if err := utils.ExportHistory(db, ctx.Args().First(), networkName(ctx), first, last); err != nil {
+		utils.Fatalf("Export error: %v\n", err)
+	}
+	fmt.Printf("Export done in %v\n", time.Since(start))
+	return nil
+}
+
+// importHistory imports era1-style archives into the ancient store.
+func importHistory(ctx *cli.Context) error {
+	if ctx.Args().Len() != 1 {
+		utils.Fatalf("This command requires an argument.")
+	}
+	stack, _ := makeConfigNode(ctx)
+	defer stack.Close()
+
+	chain, db := utils.MakeChain(ctx, stack, false)
+	defer db.Close()
+	defer chain.Stop()
+
+	start := time.Now()
+	if err := utils.ImportHistory(chain, ctx.Args().First()); err != nil {
+		utils.Fatalf("Import error: %v\n", err)
+	}
+	fmt.Printf("Import done in %v\n", time.Since(start))
+	return nil
+}
+
+// networkName returns the name of the network selected by the flags, used to
+// name the history archives.
+func networkName(ctx *cli.Context) string {
+	for _, flag := range utils.TestnetFlags {
+		if name := flag.Names()[0]; ctx.Bool(name) {
+			return name
+		}
+	}
+	return "mainnet"
+}
+
 // importPreimages imports preimage data from the specified file.
 func importPreimages(ctx *cli.Context) error {
 	if ctx.Args().Len() < 1 {
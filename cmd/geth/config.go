@@ -119,8 +119,9 @@ func defaultNodeConfig() node.Config {
 	return cfg
 }
 
-// makeConfigNode loads getx configuration and creates a blank node instance.
-func makeConfigNode(ctx *cli.Context) (*node.Node, getxConfig) {
+// loadBaseConfig loads the getx configuration from the config file and applies
+// the node flags, without creating a node instance.
+func loadBaseConfig(ctx *cli.Context) getxConfig {
 	// Load defaults.
 	cfg := getxConfig{
 		etx:     etxconfig.Defaults,
@@ -137,6 +138,12 @@ func makeConfigNode(ctx *cli.Context) (*node.Node, getxConfig) {
 
 	// Apply flags.
 	utils.SetNodeConfig(ctx, &cfg.Node)
+	return cfg
+}
+
+// makeConfigNode loads getx configuration and creates a blank node instance.
+func makeConfigNode(ctx *cli.Context) (*node.Node, getxConfig) {
+	cfg := loadBaseConfig(ctx)
 	stack, err := node.New(&cfg.Node)
 	if err != nil {
 		utils.Fatalf("Failed to create the protocol stack: %v", err)
@@ -18,7 +18,10 @@ package main
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
+	"net"
+	"net/http"
 	"os"
 	"os/signal"
 	"path/filepath"
@@ -36,13 +39,32 @@ import (
 	"github.com/ETX/go-ETX/core/types"
 	"github.com/ETX/go-ETX/crypto"
 	"github.com/ETX/go-ETX/etxdb"
+	"github.com/ETX/go-ETX/etxdb/remotedb"
 	"github.com/ETX/go-ETX/internal/flags"
 	"github.com/ETX/go-ETX/log"
+	"github.com/ETX/go-ETX/node"
+	"github.com/ETX/go-ETX/rpc"
 	"github.com/ETX/go-ETX/trie"
 	"github.com/olekukonko/tablewriter"
 	"github.com/urfave/cli/v2"
 )
 
+var (
+	dbServeIPCFlag = &cli.StringFlag{
+		Name:  "serve.ipc",
+		Usage: "Filename for the IPC socket of the database server, relative to the datadir if not absolute (empty = disabled)",
+		Value: "getxdb.ipc",
+	}
+	dbServeHTTPFlag = &cli.StringFlag{
+		Name:  "serve.http",
+		Usage: "Listening address of the HTTP database server, e.g. 127.0.0.1:8550 (empty = disabled)",
+	}
+	dbFreezerRepairFlag = &cli.BoolFlag{
+		Name:  "repair",
+		Usage: "Truncate the freezer at the first corrupt item and rewind the chain, so the data is downloaded again",
+	}
+)
+
 var (
 	removedbCommand = &cli.Command{
 		Action:    removeDB,
@@ -66,8 +88,13 @@ Remove blockchain and state databases`,
 			dbPutCmd,
 			dbGetSlotsCmd,
 			dbDumpFreezerIndex,
+			dbVerifyFreezerCmd,
+			dbBackupCmd,
+			dbRestoreCmd,
 			dbImportCmd,
 			dbExportCmd,
+			dbRestoreHistoryCmd,
+			dbServeCmd,
 			dbMetadataCmd,
 			dbMigrateFreezerCmd,
 			dbCheckStateContentCmd,
@@ -166,6 +193,50 @@ WARNING: This is a low-level operation which may cause database corruption!`,
 		}, utils.NetworkFlags, utils.DatabasePathFlags),
 		Description: "This command displays information about the freezer index.",
 	}
+	dbVerifyFreezerCmd = &cli.Command{
+		Action: freezerVerify,
+		Name:   "freezer-verify",
+		Usage:  "Verify the integrity of the chain freezer",
+		Flags: flags.Merge([]cli.Flag{
+			utils.SyncModeFlag,
+			dbFreezerRepairFlag,
+		}, utils.NetworkFlags, utils.DatabasePathFlags),
+		Description: `This command walks every table of the chain freezer, checking the stored items
+against their checksums and validating their snappy framing and RLP encoding. The headers are
+checked against the canonical hashes and their parents, the bodies and receipts against the
+roots of their headers. The ranges of corrupt items are reported.
+
+With --repair, the chain is rewound below the first corrupt item in the same way as by
+debug_setHead, truncating the freezer, so the missing part of the chain is downloaded again on
+the next sync. Items written before the checksums were introduced are verified by their content
+only.`,
+	}
+	dbBackupCmd = &cli.Command{
+		Action:    backupDatabase,
+		Name:      "backup",
+		Usage:     "Write an incremental backup of the chain database",
+		ArgsUsage: "<dir>",
+		Flags: flags.Merge([]cli.Flag{
+			utils.SyncModeFlag,
+		}, utils.NetworkFlags, utils.DatabasePathFlags),
+		Description: `This command writes a consistent backup of the chain database, both the key-value
+store and the chain freezer, into the backup repository at the given directory. The data is
+stored in content addressed files shared by all the backups of a repository, so every backup
+only writes the data changed since the previous one.
+
+The database is opened read-only. To back up a running node, use admin.backup instead.`,
+	}
+	dbRestoreCmd = &cli.Command{
+		Action:    restoreDatabase,
+		Name:      "restore",
+		Usage:     "Restore a backup of the chain database",
+		ArgsUsage: "<dir> [<backup>]",
+		Flags: flags.Merge([]cli.Flag{
+			utils.SyncModeFlag,
+		}, utils.NetworkFlags, utils.DatabasePathFlags),
+		Description: `This command restores a backup from the backup repository at the given directory
+into an empty chain database. Without a backup name, the most recent backup is restored.`,
+	}
 	dbImportCmd = &cli.Command{
 		Action:    importLDBdata,
 		Name:      "import",
@@ -186,6 +257,39 @@ WARNING: This is a low-level operation which may cause database corruption!`,
 		}, utils.NetworkFlags, utils.DatabasePathFlags),
 		Description: "Exports the specified chain data to an RLP encoded stream, optionally gzip-compressed.",
 	}
+	dbRestoreHistoryCmd = &cli.Command{
+		Action:    restoreHistory,
+		Name:      "restore-history",
+		Usage:     "Restores expired block bodies and receipts from an era1-style archive",
+		ArgsUsage: "<archive>",
+		Flags: flags.Merge([]cli.Flag{
+			utils.SyncModeFlag,
+		}, utils.NetworkFlags, utils.DatabasePathFlags),
+		Description: `The restore-history command fills in the chain history expired by --history.blocks
+from a single era1-style archive, as produced by "getx export-history". The blocks must
+already be part of the local canonical chain, their bodies and receipts are verified against
+the stored headers before being written. No blocks are added to the chain, use
+"getx import-history" to import the history of a chain from a directory of archives.`,
+	}
+	dbServeCmd = &cli.Command{
+		Action: serveDB,
+		Name:   "serve",
+		Usage:  "Serve the database read-only over IPC and HTTP",
+		Flags: flags.Merge([]cli.Flag{
+			utils.SyncModeFlag,
+			dbServeIPCFlag,
+			dbServeHTTPFlag,
+		}, utils.NetworkFlags, utils.DatabasePathFlags),
+		Description: `This command opens the database of a datadir read-only, without starting a
+node, and exposes it to remote clients over RPC. Besides the debug_dbGet, debug_dbAncient and
+debug_dbAncients metxods of a running node, the server also provides batched range iteration
+(debug_dbIterate) and ancient range reads (debug_dbAncientRange). Clients can connect with
+"getx db --remotedb <endpoint>" or through the remotedb package.
+
+The node's instance lock is not taken, but the key-value store and the freezer still lock
+their directories, so the database of a running node can't be served. A running node provides
+the same metxods in its debug namespace, connect to its endpoint instead.`,
+	}
 	dbMetadataCmd = &cli.Command{
 		Action: showMetaData,
 		Name:   "metadata",
@@ -203,7 +307,8 @@ WARNING: This is a low-level operation which may cause database corruption!`,
 		Flags: flags.Merge([]cli.Flag{
 			utils.SyncModeFlag,
 		}, utils.NetworkFlags, utils.DatabasePathFlags),
-		Description: `The freezer-migrate command checks your database for receipts in a legacy format and updates those.
+		Description: `The freezer-migrate command moves the freezer tables into the directories configured by
+--datadir.ancient.tables, then checks your database for receipts in a legacy format and updates those.
 WARNING: please back-up the receipt files in your ancients before running this command.`,
 	}
 )
@@ -707,6 +812,187 @@ func exportChaindata(ctx *cli.Context) error {
 	return utils.ExportChaindata(ctx.Args().Get(1), kind, exporter(db), stop)
 }
 
+func freezerVerify(ctx *cli.Context) error {
+	stack, _ := makeConfigNode(ctx)
+	defer stack.Close()
+
+	corrupt, tail, err := verifyFreezer(ctx, stack)
+	if err != nil {
+		return err
+	}
+	if len(corrupt) == 0 {
+		log.Info("Chain freezer verified, no corruption found")
+		return nil
+	}
+	for _, r := range corrupt {
+		log.Error("Corrupt freezer range", "first", r.First, "last", r.Last, "count", r.Last-r.First+1, "err", r.Err)
+	}
+	if !ctx.Bool(dbFreezerRepairFlag.Name) {
+		return fmt.Errorf("found %d corrupt freezer ranges", len(corrupt))
+	}
+	// Rewind the chain below the first corruption, discarding everything above
+	// it. The chain is repaired by downloading the discarded part again.
+	first := corrupt[0].First
+	if first == 0 {
+		return errors.New("genesis corrupt, the chain needs to be synced from scratch")
+	}
+	if first < tail {
+		return fmt.Errorf("corruption below the history tail #%d, the chain needs to be synced from scratch", tail)
+	}
+	chain, db := utils.MakeChain(ctx, stack, false)
+	defer db.Close()
+	defer chain.Stop()
+
+	if err := chain.Setxead(first - 1); err != nil {
+		return err
+	}
+	log.Info("Rewound chain below the corruption", "number", first-1, "hash", chain.CurrentHeader().Hash())
+	return nil
+}
+
+// verifyFreezer checks the chain freezer of the node, returning the corrupt
+// ranges and the history tail. The database is opened read-only and closed
+// again, so that the chain can be opened for repairs afterwards.
+func verifyFreezer(ctx *cli.Context, stack *node.Node) ([]rawdb.CorruptRange, uint64, error) {
+	db := utils.MakeChainDatabase(ctx, stack, true)
+	defer db.Close()
+
+	ancient, err := db.AncientDatadir()
+	if err != nil {
+		log.Info("Failed to retrieve ancient root", "err", err)
+		return nil, 0, err
+	}
+	tail, err := db.Tail()
+	if err != nil {
+		return nil, 0, err
+	}
+	corrupt, err := rawdb.VerifyChainFreezer(ancient, utils.VerifyHistory)
+	return corrupt, tail, err
+}
+
+func backupDatabase(ctx *cli.Context) error {
+	if ctx.NArg() != 1 {
+		return fmt.Errorf("required arguments: %v", ctx.Command.ArgsUsage)
+	}
+	stack, _ := makeConfigNode(ctx)
+	defer stack.Close()
+
+	db := utils.MakeChainDatabase(ctx, stack, true)
+	defer db.Close()
+
+	_, err := rawdb.Backup(db, ctx.Args().First(), nil)
+	return err
+}
+
+func restoreDatabase(ctx *cli.Context) error {
+	if ctx.NArg() < 1 || ctx.NArg() > 2 {
+		return fmt.Errorf("required arguments: %v", ctx.Command.ArgsUsage)
+	}
+	stack, _ := makeConfigNode(ctx)
+	defer stack.Close()
+
+	db := utils.MakeChainDatabase(ctx, stack, false)
+	defer db.Close()
+
+	return rawdb.RestoreBackup(db, ctx.Args().Get(0), ctx.Args().Get(1))
+}
+
+func restoreHistory(ctx *cli.Context) error {
+	if ctx.NArg() != 1 {
+		return fmt.Errorf("required arguments: %v", ctx.Command.ArgsUsage)
+	}
+	var (
+		stack, _  = makeConfigNode(ctx)
+		interrupt = make(chan os.Signal, 1)
+		stop      = make(chan struct{})
+	)
+	defer stack.Close()
+	signal.Notify(interrupt, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(interrupt)
+	defer close(interrupt)
+	go func() {
+		if _, ok := <-interrupt; ok {
+			log.Info("Interrupted during history import, stopping")
+		}
+		close(stop)
+	}()
+	db := utils.MakeChainDatabase(ctx, stack, false)
+	defer db.Close()
+
+	return utils.RestoreHistory(db, ctx.Args().Get(0), stop)
+}
+
+func serveDB(ctx *cli.Context) error {
+	// Open the database directly instead of creating a node, which would take
+	// the instance lock of the datadir.
+	cfg := loadBaseConfig(ctx).Node
+	if cfg.DataDir == "" {
+		return errors.New("no datadir to serve")
+	}
+	options := rawdb.OpenOptions{
+		Type:              cfg.DBEngine,
+		Directory:         cfg.ResolvePath("chaindata"),
+		AncientsDirectory: cfg.ResolveAncient("chaindata", ctx.String(utils.AncientFlag.Name)),
+		AncientTables:     cfg.ResolveAncientTables(),
+		Cache:             ctx.Int(utils.CacheFlag.Name) * ctx.Int(utils.CacheDatabaseFlag.Name) / 100,
+		Handles:           utils.MakeDatabaseHandles(ctx.Int(utils.FDLimitFlag.Name)),
+		ReadOnly:          true,
+	}
+	if ctx.String(utils.SyncModeFlag.Name) == "light" {
+		options.Directory, options.AncientsDirectory, options.AncientTables = cfg.ResolvePath("lightchaindata"), "", nil
+	}
+	db, err := rawdb.Open(options)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	var (
+		api       = remotedb.NewAPI(db)
+		endpoints int
+	)
+	if path := ctx.String(dbServeIPCFlag.Name); path != "" {
+		endpoint := (&node.Config{DataDir: cfg.DataDir, IPCPath: path}).IPCEndpoint()
+		listener, server, err := rpc.StartIPCEndpoint(endpoint, []rpc.API{{Namespace: "debug", Service: api}})
+		if err != nil {
+			return err
+		}
+		defer server.Stop()
+		defer listener.Close()
+
+		log.Info("Database IPC endpoint opened", "url", endpoint)
+		endpoints++
+	}
+	if addr := ctx.String(dbServeHTTPFlag.Name); addr != "" {
+		server := rpc.NewServer()
+		if err := server.RegisterName("debug", api); err != nil {
+			return err
+		}
+		defer server.Stop()
+
+		listener, err := net.Listen("tcp", addr)
+		if err != nil {
+			return err
+		}
+		httpServer := &http.Server{Handler: server}
+		go httpServer.Serve(listener)
+		defer httpServer.Close()
+
+		log.Info("Database HTTP endpoint opened", "url", "http://"+listener.Addr().String())
+		endpoints++
+	}
+	if endpoints == 0 {
+		return fmt.Errorf("no endpoint enabled, set --%s or --%s", dbServeIPCFlag.Name, dbServeHTTPFlag.Name)
+	}
+	sigc := make(chan os.Signal, 1)
+	signal.Notify(sigc, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sigc)
+
+	<-sigc
+	log.Info("Shutting down database server")
+	return nil
+}
+
 func showMetaData(ctx *cli.Context) error {
 	stack, _ := makeConfigNode(ctx)
 	defer stack.Close()
@@ -761,6 +1047,14 @@ func freezerMigrate(ctx *cli.Context) error {
 	stack, _ := makeConfigNode(ctx)
 	defer stack.Close()
 
+	// Move the freezer tables into their configured directories first, the
+	// database can't be opened while they are misplaced.
+	if dirs := stack.ResolveAncientTables(); len(dirs) > 0 {
+		ancient := stack.ResolveAncient("chaindata", ctx.String(utils.AncientFlag.Name))
+		if err := rawdb.MigrateFreezerTables(ancient, dirs); err != nil {
+			return err
+		}
+	}
 	db := utils.MakeChainDatabase(ctx, stack, false)
 	defer db.Close()
 
@@ -85,12 +85,20 @@ var (
 		utils.TxPoolAccountQueueFlag,
 		utils.TxPoolGlobalQueueFlag,
 		utils.TxPoolLifetimeFlag,
+		utils.TxPoolSnapshotFlag,
+		utils.TxPoolSnapshotIntervalFlag,
+		utils.TxPoolPolicyFlag,
 		utils.SyncModeFlag,
 		utils.SyncTargetFlag,
 		utils.ExitWhenSyncedFlag,
 		utils.GCModeFlag,
+		utils.StateSchemeFlag,
+		utils.StateHistoryFlag,
+		utils.StatePruneFlag,
+		utils.StatePruneIntervalFlag,
 		utils.SnapshotFlag,
 		utils.TxLookupLimitFlag,
+		utils.HistoryBlocksFlag,
 		utils.LightServeFlag,
 		utils.LightIngressFlag,
 		utils.LightEgressFlag,
@@ -119,6 +127,10 @@ var (
 		utils.DiscoveryPortFlag,
 		utils.MaxPeersFlag,
 		utils.MaxPendingPeersFlag,
+		utils.MaxInboundPeersFlag,
+		utils.MaxOutboundPeersFlag,
+		utils.MaxSubnetPeersFlag,
+		utils.ReservedPeersFlag,
 		utils.MiningEnabledFlag,
 		utils.MinerThreadsFlag,
 		utils.MinerNotifyFlag,
@@ -132,6 +144,8 @@ var (
 		utils.NATFlag,
 		utils.NoDiscoverFlag,
 		utils.DiscoveryV5Flag,
+		utils.DiscoveryV5TopicsFlag,
+		utils.MuxTransportFlag,
 		utils.NetrestrictFlag,
 		utils.NodeKeyFileFlag,
 		utils.NodeKeyHexFlag,
@@ -140,6 +154,11 @@ var (
 		utils.DeveloperPeriodFlag,
 		utils.DeveloperGasLimitFlag,
 		utils.VMEnableDebugFlag,
+		utils.TraceIndexFlag,
+		utils.TraceIndexConfigFlag,
+		utils.LiveTracerFlag,
+		utils.LiveTracerConfigFlag,
+		utils.LiveTraceSinkFlag,
 		utils.NetworkIdFlag,
 		utils.etxStatsURLFlag,
 		utils.FakePoWFlag,
@@ -213,6 +232,8 @@ func init() {
 		initCommand,
 		importCommand,
 		exportCommand,
+		importHistoryCommand,
+		exportHistoryCommand,
 		importPreimagesCommand,
 		exportPreimagesCommand,
 		removedbCommand,
@@ -17,14 +17,18 @@
 package main
 
 import (
+	"bufio"
 	"bytes"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"os"
+	"strconv"
 	"time"
 
 	"github.com/ETX/go-ETX/cmd/utils"
 	"github.com/ETX/go-ETX/common"
+	"github.com/ETX/go-ETX/core"
 	"github.com/ETX/go-ETX/core/rawdb"
 	"github.com/ETX/go-ETX/core/state"
 	"github.com/ETX/go-ETX/core/state/pruner"
@@ -159,6 +163,41 @@ as the backend data source, making this command a lot faster.
 
 The argument is interpreted as block number or hash. If none is provided, the latest
 block is used.
+`,
+			},
+			{
+				Name:      "export",
+				Usage:     "Export the state of a specific block into a binary file",
+				ArgsUsage: "<file> [<blockHash> | <blockNum>]",
+				Action:    exportSnapshot,
+				Flags:     flags.Merge(utils.NetworkFlags, utils.DatabasePathFlags),
+				Description: `
+getx snapshot export <file> [<blockHash> | <blockNum>]
+will export the state of the given block, using the snapshot as the data source.
+The accounts, storage slots and contract codes are written in a compact binary
+format alongside the block and its total difficulty. If no block is provided, the
+latest block is used.
+
+The export can be imported into another node with 'getx snapshot import'.
+`,
+			},
+			{
+				Name:      "import",
+				Usage:     "Import the state of a block from a binary file",
+				ArgsUsage: "<file>",
+				Action:    importSnapshot,
+				Flags:     flags.Merge([]cli.Flag{utils.StateSchemeFlag}, utils.NetworkFlags, utils.DatabasePathFlags),
+				Description: `
+getx snapshot import <file>
+will import a state exported by 'getx snapshot export'. The state tries are
+regenerated from the exported data and verified against the exported root. The
+snapshot, the contract codes and the exported block are stored as well, and the
+block becomes the head of the chain, so the node doesn't need to sync the state
+of the block.
+
+The database is initialized with the genesis block of the selected network if
+needed. It must not hold a state snapshot yet. The state tries are written with
+the hash-based state scheme, importing into a path-based database is not supported.
 `,
 			},
 		},
@@ -595,3 +634,107 @@ func checkAccount(ctx *cli.Context) error {
 	log.Info("Checked the snapshot journalled storage", "time", common.PrettyDuration(time.Since(start)))
 	return nil
 }
+
+// exportSnapshot exports the state of a block into a binary file.
+func exportSnapshot(ctx *cli.Context) error {
+	if ctx.NArg() < 1 || ctx.NArg() > 2 {
+		log.Error("Wrong number of arguments", "args", ctx.Command.ArgsUsage)
+		return errors.New("wrong number of arguments")
+	}
+	stack, _ := makeConfigNode(ctx)
+	defer stack.Close()
+
+	db := utils.MakeChainDatabase(ctx, stack, true)
+	defer db.Close()
+
+	var header *types.Header
+	if ctx.NArg() == 2 {
+		arg := ctx.Args().Get(1)
+		if hashish(arg) {
+			hash := common.HexToHash(arg)
+			if number := rawdb.ReadHeaderNumber(db, hash); number != nil {
+				header = rawdb.ReadHeader(db, hash, *number)
+			}
+		} else {
+			number, err := strconv.ParseUint(arg, 10, 64)
+			if err != nil {
+				return err
+			}
+			header = rawdb.ReadHeader(db, rawdb.ReadCanonicalHash(db, number), number)
+		}
+	} else {
+		header = rawdb.ReadHeadHeader(db)
+	}
+	if header == nil {
+		return errors.New("block not found")
+	}
+	snapConfig := snapshot.Config{
+		CacheSize:  256,
+		Recovery:   false,
+		NoBuild:    true,
+		AsyncBuild: false,
+	}
+	snaptree, err := snapshot.New(snapConfig, db, trie.NewDatabase(db), header.Root)
+	if err != nil {
+		return err
+	}
+	var (
+		hash   = header.Hash()
+		number = header.Number.Uint64()
+		block  = rawdb.ReadBlock(db, hash, number)
+		td     = rawdb.ReadTd(db, hash, number)
+	)
+	if block == nil || td == nil {
+		return errors.New("block body or total difficulty not found")
+	}
+	out, err := os.OpenFile(ctx.Args().First(), os.O_CREATE|os.O_WRONLY|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	if err := snapshot.Export(out, snaptree, block, td, db); err != nil {
+		return err
+	}
+	return out.Sync()
+}
+
+// importSnapshot imports the state of a block from a binary file.
+func importSnapshot(ctx *cli.Context) error {
+	if ctx.NArg() != 1 {
+		log.Error("Wrong number of arguments", "args", ctx.Command.ArgsUsage)
+		return errors.New("wrong number of arguments")
+	}
+	stack, _ := makeConfigNode(ctx)
+	defer stack.Close()
+
+	db := utils.MakeChainDatabase(ctx, stack, false)
+	defer db.Close()
+
+	// The state tries are regenerated with hash-keyed nodes, the path-based
+	// scheme is not supported.
+	scheme, err := rawdb.ParseStateScheme(ctx.String(utils.StateSchemeFlag.Name), db)
+	if err != nil {
+		return err
+	}
+	if scheme != rawdb.HashScheme {
+		return fmt.Errorf("state import is not supported with the %s state scheme", scheme)
+	}
+	in, err := os.Open(ctx.Args().First())
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	// The imported block becomes the chain head, make sure the genesis is
+	// committed first so it doesn't overwrite the head markers later.
+	if _, _, err := core.SetupGenesisBlock(db, utils.MakeGenesis(ctx)); err != nil {
+		return err
+	}
+	block, err := snapshot.Import(bufio.NewReader(in), db)
+	if err != nil {
+		return err
+	}
+	log.Info("State of block imported", "number", block.Number(), "hash", block.Hash(), "root", block.Root())
+	return nil
+}
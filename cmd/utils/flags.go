@@ -73,6 +73,7 @@ import (
 	"github.com/ETX/go-ETX/params"
 	"github.com/ETX/go-ETX/rlp"
 	"github.com/ETX/go-ETX/rpc"
+	"github.com/ETX/go-ETX/trie"
 	pcsclite "github.com/gballet/go-libpcsclite"
 	gopsutil "github.com/shirou/gopsutil/mem"
 	"github.com/urfave/cli/v2"
@@ -103,6 +104,16 @@ var (
 		Usage:    "Root directory for ancient data (default = inside chaindata)",
 		Category: flags.etxCategory,
 	}
+	AncientTablesFlag = &cli.StringFlag{
+		Name:     "datadir.ancient.tables",
+		Usage:    "Comma separated table=directory pairs placing chain freezer tables (headers, hashes, bodies, receipts, diffs) outside of the ancient directory",
+		Category: flags.etxCategory,
+	}
+	DBEngineFlag = &cli.StringFlag{
+		Name:     "db.engine",
+		Usage:    "Backing database implementation to use ('leveldb' or 'pebble', default = existing database or leveldb)",
+		Category: flags.etxCategory,
+	}
 	MinFreeDiskSpaceFlag = &flags.DirectoryFlag{
 		Name:     "datadir.minfreedisk",
 		Usage:    "Minimum free disk space in MB, once reached triggers auto shut down (default = --cache.gc converted to MB, 0 = disabled)",
@@ -238,6 +249,28 @@ var (
 		Value:    "full",
 		Category: flags.etxCategory,
 	}
+	StateSchemeFlag = &cli.StringFlag{
+		Name:     "state.scheme",
+		Usage:    "Scheme to use for storing ETX state ('hash' or 'path', default = existing state or hash). The path-based scheme only serves the states of the last 128 blocks, older blocks can't be traced",
+		Category: flags.etxCategory,
+	}
+	StateHistoryFlag = &cli.Uint64Flag{
+		Name:     "state.history",
+		Usage:    "Number of recent persisted states to keep reverse diffs for in path-based scheme (0 = entire chain)",
+		Value:    etxconfig.Defaults.StateHistory,
+		Category: flags.etxCategory,
+	}
+	StatePruneFlag = &cli.BoolFlag{
+		Name:     "state.prune",
+		Usage:    "Prune the stale state in the background while the node is running (hash-based scheme only)",
+		Category: flags.etxCategory,
+	}
+	StatePruneIntervalFlag = &cli.DurationFlag{
+		Name:     "state.prune.interval",
+		Usage:    "Time to wait between two background state pruning cycles",
+		Value:    etxconfig.Defaults.StatePruneInterval,
+		Category: flags.etxCategory,
+	}
 	SnapshotFlag = &cli.BoolFlag{
 		Name:     "snapshot",
 		Usage:    `Enables snapshot-database mode (default = enable)`,
@@ -250,6 +283,12 @@ var (
 		Value:    etxconfig.Defaults.TxLookupLimit,
 		Category: flags.etxCategory,
 	}
+	HistoryBlocksFlag = &cli.Uint64Flag{
+		Name:     "history.blocks",
+		Usage:    "Number of recent blocks to retain the bodies and receipts for (0 = entire chain)",
+		Value:    etxconfig.Defaults.HistoryBlocks,
+		Category: flags.etxCategory,
+	}
 	LightKDFFlag = &cli.BoolFlag{
 		Name:     "lightkdf",
 		Usage:    "Reduce key-derivation RAM & CPU usage at some expense of KDF strength",
@@ -446,6 +485,22 @@ var (
 		Value:    etxconfig.Defaults.TxPool.Lifetime,
 		Category: flags.TxPoolCategory,
 	}
+	TxPoolSnapshotFlag = &cli.StringFlag{
+		Name:     "txpool.snapshot",
+		Usage:    "Disk snapshot of all pooled transactions (local and remote) to survive node restarts",
+		Category: flags.TxPoolCategory,
+	}
+	TxPoolSnapshotIntervalFlag = &cli.DurationFlag{
+		Name:     "txpool.snapshotinterval",
+		Usage:    "Time interval to regenerate the transaction pool snapshot",
+		Value:    txpool.DefaultConfig.SnapshotInterval,
+		Category: flags.TxPoolCategory,
+	}
+	TxPoolPolicyFlag = &cli.StringFlag{
+		Name:     "txpool.policy",
+		Usage:    "JSON file with the transaction admission rules (sender/recipient lists, gas cap, rate limits, selector filters)",
+		Category: flags.TxPoolCategory,
+	}
 
 	// Performance tuning settings
 	CacheFlag = &cli.IntFlag{
@@ -606,6 +661,31 @@ var (
 		Usage:    "Record information useful for VM and contract debugging",
 		Category: flags.VMCategory,
 	}
+	TraceIndexFlag = &cli.StringFlag{
+		Name:     "trace.index",
+		Usage:    "Name of the tracer whose output to persist for every canonical transaction (e.g. callTracer)",
+		Category: flags.VMCategory,
+	}
+	TraceIndexConfigFlag = &cli.StringFlag{
+		Name:     "trace.index.config",
+		Usage:    "JSON config of the tracer used by the trace index",
+		Category: flags.VMCategory,
+	}
+	LiveTracerFlag = &cli.StringFlag{
+		Name:     "trace.live",
+		Usage:    "Name of the tracer to run on every transaction during block import (e.g. callTracer)",
+		Category: flags.VMCategory,
+	}
+	LiveTracerConfigFlag = &cli.StringFlag{
+		Name:     "trace.live.config",
+		Usage:    "JSON config of the live tracer",
+		Category: flags.VMCategory,
+	}
+	LiveTraceSinkFlag = &cli.StringFlag{
+		Name:     "trace.live.sink",
+		Usage:    "Destination of the live traces (file:<path> or unix:<socket>)",
+		Category: flags.VMCategory,
+	}
 
 	// API options.
 	RPCGlobalGasCapFlag = &cli.Uint64Flag{
@@ -814,6 +894,26 @@ var (
 		Value:    node.DefaultConfig.P2P.MaxPendingPeers,
 		Category: flags.NetworkingCategory,
 	}
+	MaxInboundPeersFlag = &cli.IntFlag{
+		Name:     "maxpeers.inbound",
+		Usage:    "Maximum number of inbound peers (derived from maxpeers if set to 0)",
+		Category: flags.NetworkingCategory,
+	}
+	MaxOutboundPeersFlag = &cli.IntFlag{
+		Name:     "maxpeers.outbound",
+		Usage:    "Maximum number of dialed peers (derived from maxpeers if set to 0)",
+		Category: flags.NetworkingCategory,
+	}
+	MaxSubnetPeersFlag = &cli.IntFlag{
+		Name:     "maxpeers.subnet",
+		Usage:    "Maximum number of peers per /24 (IPv4) or /64 (IPv6) subnet (unlimited if set to 0)",
+		Category: flags.NetworkingCategory,
+	}
+	ReservedPeersFlag = &cli.StringFlag{
+		Name:     "maxpeers.reserved",
+		Usage:    "Comma separated peer slots reserved per protocol (e.g. snap=10)",
+		Category: flags.NetworkingCategory,
+	}
 	ListenPortFlag = &cli.IntFlag{
 		Name:     "port",
 		Usage:    "Network listening port",
@@ -852,6 +952,16 @@ var (
 		Usage:    "Enables the experimental RLPx V5 (Topic Discovery) mechanism",
 		Category: flags.NetworkingCategory,
 	}
+	DiscoveryV5TopicsFlag = &cli.StringFlag{
+		Name:     "v5disc.topics",
+		Usage:    "Comma separated discovery v5 topics to advertise the node under and to find peers by",
+		Category: flags.NetworkingCategory,
+	}
+	MuxTransportFlag = &cli.BoolFlag{
+		Name:     "mux",
+		Usage:    "Enables the experimental multiplexing RLPx transport with peers supporting it",
+		Category: flags.NetworkingCategory,
+	}
 	NetrestrictFlag = &cli.StringFlag{
 		Name:     "netrestrict",
 		Usage:    "Restricts network communication to the given IP networks (CIDR masks)",
@@ -1021,8 +1131,10 @@ var (
 	DatabasePathFlags = []cli.Flag{
 		DataDirFlag,
 		AncientFlag,
+		AncientTablesFlag,
 		RemoteDBFlag,
 		HttpHeaderFlag,
+		DBEngineFlag,
 	}
 )
 
@@ -1445,6 +1557,26 @@ func SetP2PConfig(ctx *cli.Context, cfg *p2p.Config) {
 	if ctx.IsSet(MaxPendingPeersFlag.Name) {
 		cfg.MaxPendingPeers = ctx.Int(MaxPendingPeersFlag.Name)
 	}
+	if ctx.IsSet(MaxInboundPeersFlag.Name) {
+		cfg.MaxInboundPeers = ctx.Int(MaxInboundPeersFlag.Name)
+	}
+	if ctx.IsSet(MaxOutboundPeersFlag.Name) {
+		cfg.MaxOutboundPeers = ctx.Int(MaxOutboundPeersFlag.Name)
+	}
+	if ctx.IsSet(MaxSubnetPeersFlag.Name) {
+		cfg.MaxPeersPerSubnet = ctx.Int(MaxSubnetPeersFlag.Name)
+	}
+	if ctx.IsSet(ReservedPeersFlag.Name) {
+		cfg.ReservedSlots = make(map[string]int)
+		for _, pair := range SplitAndTrim(ctx.String(ReservedPeersFlag.Name)) {
+			proto, slots, ok := strings.Cut(pair, "=")
+			n, err := strconv.Atoi(slots)
+			if !ok || proto == "" || err != nil || n < 0 {
+				Fatalf("Invalid reserved peer slots %q, want protocol=slots", pair)
+			}
+			cfg.ReservedSlots[proto] = n
+		}
+	}
 	if ctx.IsSet(NoDiscoverFlag.Name) || lightClient {
 		cfg.NoDiscovery = true
 	}
@@ -1458,6 +1590,12 @@ func SetP2PConfig(ctx *cli.Context, cfg *p2p.Config) {
 	} else if forceV5Discovery {
 		cfg.DiscoveryV5 = true
 	}
+	if ctx.IsSet(DiscoveryV5TopicsFlag.Name) {
+		cfg.DiscoveryV5Topics = SplitAndTrim(ctx.String(DiscoveryV5TopicsFlag.Name))
+	}
+	if ctx.IsSet(MuxTransportFlag.Name) {
+		cfg.MuxTransport = ctx.Bool(MuxTransportFlag.Name)
+	}
 
 	if netrestrict := ctx.String(NetrestrictFlag.Name); netrestrict != "" {
 		list, err := netutil.ParseNetlist(netrestrict)
@@ -1491,6 +1629,24 @@ func SetNodeConfig(ctx *cli.Context, cfg *node.Config) {
 	if ctx.IsSet(JWTSecretFlag.Name) {
 		cfg.JWTSecret = ctx.String(JWTSecretFlag.Name)
 	}
+	if ctx.IsSet(DBEngineFlag.Name) {
+		dbEngine := ctx.String(DBEngineFlag.Name)
+		if dbEngine != "leveldb" && dbEngine != "pebble" {
+			Fatalf("Invalid choice for db.engine '%s', allowed 'leveldb' or 'pebble'", dbEngine)
+		}
+		log.Info(fmt.Sprintf("Using %s as db engine", dbEngine))
+		cfg.DBEngine = dbEngine
+	}
+	if ctx.IsSet(AncientTablesFlag.Name) {
+		cfg.AncientTables = make(map[string]string)
+		for _, pair := range SplitAndTrim(ctx.String(AncientTablesFlag.Name)) {
+			table, dir, ok := strings.Cut(pair, "=")
+			if !ok || table == "" || dir == "" {
+				Fatalf("Invalid ancient table directory %q, want table=directory", pair)
+			}
+			cfg.AncientTables[table] = dir
+		}
+	}
 
 	if ctx.IsSet(ExternalSignerFlag.Name) {
 		cfg.ExternalSigner = ctx.String(ExternalSignerFlag.Name)
@@ -1626,6 +1782,15 @@ func setTxPool(ctx *cli.Context, cfg *txpool.Config) {
 	if ctx.IsSet(TxPoolLifetimeFlag.Name) {
 		cfg.Lifetime = ctx.Duration(TxPoolLifetimeFlag.Name)
 	}
+	if ctx.IsSet(TxPoolSnapshotFlag.Name) {
+		cfg.Snapshot = ctx.String(TxPoolSnapshotFlag.Name)
+	}
+	if ctx.IsSet(TxPoolSnapshotIntervalFlag.Name) {
+		cfg.SnapshotInterval = ctx.Duration(TxPoolSnapshotIntervalFlag.Name)
+	}
+	if ctx.IsSet(TxPoolPolicyFlag.Name) {
+		cfg.PolicyFile = ctx.String(TxPoolPolicyFlag.Name)
+	}
 }
 
 func setetxash(ctx *cli.Context, cfg *etxconfig.Config) {
@@ -1817,6 +1982,24 @@ func SetetxConfig(ctx *cli.Context, stack *node.Node, cfg *etxconfig.Config) {
 	if ctx.IsSet(CacheNoPrefetchFlag.Name) {
 		cfg.NoPrefetch = ctx.Bool(CacheNoPrefetchFlag.Name)
 	}
+	if ctx.IsSet(StateSchemeFlag.Name) {
+		cfg.StateScheme = ctx.String(StateSchemeFlag.Name)
+	}
+	if ctx.IsSet(StateHistoryFlag.Name) {
+		cfg.StateHistory = ctx.Uint64(StateHistoryFlag.Name)
+	}
+	if ctx.IsSet(StatePruneFlag.Name) {
+		cfg.StatePruneOnline = ctx.Bool(StatePruneFlag.Name)
+	}
+	if ctx.IsSet(StatePruneIntervalFlag.Name) {
+		cfg.StatePruneInterval = ctx.Duration(StatePruneIntervalFlag.Name)
+	}
+	if cfg.StatePruneOnline && cfg.NoPruning {
+		Fatalf("--%s is not supported with --%s=archive", StatePruneFlag.Name, GCModeFlag.Name)
+	}
+	if cfg.NoPruning && cfg.StateScheme == rawdb.PathScheme {
+		Fatalf("--%s=archive is not supported in the path-based state scheme", GCModeFlag.Name)
+	}
 	// Read the value from the flag no matter if it's set or not.
 	cfg.Preimages = ctx.Bool(CachePreimagesFlag.Name)
 	if cfg.NoPruning && !cfg.Preimages {
@@ -1826,6 +2009,12 @@ func SetetxConfig(ctx *cli.Context, stack *node.Node, cfg *etxconfig.Config) {
 	if ctx.IsSet(TxLookupLimitFlag.Name) {
 		cfg.TxLookupLimit = ctx.Uint64(TxLookupLimitFlag.Name)
 	}
+	if ctx.IsSet(HistoryBlocksFlag.Name) {
+		cfg.HistoryBlocks = ctx.Uint64(HistoryBlocksFlag.Name)
+	}
+	if cfg.HistoryBlocks != 0 && cfg.NoPruning {
+		Fatalf("--%s is not supported with --%s=archive", HistoryBlocksFlag.Name, GCModeFlag.Name)
+	}
 	if ctx.IsSet(CacheFlag.Name) || ctx.IsSet(CacheTrieFlag.Name) {
 		cfg.TrieCleanCache = ctx.Int(CacheFlag.Name) * ctx.Int(CacheTrieFlag.Name) / 100
 	}
@@ -1860,6 +2049,21 @@ func SetetxConfig(ctx *cli.Context, stack *node.Node, cfg *etxconfig.Config) {
 		// TODO(fjl): force-enable this in --dev mode
 		cfg.EnablePreimageRecording = ctx.Bool(VMEnableDebugFlag.Name)
 	}
+	if ctx.IsSet(TraceIndexFlag.Name) {
+		cfg.TraceIndex = ctx.String(TraceIndexFlag.Name)
+	}
+	if ctx.IsSet(TraceIndexConfigFlag.Name) {
+		cfg.TraceIndexConfig = ctx.String(TraceIndexConfigFlag.Name)
+	}
+	if ctx.IsSet(LiveTracerFlag.Name) {
+		cfg.LiveTracer = ctx.String(LiveTracerFlag.Name)
+	}
+	if ctx.IsSet(LiveTracerConfigFlag.Name) {
+		cfg.LiveTracerConfig = ctx.String(LiveTracerConfigFlag.Name)
+	}
+	if ctx.IsSet(LiveTraceSinkFlag.Name) {
+		cfg.LiveTraceSink = ctx.String(LiveTraceSinkFlag.Name)
+	}
 
 	if ctx.IsSet(RPCGlobalGasCapFlag.Name) {
 		cfg.RPCGasCap = ctx.Uint64(RPCGlobalGasCapFlag.Name)
@@ -2199,6 +2403,24 @@ func MakeChainDatabase(ctx *cli.Context, stack *node.Node, readonly bool) etxdb.
 	return chainDb
 }
 
+// MakeTrieDatabase constructs a trie database on top of the given database, using
+// the state scheme requested by --state.scheme or the one of the stored state.
+// The light chain database always uses the hash-based scheme.
+func MakeTrieDatabase(ctx *cli.Context, disk etxdb.Database, preimage bool, light bool) *trie.Database {
+	config := &trie.Config{Preimages: preimage}
+	if light {
+		return trie.NewDatabaseWithConfig(disk, config)
+	}
+	scheme, err := rawdb.ParseStateScheme(ctx.String(StateSchemeFlag.Name), disk)
+	if err != nil {
+		Fatalf("%v", err)
+	}
+	if scheme == rawdb.PathScheme {
+		config.PathDB = &trie.PathConfig{StateHistory: ctx.Uint64(StateHistoryFlag.Name)}
+	}
+	return trie.NewDatabaseWithConfig(disk, config)
+}
+
 func IsNetworkPreset(ctx *cli.Context) bool {
 	for _, flag := range NetworkFlags {
 		bFlag, _ := flag.(*cli.BoolFlag)
@@ -2280,6 +2502,8 @@ func MakeChain(ctx *cli.Context, stack *node.Node, readonly bool) (*core.BlockCh
 		TrieTimeLimit:       etxconfig.Defaults.TrieTimeout,
 		SnapshotLimit:       etxconfig.Defaults.SnapshotCache,
 		Preimages:           ctx.Bool(CachePreimagesFlag.Name),
+		StateScheme:         ctx.String(StateSchemeFlag.Name),
+		StateHistory:        ctx.Uint64(StateHistoryFlag.Name),
 	}
 	if cache.TrieDirtyDisabled && !cache.Preimages {
 		cache.Preimages = true
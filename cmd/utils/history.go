@@ -0,0 +1,436 @@
+// Copyright 2022 The go-ETX Authors
+// This file is part of go-ETX.
+//
+// go-ETX is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ETX is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ETX. If not, see <http://www.gnu.org/licenses/>.
+
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/ETX/go-ETX/common"
+	"github.com/ETX/go-ETX/core"
+	"github.com/ETX/go-ETX/core/rawdb"
+	"github.com/ETX/go-ETX/core/types"
+	"github.com/ETX/go-ETX/crypto"
+	"github.com/ETX/go-ETX/etxdb"
+	"github.com/ETX/go-ETX/internal/era"
+	"github.com/ETX/go-ETX/log"
+	"github.com/ETX/go-ETX/rlp"
+	"github.com/ETX/go-ETX/trie"
+)
+
+// ExportHistory writes the canonical headers, bodies, receipts and total
+// difficulties of the given frozen block range into era1-style archives in the
+// given directory, one archive per epoch of era.MaxSize blocks. The sha256
+// checksums of the archives are written into checksums.txt.
+func ExportHistory(db etxdb.Database, dir string, network string, first uint64, last uint64) error {
+	if first > last {
+		return fmt.Errorf("invalid range [%d, %d]", first, last)
+	}
+	frozen, err := db.Ancients()
+	if err != nil {
+		return err
+	}
+	if last >= frozen {
+		return fmt.Errorf("block #%d not frozen yet, frozen items %d", last, frozen)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	log.Info("Exporting chain history", "dir", dir, "first", first, "last", last)
+
+	var (
+		checksums []string
+		start     = time.Now()
+	)
+	for from := first; from <= last; {
+		epoch := from / era.MaxSize
+		to := (epoch+1)*era.MaxSize - 1
+		if to > last {
+			to = last
+		}
+		name, sum, err := exportEpoch(db, dir, network, int(epoch), from, to)
+		if err != nil {
+			return err
+		}
+		checksums = append(checksums, fmt.Sprintf("%x  %s", sum, name))
+		log.Info("Exported chain history epoch", "file", name, "first", from, "last", to, "elapsed", common.PrettyDuration(time.Since(start)))
+
+		from = to + 1
+	}
+	if err := os.WriteFile(filepath.Join(dir, "checksums.txt"), []byte(strings.Join(checksums, "\n")+"\n"), 0644); err != nil {
+		return err
+	}
+	log.Info("Exported chain history", "dir", dir, "files", len(checksums), "elapsed", common.PrettyDuration(time.Since(start)))
+	return nil
+}
+
+// exportEpoch writes a single archive of the given block range, returning its
+// file name and its sha256 checksum.
+func exportEpoch(db etxdb.Database, dir string, network string, epoch int, first, last uint64) (string, []byte, error) {
+	// The file name depends on the accumulator, write into a temporary file
+	// and move it in place at the end.
+	tmp, err := os.CreateTemp(dir, "export-*.era1.tmp")
+	if err != nil {
+		return "", nil, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	var (
+		hasher  = sha256.New()
+		writer  = bufio.NewWriter(io.MultiWriter(tmp, hasher))
+		builder = era.NewBuilder(writer)
+	)
+	for number := first; number <= last; number++ {
+		hash := rawdb.ReadCanonicalHash(db, number)
+		if hash == (common.Hash{}) {
+			return "", nil, fmt.Errorf("canonical block #%d not found", number)
+		}
+		header := rawdb.ReadHeaderRLP(db, hash, number)
+		if len(header) == 0 {
+			return "", nil, fmt.Errorf("header #%d [%x] not found", number, hash)
+		}
+		body := rawdb.ReadBodyRLP(db, hash, number)
+		if len(body) == 0 {
+			return "", nil, fmt.Errorf("body #%d [%x] not found", number, hash)
+		}
+		receipts := rawdb.ReadReceiptsRLP(db, hash, number)
+		if len(receipts) == 0 {
+			return "", nil, fmt.Errorf("receipts #%d [%x] not found", number, hash)
+		}
+		td := rawdb.ReadTd(db, hash, number)
+		if td == nil {
+			return "", nil, fmt.Errorf("total difficulty #%d [%x] not found", number, hash)
+		}
+		if err := builder.Add(number, hash, header, body, receipts, td); err != nil {
+			return "", nil, err
+		}
+	}
+	root, err := builder.Finalize()
+	if err != nil {
+		return "", nil, err
+	}
+	if err := writer.Flush(); err != nil {
+		return "", nil, err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", nil, err
+	}
+	name := era.Filename(network, epoch, root)
+	if err := os.Rename(tmp.Name(), filepath.Join(dir, name)); err != nil {
+		return "", nil, err
+	}
+	return name, hasher.Sum(nil), nil
+}
+
+// ImportHistory imports the era1-style archives listed in the checksums.txt of
+// the given directory into the ancient store of the chain. The archives are
+// verified against their checksums and accumulators and the blocks against
+// their headers, but they are not executed.
+func ImportHistory(chain *core.BlockChain, dir string) error {
+	// Watch for Ctrl-C while the import is running.
+	// If a signal is received, the import will stop at the next archive.
+	interrupt := make(chan os.Signal, 1)
+	stop := make(chan struct{})
+	signal.Notify(interrupt, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(interrupt)
+	defer close(interrupt)
+	go func() {
+		if _, ok := <-interrupt; ok {
+			log.Info("Interrupted during history import, stopping at next archive")
+		}
+		close(stop)
+	}()
+	files, err := readChecksums(dir)
+	if err != nil {
+		return err
+	}
+	log.Info("Importing chain history", "dir", dir, "files", len(files))
+
+	start := time.Now()
+	for _, file := range files {
+		select {
+		case <-stop:
+			return errors.New("interrupted")
+		default:
+		}
+		path := filepath.Join(dir, file.name)
+		if err := verifyChecksum(path, file.sum); err != nil {
+			return err
+		}
+		if err := importEpoch(chain, path); err != nil {
+			return fmt.Errorf("%s: %v", file.name, err)
+		}
+		log.Info("Imported chain history epoch", "file", file.name, "elapsed", common.PrettyDuration(time.Since(start)))
+	}
+	log.Info("Imported chain history", "dir", dir, "files", len(files), "head", chain.CurrentFastBlock().NumberU64(), "elapsed", common.PrettyDuration(time.Since(start)))
+	return nil
+}
+
+// importEpoch verifies a single archive and inserts the blocks of it missing
+// from the chain.
+func importEpoch(chain *core.BlockChain, path string) error {
+	archive, err := era.Open(path)
+	if err != nil {
+		return err
+	}
+	defer archive.Close()
+
+	var (
+		hashes   []common.Hash
+		tds      []*big.Int
+		headers  []*types.Header
+		blocks   types.Blocks
+		receipts []types.Receipts
+		parentTd *big.Int
+		head     = chain.CurrentFastBlock().NumberU64()
+	)
+	for number := archive.Start(); number < archive.Start()+archive.Count(); number++ {
+		raw, err := archive.GetRawBlock(number)
+		if err != nil {
+			return err
+		}
+		header := new(types.Header)
+		if err := rlp.DecodeBytes(raw.Header, header); err != nil {
+			return fmt.Errorf("invalid header #%d: %v", number, err)
+		}
+		if header.Number.Uint64() != number {
+			return fmt.Errorf("header number mismatch: have %d, want %d", header.Number, number)
+		}
+		hash := header.Hash()
+		if parentTd == nil && number > 0 {
+			if parentTd = chain.GetTd(header.ParentHash, number-1); parentTd == nil {
+				return fmt.Errorf("parent of block #%d [%x] unknown", number, hash)
+			}
+		}
+		td := new(big.Int).Set(header.Difficulty)
+		if parentTd != nil {
+			td.Add(td, parentTd)
+		}
+		if td.Cmp(raw.TotalDifficulty) != 0 {
+			return fmt.Errorf("total difficulty mismatch for block #%d: have %v, want %v", number, raw.TotalDifficulty, td)
+		}
+		parentTd = td
+		hashes, tds = append(hashes, hash), append(tds, td)
+
+		blockReceipts, err := verifyHistory(header, raw.Body, raw.Receipts)
+		if err != nil {
+			return fmt.Errorf("block #%d [%x]: %v", number, hash, err)
+		}
+		if number == 0 || number <= head {
+			if chain.GetCanonicalHash(number) != hash {
+				return fmt.Errorf("block #%d [%x] conflicts with local chain", number, hash)
+			}
+			continue
+		}
+		var body types.Body
+		if err := rlp.DecodeBytes(raw.Body, &body); err != nil {
+			return err
+		}
+		headers = append(headers, header)
+		blocks = append(blocks, types.NewBlockWithHeader(header).WithBody(body.Transactions, body.Uncles))
+		receipts = append(receipts, blockReceipts)
+	}
+	root, err := era.ComputeAccumulator(hashes, tds)
+	if err != nil {
+		return err
+	}
+	if stored, err := archive.Accumulator(); err != nil {
+		return err
+	} else if stored != root {
+		return fmt.Errorf("accumulator mismatch: have %x, want %x", stored, root)
+	}
+	if len(blocks) == 0 {
+		return nil
+	}
+	if _, err := chain.InsertHeaderChain(headers, 1); err != nil {
+		return err
+	}
+	_, err = chain.InsertReceiptChain(blocks, receipts, math.MaxUint64)
+	return err
+}
+
+// checksumEntry is a single line of an archive checksum file.
+type checksumEntry struct {
+	sum  []byte
+	name string
+}
+
+// readChecksums parses the checksums.txt file of an archive directory.
+func readChecksums(dir string) ([]checksumEntry, error) {
+	blob, err := os.ReadFile(filepath.Join(dir, "checksums.txt"))
+	if err != nil {
+		return nil, err
+	}
+	var entries []checksumEntry
+	for i, line := range strings.Split(string(blob), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("invalid checksum line %d: %q", i+1, line)
+		}
+		sum, err := hex.DecodeString(fields[0])
+		if err != nil || len(sum) != sha256.Size {
+			return nil, fmt.Errorf("invalid checksum on line %d: %q", i+1, fields[0])
+		}
+		entries = append(entries, checksumEntry{sum: sum, name: filepath.Base(fields[1])})
+	}
+	return entries, nil
+}
+
+// verifyChecksum checks the sha256 checksum of the given file.
+func verifyChecksum(path string, want []byte) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, f); err != nil {
+		return err
+	}
+	if have := hasher.Sum(nil); !bytes.Equal(have, want) {
+		return fmt.Errorf("checksum mismatch for %s: have %x, want %x", path, have, want)
+	}
+	return nil
+}
+
+// RestoreHistory writes the bodies and receipts contained in an era1-style
+// archive back into the database, filling in expired chain history. Every block
+// must be part of the local canonical chain and is verified against its stored
+// header.
+func RestoreHistory(db etxdb.Database, fn string, interrupt chan struct{}) error {
+	log.Info("Restoring chain history", "file", fn)
+
+	archive, err := era.Open(fn)
+	if err != nil {
+		return err
+	}
+	defer archive.Close()
+
+	var (
+		batch    = db.NewBatch()
+		start    = time.Now()
+		logged   = time.Now()
+		restored int
+	)
+	for number := archive.Start(); number < archive.Start()+archive.Count(); number++ {
+		select {
+		case <-interrupt:
+			return errors.New("interrupted")
+		default:
+		}
+		raw, err := archive.GetRawBlock(number)
+		if err != nil {
+			return err
+		}
+		hash := rawdb.ReadCanonicalHash(db, number)
+		if hash == (common.Hash{}) {
+			return fmt.Errorf("canonical block #%d not found", number)
+		}
+		header := rawdb.ReadHeader(db, hash, number)
+		if header == nil {
+			return fmt.Errorf("header #%d [%x] not found", number, hash)
+		}
+		if have := crypto.Keccak256Hash(raw.Header); have != hash {
+			return fmt.Errorf("header #%d mismatch: have %x, want %x", number, have, hash)
+		}
+		if td := rawdb.ReadTd(db, hash, number); td == nil || td.Cmp(raw.TotalDifficulty) != 0 {
+			return fmt.Errorf("total difficulty #%d mismatch: have %v, want %v", number, raw.TotalDifficulty, td)
+		}
+		if _, err := verifyHistory(header, raw.Body, raw.Receipts); err != nil {
+			return fmt.Errorf("block #%d [%x]: %v", number, hash, err)
+		}
+		if rawdb.HasBody(db, hash, number) && rawdb.HasReceipts(db, hash, number) {
+			continue
+		}
+		rawdb.WriteBodyRLP(batch, hash, number, raw.Body)
+		rawdb.WriteReceiptsRLP(batch, hash, number, raw.Receipts)
+		restored++
+
+		if batch.ValueSize() > etxdb.IdealBatchSize {
+			if err := batch.Write(); err != nil {
+				return err
+			}
+			batch.Reset()
+		}
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Restoring chain history", "restored", restored, "number", number, "elapsed", common.PrettyDuration(time.Since(start)))
+			logged = time.Now()
+		}
+	}
+	if err := batch.Write(); err != nil {
+		return err
+	}
+	log.Info("Restored chain history", "file", fn, "restored", restored, "elapsed", common.PrettyDuration(time.Since(start)))
+	return nil
+}
+
+// VerifyHistory checks the RLP encoded body and receipts of a block against the
+// transaction, uncle and receipt roots of its header.
+func VerifyHistory(header *types.Header, rawBody, rawReceipts []byte) error {
+	_, err := verifyHistory(header, rawBody, rawReceipts)
+	return err
+}
+
+// verifyHistory checks that the RLP encoded body and storage receipts belong
+// to the given header, returning the decoded receipts.
+func verifyHistory(header *types.Header, rawBody, rawReceipts []byte) (types.Receipts, error) {
+	var body types.Body
+	if err := rlp.DecodeBytes(rawBody, &body); err != nil {
+		return nil, fmt.Errorf("invalid body: %v", err)
+	}
+	if have := types.DeriveSha(types.Transactions(body.Transactions), trie.NewStackTrie(nil)); have != header.TxHash {
+		return nil, fmt.Errorf("transaction root mismatch: have %x, want %x", have, header.TxHash)
+	}
+	if have := types.CalcUncleHash(body.Uncles); have != header.UncleHash {
+		return nil, fmt.Errorf("uncle root mismatch: have %x, want %x", have, header.UncleHash)
+	}
+	var stored []*types.ReceiptForStorage
+	if err := rlp.DecodeBytes(rawReceipts, &stored); err != nil {
+		return nil, fmt.Errorf("invalid receipts: %v", err)
+	}
+	if len(stored) != len(body.Transactions) {
+		return nil, fmt.Errorf("receipt count mismatch: have %d, want %d", len(stored), len(body.Transactions))
+	}
+	receipts := make(types.Receipts, len(stored))
+	for i, receipt := range stored {
+		receipts[i] = (*types.Receipt)(receipt)
+		receipts[i].Type = body.Transactions[i].Type()
+		receipts[i].Bloom = types.CreateBloom(types.Receipts{receipts[i]})
+	}
+	if have := types.DeriveSha(receipts, trie.NewStackTrie(nil)); have != header.ReceiptHash {
+		return nil, fmt.Errorf("receipt root mismatch: have %x, want %x", have, header.ReceiptHash)
+	}
+	return receipts, nil
+}
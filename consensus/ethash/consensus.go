@@ -30,6 +30,7 @@ import (
 	"github.com/ETX/go-ETX/consensus"
 	"github.com/ETX/go-ETX/consensus/misc"
 	"github.com/ETX/go-ETX/core/state"
+	"github.com/ETX/go-ETX/core/tracing"
 	"github.com/ETX/go-ETX/core/types"
 	"github.com/ETX/go-ETX/params"
 	"github.com/ETX/go-ETX/rlp"
@@ -666,10 +667,10 @@ func accumulateRewards(config *params.ChainConfig, state *state.StateDB, header
 		r.Sub(r, header.Number)
 		r.Mul(r, blockReward)
 		r.Div(r, big8)
-		state.AddBalance(uncle.Coinbase, r)
+		state.AddBalance(uncle.Coinbase, r, tracing.BalanceIncreaseRewardMineUncle)
 
 		r.Div(blockReward, big32)
 		reward.Add(reward, r)
 	}
-	state.AddBalance(header.Coinbase, reward)
+	state.AddBalance(header.Coinbase, reward, tracing.BalanceIncreaseRewardMineBlock)
 }
@@ -22,6 +22,7 @@ import (
 	"math/big"
 
 	"github.com/ETX/go-ETX/core/state"
+	"github.com/ETX/go-ETX/core/tracing"
 	"github.com/ETX/go-ETX/core/types"
 	"github.com/ETX/go-ETX/params"
 )
@@ -80,7 +81,8 @@ func ApplyDAOHardFork(statedb *state.StateDB) {
 
 	// Move every DAO account and extra-balance account funds into the refund contract
 	for _, addr := range params.DAODrainList() {
-		statedb.AddBalance(params.DAORefundContract, statedb.GetBalance(addr))
-		statedb.SetBalance(addr, new(big.Int))
+		balance := statedb.GetBalance(addr)
+		statedb.AddBalance(params.DAORefundContract, balance, tracing.BalanceIncreaseDaoContract)
+		statedb.SubBalance(addr, balance, tracing.BalanceDecreaseDaoAccount)
 	}
 }
@@ -136,6 +136,11 @@ type CacheConfig struct {
 	TrieTimeLimit       time.Duration // Time limit after which to flush the current in-memory trie to disk
 	SnapshotLimit       int           // Memory allowance (MB) to use for caching snapshot entries in memory
 	Preimages           bool          // Whetxer to store preimage of trie key to the disk
+	StateScheme         string        // Scheme used to store ETX states and merkle tree nodes on top
+	StateHistory        uint64        // Number of recent persisted states to keep reverse diffs for (path-based scheme only)
+	OnlinePrune         bool          // Whetxer to prune the stale state in the background (hash-based scheme only)
+	OnlinePruneInterval time.Duration // Time to wait between two background state pruning cycles
+	HistoryBlocks       uint64        // Number of recent blocks to retain the bodies and receipts for, 0 for all
 
 	SnapshotNoBuild bool // Whetxer the background generation is allowed
 	SnapshotWait    bool // Wait for snapshot construction on startup. TODO(karalabe): This is a dirty hack for testing, nuke it
@@ -221,6 +226,8 @@ type BlockChain struct {
 	processor  Processor // Block transaction processor interface
 	forker     *ForkChoice
 	vmConfig   vm.Config
+	live       *liveTracing   // Inline tracer of the imported blocks, nil if disabled
+	pruning    *onlinePruning // Background state pruner, nil if disabled
 }
 
 // NewBlockChain returns a fully initialised block chain using information
@@ -230,11 +237,29 @@ func NewBlockChain(db etxdb.Database, cacheConfig *CacheConfig, genesis *Genesis
 	if cacheConfig == nil {
 		cacheConfig = defaultCacheConfig
 	}
+	// Open the trie database with the state scheme of the stored state, or the
+	// configured one if the database is empty.
+	scheme, err := rawdb.ParseStateScheme(cacheConfig.StateScheme, db)
+	if err != nil {
+		return nil, err
+	}
+	trieConfig := &trie.Config{
+		Cache:     cacheConfig.TrieCleanLimit,
+		Journal:   cacheConfig.TrieCleanJournal,
+		Preimages: cacheConfig.Preimages,
+	}
+	if scheme == rawdb.PathScheme {
+		if cacheConfig.TrieDirtyDisabled {
+			return nil, errors.New("archive mode is not supported in path-based scheme")
+		}
+		trieConfig.PathDB = &trie.PathConfig{StateHistory: cacheConfig.StateHistory}
+	}
+	triedb := trie.NewDatabaseWithConfig(db, trieConfig)
 
 	// Setup the genesis block, commit the provided genesis specification
 	// to database if the genesis block is not present yet, or load the
 	// stored one from database.
-	chainConfig, genesisHash, genesisErr := SetupGenesisBlockWithOverride(db, genesis, overrides)
+	chainConfig, genesisHash, genesisErr := SetupGenesisBlockWithOverride(db, triedb, genesis, overrides)
 	if _, ok := genesisErr.(*params.ConfigCompatError); genesisErr != nil && !ok {
 		return nil, genesisErr
 	}
@@ -247,15 +272,11 @@ func NewBlockChain(db etxdb.Database, cacheConfig *CacheConfig, genesis *Genesis
 	log.Info("")
 
 	bc := &BlockChain{
-		chainConfig: chainConfig,
-		cacheConfig: cacheConfig,
-		db:          db,
-		triegc:      prque.New(nil),
-		stateCache: state.NewDatabaseWithConfig(db, &trie.Config{
-			Cache:     cacheConfig.TrieCleanLimit,
-			Journal:   cacheConfig.TrieCleanJournal,
-			Preimages: cacheConfig.Preimages,
-		}),
+		chainConfig:   chainConfig,
+		cacheConfig:   cacheConfig,
+		db:            db,
+		triegc:        prque.New(nil),
+		stateCache:    state.NewDatabaseWithNodeDB(db, triedb),
 		quit:          make(chan struct{}),
 		chainmu:       syncx.NewClosableMutex(),
 		bodyCache:     lru.NewCache[common.Hash, *types.Body](bodyCacheLimit),
@@ -272,7 +293,6 @@ func NewBlockChain(db etxdb.Database, cacheConfig *CacheConfig, genesis *Genesis
 	bc.prefetcher = newStatePrefetcher(chainConfig, bc, engine)
 	bc.processor = NewStateProcessor(chainConfig, bc, engine)
 
-	var err error
 	bc.hc, err = NewHeaderChain(db, chainConfig, engine, bc.insertStopped)
 	if err != nil {
 		return nil, err
@@ -400,6 +420,15 @@ func NewBlockChain(db etxdb.Database, cacheConfig *CacheConfig, genesis *Genesis
 		}
 		bc.snaps, _ = snapshot.New(snapconfig, bc.db, bc.stateCache.TrieDB(), head.Root())
 	}
+	// Start the background state pruner if requested. Archive nodes keep all
+	// the states and the path-based scheme overwrites the stale ones in place.
+	if bc.cacheConfig.OnlinePrune {
+		if bc.cacheConfig.TrieDirtyDisabled || scheme == rawdb.PathScheme {
+			log.Warn("Online state pruning is only supported in non-archive hash-based mode, disabling")
+		} else {
+			bc.startOnlinePruning()
+		}
+	}
 
 	// Start future block processor.
 	bc.wg.Add(1)
@@ -424,13 +453,22 @@ func NewBlockChain(db etxdb.Database, cacheConfig *CacheConfig, genesis *Genesis
 		bc.Setxead(compat.RewindTo)
 		rawdb.WriteChainConfig(db, genesisHash, chainConfig)
 	}
-	// Start tx indexer/unindexer if required.
+	// Start tx indexer/unindexer if required. The transactions of expired
+	// blocks can't be indexed, so the index is capped to the retained history.
 	if txLookupLimit != nil {
 		bc.txLookupLimit = *txLookupLimit
-
+		if limit := bc.cacheConfig.HistoryBlocks; limit != 0 && (bc.txLookupLimit == 0 || bc.txLookupLimit > limit) {
+			log.Info("Capping transaction index to retained history", "provided", bc.txLookupLimit, "updated", limit)
+			bc.txLookupLimit = limit
+		}
 		bc.wg.Add(1)
 		go bc.maintainTxIndex()
 	}
+	// Start expiring the old chain history if requested.
+	if bc.cacheConfig.HistoryBlocks != 0 {
+		bc.wg.Add(1)
+		go bc.maintainHistory()
+	}
 	return bc, nil
 }
 
@@ -569,6 +607,16 @@ func (bc *BlockChain) setxeadBeyondRoot(head uint64, root common.Hash, repair bo
 	// Track the block number of the requested root hash
 	var rootNumber uint64 // (no root == always 0)
 
+	// Track the canonical hashes deleted by the rewind, as the live tracer is
+	// notified about the dropped blocks only afterwards
+	var (
+		oldHead = bc.CurrentBlock().NumberU64()
+		dropped map[uint64]common.Hash
+	)
+	if bc.live != nil {
+		dropped = make(map[uint64]common.Hash)
+	}
+
 	// Retrieve the last pivot block to short circuit rollbacks beyond it and the
 	// current freezer limit to start nuking id underflown
 	pivot := rawdb.ReadLastPivotNumber(bc.db)
@@ -594,7 +642,7 @@ func (bc *BlockChain) setxeadBeyondRoot(head uint64, root common.Hash, repair bo
 					if root != (common.Hash{}) && !beyondRoot && newHeadBlock.Root() == root {
 						beyondRoot, rootNumber = true, newHeadBlock.NumberU64()
 					}
-					if _, err := state.New(newHeadBlock.Root(), bc.stateCache, bc.snaps); err != nil {
+					if _, err := state.New(newHeadBlock.Root(), bc.stateCache, bc.snaps); err != nil && !bc.recoverState(newHeadBlock.Root()) {
 						log.Trace("Block state missing, rewinding further", "number", newHeadBlock.NumberU64(), "hash", newHeadBlock.Hash())
 						if pivot == nil || newHeadBlock.NumberU64() > *pivot {
 							parent := bc.GetBlock(newHeadBlock.ParentHash(), newHeadBlock.NumberU64()-1)
@@ -617,7 +665,7 @@ func (bc *BlockChain) setxeadBeyondRoot(head uint64, root common.Hash, repair bo
 							// if the historical chain pruning is enabled. In that case the logic
 							// needs to be improved here.
 							if !bc.HasState(bc.genesisBlock.Root()) {
-								if err := CommitGenesisState(bc.db, bc.genesisBlock.Hash()); err != nil {
+								if err := CommitGenesisState(bc.db, bc.stateCache.TrieDB(), bc.genesisBlock.Hash()); err != nil {
 									log.Crit("Failed to commit genesis state", "err", err)
 								}
 								log.Debug("Recommitted genesis state to disk")
@@ -668,6 +716,9 @@ func (bc *BlockChain) setxeadBeyondRoot(head uint64, root common.Hash, repair bo
 	}
 	// Rewind the header chain, deleting all block bodies until then
 	delFn := func(db etxdb.KeyValueWriter, hash common.Hash, num uint64) {
+		if dropped != nil && num <= oldHead {
+			dropped[num] = hash
+		}
 		// Ignore the error here since light client won't hit this path
 		frozen, _ := bc.db.Ancients()
 		if num+1 <= frozen {
@@ -699,6 +750,8 @@ func (bc *BlockChain) setxeadBeyondRoot(head uint64, root common.Hash, repair bo
 		log.Warn("Rewinding blockchain", "target", head)
 		bc.hc.Setxead(head, updateFn, delFn)
 	}
+	bc.live.rewound(bc.db, oldHead, bc.CurrentBlock().NumberU64(), dropped)
+
 	// Clear out any stale content from the caches
 	bc.bodyCache.Purge()
 	bc.bodyRLPCache.Purge()
@@ -720,6 +773,21 @@ func (bc *BlockChain) setxeadBeyondRoot(head uint64, root common.Hash, repair bo
 	return rootNumber, bc.loadLastState()
 }
 
+// recoverState reverts the persistent state to the given one if it's still
+// covered by the state history of the path-based scheme, reporting whetxer
+// the state is available afterwards. All the newer states are discarded.
+func (bc *BlockChain) recoverState(root common.Hash) bool {
+	triedb := bc.stateCache.TrieDB()
+	if !triedb.Recoverable(root) {
+		return false
+	}
+	if err := triedb.Recover(root); err != nil {
+		log.Error("Failed to revert state", "root", root, "err", err)
+		return false
+	}
+	return true
+}
+
 // SnapSyncCommitHead sets the current head block to the one defined by the hash
 // irrelevant what the chain contents were prior.
 func (bc *BlockChain) SnapSyncCommitHead(hash common.Hash) error {
@@ -850,6 +918,9 @@ func (bc *BlockChain) writeHeadBlock(block *types.Block) {
 
 	bc.currentBlock.Store(block)
 	headBlockGauge.Update(int64(block.NumberU64()))
+
+	// Hand the live traces of the block over, if it was traced
+	bc.live.canonical(block)
 }
 
 // stop stops the blockchain service. If any imports are currently in progress
@@ -869,6 +940,9 @@ func (bc *BlockChain) stopWithoutSaving() {
 	// Signal shutdown to all goroutines.
 	close(bc.quit)
 	bc.StopInsert()
+	if bc.pruning != nil {
+		bc.pruning.pruner.Close()
+	}
 
 	// Now wait for all chain modifications to end and persistent goroutines to exit.
 	//
@@ -899,9 +973,18 @@ func (bc *BlockChain) Stop() {
 	//  - HEAD:     So we don't need to reprocess any blocks in the general case
 	//  - HEAD-1:   So we don't do large reorgs if our HEAD becomes an uncle
 	//  - HEAD-127: So we have a hard limit on the number of blocks reexecuted
-	if !bc.cacheConfig.TrieDirtyDisabled {
-		triedb := bc.stateCache.TrieDB()
-
+	if triedb := bc.stateCache.TrieDB(); triedb.Scheme() == rawdb.PathScheme {
+		// The path-based scheme keeps a single persisted state, flush the head
+		// one. The older states can be reverted to with the state history.
+		if number := bc.CurrentBlock().NumberU64(); number > 0 {
+			recent := bc.CurrentBlock()
+
+			log.Info("Writing cached state to disk", "block", recent.Number(), "hash", recent.Hash(), "root", recent.Root())
+			if err := triedb.Commit(recent.Root(), true, nil); err != nil {
+				log.Error("Failed to commit recent state trie", "err", err)
+			}
+		}
+	} else if !bc.cacheConfig.TrieDirtyDisabled {
 		for _, offset := range []uint64{0, 1, TriesInMemory - 1} {
 			if number := bc.CurrentBlock().NumberU64(); number > offset {
 				recent := bc.GetBlockByNumber(number - offset)
@@ -921,6 +1004,7 @@ func (bc *BlockChain) Stop() {
 		for !bc.triegc.Empty() {
 			triedb.Dereference(bc.triegc.PopItem().(common.Hash))
 		}
+		bc.dereferencePrunedStates(true)
 		if size, _ := triedb.Size(); size != 0 {
 			log.Error("Dangling trie nodes after full cleanup")
 		}
@@ -935,6 +1019,9 @@ func (bc *BlockChain) Stop() {
 		triedb := bc.stateCache.TrieDB()
 		triedb.SaveCache(bc.cacheConfig.TrieCleanJournal)
 	}
+	// Release the live trace sink, no more blocks are imported
+	bc.live.close()
+
 	log.Info("Blockchain stopped")
 }
 
@@ -1308,6 +1395,11 @@ func (bc *BlockChain) writeBlockWithState(block *types.Block, receipts []*types.
 	}
 	triedb := bc.stateCache.TrieDB()
 
+	// The path-based scheme keeps the recent states in memory as diff layers
+	// and persists the older ones by itself, no garbage collection is needed.
+	if triedb.Scheme() == rawdb.PathScheme {
+		return nil
+	}
 	// If we're running an archive node, always flush
 	if bc.cacheConfig.TrieDirtyDisabled {
 		return triedb.Commit(root, false, nil)
@@ -1316,6 +1408,9 @@ func (bc *BlockChain) writeBlockWithState(block *types.Block, receipts []*types.
 		triedb.Reference(root, common.Hash{}) // metadata reference to keep trie alive
 		bc.triegc.Push(root, -int64(block.NumberU64()))
 
+		// Release the states retained for the online pruner, if marked already
+		bc.dereferencePrunedStates(false)
+
 		if current := block.NumberU64(); current > TriesInMemory {
 			// If we exceeded our memory allowance, flush matured singleton nodes to disk
 			var (
@@ -1698,9 +1793,18 @@ func (bc *BlockChain) insertChain(chain types.Blocks, verifySeals, setxead bool)
 			}
 		}
 
-		// Process block using the parent state as reference point
+		// Process block using the parent state as reference point, running the
+		// live tracer (if any) on a private copy of the config so the prefetcher
+		// is left untouched
+		var (
+			vmConfig = bc.vmConfig
+			tracer   = bc.live.newTracer(block)
+		)
+		if tracer != nil {
+			vmConfig.Debug, vmConfig.Tracer = true, tracer
+		}
 		substart := time.Now()
-		receipts, logs, usedGas, err := bc.processor.Process(block, statedb, bc.vmConfig)
+		receipts, logs, usedGas, err := bc.processor.Process(block, statedb, vmConfig)
 		if err != nil {
 			bc.reportBlock(block, receipts, err)
 			atomic.StoreUint32(&followupInterrupt, 1)
@@ -1729,6 +1833,9 @@ func (bc *BlockChain) insertChain(chain types.Blocks, verifySeals, setxead bool)
 		}
 		proctime := time.Since(start)
 
+		// Stash away the live traces until the block becomes canonical
+		bc.live.processed(tracer)
+
 		// Update the metrics touched during block validation
 		accountHashTimer.Update(statedb.AccountHashes) // Account hashes are complete, we can mark them
 		storageHashTimer.Update(statedb.StorageHashes) // Storage hashes are complete, we can mark them
@@ -1899,6 +2006,11 @@ func (bc *BlockChain) insertSideChain(block *types.Block, it *insertIterator) (i
 	)
 	parent := it.previous()
 	for parent != nil && !bc.HasState(parent.Root) {
+		// The state of the common ancestor might be revertable in the
+		// path-based scheme, stop at it if so.
+		if bc.recoverState(parent.Root) {
+			break
+		}
 		hashes = append(hashes, parent.Hash())
 		numbers = append(numbers, parent.Number.Uint64())
 
@@ -1955,6 +2067,11 @@ func (bc *BlockChain) recoverAncestors(block *types.Block) (common.Hash, error)
 		parent  = block
 	)
 	for parent != nil && !bc.HasState(parent.Root()) {
+		// The state of the common ancestor might be revertable in the
+		// path-based scheme, stop at it if so.
+		if bc.recoverState(parent.Root()) {
+			break
+		}
 		hashes = append(hashes, parent.Hash())
 		numbers = append(numbers, parent.NumberU64())
 		parent = bc.GetBlock(parent.ParentHash(), parent.NumberU64()-1)
@@ -2095,6 +2212,11 @@ func (bc *BlockChain) reorg(oldBlock, newBlock *types.Block) error {
 		// rewind the canonical chain to a lower point.
 		log.Error("Impossible reorg, please file an issue", "oldnum", oldBlock.Number(), "oldhash", oldBlock.Hash(), "oldblocks", len(oldChain), "newnum", newBlock.Number(), "newhash", newBlock.Hash(), "newblocks", len(newChain))
 	}
+	// Notify the live trace sink about the dropped blocks before any of the
+	// new ones are emitted.
+	for _, block := range oldChain {
+		bc.live.reverted(block)
+	}
 	// Insert the new chain(except the head block(reverse order)),
 	// taking care of the proper incremental order.
 	for i := len(newChain) - 1; i >= 1; i-- {
@@ -0,0 +1,108 @@
+// Copyright 2022 The go-ETX Authors
+// This file is part of the go-ETX library.
+//
+// The go-ETX library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ETX library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ETX library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/ETX/go-ETX/common"
+	"github.com/ETX/go-ETX/consensus/etxash"
+	"github.com/ETX/go-ETX/core/rawdb"
+	"github.com/ETX/go-ETX/core/state/snapshot"
+	"github.com/ETX/go-ETX/core/types"
+	"github.com/ETX/go-ETX/core/vm"
+	"github.com/ETX/go-ETX/crypto"
+	"github.com/ETX/go-ETX/params"
+)
+
+// Tests that a chain can be opened from an imported state export, starting at
+// the exported block and continuing from there.
+func TestBlockchainFromStateExport(t *testing.T) {
+	var (
+		key, _    = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		addr      = crypto.PubkeyToAddress(key.PublicKey)
+		recipient = common.Address{0xaa}
+		engine    = etxash.NewFaker()
+		gspec     = &Genesis{
+			Config:  params.TestChainConfig,
+			Alloc:   GenesisAlloc{addr: {Balance: big.NewInt(1e18)}},
+			BaseFee: big.NewInt(params.InitialBaseFee),
+		}
+		signer = types.LatestSigner(gspec.Config)
+	)
+	transfer := func(i int, b *BlockGen) {
+		tx, err := types.SignTx(types.NewTransaction(b.TxNonce(addr), recipient, big.NewInt(1000), params.TxGas, b.BaseFee(), nil), signer, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b.AddTx(tx)
+	}
+	gendb, blocks, _ := GenerateChainWithGenesis(gspec, engine, 8, transfer)
+
+	chain, err := NewBlockChain(rawdb.NewMemoryDatabase(), nil, gspec, nil, engine, vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	defer chain.Stop()
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	head := chain.CurrentBlock()
+
+	var buf bytes.Buffer
+	if err := snapshot.Export(&buf, chain.Snapshots(), head, chain.GetTd(head.Hash(), head.NumberU64()), chain.db); err != nil {
+		t.Fatalf("failed to export state: %v", err)
+	}
+	// Import the state into a fresh database and open the chain on it
+	db := rawdb.NewMemoryDatabase()
+	gspec.MustCommit(db)
+	if _, err := snapshot.Import(bytes.NewReader(buf.Bytes()), db); err != nil {
+		t.Fatalf("failed to import state: %v", err)
+	}
+	imported, err := NewBlockChain(db, nil, gspec, nil, engine, vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to open imported chain: %v", err)
+	}
+	defer imported.Stop()
+
+	if have := imported.CurrentBlock().Hash(); have != head.Hash() {
+		t.Fatalf("head block mismatch: have %x, want %x", have, head.Hash())
+	}
+	if have := imported.CurrentFastBlock().Hash(); have != head.Hash() {
+		t.Fatalf("head fast block mismatch: have %x, want %x", have, head.Hash())
+	}
+	if have := imported.CurrentHeader().Hash(); have != head.Hash() {
+		t.Fatalf("head header mismatch: have %x, want %x", have, head.Hash())
+	}
+	if imported.Snapshots() == nil || imported.Snapshots().Snapshot(head.Root()) == nil {
+		t.Fatal("imported snapshot discarded")
+	}
+	statedb, err := imported.State()
+	if err != nil {
+		t.Fatalf("failed to open head state: %v", err)
+	}
+	if have, want := statedb.GetBalance(recipient), big.NewInt(8*1000); have.Cmp(want) != 0 {
+		t.Fatalf("balance mismatch: have %v, want %v", have, want)
+	}
+	// The chain must be able to continue from the imported block
+	next, _ := GenerateChain(gspec.Config, blocks[len(blocks)-1], engine, gendb, 1, transfer)
+	if _, err := imported.InsertChain(next); err != nil {
+		t.Fatalf("failed to extend imported chain: %v", err)
+	}
+}
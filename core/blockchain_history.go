@@ -0,0 +1,123 @@
+// Copyright 2022 The go-ETX Authors
+// This file is part of the go-ETX library.
+//
+// The go-ETX library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ETX library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ETX library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"time"
+
+	"github.com/ETX/go-ETX/common"
+	"github.com/ETX/go-ETX/core/rawdb"
+	"github.com/ETX/go-ETX/log"
+)
+
+// historyExpiryRecheck is the time interval to check whetxer the retained chain
+// history can be moved forward.
+const historyExpiryRecheck = time.Minute
+
+// maintainHistory periodically discards the block bodies and receipts older
+// than the configured number of recent blocks from the ancient store.
+//
+// Only frozen blocks are expired, so the history retained is never shorter than
+// the freezer threshold. Headers are kept, so the chain can still be verified
+// and the expired history can be imported back from an archive.
+func (bc *BlockChain) maintainHistory() {
+	defer bc.wg.Done()
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-timer.C:
+		case <-bc.quit:
+			return
+		}
+		if err := bc.expireHistory(); err != nil {
+			log.Warn("Failed to expire chain history", "err", err)
+		}
+		timer.Reset(historyExpiryRecheck)
+	}
+}
+
+// expireHistory moves the tail of the ancient bodies and receipts up to the
+// history retention limit.
+func (bc *BlockChain) expireHistory() error {
+	head := bc.CurrentBlock().NumberU64()
+	if head < bc.cacheConfig.HistoryBlocks {
+		return nil
+	}
+	target := head + 1 - bc.cacheConfig.HistoryBlocks
+
+	frozen, err := bc.db.Ancients()
+	if err != nil {
+		return err
+	}
+	if target > frozen {
+		target = frozen
+	}
+	// Unindexing transactions needs the block bodies, wait for the indexer
+	// to catch up before discarding them.
+	if bc.txLookupLimit != 0 {
+		tail := rawdb.ReadTxIndexTail(bc.db)
+		if tail == nil {
+			return nil
+		}
+		if target > *tail {
+			target = *tail
+		}
+	}
+	tail, err := bc.db.Tail()
+	if err != nil {
+		return err
+	}
+	if target <= tail {
+		return nil
+	}
+	// The genesis block is needed on startup, move it over to the key-value
+	// store before its first expiry.
+	if tail == 0 {
+		genesis := bc.genesisBlock
+		rawdb.WriteBody(bc.db, genesis.Hash(), 0, genesis.Body())
+		rawdb.WriteReceipts(bc.db, genesis.Hash(), 0, nil)
+	}
+	start := time.Now()
+	if err := bc.db.TruncateTail(target); err != nil {
+		return err
+	}
+	// Drop the cached blocks, so the expired ones aren't served anymore
+	bc.bodyCache.Purge()
+	bc.bodyRLPCache.Purge()
+	bc.receiptsCache.Purge()
+	bc.blockCache.Purge()
+
+	log.Info("Expired chain history", "tail", target, "expired", target-tail, "elapsed", common.PrettyDuration(time.Since(start)))
+	return nil
+}
+
+// HistoryPruned returns a PrunedHistoryError if the body and receipts of the
+// block with the given number were expired from the node. The genesis block
+// is always retained.
+func (bc *BlockChain) HistoryPruned(number uint64) error {
+	if number == 0 {
+		return nil
+	}
+	tail, err := bc.db.Tail()
+	if err != nil || number >= tail {
+		return nil
+	}
+	return &PrunedHistoryError{Number: number, Tail: tail}
+}
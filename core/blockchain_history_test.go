@@ -0,0 +1,103 @@
+// Copyright 2022 The go-ETX Authors
+// This file is part of the go-ETX library.
+//
+// The go-ETX library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ETX library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ETX library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ETX/go-ETX/common"
+	"github.com/ETX/go-ETX/consensus/etxash"
+	"github.com/ETX/go-ETX/core/rawdb"
+	"github.com/ETX/go-ETX/core/types"
+	"github.com/ETX/go-ETX/core/vm"
+	"github.com/ETX/go-ETX/crypto"
+	"github.com/ETX/go-ETX/params"
+)
+
+// Tests that the bodies and receipts of the frozen blocks older than the
+// retained history are expired, and that accessing them reports the pruning.
+func TestExpireHistory(t *testing.T) {
+	var (
+		key, _ = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		addr   = crypto.PubkeyToAddress(key.PublicKey)
+		gspec  = &Genesis{Config: params.TestChainConfig, Alloc: GenesisAlloc{addr: {Balance: big.NewInt(params.etxer)}}}
+		signer = types.LatestSigner(gspec.Config)
+	)
+	_, blocks, _ := GenerateChainWithGenesis(gspec, etxash.NewFaker(), 10, func(i int, gen *BlockGen) {
+		tx, _ := types.SignTx(types.NewTransaction(gen.TxNonce(addr), common.Address{0xaa}, big.NewInt(1), params.TxGas, gen.header.BaseFee, nil), signer, key)
+		gen.AddTx(tx)
+	})
+	db, err := rawdb.NewDatabaseWithFreezer(rawdb.NewMemoryDatabase(), t.TempDir(), "", false)
+	if err != nil {
+		t.Fatalf("failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	config := *defaultCacheConfig
+	config.HistoryBlocks = 4
+	chain, err := NewBlockChain(db, &config, gspec, nil, etxash.NewFaker(), vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create tester chain: %v", err)
+	}
+	defer chain.Stop()
+
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	// Freeze all blocks up to #8, the expiry is capped to the frozen ones
+	if err := db.(interface{ Freeze(uint64) error }).Freeze(2); err != nil {
+		t.Fatalf("failed to freeze blocks: %v", err)
+	}
+	if err := chain.expireHistory(); err != nil {
+		t.Fatalf("failed to expire history: %v", err)
+	}
+	tail, err := db.Tail()
+	if err != nil || tail != 7 {
+		t.Fatalf("history tail mismatch: have %d (%v), want 7", tail, err)
+	}
+	for _, block := range blocks {
+		var (
+			number = block.NumberU64()
+			pruned = chain.HistoryPruned(number)
+		)
+		if number < tail {
+			var perr *PrunedHistoryError
+			if !errors.As(pruned, &perr) || perr.Number != number || perr.Tail != tail || perr.ErrorCode() != 4444 {
+				t.Errorf("block %d: pruned history error mismatch: %v", number, pruned)
+			}
+			if chain.GetBlockByNumber(number) != nil || chain.GetReceiptsByHash(block.Hash()) != nil {
+				t.Errorf("block %d: expired history still available", number)
+			}
+			if chain.GetxeaderByNumber(number) == nil {
+				t.Errorf("block %d: header of expired block missing", number)
+			}
+			continue
+		}
+		if pruned != nil {
+			t.Errorf("block %d: retained history reported as pruned: %v", number, pruned)
+		}
+		if chain.GetBlockByNumber(number) == nil || len(chain.GetReceiptsByHash(block.Hash())) != 1 {
+			t.Errorf("block %d: retained history missing", number)
+		}
+	}
+	// The genesis block is moved out of the freezer before expiring it
+	if chain.HistoryPruned(0) != nil || chain.GetBlockByNumber(0) == nil {
+		t.Errorf("genesis block expired")
+	}
+}
@@ -0,0 +1,187 @@
+// Copyright 2022 The go-ETX Authors
+// This file is part of the go-ETX library.
+//
+// The go-ETX library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ETX library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ETX library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/ETX/go-ETX/common"
+	"github.com/ETX/go-ETX/core/state/pruner"
+	"github.com/ETX/go-ETX/log"
+)
+
+// onlinePruneRetry is the time to wait before retrying a pruning cycle which
+// couldn't be started, e.g. because the snapshot is not old enough yet.
+const onlinePruneRetry = time.Minute
+
+// errPruningDisabled is returned if the online pruner is accessed without being
+// enabled.
+var errPruningDisabled = errors.New("online state pruning is not enabled")
+
+// onlinePruning is the background state pruner of the chain along with the
+// states retained for it in the trie database.
+type onlinePruning struct {
+	pruner   *pruner.OnlinePruner
+	pinned   []common.Hash // States referenced until they are marked
+	released []common.Hash // States marked, but not dereferenced yet
+	lock     sync.Mutex
+}
+
+// release moves a marked state over to the list of dereferenceable ones.
+func (p *onlinePruning) release(root common.Hash) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	for i, pinned := range p.pinned {
+		if pinned == root {
+			p.pinned = append(p.pinned[:i], p.pinned[i+1:]...)
+			p.released = append(p.released, root)
+			return
+		}
+	}
+}
+
+// startOnlinePruning creates the online state pruner and starts running it in
+// the background. The pruner marks every trie node flushed from now on.
+func (bc *BlockChain) startOnlinePruning() {
+	if bc.snaps == nil {
+		log.Warn("Online state pruning requires snapshots, disabling")
+		return
+	}
+	triedb := bc.stateCache.TrieDB()
+	p, err := pruner.NewOnlinePruner(bc.db, triedb, bc.snaps, pruner.DefaultOnlineConfig)
+	if err != nil {
+		log.Warn("Failed to create online state pruner", "err", err)
+		return
+	}
+	triedb.SetFlushCallback(p.Mark)
+	bc.pruning = &onlinePruning{pruner: p}
+
+	bc.wg.Add(1)
+	go bc.pruneLoop()
+}
+
+// pruneLoop runs a state pruning cycle in every configured interval.
+func (bc *BlockChain) pruneLoop() {
+	defer bc.wg.Done()
+
+	timer := time.NewTimer(onlinePruneRetry)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-timer.C:
+		case <-bc.quit:
+			return
+		}
+		wait := bc.cacheConfig.OnlinePruneInterval
+		if wait < onlinePruneRetry {
+			wait = onlinePruneRetry
+		}
+		if err := bc.pruneState(); err != nil {
+			log.Warn("Online state pruning failed", "err", err)
+		}
+		timer.Reset(wait)
+	}
+}
+
+// pruneState prepares a pruning target on top of the current head and runs a
+// pruning cycle on it.
+func (bc *BlockChain) pruneState() error {
+	// Pin the head state against imports and garbage collection until the recent
+	// roots are referenced. A stopped chain refuses the lock, ending the loop.
+	if !bc.chainmu.TryLock() {
+		return errChainStopped
+	}
+	triedb := bc.stateCache.TrieDB()
+
+	target, roots, err := bc.pruning.pruner.Prepare(bc.CurrentBlock().Root())
+	if err != nil {
+		bc.chainmu.Unlock()
+		log.Debug("Online state pruning postponed", "reason", err)
+		return nil
+	}
+	// Persist the target, so that the chain can be restarted on top of a
+	// complete state if the node crashes while the older ones are pruned.
+	if err := triedb.Commit(target, false, nil); err != nil {
+		bc.chainmu.Unlock()
+		return err
+	}
+	// Retain the recent states until the trie paths modified by them are
+	// marked, they might be garbage collected otherwise.
+	for _, root := range roots {
+		triedb.Reference(root, common.Hash{})
+	}
+	bc.pruning.lock.Lock()
+	bc.pruning.pinned = append(bc.pruning.pinned, roots...)
+	bc.pruning.lock.Unlock()
+	bc.chainmu.Unlock()
+
+	return bc.pruning.pruner.Prune(bc.pruning.release)
+}
+
+// dereferencePrunedStates releases the states which are no longer needed by the
+// online pruner. If all is set, the still pinned ones are released too.
+//
+// This function must be called with the chain mutex held, or after the chain
+// is stopped.
+func (bc *BlockChain) dereferencePrunedStates(all bool) {
+	if bc.pruning == nil {
+		return
+	}
+	bc.pruning.lock.Lock()
+	roots := bc.pruning.released
+	if all {
+		roots = append(roots, bc.pruning.pinned...)
+		bc.pruning.pinned = nil
+	}
+	bc.pruning.released = nil
+	bc.pruning.lock.Unlock()
+
+	triedb := bc.stateCache.TrieDB()
+	for _, root := range roots {
+		triedb.Dereference(root)
+	}
+}
+
+// PruneStatus returns the progress of the online state pruner.
+func (bc *BlockChain) PruneStatus() (pruner.OnlineStatus, error) {
+	if bc.pruning == nil {
+		return pruner.OnlineStatus{}, errPruningDisabled
+	}
+	return bc.pruning.pruner.Status(), nil
+}
+
+// PausePruning suspends the online state pruner.
+func (bc *BlockChain) PausePruning() error {
+	if bc.pruning == nil {
+		return errPruningDisabled
+	}
+	bc.pruning.pruner.Pause()
+	return nil
+}
+
+// ResumePruning continues the suspended online state pruner.
+func (bc *BlockChain) ResumePruning() error {
+	if bc.pruning == nil {
+		return errPruningDisabled
+	}
+	bc.pruning.pruner.Resume()
+	return nil
+}
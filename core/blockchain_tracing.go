@@ -0,0 +1,331 @@
+// Copyright 2022 The go-ETX Authors
+// This file is part of the go-ETX library.
+//
+// The go-ETX library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ETX library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ETX library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"encoding/json"
+	"math/big"
+	"time"
+
+	"github.com/ETX/go-ETX/common"
+	"github.com/ETX/go-ETX/common/lru"
+	"github.com/ETX/go-ETX/core/rawdb"
+	"github.com/ETX/go-ETX/core/tracing"
+	"github.com/ETX/go-ETX/core/types"
+	"github.com/ETX/go-ETX/core/vm"
+	"github.com/ETX/go-ETX/etxdb"
+	"github.com/ETX/go-ETX/log"
+)
+
+// liveTraceCacheLimit is the number of traces of processed, but not (yet)
+// canonical blocks retained until they are either made canonical or evicted.
+const liveTraceCacheLimit = 256
+
+// LiveTxTracer is a tracer attached to a single transaction executed during
+// block import. Its result is collected as soon as the transaction finishes.
+type LiveTxTracer interface {
+	vm.EVMLogger
+	GetResult() (json.RawMessage, error)
+}
+
+// LiveTracerFactory creates the tracer of a transaction of a block being
+// imported, identified by its index in the block.
+type LiveTracerFactory func(block *types.Block, index int) (LiveTxTracer, error)
+
+// TxTrace is the live trace of a single transaction.
+type TxTrace struct {
+	TxHash common.Hash     `json:"txHash"`
+	Result json.RawMessage `json:"result,omitempty"`
+	Error  string          `json:"error,omitempty"`
+}
+
+// BlockTrace is the live trace of all the transactions of a block.
+type BlockTrace struct {
+	Number     uint64      `json:"number"`
+	Hash       common.Hash `json:"hash"`
+	ParentHash common.Hash `json:"parentHash"`
+	Traces     []*TxTrace  `json:"traces"`
+}
+
+// LiveTraceSink receives the traces produced during block import. The metxods
+// are invoked synchronously from the import, sinks are expected to be fast and
+// buffer internally if needed.
+type LiveTraceSink interface {
+	// OnBlock is called with the traces of a block which became canonical.
+	OnBlock(trace *BlockTrace) error
+
+	// OnRevert is called for every block dropped from the canonical chain by a
+	// reorg or a rewind, highest first. The block might not have been passed to OnBlock if
+	// it was imported before live tracing was enabled.
+	OnRevert(number uint64, hash common.Hash) error
+
+	// Close releases the resources held by the sink.
+	Close() error
+}
+
+// liveTracing runs a tracer inline during block import and feeds the traces
+// of the blocks becoming canonical into a sink.
+type liveTracing struct {
+	factory LiveTracerFactory
+	sink    LiveTraceSink
+	pending *lru.Cache[common.Hash, *BlockTrace] // Traces of processed blocks not yet emitted
+}
+
+// SetLiveTracer configures the tracer to run for every transaction executed
+// during block import, and the sink to hand the per-block traces over to. It
+// should be called before the import starts, blocks processed earlier are not
+// traced. The sink is closed when the chain is stopped.
+func (bc *BlockChain) SetLiveTracer(factory LiveTracerFactory, sink LiveTraceSink) error {
+	// Swap the tracer in between block imports, so that no block is traced only
+	// partially and no trace is handed to an already closed sink
+	if !bc.chainmu.TryLock() {
+		return errChainStopped
+	}
+	defer bc.chainmu.Unlock()
+
+	if bc.live != nil {
+		bc.live.sink.Close()
+	}
+	bc.live = &liveTracing{
+		factory: factory,
+		sink:    sink,
+		pending: lru.NewCache[common.Hash, *BlockTrace](liveTraceCacheLimit),
+	}
+	return nil
+}
+
+// newTracer returns the tracer to process the given block with, or nil if live
+// tracing is disabled.
+func (l *liveTracing) newTracer(block *types.Block) *blockTracer {
+	if l == nil {
+		return nil
+	}
+	return newBlockTracer(block, l.factory)
+}
+
+// processed stashes the traces of a successfully processed block until it
+// becomes canonical.
+func (l *liveTracing) processed(tracer *blockTracer) {
+	if l == nil || tracer == nil {
+		return
+	}
+	trace := tracer.result()
+	l.pending.Add(trace.Hash, trace)
+}
+
+// canonical emits the traces of a block which became canonical.
+func (l *liveTracing) canonical(block *types.Block) {
+	if l == nil {
+		return
+	}
+	trace, ok := l.pending.Get(block.Hash())
+	if !ok {
+		// Blocks not processed by the chain (e.g. locally mined ones or ones
+		// processed before a restart) don't have a trace
+		log.Debug("Missing live trace of canonical block", "number", block.Number(), "hash", block.Hash())
+		return
+	}
+	l.pending.Remove(block.Hash())
+
+	if err := l.sink.OnBlock(trace); err != nil {
+		log.Warn("Failed to emit live block trace", "number", block.Number(), "hash", block.Hash(), "err", err)
+	}
+}
+
+// reverted emits the revert notification of a block dropped from the canonical
+// chain.
+func (l *liveTracing) reverted(block *types.Block) {
+	if l == nil {
+		return
+	}
+	if err := l.sink.OnRevert(block.NumberU64(), block.Hash()); err != nil {
+		log.Warn("Failed to emit live trace revert", "number", block.Number(), "hash", block.Hash(), "err", err)
+	}
+}
+
+// rewound emits the revert notifications of the blocks dropped from the canonical
+// chain by a Setxead, highest first. The canonical hashes deleted by the rewind
+// are taken from the dropped set, the others are still in the database.
+func (l *liveTracing) rewound(db etxdb.Reader, oldHead, newHead uint64, dropped map[uint64]common.Hash) {
+	if l == nil {
+		return
+	}
+	for number := oldHead; number > newHead; number-- {
+		hash, ok := dropped[number]
+		if !ok {
+			hash = rawdb.ReadCanonicalHash(db, number)
+		}
+		if hash == (common.Hash{}) {
+			continue
+		}
+		if err := l.sink.OnRevert(number, hash); err != nil {
+			log.Warn("Failed to emit live trace revert", "number", number, "hash", hash, "err", err)
+		}
+	}
+}
+
+// close releases the sink.
+func (l *liveTracing) close() {
+	if l == nil {
+		return
+	}
+	if err := l.sink.Close(); err != nil {
+		log.Warn("Failed to close live trace sink", "err", err)
+	}
+}
+
+// blockTracer is an EVMLogger running a fresh transaction tracer for every
+// transaction of a block and collecting their results.
+type blockTracer struct {
+	block   *types.Block
+	factory LiveTracerFactory
+	current LiveTxTracer // Tracer of the transaction being executed
+	traces  []*TxTrace
+}
+
+func newBlockTracer(block *types.Block, factory LiveTracerFactory) *blockTracer {
+	return &blockTracer{
+		block:   block,
+		factory: factory,
+		traces:  make([]*TxTrace, 0, len(block.Transactions())),
+	}
+}
+
+// result returns the collected traces of the block.
+func (t *blockTracer) result() *BlockTrace {
+	return &BlockTrace{
+		Number:     t.block.NumberU64(),
+		Hash:       t.block.Hash(),
+		ParentHash: t.block.ParentHash(),
+		Traces:     t.traces,
+	}
+}
+
+// CaptureTxStart creates the tracer of the next transaction of the block. The
+// transactions are executed in block order, one at a time.
+func (t *blockTracer) CaptureTxStart(gasLimit uint64) {
+	var (
+		index = len(t.traces)
+		trace = new(TxTrace)
+		txs   = t.block.Transactions()
+	)
+	if index < len(txs) {
+		trace.TxHash = txs[index].Hash()
+	}
+	t.traces = append(t.traces, trace)
+
+	tracer, err := t.factory(t.block, index)
+	if err != nil {
+		trace.Error = err.Error()
+		t.current = nil
+		return
+	}
+	t.current = tracer
+	t.current.CaptureTxStart(gasLimit)
+}
+
+// CaptureTxEnd finalizes the tracer of the current transaction and collects
+// its result.
+func (t *blockTracer) CaptureTxEnd(restGas uint64) {
+	if t.current == nil {
+		return
+	}
+	t.current.CaptureTxEnd(restGas)
+
+	trace := t.traces[len(t.traces)-1]
+	if res, err := t.current.GetResult(); err != nil {
+		trace.Error = err.Error()
+	} else {
+		trace.Result = res
+	}
+	t.current = nil
+}
+
+func (t *blockTracer) CaptureStart(env *vm.EVM, from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) {
+	if t.current != nil {
+		t.current.CaptureStart(env, from, to, create, input, gas, value)
+	}
+}
+
+func (t *blockTracer) CaptureEnd(output []byte, gasUsed uint64, elapsed time.Duration, err error) {
+	if t.current != nil {
+		t.current.CaptureEnd(output, gasUsed, elapsed, err)
+	}
+}
+
+func (t *blockTracer) CaptureEnter(typ vm.OpCode, from common.Address, to common.Address, input []byte, gas uint64, value *big.Int) {
+	if t.current != nil {
+		t.current.CaptureEnter(typ, from, to, input, gas, value)
+	}
+}
+
+func (t *blockTracer) CaptureExit(output []byte, gasUsed uint64, err error) {
+	if t.current != nil {
+		t.current.CaptureExit(output, gasUsed, err)
+	}
+}
+
+func (t *blockTracer) CaptureState(pc uint64, op vm.OpCode, gas, cost uint64, scope *vm.ScopeContext, rData []byte, depth int, err error) {
+	if t.current != nil {
+		t.current.CaptureState(pc, op, gas, cost, scope, rData, depth, err)
+	}
+}
+
+func (t *blockTracer) CaptureFault(pc uint64, op vm.OpCode, gas, cost uint64, scope *vm.ScopeContext, depth int, err error) {
+	if t.current != nil {
+		t.current.CaptureFault(pc, op, gas, cost, scope, depth, err)
+	}
+}
+
+// stateLogger returns the tracer of the current transaction if it's interested
+// in state changes.
+func (t *blockTracer) stateLogger() tracing.StateLogger {
+	if logger, ok := t.current.(tracing.StateLogger); ok {
+		return logger
+	}
+	return nil
+}
+
+func (t *blockTracer) OnBalanceChange(addr common.Address, prev, new *big.Int, reason tracing.BalanceChangeReason) {
+	if logger := t.stateLogger(); logger != nil {
+		logger.OnBalanceChange(addr, prev, new, reason)
+	}
+}
+
+func (t *blockTracer) OnNonceChange(addr common.Address, prev, new uint64) {
+	if logger := t.stateLogger(); logger != nil {
+		logger.OnNonceChange(addr, prev, new)
+	}
+}
+
+func (t *blockTracer) OnCodeChange(addr common.Address, prevCodeHash common.Hash, prevCode []byte, codeHash common.Hash, code []byte) {
+	if logger := t.stateLogger(); logger != nil {
+		logger.OnCodeChange(addr, prevCodeHash, prevCode, codeHash, code)
+	}
+}
+
+func (t *blockTracer) OnStorageChange(addr common.Address, slot common.Hash, prev, new common.Hash) {
+	if logger := t.stateLogger(); logger != nil {
+		logger.OnStorageChange(addr, slot, prev, new)
+	}
+}
+
+func (t *blockTracer) OnLog(log *types.Log) {
+	if logger := t.stateLogger(); logger != nil {
+		logger.OnLog(log)
+	}
+}
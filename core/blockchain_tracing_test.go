@@ -0,0 +1,146 @@
+// Copyright 2022 The go-ETX Authors
+// This file is part of the go-ETX library.
+//
+// The go-ETX library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ETX library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ETX library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"fmt"
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/ETX/go-ETX/common"
+	"github.com/ETX/go-ETX/consensus/etxash"
+	"github.com/ETX/go-ETX/core/rawdb"
+	"github.com/ETX/go-ETX/core/types"
+	"github.com/ETX/go-ETX/core/vm"
+	"github.com/ETX/go-ETX/crypto"
+	"github.com/ETX/go-ETX/etx/tracers/logger"
+	"github.com/ETX/go-ETX/params"
+)
+
+// testLiveSink records the events emitted by the live tracing.
+type testLiveSink struct {
+	events []string
+	traces map[common.Hash]*BlockTrace
+	closed bool
+}
+
+func (s *testLiveSink) OnBlock(trace *BlockTrace) error {
+	s.events = append(s.events, fmt.Sprintf("block %d %x", trace.Number, trace.Hash))
+	s.traces[trace.Hash] = trace
+	return nil
+}
+
+func (s *testLiveSink) OnRevert(number uint64, hash common.Hash) error {
+	s.events = append(s.events, fmt.Sprintf("revert %d %x", number, hash))
+	return nil
+}
+
+func (s *testLiveSink) Close() error {
+	s.closed = true
+	return nil
+}
+
+// Tests that the live tracer runs during block import, emitting the traces of
+// the canonical blocks and revert notifications for the reorged and rewound
+// blocks.
+func TestLiveTracing(t *testing.T) {
+	var (
+		key, _ = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		addr   = crypto.PubkeyToAddress(key.PublicKey)
+		gspec  = &Genesis{Config: params.TestChainConfig, Alloc: GenesisAlloc{addr: {Balance: big.NewInt(params.etxer)}}}
+		signer = types.LatestSigner(gspec.Config)
+		sink   = &testLiveSink{traces: make(map[common.Hash]*BlockTrace)}
+	)
+	chain, err := NewBlockChain(rawdb.NewMemoryDatabase(), nil, gspec, nil, etxash.NewFaker(), vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create tester chain: %v", err)
+	}
+	factory := func(block *types.Block, index int) (LiveTxTracer, error) {
+		return logger.NewStructLogger(nil), nil
+	}
+	if err := chain.SetLiveTracer(factory, sink); err != nil {
+		t.Fatalf("failed to set live tracer: %v", err)
+	}
+	// Import a chain with transactions and check the emitted traces
+	_, blocks, _ := GenerateChainWithGenesis(gspec, etxash.NewFaker(), 3, func(i int, gen *BlockGen) {
+		for j := 0; j <= i; j++ {
+			tx, _ := types.SignTx(types.NewTransaction(gen.TxNonce(addr), common.Address{0xaa}, big.NewInt(1), params.TxGas, gen.header.BaseFee, nil), signer, key)
+			gen.AddTx(tx)
+		}
+	})
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	var want []string
+	for _, block := range blocks {
+		want = append(want, fmt.Sprintf("block %d %x", block.NumberU64(), block.Hash()))
+
+		trace := sink.traces[block.Hash()]
+		if trace == nil {
+			t.Fatalf("block %d: missing trace", block.NumberU64())
+		}
+		if trace.ParentHash != block.ParentHash() {
+			t.Errorf("block %d: parent hash mismatch: have %x, want %x", block.NumberU64(), trace.ParentHash, block.ParentHash())
+		}
+		if len(trace.Traces) != len(block.Transactions()) {
+			t.Fatalf("block %d: trace count mismatch: have %d, want %d", block.NumberU64(), len(trace.Traces), len(block.Transactions()))
+		}
+		for i, tx := range block.Transactions() {
+			if trace.Traces[i].TxHash != tx.Hash() {
+				t.Errorf("block %d, tx %d: hash mismatch: have %x, want %x", block.NumberU64(), i, trace.Traces[i].TxHash, tx.Hash())
+			}
+			if trace.Traces[i].Error != "" || len(trace.Traces[i].Result) == 0 {
+				t.Errorf("block %d, tx %d: missing result: %v", block.NumberU64(), i, trace.Traces[i].Error)
+			}
+		}
+	}
+	if !reflect.DeepEqual(sink.events, want) {
+		t.Fatalf("events mismatch: have %v, want %v", sink.events, want)
+	}
+	// Reorg to a longer chain and ensure the dropped blocks are reverted first,
+	// highest one first
+	_, forks, _ := GenerateChainWithGenesis(gspec, etxash.NewFaker(), 4, func(i int, gen *BlockGen) {
+		gen.SetCoinbase(common.Address{0xbb})
+	})
+	if _, err := chain.InsertChain(forks); err != nil {
+		t.Fatalf("failed to insert fork: %v", err)
+	}
+	for i := len(blocks) - 1; i >= 0; i-- {
+		want = append(want, fmt.Sprintf("revert %d %x", blocks[i].NumberU64(), blocks[i].Hash()))
+	}
+	for _, block := range forks {
+		want = append(want, fmt.Sprintf("block %d %x", block.NumberU64(), block.Hash()))
+	}
+	if !reflect.DeepEqual(sink.events, want) {
+		t.Fatalf("events mismatch after reorg:\nhave %v\nwant %v", sink.events, want)
+	}
+	// Rewind the chain and ensure the dropped blocks are reverted too
+	if err := chain.Setxead(2); err != nil {
+		t.Fatalf("failed to rewind chain: %v", err)
+	}
+	for i := len(forks) - 1; i >= 2; i-- {
+		want = append(want, fmt.Sprintf("revert %d %x", forks[i].NumberU64(), forks[i].Hash()))
+	}
+	if !reflect.DeepEqual(sink.events, want) {
+		t.Fatalf("events mismatch after rewind:\nhave %v\nwant %v", sink.events, want)
+	}
+	chain.Stop()
+	if !sink.closed {
+		t.Errorf("sink not closed on stop")
+	}
+}
@@ -18,6 +18,7 @@ package core
 
 import (
 	"errors"
+	"fmt"
 
 	"github.com/ETX/go-ETX/core/types"
 )
@@ -97,3 +98,19 @@ var (
 	// ErrSenderNoEOA is returned if the sender of a transaction is a contract.
 	ErrSenderNoEOA = errors.New("sender not an eoa")
 )
+
+// PrunedHistoryError is returned if the body or the receipts of a block were
+// requested, which are below the history retained by the node.
+type PrunedHistoryError struct {
+	Number uint64 // Number of the requested block
+	Tail   uint64 // Number of the oldest block with a retained history
+}
+
+func (e *PrunedHistoryError) Error() string {
+	return fmt.Sprintf("pruned history unavailable: block #%d is below the retained history tail #%d", e.Number, e.Tail)
+}
+
+// ErrorCode returns the JSON-RPC error code reported for pruned history.
+func (e *PrunedHistoryError) ErrorCode() int {
+	return 4444
+}
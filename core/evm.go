@@ -21,6 +21,7 @@ import (
 
 	"github.com/ETX/go-ETX/common"
 	"github.com/ETX/go-ETX/consensus"
+	"github.com/ETX/go-ETX/core/tracing"
 	"github.com/ETX/go-ETX/core/types"
 	"github.com/ETX/go-ETX/core/vm"
 )
@@ -124,6 +125,6 @@ func CanTransfer(db vm.StateDB, addr common.Address, amount *big.Int) bool {
 
 // Transfer subtracts amount from sender and adds amount to recipient using the given Db
 func Transfer(db vm.StateDB, sender, recipient common.Address, amount *big.Int) {
-	db.SubBalance(sender, amount)
-	db.AddBalance(recipient, amount)
+	db.SubBalance(sender, amount, tracing.BalanceChangeTransfer)
+	db.AddBalance(recipient, amount, tracing.BalanceChangeTransfer)
 }
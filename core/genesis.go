@@ -30,6 +30,7 @@ import (
 	"github.com/ETX/go-ETX/common/math"
 	"github.com/ETX/go-ETX/core/rawdb"
 	"github.com/ETX/go-ETX/core/state"
+	"github.com/ETX/go-ETX/core/tracing"
 	"github.com/ETX/go-ETX/core/types"
 	"github.com/ETX/go-ETX/crypto"
 	"github.com/ETX/go-ETX/etxdb"
@@ -125,7 +126,7 @@ func (ga *GenesisAlloc) deriveHash() (common.Hash, error) {
 		return common.Hash{}, err
 	}
 	for addr, account := range *ga {
-		statedb.AddBalance(addr, account.Balance)
+		statedb.AddBalance(addr, account.Balance, tracing.BalanceIncreaseGenesisBalance)
 		statedb.SetCode(addr, account.Code)
 		statedb.SetNonce(addr, account.Nonce)
 		for key, value := range account.Storage {
@@ -138,13 +139,13 @@ func (ga *GenesisAlloc) deriveHash() (common.Hash, error) {
 // flush is very similar with deriveHash, but the main difference is
 // all the generated states will be persisted into the given database.
 // Also, the genesis state specification will be flushed as well.
-func (ga *GenesisAlloc) flush(db etxdb.Database) error {
-	statedb, err := state.New(common.Hash{}, state.NewDatabaseWithConfig(db, &trie.Config{Preimages: true}), nil)
+func (ga *GenesisAlloc) flush(db etxdb.Database, triedb *trie.Database) error {
+	statedb, err := state.New(common.Hash{}, state.NewDatabaseWithNodeDB(db, triedb), nil)
 	if err != nil {
 		return err
 	}
 	for addr, account := range *ga {
-		statedb.AddBalance(addr, account.Balance)
+		statedb.AddBalance(addr, account.Balance, tracing.BalanceIncreaseGenesisBalance)
 		statedb.SetCode(addr, account.Code)
 		statedb.SetNonce(addr, account.Nonce)
 		for key, value := range account.Storage {
@@ -155,7 +156,7 @@ func (ga *GenesisAlloc) flush(db etxdb.Database) error {
 	if err != nil {
 		return err
 	}
-	err = statedb.Database().TrieDB().Commit(root, true, nil)
+	err = triedb.Commit(root, true, nil)
 	if err != nil {
 		return err
 	}
@@ -170,7 +171,7 @@ func (ga *GenesisAlloc) flush(db etxdb.Database) error {
 
 // CommitGenesisState loads the stored genesis state with the given block
 // hash and commits them into the given database handler.
-func CommitGenesisState(db etxdb.Database, hash common.Hash) error {
+func CommitGenesisState(db etxdb.Database, triedb *trie.Database, hash common.Hash) error {
 	var alloc GenesisAlloc
 	blob := rawdb.ReadGenesisStateSpec(db, hash)
 	if len(blob) != 0 {
@@ -202,7 +203,7 @@ func CommitGenesisState(db etxdb.Database, hash common.Hash) error {
 			return errors.New("not found")
 		}
 	}
-	return alloc.flush(db)
+	return alloc.flush(db, triedb)
 }
 
 // GenesisAccount is an account in the state of the genesis block.
@@ -285,10 +286,10 @@ type ChainOverrides struct {
 //
 // The returned chain configuration is never nil.
 func SetupGenesisBlock(db etxdb.Database, genesis *Genesis) (*params.ChainConfig, common.Hash, error) {
-	return SetupGenesisBlockWithOverride(db, genesis, nil)
+	return SetupGenesisBlockWithOverride(db, newGenesisTrieDB(db), genesis, nil)
 }
 
-func SetupGenesisBlockWithOverride(db etxdb.Database, genesis *Genesis, overrides *ChainOverrides) (*params.ChainConfig, common.Hash, error) {
+func SetupGenesisBlockWithOverride(db etxdb.Database, triedb *trie.Database, genesis *Genesis, overrides *ChainOverrides) (*params.ChainConfig, common.Hash, error) {
 	if genesis != nil && genesis.Config == nil {
 		return params.AlletxashProtocolChanges, common.Hash{}, errGenesisNoConfig
 	}
@@ -313,7 +314,7 @@ func SetupGenesisBlockWithOverride(db etxdb.Database, genesis *Genesis, override
 		} else {
 			log.Info("Writing custom genesis block")
 		}
-		block, err := genesis.Commit(db)
+		block, err := genesis.commit(db, triedb)
 		if err != nil {
 			return genesis.Config, common.Hash{}, err
 		}
@@ -323,7 +324,7 @@ func SetupGenesisBlockWithOverride(db etxdb.Database, genesis *Genesis, override
 	// We have the genesis block in database(perhaps in ancient database)
 	// but the corresponding state is missing.
 	header := rawdb.ReadHeader(db, stored, 0)
-	if _, err := state.New(header.Root, state.NewDatabaseWithConfig(db, nil), nil); err != nil {
+	if _, err := state.New(header.Root, state.NewDatabaseWithNodeDB(db, triedb), nil); err != nil {
 		if genesis == nil {
 			genesis = DefaultGenesisBlock()
 		}
@@ -332,7 +333,7 @@ func SetupGenesisBlockWithOverride(db etxdb.Database, genesis *Genesis, override
 		if hash != stored {
 			return genesis.Config, hash, &GenesisMismatchError{stored, hash}
 		}
-		block, err := genesis.Commit(db)
+		block, err := genesis.commit(db, triedb)
 		if err != nil {
 			return genesis.Config, hash, err
 		}
@@ -481,6 +482,13 @@ func (g *Genesis) ToBlock() *types.Block {
 // Commit writes the block and state of a genesis specification to the database.
 // The block is committed as the canonical head block.
 func (g *Genesis) Commit(db etxdb.Database) (*types.Block, error) {
+	return g.commit(db, nil)
+}
+
+// commit writes the block and state of a genesis specification to the database,
+// flushing the state through the given trie database. If no trie database is
+// provided, one matching the scheme of the stored state is opened.
+func (g *Genesis) commit(db etxdb.Database, triedb *trie.Database) (*types.Block, error) {
 	block := g.ToBlock()
 	if block.Number().Sign() != 0 {
 		return nil, errors.New("can't commit genesis block with number > 0")
@@ -498,7 +506,10 @@ func (g *Genesis) Commit(db etxdb.Database) (*types.Block, error) {
 	// All the checks has passed, flush the states derived from the genesis
 	// specification as well as the specification itself into the provided
 	// database.
-	if err := g.Alloc.flush(db); err != nil {
+	if triedb == nil {
+		triedb = newGenesisTrieDB(db)
+	}
+	if err := g.Alloc.flush(db, triedb); err != nil {
 		return nil, err
 	}
 	rawdb.WriteTd(db, block.Hash(), block.NumberU64(), block.Difficulty())
@@ -522,6 +533,17 @@ func (g *Genesis) MustCommit(db etxdb.Database) *types.Block {
 	return block
 }
 
+// newGenesisTrieDB opens a trie database on top of the given database, using
+// the scheme of the state already stored in it, or the hash-based scheme for
+// an empty database.
+func newGenesisTrieDB(db etxdb.Database) *trie.Database {
+	config := &trie.Config{Preimages: true}
+	if rawdb.ReadStateScheme(db) == rawdb.PathScheme {
+		config.PathDB = &trie.PathConfig{}
+	}
+	return trie.NewDatabaseWithConfig(db, config)
+}
+
 // DefaultGenesisBlock returns the ETX main net genesis block.
 func DefaultGenesisBlock() *Genesis {
 	return &Genesis{
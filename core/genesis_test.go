@@ -28,6 +28,7 @@ import (
 	"github.com/ETX/go-ETX/core/vm"
 	"github.com/ETX/go-ETX/etxdb"
 	"github.com/ETX/go-ETX/params"
+	"github.com/ETX/go-ETX/trie"
 )
 
 func TestInvalidCliqueConfig(t *testing.T) {
@@ -219,7 +220,7 @@ func TestReadWriteGenesisAlloc(t *testing.T) {
 		}
 		hash, _ = alloc.deriveHash()
 	)
-	alloc.flush(db)
+	alloc.flush(db, trie.NewDatabaseWithConfig(db, &trie.Config{Preimages: true}))
 
 	var reload GenesisAlloc
 	err := reload.UnmarshalJSON(rawdb.ReadGenesisStateSpec(db, hash))
@@ -239,3 +240,35 @@ func TestReadWriteGenesisAlloc(t *testing.T) {
 		}
 	}
 }
+
+func TestSetupGenesisPathScheme(t *testing.T) {
+	var (
+		db      = rawdb.NewMemoryDatabase()
+		genesis = &Genesis{
+			Config: params.TestChainConfig,
+			Alloc: GenesisAlloc{
+				{1}: {Balance: big.NewInt(1), Storage: map[common.Hash]common.Hash{{1}: {1}}},
+			},
+		}
+		triedb = trie.NewDatabaseWithConfig(db, &trie.Config{PathDB: &trie.PathConfig{}})
+	)
+	_, hash, err := SetupGenesisBlockWithOverride(db, triedb, genesis, nil)
+	if err != nil {
+		t.Fatalf("Failed to setup genesis: %v", err)
+	}
+	if scheme := rawdb.ReadStateScheme(db); scheme != rawdb.PathScheme {
+		t.Fatalf("Unexpected state scheme, want %s, got %s", rawdb.PathScheme, scheme)
+	}
+	// Reopen the database, the stored genesis state must be picked up
+	// in the path-based scheme rather than being committed again.
+	_, hash2, err := SetupGenesisBlock(db, genesis)
+	if err != nil {
+		t.Fatalf("Failed to reload genesis: %v", err)
+	}
+	if hash != hash2 {
+		t.Fatalf("Genesis hash mismatch, want %x, got %x", hash, hash2)
+	}
+	if rawdb.ReadTrieNode(db, genesis.ToBlock().Root()) != nil {
+		t.Fatal("Genesis state stored in hash-based scheme")
+	}
+}
@@ -444,9 +444,12 @@ func ReadBodyRLP(db etxdb.Reader, hash common.Hash, number uint64) rlp.RawValue
 		// Check if the data is in ancients
 		if isCanon(reader, number, hash) {
 			data, _ = reader.Ancient(chainFreezerBodiesTable, number)
-			return nil
+			if len(data) > 0 {
+				return nil
+			}
 		}
-		// If not, try reading from leveldb
+		// If not, or if the history was expired from the ancients,
+		// try reading from leveldb
 		data, _ = db.Get(blockBodyKey(number, hash))
 		return nil
 	})
@@ -462,10 +465,14 @@ func ReadCanonicalBodyRLP(db etxdb.Reader, number uint64) rlp.RawValue {
 		if len(data) > 0 {
 			return nil
 		}
-		// Block is not in ancients, read from leveldb by hash and number.
+		// Block is not in ancients or was expired from there, read from
+		// leveldb by hash and number.
 		// Note: ReadCanonicalHash cannot be used here because it also
 		// calls ReadAncients internally.
-		hash, _ := db.Get(headerHashKey(number))
+		hash, _ := reader.Ancient(chainFreezerHashTable, number)
+		if len(hash) == 0 {
+			hash, _ = db.Get(headerHashKey(number))
+		}
 		data, _ = db.Get(blockBodyKey(number, common.BytesToHash(hash)))
 		return nil
 	})
@@ -482,7 +489,9 @@ func WriteBodyRLP(db etxdb.KeyValueWriter, hash common.Hash, number uint64, rlp
 // HasBody verifies the existence of a block body corresponding to the hash.
 func HasBody(db etxdb.Reader, hash common.Hash, number uint64) bool {
 	if isCanon(db, number, hash) {
-		return true
+		if has, _ := db.HasAncient(chainFreezerBodiesTable, number); has {
+			return true
+		}
 	}
 	if has, err := db.Has(blockBodyKey(number, hash)); !has || err != nil {
 		return false
@@ -572,7 +581,9 @@ func DeleteTd(db etxdb.KeyValueWriter, hash common.Hash, number uint64) {
 // to a block.
 func HasReceipts(db etxdb.Reader, hash common.Hash, number uint64) bool {
 	if isCanon(db, number, hash) {
-		return true
+		if has, _ := db.HasAncient(chainFreezerReceiptTable, number); has {
+			return true
+		}
 	}
 	if has, err := db.Has(blockReceiptsKey(number, hash)); !has || err != nil {
 		return false
@@ -587,9 +598,12 @@ func ReadReceiptsRLP(db etxdb.Reader, hash common.Hash, number uint64) rlp.RawVa
 		// Check if the data is in ancients
 		if isCanon(reader, number, hash) {
 			data, _ = reader.Ancient(chainFreezerReceiptTable, number)
-			return nil
+			if len(data) > 0 {
+				return nil
+			}
 		}
-		// If not, try reading from leveldb
+		// If not, or if the history was expired from the ancients,
+		// try reading from leveldb
 		data, _ = db.Get(blockReceiptsKey(number, hash))
 		return nil
 	})
@@ -655,7 +669,13 @@ func WriteReceipts(db etxdb.KeyValueWriter, hash common.Hash, number uint64, rec
 		log.Crit("Failed to encode block receipts", "err", err)
 	}
 	// Store the flattened receipt slice
-	if err := db.Put(blockReceiptsKey(number, hash), bytes); err != nil {
+	WriteReceiptsRLP(db, hash, number, bytes)
+}
+
+// WriteReceiptsRLP stores the RLP encoded storage receipts of a block into the
+// database.
+func WriteReceiptsRLP(db etxdb.KeyValueWriter, hash common.Hash, number uint64, rlp rlp.RawValue) {
+	if err := db.Put(blockReceiptsKey(number, hash), rlp); err != nil {
 		log.Crit("Failed to store block receipts", "err", err)
 	}
 }
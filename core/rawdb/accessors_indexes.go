@@ -175,3 +175,45 @@ func DeleteBloombits(db etxdb.Database, bit uint, from uint64, to uint64) {
 		log.Crit("Failed to delete bloom bits", "err", it.Error())
 	}
 }
+
+// txTraceEntry is the database representation of a persisted transaction trace.
+type txTraceEntry struct {
+	BlockHash common.Hash
+	Result    []byte
+}
+
+// ReadTxTrace retrieves the persisted output of the tracer identified by the
+// given id for a transaction, along with the hash of the block it was traced
+// in. The block hash is needed to discard traces made obsolete by a reorg.
+func ReadTxTrace(db etxdb.KeyValueReader, tracer common.Hash, hash common.Hash) (common.Hash, []byte) {
+	data, _ := db.Get(txTraceKey(tracer, hash))
+	if len(data) == 0 {
+		return common.Hash{}, nil
+	}
+	var entry txTraceEntry
+	if err := rlp.DecodeBytes(data, &entry); err != nil {
+		log.Error("Invalid transaction trace RLP", "hash", hash, "err", err)
+		return common.Hash{}, nil
+	}
+	return entry.BlockHash, entry.Result
+}
+
+// WriteTxTrace stores the output of the tracer identified by the given id for
+// a transaction executed in the given block.
+func WriteTxTrace(db etxdb.KeyValueWriter, tracer common.Hash, hash common.Hash, blockHash common.Hash, result []byte) {
+	data, err := rlp.EncodeToBytes(&txTraceEntry{BlockHash: blockHash, Result: result})
+	if err != nil {
+		log.Crit("Failed to encode transaction trace", "err", err)
+	}
+	if err := db.Put(txTraceKey(tracer, hash), data); err != nil {
+		log.Crit("Failed to store transaction trace", "err", err)
+	}
+}
+
+// DeleteTxTrace removes the persisted output of the tracer identified by the
+// given id for a transaction.
+func DeleteTxTrace(db etxdb.KeyValueWriter, tracer common.Hash, hash common.Hash) {
+	if err := db.Delete(txTraceKey(tracer, hash)); err != nil {
+		log.Crit("Failed to delete transaction trace", "err", err)
+	}
+}
@@ -177,3 +177,29 @@ func TestDeleteBloomBits(t *testing.T) {
 	check(1, 1, params.MainnetGenesisHash, true)
 	check(1, 1, params.RinkebyGenesisHash, true)
 }
+
+func TestTxTraceStorage(t *testing.T) {
+	db := NewMemoryDatabase()
+
+	var (
+		callTracer  = common.HexToHash("0x01")
+		otherTracer = common.HexToHash("0x02")
+		txHash      = common.HexToHash("0x1234")
+		blockHash   = common.HexToHash("0xabcd")
+		result      = []byte(`{"type":"CALL"}`)
+	)
+	if hash, blob := ReadTxTrace(db, callTracer, txHash); hash != (common.Hash{}) || blob != nil {
+		t.Fatalf("non existent trace returned")
+	}
+	WriteTxTrace(db, callTracer, txHash, blockHash, result)
+	if hash, blob := ReadTxTrace(db, callTracer, txHash); hash != blockHash || !bytes.Equal(blob, result) {
+		t.Fatalf("trace mismatch: have %x/%s, want %x/%s", hash, blob, blockHash, result)
+	}
+	if _, blob := ReadTxTrace(db, otherTracer, txHash); blob != nil {
+		t.Fatalf("trace returned for a different tracer")
+	}
+	DeleteTxTrace(db, callTracer, txHash)
+	if _, blob := ReadTxTrace(db, callTracer, txHash); blob != nil {
+		t.Fatalf("deleted trace returned")
+	}
+}
@@ -0,0 +1,229 @@
+// Copyright 2022 The go-ETX Authors
+// This file is part of the go-ETX library.
+//
+// The go-ETX library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ETX library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ETX library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/ETX/go-ETX/common"
+	"github.com/ETX/go-ETX/crypto"
+	"github.com/ETX/go-ETX/etxdb"
+	"github.com/ETX/go-ETX/log"
+)
+
+// The list of state schemes supported by the trie database.
+const (
+	// HashScheme is the legacy state scheme, in which trie nodes are keyed by
+	// their hash. Stale nodes can only be reclaimed by offline pruning.
+	HashScheme = "hash"
+
+	// PathScheme is the state scheme in which trie nodes are keyed by their
+	// owner and path. Only the latest persisted state is kept on disk, since
+	// every trie node is overwritten in place.
+	PathScheme = "path"
+)
+
+// ReadAccountTrieNode retrieves the account trie node and the associated node
+// hash with the specified node path.
+func ReadAccountTrieNode(db etxdb.KeyValueReader, path []byte) ([]byte, common.Hash) {
+	data, err := db.Get(accountTrieNodeKey(path))
+	if err != nil {
+		return nil, common.Hash{}
+	}
+	return data, crypto.Keccak256Hash(data)
+}
+
+// HasAccountTrieNode checks the account trie node presence with the specified
+// node path and the associated node hash.
+func HasAccountTrieNode(db etxdb.KeyValueReader, path []byte, hash common.Hash) bool {
+	data, hash2 := ReadAccountTrieNode(db, path)
+	if len(data) == 0 {
+		return false
+	}
+	return hash == hash2
+}
+
+// WriteAccountTrieNode writes the provided account trie node into database.
+func WriteAccountTrieNode(db etxdb.KeyValueWriter, path []byte, node []byte) {
+	if err := db.Put(accountTrieNodeKey(path), node); err != nil {
+		log.Crit("Failed to store account trie node", "err", err)
+	}
+}
+
+// DeleteAccountTrieNode deletes the specified account trie node from the database.
+func DeleteAccountTrieNode(db etxdb.KeyValueWriter, path []byte) {
+	if err := db.Delete(accountTrieNodeKey(path)); err != nil {
+		log.Crit("Failed to delete account trie node", "err", err)
+	}
+}
+
+// ReadStorageTrieNode retrieves the storage trie node and the associated node
+// hash with the specified node path.
+func ReadStorageTrieNode(db etxdb.KeyValueReader, accountHash common.Hash, path []byte) ([]byte, common.Hash) {
+	data, err := db.Get(storageTrieNodeKey(accountHash, path))
+	if err != nil {
+		return nil, common.Hash{}
+	}
+	return data, crypto.Keccak256Hash(data)
+}
+
+// HasStorageTrieNode checks the storage trie node presence with the provided
+// node path and the associated node hash.
+func HasStorageTrieNode(db etxdb.KeyValueReader, accountHash common.Hash, path []byte, hash common.Hash) bool {
+	data, hash2 := ReadStorageTrieNode(db, accountHash, path)
+	if len(data) == 0 {
+		return false
+	}
+	return hash == hash2
+}
+
+// WriteStorageTrieNode writes the provided storage trie node into database.
+func WriteStorageTrieNode(db etxdb.KeyValueWriter, accountHash common.Hash, path []byte, node []byte) {
+	if err := db.Put(storageTrieNodeKey(accountHash, path), node); err != nil {
+		log.Crit("Failed to store storage trie node", "err", err)
+	}
+}
+
+// DeleteStorageTrieNode deletes the specified storage trie node from the database.
+func DeleteStorageTrieNode(db etxdb.KeyValueWriter, accountHash common.Hash, path []byte) {
+	if err := db.Delete(storageTrieNodeKey(accountHash, path)); err != nil {
+		log.Crit("Failed to delete storage trie node", "err", err)
+	}
+}
+
+// ReadStateScheme reads the state scheme of persistent state, or none
+// if the state is not present in database.
+func ReadStateScheme(db etxdb.Reader) string {
+	// Check if state in path-based scheme is present
+	blob, _ := ReadAccountTrieNode(db, nil)
+	if len(blob) != 0 {
+		return PathScheme
+	}
+	// In a hash-based scheme, the genesis state is consistently stored
+	// on the disk. To assess the scheme of the persistent state, it
+	// suffices to inspect the scheme of the genesis state.
+	header := ReadHeader(db, ReadCanonicalHash(db, 0), 0)
+	if header == nil {
+		return "" // empty datadir
+	}
+	blob = ReadTrieNode(db, header.Root)
+	if len(blob) == 0 {
+		return "" // no state in disk
+	}
+	return HashScheme
+}
+
+// ParseStateScheme checks if the specified state scheme is compatible with
+// the stored state. If the scheme is not specified, the stored one is used,
+// or the hash-based scheme for an empty database.
+func ParseStateScheme(provided string, disk etxdb.Database) (string, error) {
+	if provided != "" && provided != HashScheme && provided != PathScheme {
+		return "", fmt.Errorf("unknown state scheme %q", provided)
+	}
+	stored := ReadStateScheme(disk)
+	if provided == "" {
+		if stored == "" {
+			return HashScheme, nil
+		}
+		return stored, nil
+	}
+	if stored == "" || provided == stored {
+		return provided, nil
+	}
+	return "", fmt.Errorf("incompatible state scheme, stored: %s, provided: %s", stored, provided)
+}
+
+// ReadPersistentStateID retrieves the id of the persistent state from the database.
+func ReadPersistentStateID(db etxdb.KeyValueReader) uint64 {
+	data, _ := db.Get(persistentStateIDKey)
+	if len(data) != 8 {
+		return 0
+	}
+	return binary.BigEndian.Uint64(data)
+}
+
+// WritePersistentStateID stores the id of the persistent state into database.
+func WritePersistentStateID(db etxdb.KeyValueWriter, number uint64) {
+	if err := db.Put(persistentStateIDKey, encodeBlockNumber(number)); err != nil {
+		log.Crit("Failed to store the persistent state ID", "err", err)
+	}
+}
+
+// ReadStateID retrieves the state id with the provided state root.
+func ReadStateID(db etxdb.KeyValueReader, root common.Hash) *uint64 {
+	data, err := db.Get(stateIDKey(root))
+	if err != nil || len(data) == 0 {
+		return nil
+	}
+	number := binary.BigEndian.Uint64(data)
+	return &number
+}
+
+// WriteStateID writes the provided state lookup to database.
+func WriteStateID(db etxdb.KeyValueWriter, root common.Hash, id uint64) {
+	var buff [8]byte
+	binary.BigEndian.PutUint64(buff[:], id)
+	if err := db.Put(stateIDKey(root), buff[:]); err != nil {
+		log.Crit("Failed to store state ID", "err", err)
+	}
+}
+
+// DeleteStateID deletes the specified state lookup from the database.
+func DeleteStateID(db etxdb.KeyValueWriter, root common.Hash) {
+	if err := db.Delete(stateIDKey(root)); err != nil {
+		log.Crit("Failed to delete state ID", "err", err)
+	}
+}
+
+// ReadReverseDiff retrieves the RLP-encoded reverse diff which reverts the
+// state with the given id to its parent.
+func ReadReverseDiff(db etxdb.KeyValueReader, id uint64) []byte {
+	data, _ := db.Get(reverseDiffKey(id))
+	return data
+}
+
+// WriteReverseDiff writes the provided reverse diff to database.
+func WriteReverseDiff(db etxdb.KeyValueWriter, id uint64, blob []byte) {
+	if err := db.Put(reverseDiffKey(id), blob); err != nil {
+		log.Crit("Failed to store reverse diff", "err", err)
+	}
+}
+
+// DeleteReverseDiff deletes the specified reverse diff from the database.
+func DeleteReverseDiff(db etxdb.KeyValueWriter, id uint64) {
+	if err := db.Delete(reverseDiffKey(id)); err != nil {
+		log.Crit("Failed to delete reverse diff", "err", err)
+	}
+}
+
+// ReadStateHistoryTail retrieves the id of the newest pruned reverse diff,
+// all the reverse diffs up to and including it are gone.
+func ReadStateHistoryTail(db etxdb.KeyValueReader) uint64 {
+	data, _ := db.Get(stateHistoryTailKey)
+	if len(data) != 8 {
+		return 0
+	}
+	return binary.BigEndian.Uint64(data)
+}
+
+// WriteStateHistoryTail stores the id of the newest pruned reverse diff.
+func WriteStateHistoryTail(db etxdb.KeyValueWriter, id uint64) {
+	if err := db.Put(stateHistoryTailKey, encodeBlockNumber(id)); err != nil {
+		log.Crit("Failed to store the state history tail", "err", err)
+	}
+}
@@ -44,6 +44,14 @@ var chainFreezerNoSnappy = map[string]bool{
 	chainFreezerDifficultyTable: true,
 }
 
+// chainFreezerPrunable lists the ancient-tables which may be truncated at the
+// tail to expire old history. Headers, hashes and difficulties are retained in
+// full, so that the chain can still be verified.
+var chainFreezerPrunable = map[string]bool{
+	chainFreezerBodiesTable:  true,
+	chainFreezerReceiptTable: true,
+}
+
 // The list of identifiers of ancient stores.
 var (
 	chainFreezerName = "chain" // the folder name of chain segment ancient store.
@@ -18,13 +18,22 @@ package rawdb
 
 import (
 	"fmt"
+	"math"
+	"math/big"
+	"sort"
+	"sync/atomic"
+	"time"
 
 	"github.com/ETX/go-ETX/common"
+	"github.com/ETX/go-ETX/core/types"
 	"github.com/ETX/go-ETX/etxdb"
+	"github.com/ETX/go-ETX/log"
+	"github.com/ETX/go-ETX/rlp"
 )
 
 type tableSize struct {
 	name string
+	dir  string // The directory of the table, empty if unknown
 	size common.StorageSize
 }
 
@@ -50,6 +59,28 @@ func (info *freezerInfo) size() common.StorageSize {
 	return total
 }
 
+// locations returns the storage size of the freezer per directory, sorted by
+// the directory names. Tables of unknown location are omitted.
+func (info *freezerInfo) locations() ([]string, []common.StorageSize) {
+	sizes := make(map[string]common.StorageSize)
+	for _, table := range info.sizes {
+		if table.dir != "" {
+			sizes[table.dir] += table.size
+		}
+	}
+	dirs := make([]string, 0, len(sizes))
+	for dir := range sizes {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+
+	totals := make([]common.StorageSize, len(dirs))
+	for i, dir := range dirs {
+		totals[i] = sizes[dir]
+	}
+	return dirs, totals
+}
+
 // inspectFreezers inspects all freezers registered in the system.
 func inspectFreezers(db etxdb.Database) ([]freezerInfo, error) {
 	var infos []freezerInfo
@@ -59,13 +90,21 @@ func inspectFreezers(db etxdb.Database) ([]freezerInfo, error) {
 			// Chain ancient store is a bit special. It's always opened along
 			// with the key-value store, inspect the chain store directly.
 			info := freezerInfo{name: freezer}
+
+			// Resolve the table locations, unknown for remote databases.
+			layout := make(map[string]string)
+			if ancient, err := db.AncientDatadir(); err == nil {
+				if layout, err = readFreezerLayout(resolveChainFreezerDir(ancient), chainFreezerNoSnappy); err != nil {
+					return nil, err
+				}
+			}
 			// Retrieve storage size of every contained table.
 			for table := range chainFreezerNoSnappy {
 				size, err := db.AncientSize(table)
 				if err != nil {
 					return nil, err
 				}
-				info.sizes = append(info.sizes, tableSize{name: table, size: common.StorageSize(size)})
+				info.sizes = append(info.sizes, tableSize{name: table, dir: layout[table], size: common.StorageSize(size)})
 			}
 			// Retrieve the number of last stored item
 			ancients, err := db.Ancients()
@@ -112,10 +151,170 @@ func InspectFreezerTable(ancient string, freezerName string, tableName string, s
 		}
 		return fmt.Errorf("unknown table, supported ones: %v", names)
 	}
-	table, err := newFreezerTable(path, tableName, noSnappy, true)
+	layout, err := readFreezerLayout(path, tables)
+	if err != nil {
+		return err
+	}
+	table, err := newFreezerTable(layout[tableName], tableName, noSnappy, true)
 	if err != nil {
 		return err
 	}
 	table.dumpIndexStdout(start, end)
 	return nil
 }
+
+// CorruptRange is a range of consecutive chain freezer items failing the
+// integrity verification.
+type CorruptRange struct {
+	First uint64 // Number of the first corrupt item
+	Last  uint64 // Number of the last corrupt item
+	Err   error  // Failure of the first corrupt item
+}
+
+// VerifyChainFreezer checks the integrity of every item in the chain freezer.
+// The passed ancient indicates the path of root ancient directory where the
+// chain freezer can be opened. Stored items are validated against their
+// checksums and snappy framing, then RLP decoded and checked for the hash
+// linkage between the canonical hashes and the headers. The optional check
+// callback further validates the bodies and receipts against their header,
+// it is only invoked if neither of them was pruned. Consecutive failing items
+// are merged into ranges.
+func VerifyChainFreezer(ancient string, check func(header *types.Header, body, receipts []byte) error) ([]CorruptRange, error) {
+	layout, err := readFreezerLayout(resolveChainFreezerDir(ancient), chainFreezerNoSnappy)
+	if err != nil {
+		return nil, err
+	}
+	tables := make(map[string]*freezerTable)
+	defer func() {
+		for _, table := range tables {
+			table.Close()
+		}
+	}()
+	for name, noSnappy := range chainFreezerNoSnappy {
+		table, err := newFreezerTable(layout[name], name, noSnappy, true)
+		if err != nil {
+			return nil, err
+		}
+		tables[name] = table
+	}
+	// The tables should be of equal length, but they are opened without the
+	// freezer repairing them. Verify the range every one of them covers.
+	var tail, head uint64 = math.MaxUint64, math.MaxUint64
+	for _, table := range tables {
+		if items := atomic.LoadUint64(&table.items); items < head {
+			head = items
+		}
+		if hidden := atomic.LoadUint64(&table.itemHidden); hidden < tail {
+			tail = hidden
+		}
+	}
+	var (
+		corrupt []CorruptRange
+		parent  common.Hash // Hash of the last verified header, if it's the parent
+		start   = time.Now()
+		logged  = time.Now()
+	)
+	for number := tail; number < head; {
+		count := head - number
+		if count > 1024 {
+			count = 1024
+		}
+		var (
+			items = make(map[string][][]byte)
+			errs  = make([]error, count)
+		)
+		for name, table := range tables {
+			// Tables pruned at the tail are verified from their own tail
+			first := atomic.LoadUint64(&table.itemHidden)
+			if first < number {
+				first = number
+			}
+			items[name] = make([][]byte, count)
+			if first >= number+count {
+				continue
+			}
+			blobs, failures := table.verifyItems(first, number+count-first)
+			for i, failure := range failures {
+				n := first - number + uint64(i)
+				if failure != nil && errs[n] == nil {
+					errs[n] = fmt.Errorf("%s: %w", name, failure)
+				}
+				items[name][n] = blobs[i]
+			}
+		}
+		for i := uint64(0); i < count; i++ {
+			err := errs[i]
+			if err == nil {
+				var hash common.Hash
+				if hash, err = verifyFrozenBlock(number+i, parent, items, i, check); err == nil {
+					parent = hash
+				}
+			}
+			if err != nil {
+				parent = common.Hash{}
+				if n := len(corrupt); n > 0 && corrupt[n-1].Last+1 == number+i {
+					corrupt[n-1].Last = number + i
+				} else {
+					corrupt = append(corrupt, CorruptRange{First: number + i, Last: number + i, Err: err})
+				}
+			}
+		}
+		number += count
+
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Verifying chain freezer", "number", number, "head", head, "corrupt", len(corrupt), "elapsed", common.PrettyDuration(time.Since(start)))
+			logged = time.Now()
+		}
+	}
+	log.Info("Verified chain freezer", "tail", tail, "head", head, "corrupt", len(corrupt), "elapsed", common.PrettyDuration(time.Since(start)))
+	return corrupt, nil
+}
+
+// verifyFrozenBlock checks the decoded chain freezer items of a single number,
+// returning the verified header hash. An empty parent hash skips the check of
+// the linkage to the previous header.
+func verifyFrozenBlock(number uint64, parent common.Hash, items map[string][][]byte, index uint64, check func(*types.Header, []byte, []byte) error) (common.Hash, error) {
+	blob := items[chainFreezerHashTable][index]
+	if len(blob) != common.HashLength {
+		return common.Hash{}, fmt.Errorf("invalid canonical hash length %d", len(blob))
+	}
+	hash := common.BytesToHash(blob)
+
+	header := new(types.Header)
+	if err := rlp.DecodeBytes(items[chainFreezerHeaderTable][index], header); err != nil {
+		return common.Hash{}, fmt.Errorf("invalid header: %v", err)
+	}
+	if header.Number == nil || header.Number.Uint64() != number {
+		return common.Hash{}, fmt.Errorf("header number mismatch: have %v, want %d", header.Number, number)
+	}
+	if have := header.Hash(); have != hash {
+		return common.Hash{}, fmt.Errorf("header hash mismatch: have %x, want %x", have, hash)
+	}
+	if parent != (common.Hash{}) && header.ParentHash != parent {
+		return common.Hash{}, fmt.Errorf("parent hash mismatch: have %x, want %x", header.ParentHash, parent)
+	}
+	td := new(big.Int)
+	if err := rlp.DecodeBytes(items[chainFreezerDifficultyTable][index], td); err != nil {
+		return common.Hash{}, fmt.Errorf("invalid total difficulty: %v", err)
+	}
+	var (
+		body     = items[chainFreezerBodiesTable][index]
+		receipts = items[chainFreezerReceiptTable][index]
+	)
+	if body != nil {
+		if err := rlp.DecodeBytes(body, new(types.Body)); err != nil {
+			return common.Hash{}, fmt.Errorf("invalid body: %v", err)
+		}
+	}
+	if receipts != nil {
+		if err := rlp.DecodeBytes(receipts, new([]*types.ReceiptForStorage)); err != nil {
+			return common.Hash{}, fmt.Errorf("invalid receipts: %v", err)
+		}
+	}
+	if check != nil && body != nil && receipts != nil {
+		if err := check(header, body, receipts); err != nil {
+			return common.Hash{}, err
+		}
+	}
+	return hash, nil
+}
@@ -0,0 +1,532 @@
+// Copyright 2022 The go-ETX Authors
+// This file is part of the go-ETX library.
+//
+// The go-ETX library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ETX library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ETX library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ETX/go-ETX/common"
+	"github.com/ETX/go-ETX/crypto"
+	"github.com/ETX/go-ETX/etxdb"
+	"github.com/ETX/go-ETX/log"
+	"github.com/ETX/go-ETX/rlp"
+	"github.com/golang/snappy"
+)
+
+// A backup repository is a directory holding any number of database backups,
+// each of them described by a manifest file. The data is stored in content
+// addressed files shared between the backups, so every backup only writes the
+// data changed since the previous one:
+//
+//   - The key-value store is split into segments of consecutive entries. The
+//     segment boundaries are derived from the keys, so an update only changes
+//     the segments it falls into.
+//   - The chain freezer is append-only and stored in chunks of consecutive
+//     items. The complete chunks of the previous backup are reused as long as
+//     they are still part of the freezer.
+const (
+	backupVersion = 1
+
+	// backupSegmentMask selects the keys closing a key-value segment, resulting
+	// in segments of about 4096 entries.
+	backupSegmentMask = 0xfff
+
+	// backupSegmentBytes is the size limit of a key-value segment, closing it
+	// regardless of its last key.
+	backupSegmentBytes = 16 * 1024 * 1024
+
+	// backupChunkItems is the number of freezer items stored in a chunk. Only
+	// the last chunk of a backup may be shorter.
+	backupChunkItems = 8192
+
+	backupSegmentDir = "segments"
+	backupChunkDir   = "ancients"
+)
+
+var (
+	// errBackupNotEmpty is returned if a backup is restored into a database which
+	// already holds a chain.
+	errBackupNotEmpty = errors.New("database not empty")
+
+	// ErrBackupAborted is returned if a backup is aborted before completion. The
+	// data already written is reused by the next backup.
+	ErrBackupAborted = errors.New("backup aborted")
+)
+
+// BackupChunk references a range of chain freezer items stored in a backup.
+type BackupChunk struct {
+	Start uint64      `json:"start"`
+	Count uint64      `json:"count"`
+	Hash  common.Hash `json:"hash"`
+	Last  common.Hash `json:"last"` // Canonical hash of the last block in the chunk
+}
+
+// BackupManifest describes a single backup in a backup repository.
+type BackupManifest struct {
+	Version  uint64        `json:"version"`
+	Time     uint64        `json:"time"`             // Unix time of the backup
+	Parent   string        `json:"parent,omitempty"` // Name of the backup this one was built upon
+	Tables   []string      `json:"tables"`           // Freezer tables, in the order stored in the chunks
+	Ancients uint64        `json:"ancients"`         // Number of frozen items
+	Tail     uint64        `json:"tail"`             // Number of the first item of the prunable tables
+	Chunks   []BackupChunk `json:"chunks"`           // Chunks of the frozen items
+	Segments []common.Hash `json:"segments"`         // Segments of the key-value store
+}
+
+// BackupStats contains the amount of data written by a backup.
+type BackupStats struct {
+	Name           string `json:"name"`           // Name of the created backup
+	Segments       int    `json:"segments"`       // Total number of key-value segments
+	SegmentsStored int    `json:"segmentsStored"` // Number of key-value segments written
+	Chunks         int    `json:"chunks"`         // Total number of freezer chunks
+	ChunksStored   int    `json:"chunksStored"`   // Number of freezer chunks written
+	Bytes          uint64 `json:"bytes"`          // Number of bytes written
+}
+
+// backupEntry is a single key-value pair stored in a segment.
+type backupEntry struct {
+	Key   []byte
+	Value []byte
+}
+
+// Backup writes a consistent backup of the database into the repository at
+// the given directory, storing only the data missing from the previous backups.
+// It is safe to use on a database in use: the key-value store is read through
+// a snapshot and the freezer up to the number of items frozen when the snapshot
+// was taken. Items frozen later are still part of the snapshot.
+//
+// Closing the abort channel stops the backup without writing the manifest, nil
+// never aborts.
+func Backup(db etxdb.Database, dir string, abort <-chan struct{}) (*BackupStats, error) {
+	for _, sub := range []string{backupSegmentDir, backupChunkDir} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0755); err != nil {
+			return nil, err
+		}
+	}
+	parent, err := LatestBackup(dir)
+	if err != nil {
+		return nil, err
+	}
+	// Take the snapshot first and read the freezer afterwards. Items frozen in
+	// between are both in the snapshot and the freezer, but none is missing.
+	snap, err := db.NewSnapshot()
+	if err != nil {
+		return nil, err
+	}
+	defer snap.Release()
+
+	var (
+		start    = time.Now()
+		stats    = new(BackupStats)
+		manifest = &BackupManifest{
+			Version: backupVersion,
+			Time:    uint64(start.Unix()),
+			Tables:  backupTables(),
+		}
+	)
+	if manifest.Ancients, err = db.Ancients(); err != nil && !errors.Is(err, errNotSupported) {
+		return nil, err
+	}
+	if manifest.Tail, err = db.Tail(); err != nil && !errors.Is(err, errNotSupported) {
+		return nil, err
+	}
+	var reuse []BackupChunk
+	if parent != "" {
+		prev, err := ReadBackupManifest(dir, parent)
+		if err != nil {
+			return nil, err
+		}
+		manifest.Parent = parent
+		if strings.Join(prev.Tables, ",") == strings.Join(manifest.Tables, ",") {
+			reuse = prev.Chunks
+		}
+	}
+	if err := backupAncients(db, dir, manifest, reuse, stats, abort); err != nil {
+		return nil, err
+	}
+	if err := backupKeyValues(snap, dir, manifest, stats, abort); err != nil {
+		return nil, err
+	}
+	stats.Name = time.Unix(int64(manifest.Time), 0).UTC().Format("backup-20060102-150405")
+	if common.FileExist(backupManifestPath(dir, stats.Name)) {
+		return nil, fmt.Errorf("backup %s already exists", stats.Name)
+	}
+	blob, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := writeBackupFile(backupManifestPath(dir, stats.Name), blob); err != nil {
+		return nil, err
+	}
+	log.Info("Created database backup", "name", stats.Name, "parent", parent, "ancients", manifest.Ancients,
+		"segments", stats.Segments, "segwritten", stats.SegmentsStored, "chunks", stats.Chunks, "chunkwritten", stats.ChunksStored,
+		"size", common.StorageSize(stats.Bytes), "elapsed", common.PrettyDuration(time.Since(start)))
+	return stats, nil
+}
+
+// backupTables returns the chain freezer tables in the order stored in the chunks.
+func backupTables() []string {
+	tables := make([]string, 0, len(chainFreezerNoSnappy))
+	for name := range chainFreezerNoSnappy {
+		tables = append(tables, name)
+	}
+	sort.Strings(tables)
+	return tables
+}
+
+// backupAncients stores the frozen items in chunks, reusing the complete chunks
+// of the previous backup which are still part of the freezer. A chunk is only
+// reused if the canonical hash of its last block is unchanged, so chunks of a
+// freezer rewound and refilled with another chain since are stored anew.
+func backupAncients(db etxdb.Database, dir string, manifest *BackupManifest, reuse []BackupChunk, stats *BackupStats, abort <-chan struct{}) error {
+	var (
+		next   uint64
+		logged = time.Now()
+	)
+	hashIndex := sort.SearchStrings(manifest.Tables, chainFreezerHashTable)
+	for _, chunk := range reuse {
+		if chunk.Start != next || chunk.Count != backupChunkItems || chunk.Start+chunk.Count > manifest.Ancients {
+			break
+		}
+		last, err := db.Ancient(chainFreezerHashTable, chunk.Start+chunk.Count-1)
+		if err != nil {
+			return err
+		}
+		if common.BytesToHash(last) != chunk.Last {
+			break
+		}
+		manifest.Chunks = append(manifest.Chunks, chunk)
+		next += chunk.Count
+	}
+	for next < manifest.Ancients {
+		select {
+		case <-abort:
+			return ErrBackupAborted
+		default:
+		}
+		count := uint64(backupChunkItems)
+		if next+count > manifest.Ancients {
+			count = manifest.Ancients - next
+		}
+		items, err := readBackupChunk(db, manifest.Tables, manifest.Tail, next, count)
+		if err != nil {
+			return err
+		}
+		hash, written, err := writeBackupBlob(dir, backupChunkDir, items)
+		if err != nil {
+			return err
+		}
+		if written > 0 {
+			stats.ChunksStored++
+			stats.Bytes += written
+		}
+		last := common.BytesToHash(items[count-1][hashIndex])
+		manifest.Chunks = append(manifest.Chunks, BackupChunk{Start: next, Count: count, Hash: hash, Last: last})
+		next += count
+
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Backing up ancient items", "number", next, "ancients", manifest.Ancients)
+			logged = time.Now()
+		}
+	}
+	stats.Chunks = len(manifest.Chunks)
+	return nil
+}
+
+// readBackupChunk retrieves the items of all the tables in the given range.
+// Items of prunable tables below the tail are left empty.
+func readBackupChunk(db etxdb.Database, tables []string, tail uint64, start, count uint64) ([][][]byte, error) {
+	items := make([][][]byte, count)
+	for i := range items {
+		items[i] = make([][]byte, len(tables))
+	}
+	for t, table := range tables {
+		from := start
+		if chainFreezerPrunable[table] && from < tail {
+			from = tail
+		}
+		for from < start+count {
+			blobs, err := db.AncientRange(table, from, start+count-from, backupSegmentBytes)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read %s #%d: %v", table, from, err)
+			}
+			for _, blob := range blobs {
+				items[from-start][t] = blob
+				from++
+			}
+		}
+	}
+	return items, nil
+}
+
+// backupKeyValues stores the key-value store of the snapshot in segments.
+func backupKeyValues(snap etxdb.Snapshot, dir string, manifest *BackupManifest, stats *BackupStats, abort <-chan struct{}) error {
+	var (
+		it      = snap.NewIterator(nil, nil)
+		segment []backupEntry
+		size    int
+		entries int
+		logged  = time.Now()
+	)
+	defer it.Release()
+
+	flush := func() error {
+		hash, written, err := writeBackupBlob(dir, backupSegmentDir, segment)
+		if err != nil {
+			return err
+		}
+		if written > 0 {
+			stats.SegmentsStored++
+			stats.Bytes += written
+		}
+		manifest.Segments = append(manifest.Segments, hash)
+		segment, size = segment[:0], 0
+		return nil
+	}
+	for it.Next() {
+		key, value := common.CopyBytes(it.Key()), common.CopyBytes(it.Value())
+		segment = append(segment, backupEntry{Key: key, Value: value})
+		size += len(key) + len(value)
+		entries++
+
+		if crc32.ChecksumIEEE(key)&backupSegmentMask == 0 || size >= backupSegmentBytes {
+			if err := flush(); err != nil {
+				return err
+			}
+			select {
+			case <-abort:
+				return ErrBackupAborted
+			default:
+			}
+		}
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Backing up key-value store", "entries", entries, "segments", len(manifest.Segments))
+			logged = time.Now()
+		}
+	}
+	if err := it.Error(); err != nil {
+		return err
+	}
+	if len(segment) > 0 {
+		if err := flush(); err != nil {
+			return err
+		}
+	}
+	stats.Segments = len(manifest.Segments)
+	return nil
+}
+
+// writeBackupBlob stores the RLP encoding of the given value in the named
+// directory of the repository, unless already present. The content hash and
+// the number of bytes written are returned.
+func writeBackupBlob(dir string, kind string, val interface{}) (common.Hash, uint64, error) {
+	blob, err := rlp.EncodeToBytes(val)
+	if err != nil {
+		return common.Hash{}, 0, err
+	}
+	hash := crypto.Keccak256Hash(blob)
+	path := filepath.Join(dir, kind, hash.Hex())
+	if common.FileExist(path) {
+		return hash, 0, nil
+	}
+	compressed := snappy.Encode(nil, blob)
+	if err := writeBackupFile(path, compressed); err != nil {
+		return common.Hash{}, 0, err
+	}
+	return hash, uint64(len(compressed)), nil
+}
+
+// readBackupBlob loads and verifies a content addressed file of the repository,
+// decoding it into the given value.
+func readBackupBlob(dir string, kind string, hash common.Hash, val interface{}) error {
+	compressed, err := os.ReadFile(filepath.Join(dir, kind, hash.Hex()))
+	if err != nil {
+		return err
+	}
+	blob, err := snappy.Decode(nil, compressed)
+	if err != nil {
+		return fmt.Errorf("corrupt backup file %s: %v", hash.Hex(), err)
+	}
+	if have := crypto.Keccak256Hash(blob); have != hash {
+		return fmt.Errorf("corrupt backup file %s: content hash %x", hash.Hex(), have)
+	}
+	return rlp.DecodeBytes(blob, val)
+}
+
+// writeBackupFile atomically writes a file of the repository.
+func writeBackupFile(path string, blob []byte) error {
+	f, err := os.OpenFile(path+".tmp", os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(blob); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+// backupManifestPath returns the path of the manifest of the named backup.
+func backupManifestPath(dir string, name string) string {
+	return filepath.Join(dir, name+".json")
+}
+
+// ListBackups returns the names of the backups in the repository, oldest first.
+func ListBackups(dir string) ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "backup-*.json"))
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, len(files))
+	for i, file := range files {
+		names[i] = strings.TrimSuffix(filepath.Base(file), ".json")
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// LatestBackup returns the name of the most recent backup in the repository,
+// or an empty string if there's none.
+func LatestBackup(dir string) (string, error) {
+	names, err := ListBackups(dir)
+	if err != nil || len(names) == 0 {
+		return "", err
+	}
+	return names[len(names)-1], nil
+}
+
+// ReadBackupManifest loads the manifest of the named backup.
+func ReadBackupManifest(dir string, name string) (*BackupManifest, error) {
+	blob, err := os.ReadFile(backupManifestPath(dir, name))
+	if err != nil {
+		return nil, err
+	}
+	manifest := new(BackupManifest)
+	if err := json.Unmarshal(blob, manifest); err != nil {
+		return nil, fmt.Errorf("invalid backup manifest %s: %v", name, err)
+	}
+	if manifest.Version != backupVersion {
+		return nil, fmt.Errorf("unsupported backup version %d", manifest.Version)
+	}
+	return manifest, nil
+}
+
+// RestoreBackup writes the named backup of the repository into an empty
+// database, the most recent one if no name is given.
+func RestoreBackup(db etxdb.Database, dir string, name string) error {
+	if name == "" {
+		latest, err := LatestBackup(dir)
+		if err != nil {
+			return err
+		}
+		if latest == "" {
+			return fmt.Errorf("no backup found in %s", dir)
+		}
+		name = latest
+	}
+	manifest, err := ReadBackupManifest(dir, name)
+	if err != nil {
+		return err
+	}
+	if frozen, _ := db.Ancients(); frozen > 0 || ReadCanonicalHash(db, 0) != (common.Hash{}) {
+		return errBackupNotEmpty
+	}
+	var (
+		start  = time.Now()
+		logged = time.Now()
+	)
+	for _, chunk := range manifest.Chunks {
+		var items [][][]byte
+		if err := readBackupBlob(dir, backupChunkDir, chunk.Hash, &items); err != nil {
+			return err
+		}
+		if uint64(len(items)) != chunk.Count {
+			return fmt.Errorf("backup chunk %x item count mismatch: have %d, want %d", chunk.Hash, len(items), chunk.Count)
+		}
+		_, err := db.ModifyAncients(func(op etxdb.AncientWriteOp) error {
+			for i, item := range items {
+				if len(item) != len(manifest.Tables) {
+					return fmt.Errorf("backup chunk %x table count mismatch", chunk.Hash)
+				}
+				for t, table := range manifest.Tables {
+					if err := op.AppendRaw(table, chunk.Start+uint64(i), item[t]); err != nil {
+						return err
+					}
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Restoring ancient items", "number", chunk.Start+chunk.Count, "ancients", manifest.Ancients)
+			logged = time.Now()
+		}
+	}
+	// Hide the prunable items below the tail, they were stored empty
+	if manifest.Tail > 0 {
+		if err := db.TruncateTail(manifest.Tail); err != nil {
+			return err
+		}
+	}
+	if err := db.Sync(); err != nil {
+		return err
+	}
+	for i, hash := range manifest.Segments {
+		var entries []backupEntry
+		if err := readBackupBlob(dir, backupSegmentDir, hash, &entries); err != nil {
+			return err
+		}
+		batch := db.NewBatch()
+		for _, entry := range entries {
+			if err := batch.Put(entry.Key, entry.Value); err != nil {
+				return err
+			}
+			if batch.ValueSize() > etxdb.IdealBatchSize {
+				if err := batch.Write(); err != nil {
+					return err
+				}
+				batch.Reset()
+			}
+		}
+		if err := batch.Write(); err != nil {
+			return err
+		}
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Restoring key-value store", "segment", i+1, "segments", len(manifest.Segments))
+			logged = time.Now()
+		}
+	}
+	log.Info("Restored database backup", "name", name, "ancients", manifest.Ancients, "segments", len(manifest.Segments), "elapsed", common.PrettyDuration(time.Since(start)))
+	return nil
+}
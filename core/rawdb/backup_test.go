@@ -0,0 +1,185 @@
+// Copyright 2022 The go-ETX Authors
+// This file is part of the go-ETX library.
+//
+// The go-ETX library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ETX library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ETX library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/ETX/go-ETX/etxdb"
+)
+
+// appendBackupItems freezes the given range of items into all the chain tables.
+func appendBackupItems(t *testing.T, db etxdb.Database, from, to uint64) {
+	t.Helper()
+	_, err := db.ModifyAncients(func(op etxdb.AncientWriteOp) error {
+		for i := from; i < to; i++ {
+			for _, table := range backupTables() {
+				if err := op.AppendRaw(table, i, []byte(fmt.Sprintf("%s-%d", table, i))); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal("failed to freeze items:", err)
+	}
+}
+
+func TestBackupRestore(t *testing.T) {
+	db, err := NewDatabaseWithFreezer(NewMemoryDatabase(), t.TempDir(), "", false)
+	if err != nil {
+		t.Fatal("failed to create database:", err)
+	}
+	defer db.Close()
+
+	appendBackupItems(t, db, 0, backupChunkItems+100)
+	for i := 0; i < 10000; i++ {
+		db.Put([]byte(fmt.Sprintf("key-%05d", i)), []byte(fmt.Sprintf("val-%d", i)))
+	}
+	dir := t.TempDir()
+	first, err := Backup(db, dir, nil)
+	if err != nil {
+		t.Fatal("failed to create backup:", err)
+	}
+	if first.ChunksStored != 2 || first.SegmentsStored != first.Segments {
+		t.Fatalf("unexpected first backup: %+v", first)
+	}
+	// Extend the chain, update a single key and back up again. Only the last,
+	// partial chunk and the changed segment should be written.
+	appendBackupItems(t, db, backupChunkItems+100, backupChunkItems+200)
+	db.Put([]byte("key-00042"), []byte("updated"))
+	if err := db.TruncateTail(10); err != nil {
+		t.Fatal("failed to truncate tail:", err)
+	}
+	// Manifest names have a resolution of one second, rename the first one
+	old, _ := ListBackups(dir)
+	renameBackup(t, dir, old[0], "backup-00000000-000000")
+
+	second, err := Backup(db, dir, nil)
+	if err != nil {
+		t.Fatal("failed to create backup:", err)
+	}
+	if second.ChunksStored != 1 {
+		t.Fatalf("chunks written mismatch: have %d, want 1", second.ChunksStored)
+	}
+	if second.SegmentsStored != 1 {
+		t.Fatalf("segments written mismatch: have %d, want 1", second.SegmentsStored)
+	}
+	// Restore the latest backup into a fresh database and compare
+	restored, err := NewDatabaseWithFreezer(NewMemoryDatabase(), t.TempDir(), "", false)
+	if err != nil {
+		t.Fatal("failed to create database:", err)
+	}
+	defer restored.Close()
+
+	if err := RestoreBackup(restored, dir, ""); err != nil {
+		t.Fatal("failed to restore backup:", err)
+	}
+	if frozen, _ := restored.Ancients(); frozen != backupChunkItems+200 {
+		t.Fatalf("ancients mismatch: have %d, want %d", frozen, backupChunkItems+200)
+	}
+	if tail, _ := restored.Tail(); tail != 10 {
+		t.Fatalf("tail mismatch: have %d, want 10", tail)
+	}
+	for _, table := range backupTables() {
+		from := uint64(0)
+		if chainFreezerPrunable[table] {
+			from = 10
+		}
+		for _, i := range []uint64{from, backupChunkItems, backupChunkItems + 199} {
+			blob, err := restored.Ancient(table, i)
+			if err != nil {
+				t.Fatalf("failed to read %s #%d: %v", table, i, err)
+			}
+			if want := fmt.Sprintf("%s-%d", table, i); string(blob) != want {
+				t.Fatalf("%s #%d mismatch: have %q, want %q", table, i, blob, want)
+			}
+		}
+	}
+	it := db.NewIterator(nil, nil)
+	defer it.Release()
+	for it.Next() {
+		val, err := restored.Get(it.Key())
+		if err != nil || !bytes.Equal(val, it.Value()) {
+			t.Fatalf("key %q mismatch: have %q, want %q (%v)", it.Key(), val, it.Value(), err)
+		}
+	}
+	// Restoring into a non-empty database must fail
+	if err := RestoreBackup(restored, dir, ""); err != errBackupNotEmpty {
+		t.Fatalf("restore into non-empty database: have %v, want %v", err, errBackupNotEmpty)
+	}
+	// Rewind the freezer into the first chunk and refill it with another chain.
+	// The first chunk must not be reused anymore.
+	if err := db.TruncateHead(backupChunkItems - 10); err != nil {
+		t.Fatal("failed to truncate head:", err)
+	}
+	_, err = db.ModifyAncients(func(op etxdb.AncientWriteOp) error {
+		for i := uint64(backupChunkItems - 10); i < backupChunkItems+200; i++ {
+			for _, table := range backupTables() {
+				if err := op.AppendRaw(table, i, []byte(fmt.Sprintf("%s-%d-reorg", table, i))); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal("failed to freeze items:", err)
+	}
+	renameBackup(t, dir, second.Name, "backup-00000000-000001")
+
+	third, err := Backup(db, dir, nil)
+	if err != nil {
+		t.Fatal("failed to create backup:", err)
+	}
+	if third.ChunksStored != 2 {
+		t.Fatalf("chunks written after reorg mismatch: have %d, want 2", third.ChunksStored)
+	}
+}
+
+// Tests that an aborted backup doesn't leave a manifest behind.
+func TestBackupAbort(t *testing.T) {
+	db, err := NewDatabaseWithFreezer(NewMemoryDatabase(), t.TempDir(), "", false)
+	if err != nil {
+		t.Fatal("failed to create database:", err)
+	}
+	defer db.Close()
+
+	appendBackupItems(t, db, 0, 100)
+
+	dir := t.TempDir()
+	abort := make(chan struct{})
+	close(abort)
+	if _, err := Backup(db, dir, abort); err != ErrBackupAborted {
+		t.Fatalf("backup error mismatch: have %v, want %v", err, ErrBackupAborted)
+	}
+	if backups, err := ListBackups(dir); err != nil || len(backups) != 0 {
+		t.Fatalf("aborted backup listed: %v, %v", backups, err)
+	}
+}
+
+// renameBackup renames a backup of the repository.
+func renameBackup(t *testing.T, dir string, from, to string) {
+	t.Helper()
+	if err := os.Rename(backupManifestPath(dir, from), backupManifestPath(dir, to)); err != nil {
+		t.Fatal(err)
+	}
+}
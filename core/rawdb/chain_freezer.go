@@ -54,9 +54,10 @@ type chainFreezer struct {
 	trigger chan chan struct{} // Manual blocking freeze trigger, test determinism
 }
 
-// newChainFreezer initializes the freezer for ancient chain data.
-func newChainFreezer(datadir string, namespace string, readonly bool, maxTableSize uint32, tables map[string]bool) (*chainFreezer, error) {
-	freezer, err := NewFreezer(datadir, namespace, readonly, maxTableSize, tables)
+// newChainFreezer initializes the freezer for ancient chain data. The dirs map
+// overrides the directories of individual tables.
+func newChainFreezer(datadir string, namespace string, readonly bool, maxTableSize uint32, tables map[string]bool, dirs map[string]string) (*chainFreezer, error) {
+	freezer, err := newFreezer(datadir, namespace, readonly, maxTableSize, tables, chainFreezerPrunable, dirs)
 	if err != nil {
 		return nil, err
 	}
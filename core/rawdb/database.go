@@ -22,11 +22,13 @@ import (
 	"fmt"
 	"os"
 	"path"
+	"path/filepath"
 	"strings"
 	"sync/atomic"
 	"time"
 
 	"github.com/ETX/go-ETX/common"
+	"github.com/ETX/go-ETX/crypto"
 	"github.com/ETX/go-ETX/etxdb"
 	"github.com/ETX/go-ETX/etxdb/leveldb"
 	"github.com/ETX/go-ETX/etxdb/memorydb"
@@ -198,8 +200,15 @@ func resolveChainFreezerDir(ancient string) string {
 // storage. The passed ancient indicates the path of root ancient directory
 // where the chain freezer can be opened.
 func NewDatabaseWithFreezer(db etxdb.KeyValueStore, ancient string, namespace string, readonly bool) (etxdb.Database, error) {
+	return newDatabaseWithFreezer(db, ancient, nil, namespace, readonly)
+}
+
+// newDatabaseWithFreezer creates a high level database on top of a given key-
+// value data store with a chain freezer, storing the tables listed in dirs in
+// the given directories instead of the ancient one.
+func newDatabaseWithFreezer(db etxdb.KeyValueStore, ancient string, dirs map[string]string, namespace string, readonly bool) (etxdb.Database, error) {
 	// Create the idle freezer instance
-	frdb, err := newChainFreezer(resolveChainFreezerDir(ancient), namespace, readonly, freezerTableSize, chainFreezerNoSnappy)
+	frdb, err := newChainFreezer(resolveChainFreezerDir(ancient), namespace, readonly, freezerTableSize, chainFreezerNoSnappy, dirs)
 	if err != nil {
 		return nil, err
 	}
@@ -322,6 +331,85 @@ func NewLevelDBDatabaseWithFreezer(file string, cache int, handles int, ancient
 	return frdb, nil
 }
 
+const (
+	dbPebble  = "pebble"
+	dbLeveldb = "leveldb"
+)
+
+// hasPreexistingDb checks the given data directory whetxer a database is already
+// instantiated at that location, and if so, returns the type of database (or the
+// empty string).
+func hasPreexistingDb(path string) string {
+	if _, err := os.Stat(filepath.Join(path, "CURRENT")); err != nil {
+		return "" // No pre-existing db
+	}
+	if matches, err := filepath.Glob(filepath.Join(path, "OPTIONS*")); len(matches) > 0 || err != nil {
+		if err != nil {
+			panic(err) // only possible if the pattern is malformed
+		}
+		return dbPebble
+	}
+	return dbLeveldb
+}
+
+// OpenOptions contains the options to apply when opening a database.
+// If AncientsDirectory is empty, it indicates that no freezer is to be used.
+type OpenOptions struct {
+	Type              string            // "leveldb" | "pebble"
+	Directory         string            // the datadir
+	AncientsDirectory string            // the ancients-dir
+	AncientTables     map[string]string // the dirs of chain freezer tables stored outside the ancients-dir
+	Namespace         string            // the namespace for database relevant metrics
+	Cache             int               // the capacity(in megabytes) of the data caching
+	Handles           int               // number of files to be open simultaneously
+	ReadOnly          bool
+}
+
+// openKeyValueDatabase opens a disk-based key-value database, e.g. leveldb or pebble.
+//
+//	                      type == null          type != null
+//	                   +----------------------------------------
+//	db is non-existent |  leveldb default  |  specified type
+//	db is existent     |  from db          |  specified type (if compatible)
+func openKeyValueDatabase(o OpenOptions) (etxdb.Database, error) {
+	existingDb := hasPreexistingDb(o.Directory)
+	if len(existingDb) != 0 && len(o.Type) != 0 && o.Type != existingDb {
+		return nil, fmt.Errorf("db.engine choice was %v but found pre-existing %v database in specified data directory", o.Type, existingDb)
+	}
+	if o.Type == dbPebble || existingDb == dbPebble {
+		if !PebbleEnabled {
+			return nil, errors.New("db.engine 'pebble' not supported on this platform")
+		}
+		log.Info("Using pebble as the backing database")
+		return NewPebbleDBDatabase(o.Directory, o.Cache, o.Handles, o.Namespace, o.ReadOnly)
+	}
+	if len(o.Type) != 0 && o.Type != dbLeveldb {
+		return nil, fmt.Errorf("unknown db.engine %v", o.Type)
+	}
+	// Use leveldb, either as default (no explicit choice), or pre-existing, or chosen explicitly
+	log.Info("Using leveldb as the backing database")
+	return NewLevelDBDatabase(o.Directory, o.Cache, o.Handles, o.Namespace, o.ReadOnly)
+}
+
+// Open opens both a disk-based key-value database such as leveldb or pebble, but also
+// integrates it with a freezer database -- if the AncientsDirectory option has been
+// set on the provided OpenOptions.
+func Open(o OpenOptions) (etxdb.Database, error) {
+	kvdb, err := openKeyValueDatabase(o)
+	if err != nil {
+		return nil, err
+	}
+	if len(o.AncientsDirectory) == 0 {
+		return kvdb, nil
+	}
+	frdb, err := newDatabaseWithFreezer(kvdb, o.AncientsDirectory, o.AncientTables, o.Namespace, o.ReadOnly)
+	if err != nil {
+		kvdb.Close()
+		return nil, err
+	}
+	return frdb, nil
+}
+
 type counter uint64
 
 func (c counter) String() string {
@@ -371,12 +459,15 @@ func InspectDatabase(db etxdb.Database, keyPrefix, keyStart []byte) error {
 		numHashPairings stat
 		hashNumPairings stat
 		tries           stat
+		pathTries       stat
+		stateHistory    stat
 		codes           stat
 		txLookups       stat
 		accountSnaps    stat
 		storageSnaps    stat
 		preimages       stat
 		bloomBits       stat
+		txTraces        stat
 		beaconHeaders   stat
 		cliqueSnaps     stat
 
@@ -411,8 +502,14 @@ func InspectDatabase(db etxdb.Database, keyPrefix, keyStart []byte) error {
 			numHashPairings.Add(size)
 		case bytes.HasPrefix(key, headerNumberPrefix) && len(key) == (len(headerNumberPrefix)+common.HashLength):
 			hashNumPairings.Add(size)
-		case len(key) == common.HashLength:
+		case len(key) == common.HashLength && bytes.Equal(key, crypto.Keccak256(it.Value())):
 			tries.Add(size)
+		case IsAccountTrieNode(key) || IsStorageTrieNode(key):
+			pathTries.Add(size)
+		case bytes.HasPrefix(key, stateIDPrefix) && len(key) == len(stateIDPrefix)+common.HashLength:
+			stateHistory.Add(size)
+		case bytes.HasPrefix(key, reverseDiffPrefix) && len(key) == len(reverseDiffPrefix)+8:
+			stateHistory.Add(size)
 		case bytes.HasPrefix(key, CodePrefix) && len(key) == len(CodePrefix)+common.HashLength:
 			codes.Add(size)
 		case bytes.HasPrefix(key, txLookupPrefix) && len(key) == (len(txLookupPrefix)+common.HashLength):
@@ -431,6 +528,10 @@ func InspectDatabase(db etxdb.Database, keyPrefix, keyStart []byte) error {
 			bloomBits.Add(size)
 		case bytes.HasPrefix(key, BloomBitsIndexPrefix):
 			bloomBits.Add(size)
+		case bytes.HasPrefix(key, txTracePrefix) && len(key) == (len(txTracePrefix)+2*common.HashLength):
+			txTraces.Add(size)
+		case bytes.HasPrefix(key, TxTraceIndexPrefix):
+			txTraces.Add(size)
 		case bytes.HasPrefix(key, skeletonHeaderPrefix) && len(key) == (len(skeletonHeaderPrefix)+8):
 			beaconHeaders.Add(size)
 		case bytes.HasPrefix(key, CliqueSnapshotPrefix) && len(key) == 7+common.HashLength:
@@ -450,6 +551,7 @@ func InspectDatabase(db etxdb.Database, keyPrefix, keyStart []byte) error {
 				lastPivotKey, fastTrieProgressKey, snapshotDisabledKey, SnapshotRootKey, snapshotJournalKey,
 				snapshotGeneratorKey, snapshotRecoveryKey, txIndexTailKey, fastTxLookupLimitKey,
 				uncleanShutdownKey, badBlockKey, transitionStatusKey, skeletonSyncStatusKey,
+				persistentStateIDKey, stateHistoryTailKey,
 			} {
 				if bytes.Equal(key, meta) {
 					metadata.Add(size)
@@ -477,8 +579,11 @@ func InspectDatabase(db etxdb.Database, keyPrefix, keyStart []byte) error {
 		{"Key-Value store", "Block hash->number", hashNumPairings.Size(), hashNumPairings.Count()},
 		{"Key-Value store", "Transaction index", txLookups.Size(), txLookups.Count()},
 		{"Key-Value store", "Bloombit index", bloomBits.Size(), bloomBits.Count()},
+		{"Key-Value store", "Transaction traces", txTraces.Size(), txTraces.Count()},
 		{"Key-Value store", "Contract codes", codes.Size(), codes.Count()},
 		{"Key-Value store", "Trie nodes", tries.Size(), tries.Count()},
+		{"Key-Value store", "Path trie nodes", pathTries.Size(), pathTries.Count()},
+		{"Key-Value store", "State history", stateHistory.Size(), stateHistory.Count()},
 		{"Key-Value store", "Trie preimages", preimages.Size(), preimages.Count()},
 		{"Key-Value store", "Account snapshot", accountSnaps.Size(), accountSnaps.Count()},
 		{"Key-Value store", "Storage snapshot", storageSnaps.Size(), storageSnaps.Count()},
@@ -502,6 +607,17 @@ func InspectDatabase(db etxdb.Database, keyPrefix, keyStart []byte) error {
 				fmt.Sprintf("%d", ancient.count()),
 			})
 		}
+		// Report the size per location if the tables are spread across directories
+		if dirs, sizes := ancient.locations(); len(dirs) > 1 {
+			for i, dir := range dirs {
+				stats = append(stats, []string{
+					fmt.Sprintf("Ancient store (%s)", strings.Title(ancient.name)),
+					fmt.Sprintf("Location %s", dir),
+					sizes[i].String(),
+					"",
+				})
+			}
+		}
 		total += ancient.size()
 	}
 	table := tablewriter.NewWriter(os.Stdout)
@@ -75,6 +75,7 @@ type Freezer struct {
 
 	readonly     bool
 	tables       map[string]*freezerTable // Data tables for storing everything
+	prunable     map[string]bool          // Tables subject to tail truncation, nil for all
 	instanceLock fileutil.Releaser        // File-system lock to prevent double opens
 	closeOnce    sync.Once
 }
@@ -85,6 +86,17 @@ type Freezer struct {
 // The 'tables' argument defines the data tables. If the value of a map
 // entry is true, snappy compression is disabled for the table.
 func NewFreezer(datadir string, namespace string, readonly bool, maxTableSize uint32, tables map[string]bool) (*Freezer, error) {
+	return newFreezer(datadir, namespace, readonly, maxTableSize, tables, nil, nil)
+}
+
+// newFreezer creates a freezer instance in which only the tables listed in
+// prunable are truncated at the tail, the others being retained in full. A
+// nil prunable set applies tail truncation to all the tables.
+//
+// The tables are stored in the datadir, apart from the ones listed in dirs or
+// recorded in the layout file of the freezer. Tables holding data can only be
+// relocated by MigrateFreezerTables.
+func newFreezer(datadir string, namespace string, readonly bool, maxTableSize uint32, tables map[string]bool, prunable map[string]bool, dirs map[string]string) (*Freezer, error) {
 	// Create the initial freezer object
 	var (
 		readMeter  = metrics.NewRegisteredMeter(namespace+"ancient/read", nil)
@@ -104,16 +116,34 @@ func NewFreezer(datadir string, namespace string, readonly bool, maxTableSize ui
 	if err != nil {
 		return nil, err
 	}
+	// Resolve the directories of the tables
+	layout, err := readFreezerLayout(datadir, tables)
+	if err != nil {
+		lock.Release()
+		return nil, err
+	}
+	wanted, err := resolveTableDirs(layout, tables, dirs)
+	if err == nil {
+		err = checkFreezerLayout(layout, wanted)
+	}
+	if err == nil && !readonly {
+		err = writeFreezerLayout(datadir, wanted)
+	}
+	if err != nil {
+		lock.Release()
+		return nil, err
+	}
 	// Open all the supported data tables
 	freezer := &Freezer{
 		readonly:     readonly,
 		tables:       make(map[string]*freezerTable),
+		prunable:     prunable,
 		instanceLock: lock,
 	}
 
 	// Create the tables.
 	for name, disableSnappy := range tables {
-		table, err := newTable(datadir, name, readMeter, writeMeter, sizeGauge, maxTableSize, disableSnappy, readonly)
+		table, err := newTable(wanted[name], name, readMeter, writeMeter, sizeGauge, maxTableSize, disableSnappy, readonly)
 		if err != nil {
 			for _, table := range freezer.tables {
 				table.Close()
@@ -203,7 +233,8 @@ func (f *Freezer) Ancients() (uint64, error) {
 	return atomic.LoadUint64(&f.frozen), nil
 }
 
-// Tail returns the number of first stored item in the freezer.
+// Tail returns the number of first stored item in the freezer. If only some
+// of the tables are prunable, the tail of those is returned.
 func (f *Freezer) Tail() (uint64, error) {
 	return atomic.LoadUint64(&f.tail), nil
 }
@@ -285,6 +316,7 @@ func (f *Freezer) TruncateHead(items uint64) error {
 }
 
 // TruncateTail discards any recent data below the provided threshold number.
+// Only the prunable tables are truncated.
 func (f *Freezer) TruncateTail(tail uint64) error {
 	if f.readonly {
 		return errReadOnly
@@ -295,7 +327,10 @@ func (f *Freezer) TruncateTail(tail uint64) error {
 	if atomic.LoadUint64(&f.tail) >= tail {
 		return nil
 	}
-	for _, table := range f.tables {
+	for kind, table := range f.tables {
+		if !f.isPrunable(kind) {
+			continue
+		}
 		if err := table.truncateTail(tail); err != nil {
 			return err
 		}
@@ -345,26 +380,35 @@ func (f *Freezer) validate() error {
 	return nil
 }
 
-// repair truncates all data tables to the same length.
+// isPrunable reports whetxer the given table is subject to tail truncation.
+func (f *Freezer) isPrunable(kind string) bool {
+	return f.prunable == nil || f.prunable[kind]
+}
+
+// repair truncates all data tables to the same length. The tails are aligned
+// within the prunable tables only.
 func (f *Freezer) repair() error {
 	var (
 		head = uint64(math.MaxUint64)
 		tail = uint64(0)
 	)
-	for _, table := range f.tables {
+	for kind, table := range f.tables {
 		items := atomic.LoadUint64(&table.items)
 		if head > items {
 			head = items
 		}
 		hidden := atomic.LoadUint64(&table.itemHidden)
-		if hidden > tail {
+		if f.isPrunable(kind) && hidden > tail {
 			tail = hidden
 		}
 	}
-	for _, table := range f.tables {
+	for kind, table := range f.tables {
 		if err := table.truncateHead(head); err != nil {
 			return err
 		}
+		if !f.isPrunable(kind) {
+			continue
+		}
 		if err := table.truncateTail(tail); err != nil {
 			return err
 		}
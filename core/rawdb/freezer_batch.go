@@ -17,6 +17,7 @@
 package rawdb
 
 import (
+	"encoding/binary"
 	"fmt"
 	"sync/atomic"
 
@@ -89,6 +90,7 @@ type freezerTableBatch struct {
 	encBuffer   writeBuffer
 	dataBuffer  []byte
 	indexBuffer []byte
+	crcBuffer   []byte
 	curItem     uint64 // expected index of next append
 	totalBytes  int64  // counts written bytes since reset
 }
@@ -107,6 +109,7 @@ func (t *freezerTable) newBatch() *freezerTableBatch {
 func (batch *freezerTableBatch) reset() {
 	batch.dataBuffer = batch.dataBuffer[:0]
 	batch.indexBuffer = batch.indexBuffer[:0]
+	batch.crcBuffer = batch.crcBuffer[:0]
 	batch.curItem = atomic.LoadUint64(&batch.t.items)
 	batch.totalBytes = 0
 }
@@ -168,6 +171,13 @@ func (batch *freezerTableBatch) appendItem(data []byte) error {
 	// Put index entry to buffer.
 	entry := indexEntry{filenum: batch.t.headId, offset: uint32(itemOffset + itemSize)}
 	batch.indexBuffer = entry.append(batch.indexBuffer)
+
+	// Put checksum to buffer.
+	if batch.t.crc != nil {
+		var sum [checksumSize]byte
+		binary.BigEndian.PutUint32(sum[:], itemChecksum(data))
+		batch.crcBuffer = append(batch.crcBuffer, sum[:]...)
+	}
 	batch.curItem++
 
 	return batch.maybeCommit()
@@ -199,6 +209,14 @@ func (batch *freezerTableBatch) commit() error {
 	indexSize := int64(len(batch.indexBuffer))
 	batch.indexBuffer = batch.indexBuffer[:0]
 
+	// Write checksums.
+	if batch.t.crc != nil {
+		if _, err := batch.t.crc.Write(batch.crcBuffer); err != nil {
+			return err
+		}
+	}
+	batch.crcBuffer = batch.crcBuffer[:0]
+
 	// Update headBytes of table.
 	batch.t.headBytes += dataSize
 	atomic.StoreUint64(&batch.t.items, batch.curItem)
@@ -0,0 +1,226 @@
+// Copyright 2022 The go-ETX Authors
+// This file is part of the go-ETX library.
+//
+// The go-ETX library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ETX library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ETX library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"os"
+
+	"github.com/golang/snappy"
+)
+
+// The checksum file is a sidecar of the freezer table, storing the crc32
+// (Castagnoli) checksum of every item as written into the data files. It starts
+// with the number of the first covered item as a big endian uint64, followed
+// by one big endian uint32 checksum per item. Items written before the file was
+// created are not covered. Tail deletion does not compact the file, the
+// checksums of deleted items are simply left unused.
+const (
+	checksumHeaderSize = 8
+	checksumSize       = 4
+
+	// verifyBatchBytes is the maximum amount of data read in one go during
+	// the verification of a freezer table.
+	verifyBatchBytes = 4 * 1024 * 1024
+)
+
+// errChecksumMismatch is returned if a stored item doesn't match its checksum.
+var errChecksumMismatch = errors.New("checksum mismatch")
+
+var checksumTable = crc32.MakeTable(crc32.Castagnoli)
+
+// itemChecksum calculates the checksum of an item in its stored form.
+func itemChecksum(data []byte) uint32 {
+	return crc32.Checksum(data, checksumTable)
+}
+
+// openChecksums opens the checksum file of the table. In read-only mode a
+// missing checksum file is tolerated, leaving the table uncovered.
+func (t *freezerTable) openChecksums(filename string) error {
+	var err error
+	if t.readonly {
+		t.crc, err = openFreezerFileForReadOnly(filename)
+		if os.IsNotExist(err) {
+			return nil
+		}
+	} else {
+		t.crc, err = openFreezerFileForAppend(filename)
+	}
+	return err
+}
+
+// repairChecksums brings the checksum file in sync with the table after a
+// potential crash. Surplus checksums are dropped and missing ones are
+// recalculated from the stored items. The caller must ensure the data files
+// are already opened and the table is not yet in use.
+func (t *freezerTable) repairChecksums() error {
+	if t.crc == nil {
+		return nil
+	}
+	stat, err := t.crc.Stat()
+	if err != nil {
+		return err
+	}
+	if stat.Size() < checksumHeaderSize {
+		if t.readonly {
+			return nil
+		}
+		// Freshly created checksum file, cover items from the current head
+		return t.resetChecksums(t.items)
+	}
+	var header [checksumHeaderSize]byte
+	if _, err := t.crc.ReadAt(header[:], 0); err != nil {
+		return err
+	}
+	t.crcFirst = binary.BigEndian.Uint64(header[:])
+	if t.readonly {
+		return nil
+	}
+	if t.crcFirst > t.items {
+		// The table was truncated below the covered range, start over
+		return t.resetChecksums(t.items)
+	}
+	covered := uint64(stat.Size()-checksumHeaderSize) / checksumSize
+	if t.crcFirst+covered > t.items {
+		t.logger.Warn("Truncating dangling checksums", "covered", t.crcFirst+covered, "items", t.items)
+		covered = t.items - t.crcFirst
+	}
+	if err := truncateFreezerFile(t.crc, checksumHeaderSize+int64(covered)*checksumSize); err != nil {
+		return err
+	}
+	// Recalculate the checksums of the items written after the last synced
+	// checksum. If they are already hidden, stop covering the table there.
+	next := t.crcFirst + covered
+	if next < t.items {
+		if next < t.itemHidden {
+			return t.resetChecksums(t.items)
+		}
+		t.logger.Warn("Recalculating missing checksums", "from", next, "items", t.items)
+		for next < t.items {
+			data, sizes, err := t.retrieveItems(next, t.items-next, verifyBatchBytes)
+			if err != nil {
+				return err
+			}
+			var (
+				buf    = make([]byte, len(sizes)*checksumSize)
+				offset int
+			)
+			for i, size := range sizes {
+				binary.BigEndian.PutUint32(buf[i*checksumSize:], itemChecksum(data[offset:offset+size]))
+				offset += size
+			}
+			if _, err := t.crc.Write(buf); err != nil {
+				return err
+			}
+			next += uint64(len(sizes))
+		}
+	}
+	return t.crc.Sync()
+}
+
+// resetChecksums discards all stored checksums and restarts the coverage from
+// the given item.
+func (t *freezerTable) resetChecksums(first uint64) error {
+	if err := truncateFreezerFile(t.crc, 0); err != nil {
+		return err
+	}
+	var header [checksumHeaderSize]byte
+	binary.BigEndian.PutUint64(header[:], first)
+	if _, err := t.crc.Write(header[:]); err != nil {
+		return err
+	}
+	t.crcFirst = first
+	return nil
+}
+
+// truncateChecksums discards the checksums of the items above the provided
+// threshold number. The caller must hold the write lock.
+func (t *freezerTable) truncateChecksums(items uint64) error {
+	if t.crc == nil {
+		return nil
+	}
+	if items < t.crcFirst {
+		return t.resetChecksums(items)
+	}
+	return truncateFreezerFile(t.crc, checksumHeaderSize+int64(items-t.crcFirst)*checksumSize)
+}
+
+// checksum returns the stored checksum of the given item, if it's covered.
+func (t *freezerTable) checksum(item uint64) (uint32, bool, error) {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	if t.crc == nil || item < t.crcFirst {
+		return 0, false, nil
+	}
+	var buf [checksumSize]byte
+	n, err := t.crc.ReadAt(buf[:], checksumHeaderSize+int64(item-t.crcFirst)*checksumSize)
+	if n < checksumSize {
+		// Not covered, e.g. written by a node unaware of checksums
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	return binary.BigEndian.Uint32(buf[:]), true, nil
+}
+
+// verifyItems retrieves count items starting from start, checking every item
+// against its stored checksum and validating its snappy framing. In contrast
+// to RetrieveItems, corrupt items don't abort the retrieval; the failure of
+// every item is returned alongside the decoded items instead.
+func (t *freezerTable) verifyItems(start, count uint64) ([][]byte, []error) {
+	var (
+		items = make([][]byte, count)
+		errs  = make([]error, count)
+	)
+	for n := uint64(0); n < count; {
+		data, sizes, err := t.retrieveItems(start+n, count-n, verifyBatchBytes)
+		if err != nil {
+			// The batch couldn't be read, narrow the failure down to an item
+			if data, sizes, err = t.retrieveItems(start+n, 1, 0); err != nil {
+				errs[n] = err
+				n++
+				continue
+			}
+		}
+		var offset int
+		for _, size := range sizes {
+			items[n], errs[n] = t.verifyItem(start+n, data[offset:offset+size])
+			offset += size
+			n++
+		}
+	}
+	return items, errs
+}
+
+// verifyItem checks a single item in its stored form and decodes it.
+func (t *freezerTable) verifyItem(item uint64, blob []byte) ([]byte, error) {
+	sum, ok, err := t.checksum(item)
+	if err != nil {
+		return nil, err
+	}
+	if ok && itemChecksum(blob) != sum {
+		return nil, errChecksumMismatch
+	}
+	if t.noCompression {
+		return blob, nil
+	}
+	return snappy.Decode(nil, blob)
+}
@@ -0,0 +1,221 @@
+// Copyright 2022 The go-ETX Authors
+// This file is part of the go-ETX library.
+//
+// The go-ETX library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ETX library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ETX library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/ETX/go-ETX/common"
+	"github.com/ETX/go-ETX/log"
+	"github.com/prometxeus/tsdb/fileutil"
+)
+
+// freezerLayoutFile is the name of the file in the freezer directory recording
+// the directories of the tables stored outside of it. Without a layout file,
+// all the tables are located in the freezer directory.
+const freezerLayoutFile = "LAYOUT"
+
+// errTableMoved is returned if the configured directory of a freezer table
+// differs from the one its data is stored in.
+var errTableMoved = errors.New("freezer table moved, run 'getx db freezer-migrate' to relocate it")
+
+// readFreezerLayout loads the directory of every table from the layout file of
+// the freezer. Tables missing from the layout are located in the freezer
+// directory.
+func readFreezerLayout(datadir string, tables map[string]bool) (map[string]string, error) {
+	layout := make(map[string]string)
+	blob, err := os.ReadFile(filepath.Join(datadir, freezerLayoutFile))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if len(blob) > 0 {
+		if err := json.Unmarshal(blob, &layout); err != nil {
+			return nil, fmt.Errorf("invalid freezer layout: %v", err)
+		}
+	}
+	for name := range tables {
+		if layout[name] == "" {
+			layout[name] = datadir
+		}
+	}
+	return layout, nil
+}
+
+// writeFreezerLayout stores the directories of the tables located outside of
+// the freezer directory. The layout file is removed if there are none.
+func writeFreezerLayout(datadir string, layout map[string]string) error {
+	external := make(map[string]string)
+	for name, dir := range layout {
+		if dir != datadir {
+			external[name] = dir
+		}
+	}
+	path := filepath.Join(datadir, freezerLayoutFile)
+	if len(external) == 0 {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	blob, err := json.MarshalIndent(external, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path+".tmp", blob, 0644); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+// resolveTableDirs returns the directory of every table, overriding the given
+// layout with the configured directories.
+func resolveTableDirs(layout map[string]string, tables map[string]bool, dirs map[string]string) (map[string]string, error) {
+	resolved := make(map[string]string)
+	for name, dir := range layout {
+		resolved[name] = dir
+	}
+	for name, dir := range dirs {
+		if _, ok := tables[name]; !ok {
+			var names []string
+			for name := range tables {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			return nil, fmt.Errorf("unknown freezer table %q, supported ones: %v", name, names)
+		}
+		if !filepath.IsAbs(dir) {
+			return nil, fmt.Errorf("freezer table %q directory %q is not absolute", name, dir)
+		}
+		resolved[name] = filepath.Clean(dir)
+	}
+	return resolved, nil
+}
+
+// tableFiles returns the files of the named table in the given directory.
+func tableFiles(dir string, name string) ([]string, error) {
+	return filepath.Glob(filepath.Join(dir, name+".*"))
+}
+
+// checkFreezerLayout ensures no table of the freezer is configured to a
+// directory other than the one it's stored in, as it would be opened empty and
+// truncate the rest of the freezer. Tables without data can be relocated freely.
+func checkFreezerLayout(layout, dirs map[string]string) error {
+	for name, dir := range dirs {
+		if layout[name] == dir {
+			continue
+		}
+		files, err := tableFiles(layout[name], name)
+		if err != nil {
+			return err
+		}
+		if len(files) > 0 {
+			return fmt.Errorf("%w: table %q stored in %s, configured in %s", errTableMoved, name, layout[name], dir)
+		}
+	}
+	return nil
+}
+
+// MigrateFreezerTables moves the tables of the chain freezer into the configured
+// directories. The passed ancient indicates the path of root ancient directory
+// where the chain freezer can be found, dirs maps table names to their new
+// directories. Tables not listed stay where they are.
+func MigrateFreezerTables(ancient string, dirs map[string]string) error {
+	datadir := resolveChainFreezerDir(ancient)
+	if err := os.MkdirAll(datadir, 0755); err != nil {
+		return err
+	}
+	lock, _, err := fileutil.Flock(filepath.Join(datadir, "FLOCK"))
+	if err != nil {
+		return err
+	}
+	defer lock.Release()
+
+	layout, err := readFreezerLayout(datadir, chainFreezerNoSnappy)
+	if err != nil {
+		return err
+	}
+	wanted, err := resolveTableDirs(layout, chainFreezerNoSnappy, dirs)
+	if err != nil {
+		return err
+	}
+	for name, dir := range wanted {
+		if layout[name] == dir {
+			continue
+		}
+		files, err := tableFiles(layout[name], name)
+		if err != nil {
+			return err
+		}
+		log.Info("Moving freezer table", "table", name, "from", layout[name], "to", dir, "files", len(files))
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+		// Copy all the files first and switch the layout over afterwards, a
+		// crash in between leaving the table at its original location.
+		for _, file := range files {
+			if err := copyTableFile(file, filepath.Join(dir, filepath.Base(file))); err != nil {
+				return err
+			}
+		}
+		layout[name] = dir
+		if err := writeFreezerLayout(datadir, layout); err != nil {
+			return err
+		}
+		for _, file := range files {
+			if err := os.Remove(file); err != nil {
+				log.Warn("Failed to remove moved freezer file", "file", file, "err", err)
+			}
+		}
+	}
+	return nil
+}
+
+// copyTableFile copies a freezer file, which may reside on another filesystem,
+// to its new location.
+func copyTableFile(src, dst string) error {
+	if common.FileExist(dst) {
+		log.Warn("Overwriting stale freezer file", "file", dst)
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst+".tmp", os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	return os.Rename(dst+".tmp", dst)
+}
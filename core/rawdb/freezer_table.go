@@ -110,10 +110,13 @@ type freezerTable struct {
 	head   *os.File            // File descriptor for the data head of the table
 	index  *os.File            // File descriptor for the indexEntry file of the table
 	meta   *os.File            // File descriptor for metadata of the table
+	crc    *os.File            // File descriptor for the item checksums of the table, nil if missing
 	files  map[uint32]*os.File // open files
 	headId uint32              // number of the currently active head file
 	tailId uint32              // number of the earliest file
 
+	crcFirst uint64 // Number of the first item covered by the checksum file
+
 	headBytes  int64         // Number of bytes written to the head file
 	readMeter  metrics.Meter // Meter for measuring the effective amount of data read
 	writeMeter metrics.Meter // Meter for measuring the effective amount of data written
@@ -182,6 +185,10 @@ func newTable(path string, name string, readMeter metrics.Meter, writeMeter metr
 		readonly:      readonly,
 		maxFileSize:   maxFilesize,
 	}
+	if err := tab.openChecksums(filepath.Join(path, fmt.Sprintf("%s.crc", name))); err != nil {
+		tab.Close()
+		return nil, err
+	}
 	if err := tab.repair(); err != nil {
 		tab.Close()
 		return nil, err
@@ -343,6 +350,10 @@ func (t *freezerTable) repair() error {
 	if err := t.preopen(); err != nil {
 		return err
 	}
+	// Sync the checksums with the repaired table
+	if err := t.repairChecksums(); err != nil {
+		return err
+	}
 	t.logger.Debug("Chain freezer table opened", "items", t.items, "size", common.StorageSize(t.headBytes))
 	return nil
 }
@@ -430,6 +441,9 @@ func (t *freezerTable) truncateHead(items uint64) error {
 	if err := truncateFreezerFile(t.head, int64(expected.offset)); err != nil {
 		return err
 	}
+	if err := t.truncateChecksums(items); err != nil {
+		return err
+	}
 	// All data files truncated, set internal counters and return
 	t.headBytes = int64(expected.offset)
 	atomic.StoreUint64(&t.items, items)
@@ -563,6 +577,12 @@ func (t *freezerTable) Close() error {
 	}
 	t.meta = nil
 
+	if t.crc != nil {
+		if err := t.crc.Close(); err != nil {
+			errs = append(errs, err)
+		}
+		t.crc = nil
+	}
 	for _, f := range t.files {
 		if err := f.Close(); err != nil {
 			errs = append(errs, err)
@@ -873,6 +893,11 @@ func (t *freezerTable) Sync() error {
 	if err := t.meta.Sync(); err != nil {
 		return err
 	}
+	if t.crc != nil {
+		if err := t.crc.Sync(); err != nil {
+			return err
+		}
+	}
 	return t.head.Sync()
 }
 
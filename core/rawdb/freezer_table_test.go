@@ -19,6 +19,7 @@ package rawdb
 import (
 	"bytes"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"math/rand"
 	"os"
@@ -364,6 +365,81 @@ func TestFreezerRepairDanglingIndex(t *testing.T) {
 	}
 }
 
+// TestFreezerChecksums tests that corrupted items are detected by their
+// checksums, and that the checksums follow head truncations and crashes.
+func TestFreezerChecksums(t *testing.T) {
+	t.Parallel()
+	rm, wm, sg := metrics.NewMeter(), metrics.NewMeter(), metrics.NewGauge()
+	fname := fmt.Sprintf("checksums-%d", rand.Uint64())
+
+	// Fill a table, drop the last checksums as if crashed and flip a byte
+	{
+		f, err := newTable(os.TempDir(), fname, rm, wm, sg, 50, true, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		writeChunks(t, f, 9, 15)
+		f.Close()
+	}
+	crcFile := filepath.Join(os.TempDir(), fmt.Sprintf("%s.crc", fname))
+	if err := assertFileSize(crcFile, checksumHeaderSize+9*checksumSize); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Truncate(crcFile, checksumHeaderSize+7*checksumSize); err != nil {
+		t.Fatal(err)
+	}
+	file, err := os.OpenFile(filepath.Join(os.TempDir(), fmt.Sprintf("%s.0001.rdat", fname)), os.O_RDWR, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.WriteAt([]byte{0xff}, 20) // item 4
+	file.Close()
+
+	// Reopen the table, the missing checksums should be recalculated
+	f, err := newTable(os.TempDir(), fname, rm, wm, sg, 50, true, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := assertFileSize(crcFile, checksumHeaderSize+9*checksumSize); err != nil {
+		t.Fatal(err)
+	}
+	items, errs := f.verifyItems(0, 9)
+	for i := range items {
+		if i == 4 {
+			if !errors.Is(errs[i], errChecksumMismatch) {
+				t.Fatalf("item %d: corruption not detected, error %v", i, errs[i])
+			}
+			continue
+		}
+		if errs[i] != nil {
+			t.Fatalf("item %d: unexpected error %v", i, errs[i])
+		}
+		if !bytes.Equal(items[i], getChunk(15, i)) {
+			t.Fatalf("item %d: content mismatch", i)
+		}
+	}
+	// Truncate below the corruption and rewrite, the checksums should follow
+	if err := f.truncateHead(4); err != nil {
+		t.Fatal(err)
+	}
+	if err := assertFileSize(crcFile, checksumHeaderSize+4*checksumSize); err != nil {
+		t.Fatal(err)
+	}
+	batch := f.newBatch()
+	for i := 4; i < 9; i++ {
+		require.NoError(t, batch.AppendRaw(uint64(i), getChunk(15, i)))
+	}
+	require.NoError(t, batch.commit())
+
+	_, errs = f.verifyItems(0, 9)
+	for i, err := range errs {
+		if err != nil {
+			t.Fatalf("item %d: unexpected error %v", i, err)
+		}
+	}
+}
+
 func TestFreezerTruncate(t *testing.T) {
 	t.Parallel()
 	rm, wm, sg := metrics.NewMeter(), metrics.NewMeter(), metrics.NewGauge()
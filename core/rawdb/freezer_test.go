@@ -283,6 +283,109 @@ func TestFreezerReadonlyValidate(t *testing.T) {
 	}
 }
 
+func TestFreezerPrunableTruncateTail(t *testing.T) {
+	var (
+		tables   = map[string]bool{"kept": true, "pruned": true}
+		prunable = map[string]bool{"pruned": true}
+		dir      = t.TempDir()
+	)
+	f, err := newFreezer(dir, "", false, 2049, tables, prunable, nil)
+	if err != nil {
+		t.Fatal("can't open freezer", err)
+	}
+	_, err = f.ModifyAncients(func(op etxdb.AncientWriteOp) error {
+		for i := uint64(0); i < 10; i++ {
+			if err := op.AppendRaw("kept", i, getChunk(256, int(i))); err != nil {
+				return err
+			}
+			if err := op.AppendRaw("pruned", i, getChunk(256, int(i))); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	require.NoError(t, f.TruncateTail(5))
+
+	check := func(f *Freezer) {
+		t.Helper()
+
+		if tail, _ := f.Tail(); tail != 5 {
+			t.Fatalf("Tail() returned %d, want 5", tail)
+		}
+		if ok, _ := f.HasAncient("pruned", 4); ok {
+			t.Fatal("truncated item of prunable table still present")
+		}
+		if ok, _ := f.HasAncient("pruned", 5); !ok {
+			t.Fatal("retained item of prunable table missing")
+		}
+		if ok, _ := f.HasAncient("kept", 0); !ok {
+			t.Fatal("item of non-prunable table truncated")
+		}
+	}
+	check(f)
+
+	// The tails must survive the repair on reopening
+	require.NoError(t, f.Close())
+	f, err = newFreezer(dir, "", false, 2049, tables, prunable, nil)
+	if err != nil {
+		t.Fatal("can't reopen freezer", err)
+	}
+	defer f.Close()
+	check(f)
+}
+
+func TestFreezerTableDirs(t *testing.T) {
+	var (
+		tables = map[string]bool{"hot": true, "cold": true}
+		dir    = t.TempDir()
+		cold   = t.TempDir()
+	)
+	f, err := newFreezer(dir, "", false, 2049, tables, nil, map[string]string{"cold": cold})
+	if err != nil {
+		t.Fatal("can't open freezer", err)
+	}
+	_, err = f.ModifyAncients(func(op etxdb.AncientWriteOp) error {
+		for i := uint64(0); i < 10; i++ {
+			if err := op.AppendRaw("hot", i, getChunk(256, int(i))); err != nil {
+				return err
+			}
+			if err := op.AppendRaw("cold", i, getChunk(256, int(i))); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	if files, _ := tableFiles(cold, "cold"); len(files) == 0 {
+		t.Fatal("relocated table not stored in its directory")
+	}
+	if files, _ := tableFiles(dir, "cold"); len(files) != 0 {
+		t.Fatalf("relocated table left files in the freezer directory: %v", files)
+	}
+	// Unconfigured tables must be opened from their recorded location
+	f, err = newFreezer(dir, "", true, 2049, tables, nil, nil)
+	if err != nil {
+		t.Fatal("can't reopen freezer", err)
+	}
+	if blob, err := f.Ancient("cold", 9); err != nil || !bytes.Equal(blob, getChunk(256, 9)) {
+		t.Fatalf("relocated item mismatch: %x, %v", blob, err)
+	}
+	require.NoError(t, f.Close())
+
+	// Moving a table holding data must be refused
+	_, err = newFreezer(dir, "", false, 2049, tables, nil, map[string]string{"cold": t.TempDir()})
+	if !errors.Is(err, errTableMoved) {
+		t.Fatalf("moved table error mismatch: have %v, want %v", err, errTableMoved)
+	}
+	_, err = newFreezer(dir, "", false, 2049, tables, nil, map[string]string{"unknown": cold})
+	if err == nil {
+		t.Fatal("unknown table relocated")
+	}
+}
+
 func newFreezerForTesting(t *testing.T, tables map[string]bool) (*Freezer, string) {
 	t.Helper()
 
@@ -84,6 +84,12 @@ var (
 	// transitionStatusKey tracks the etx2 transition status.
 	transitionStatusKey = []byte("etx2-transition")
 
+	// persistentStateIDKey tracks the id of latest stored state(for path-based only).
+	persistentStateIDKey = []byte("LastStateID")
+
+	// stateHistoryTailKey tracks the id of the newest pruned reverse diff(for path-based only).
+	stateHistoryTailKey = []byte("StateHistoryTail")
+
 	// Data item prefixes (use single byte to avoid mixing data types, avoid `i`, used for indexes).
 	headerPrefix       = []byte("h") // headerPrefix + num (uint64 big endian) + hash -> header
 	headerTDSuffix     = []byte("t") // headerPrefix + num (uint64 big endian) + hash + headerTDSuffix -> td
@@ -95,10 +101,15 @@ var (
 
 	txLookupPrefix        = []byte("l") // txLookupPrefix + hash -> transaction/receipt lookup metadata
 	bloomBitsPrefix       = []byte("B") // bloomBitsPrefix + bit (uint16 big endian) + section (uint64 big endian) + hash -> bloom bits
+	txTracePrefix         = []byte("T") // txTracePrefix + tracer id + tx hash -> block hash + trace result
 	SnapshotAccountPrefix = []byte("a") // SnapshotAccountPrefix + account hash -> account trie value
 	SnapshotStoragePrefix = []byte("o") // SnapshotStoragePrefix + account hash + storage hash -> storage trie value
 	CodePrefix            = []byte("c") // CodePrefix + code hash -> account code
 	skeletonHeaderPrefix  = []byte("S") // skeletonHeaderPrefix + num (uint64 big endian) -> header
+	TrieNodeAccountPrefix = []byte("A") // TrieNodeAccountPrefix + hexPath -> trie node
+	TrieNodeStoragePrefix = []byte("O") // TrieNodeStoragePrefix + accountHash + hexPath -> trie node
+	stateIDPrefix         = []byte("L") // stateIDPrefix + state root -> state id
+	reverseDiffPrefix     = []byte("R") // reverseDiffPrefix + state id (uint64 big endian) -> reverse diff
 
 	PreimagePrefix = []byte("secure-key-")       // PreimagePrefix + hash -> preimage
 	configPrefix   = []byte("ETX-config-")  // config prefix for the db
@@ -109,6 +120,9 @@ var (
 	// BloomBitsIndexPrefix is the data table of a chain indexer to track its progress
 	BloomBitsIndexPrefix = []byte("iB")
 
+	// TxTraceIndexPrefix is the data table of a chain indexer to track its progress
+	TxTraceIndexPrefix = []byte("iT")
+
 	ChtPrefix           = []byte("chtRootV2-") // ChtPrefix + chtNum (uint64 big endian) -> trie root hash
 	ChtTablePrefix      = []byte("cht-")
 	ChtIndexTablePrefix = []byte("chtIndexV2-")
@@ -203,11 +217,69 @@ func bloomBitsKey(bit uint, section uint64, hash common.Hash) []byte {
 	return key
 }
 
+// txTraceKey = txTracePrefix + tracer id + tx hash
+func txTraceKey(tracer common.Hash, hash common.Hash) []byte {
+	return append(append(txTracePrefix, tracer.Bytes()...), hash.Bytes()...)
+}
+
 // skeletonHeaderKey = skeletonHeaderPrefix + num (uint64 big endian)
 func skeletonHeaderKey(number uint64) []byte {
 	return append(skeletonHeaderPrefix, encodeBlockNumber(number)...)
 }
 
+// accountTrieNodeKey = TrieNodeAccountPrefix + nodePath.
+func accountTrieNodeKey(path []byte) []byte {
+	return append(TrieNodeAccountPrefix, path...)
+}
+
+// storageTrieNodeKey = TrieNodeStoragePrefix + accountHash + nodePath.
+func storageTrieNodeKey(accountHash common.Hash, path []byte) []byte {
+	return append(append(TrieNodeStoragePrefix, accountHash.Bytes()...), path...)
+}
+
+// IsAccountTrieNode reports whetxer a provided database entry is an account
+// trie node in path-based state scheme.
+func IsAccountTrieNode(key []byte) bool {
+	if !bytes.HasPrefix(key, TrieNodeAccountPrefix) {
+		return false
+	}
+	// The remaining key should only consist a hex node path
+	// whose length is in the range 0 to 64 (64 is excluded
+	// since leaves are always wrapped with shortNode).
+	if len(key) >= len(TrieNodeAccountPrefix)+common.HashLength*2 {
+		return false
+	}
+	return true
+}
+
+// IsStorageTrieNode reports whetxer a provided database entry is a storage
+// trie node in path-based state scheme.
+func IsStorageTrieNode(key []byte) bool {
+	if !bytes.HasPrefix(key, TrieNodeStoragePrefix) {
+		return false
+	}
+	// The remaining key consists of 2 parts:
+	// - 32 bytes account hash
+	// - hex node path whose length is in the range 0 to 64
+	if len(key) < len(TrieNodeStoragePrefix)+common.HashLength {
+		return false
+	}
+	if len(key) >= len(TrieNodeStoragePrefix)+common.HashLength+common.HashLength*2 {
+		return false
+	}
+	return true
+}
+
+// stateIDKey = stateIDPrefix + root (32 bytes)
+func stateIDKey(root common.Hash) []byte {
+	return append(stateIDPrefix, root.Bytes()...)
+}
+
+// reverseDiffKey = reverseDiffPrefix + id (uint64 big endian)
+func reverseDiffKey(id uint64) []byte {
+	return append(reverseDiffPrefix, encodeBlockNumber(id)...)
+}
+
 // preimageKey = PreimagePrefix + hash
 func preimageKey(hash common.Hash) []byte {
 	return append(PreimagePrefix, hash.Bytes()...)
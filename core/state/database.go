@@ -137,6 +137,17 @@ func NewDatabaseWithConfig(db etxdb.Database, config *trie.Config) Database {
 	}
 }
 
+// NewDatabaseWithNodeDB creates a state database with an already initialized
+// trie database, e.g. one using the path-based scheme.
+func NewDatabaseWithNodeDB(db etxdb.Database, triedb *trie.Database) Database {
+	return &cachingDB{
+		db:            triedb,
+		disk:          db,
+		codeSizeCache: lru.NewCache[common.Hash, int](codeSizeCacheSize),
+		codeCache:     lru.NewSizeConstrainedCache[common.Hash, []byte](codeCacheSize),
+	}
+}
+
 type cachingDB struct {
 	db            *trie.Database
 	disk          etxdb.KeyValueStore
@@ -0,0 +1,587 @@
+// Copyright 2022 The go-ETX Authors
+// This file is part of the go-ETX library.
+//
+// The go-ETX library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ETX library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ETX library. If not, see <http://www.gnu.org/licenses/>.
+
+package pruner
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/ETX/go-ETX/common"
+	"github.com/ETX/go-ETX/core/rawdb"
+	"github.com/ETX/go-ETX/core/state/snapshot"
+	"github.com/ETX/go-ETX/core/types"
+	"github.com/ETX/go-ETX/etxdb"
+	"github.com/ETX/go-ETX/log"
+	"github.com/ETX/go-ETX/rlp"
+	"github.com/ETX/go-ETX/trie"
+)
+
+const (
+	// onlineLayers is the number of snapshot diff layers required above the
+	// pruning target, mirroring the depth the offline pruner works with.
+	onlineLayers = 128
+
+	// Phases reported by the online pruner.
+	PhaseIdle     = "idle"
+	PhaseMarking  = "marking"
+	PhaseSweeping = "sweeping"
+)
+
+var (
+	// errPruneAborted is returned if the online pruner is closed while running.
+	errPruneAborted = errors.New("pruning aborted")
+
+	// errNotPrepared is returned if a pruning cycle is started without a target.
+	errNotPrepared = errors.New("pruning target not prepared")
+)
+
+// OnlineConfig includes all the configurations for online pruning.
+type OnlineConfig struct {
+	BloomSize uint64        // The Megabytes of memory allocated to bloom-filter
+	BatchSize int           // Number of database entries processed in a batch
+	Throttle  time.Duration // Time to wait between two batches
+}
+
+// DefaultOnlineConfig contains the default settings for online pruning.
+var DefaultOnlineConfig = OnlineConfig{
+	BloomSize: 2048,
+	BatchSize: 10000,
+	Throttle:  100 * time.Millisecond,
+}
+
+// OnlineStatus is the progress report of the online pruner.
+type OnlineStatus struct {
+	Phase    string             `json:"phase"`           // Current phase of the pruning cycle
+	Paused   bool               `json:"paused"`          // Whetxer the pruner is paused
+	Root     common.Hash        `json:"root"`            // Target state root of the current or last cycle
+	Started  time.Time          `json:"started"`         // Start time of the current or last cycle
+	Marked   uint64             `json:"marked"`          // Number of trie nodes marked as live
+	Scanned  uint64             `json:"scanned"`         // Number of database entries iterated
+	Pruned   uint64             `json:"pruned"`          // Number of stale trie nodes deleted
+	Size     common.StorageSize `json:"size"`            // Storage released by deleting stale trie nodes
+	Progress float64            `json:"progress"`        // Fraction of the key space swept
+	Cycles   uint64             `json:"cycles"`          // Number of completed pruning cycles
+	Error    string             `json:"error,omitempty"` // Failure of the last cycle, if any
+}
+
+// layerDiff is the set of trie paths modified by a snapshot diff layer.
+type layerDiff struct {
+	root     common.Hash
+	accounts []common.Hash
+	codes    [][]byte
+	storage  map[common.Hash]storageDiff
+}
+
+// storageDiff is the set of storage slots modified in a storage trie.
+type storageDiff struct {
+	root  common.Hash
+	slots []common.Hash
+}
+
+// OnlinePruner prunes the stale state in the background while the node keeps
+// processing blocks. The workflow is similar to the offline one:
+//
+//   - mark the state of the bottom-most snapshot diff layer, along with all
+//     the trie paths modified by the diff layers on top
+//   - iterate the database, delete all trie nodes which are not marked
+//
+// The trie nodes flushed by the trie database are marked as well via Mark,
+// so that the states created during the pruning are left untouched.
+//
+// Only the hash-based scheme is supported, stale trie nodes are overwritten
+// in place in the path-based scheme.
+type OnlinePruner struct {
+	config   OnlineConfig
+	db       etxdb.Database
+	triedb   *trie.Database
+	snaptree *snapshot.Tree
+
+	target common.Hash // State root prepared for the next cycle
+	diffs  []layerDiff // Trie paths modified by the diff layers above the target
+
+	bloom     *stateBloom // Filter of the live trie nodes
+	bloomLock sync.Mutex  // Lock serializing marking and sweeping
+
+	status OnlineStatus
+	resume chan struct{} // Channel closed on resume, nil if not paused
+	lock   sync.Mutex    // Lock protecting the status and the pause state
+
+	quit      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewOnlinePruner creates the online pruner instance.
+func NewOnlinePruner(db etxdb.Database, triedb *trie.Database, snaptree *snapshot.Tree, config OnlineConfig) (*OnlinePruner, error) {
+	if triedb.Scheme() != rawdb.HashScheme {
+		return nil, errors.New("online pruning is not needed in path-based scheme")
+	}
+	if snaptree == nil {
+		return nil, errors.New("online pruning requires snapshots")
+	}
+	if config.BloomSize == 0 {
+		config.BloomSize = DefaultOnlineConfig.BloomSize
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = DefaultOnlineConfig.BatchSize
+	}
+	bloom, err := newStateBloomWithSize(config.BloomSize)
+	if err != nil {
+		return nil, err
+	}
+	return &OnlinePruner{
+		config:   config,
+		db:       db,
+		triedb:   triedb,
+		snaptree: snaptree,
+		bloom:    bloom,
+		status:   OnlineStatus{Phase: PhaseIdle},
+		quit:     make(chan struct{}),
+	}, nil
+}
+
+// Mark records the given trie node as live. It's meant to be registered as the
+// flush callback of the trie database, so it must be invoked before the node is
+// written to disk.
+func (p *OnlinePruner) Mark(hash common.Hash) {
+	p.bloomLock.Lock()
+	defer p.bloomLock.Unlock()
+
+	p.bloom.Put(hash.Bytes(), nil)
+}
+
+// Prepare selects the bottom-most snapshot diff layer below the given head as
+// the pruning target and collects the trie paths modified by the layers above.
+// It returns the target along with the roots of the layers above it. The caller
+// must persist the target state and retain the returned states in the trie
+// database until they are released by Prune.
+//
+// The chain must not be modified while the target is prepared.
+func (p *OnlinePruner) Prepare(head common.Hash) (common.Hash, []common.Hash, error) {
+	layers := p.snaptree.Snapshots(head, onlineLayers, true)
+	if len(layers) != onlineLayers {
+		return common.Hash{}, nil, fmt.Errorf("snapshot not old enough yet: need %d more blocks", onlineLayers-len(layers))
+	}
+	target := layers[len(layers)-1].Root()
+
+	var (
+		diffs []layerDiff
+		roots []common.Hash
+	)
+	for i := len(layers) - 2; i >= 0; i-- {
+		diff, err := newLayerDiff(layers[i])
+		if err != nil {
+			return common.Hash{}, nil, err
+		}
+		diffs = append(diffs, diff)
+		roots = append(roots, diff.root)
+	}
+	p.target, p.diffs = target, diffs
+	return target, roots, nil
+}
+
+// newLayerDiff collects the trie paths modified by the given snapshot diff layer.
+func newLayerDiff(layer snapshot.Snapshot) (layerDiff, error) {
+	diff, ok := layer.(interface {
+		AccountList() []common.Hash
+		StorageList(accountHash common.Hash) ([]common.Hash, bool)
+	})
+	if !ok {
+		return layerDiff{}, fmt.Errorf("layer %x is not a diff layer", layer.Root())
+	}
+	ret := layerDiff{
+		root:     layer.Root(),
+		accounts: diff.AccountList(),
+		storage:  make(map[common.Hash]storageDiff),
+	}
+	for _, hash := range ret.accounts {
+		account, err := layer.Account(hash)
+		if err != nil {
+			return layerDiff{}, err
+		}
+		if account == nil {
+			continue // Account deleted, its storage is gone with it
+		}
+		if codeHash := account.CodeHash; len(codeHash) != 0 && !bytes.Equal(codeHash, emptyCode) {
+			ret.codes = append(ret.codes, codeHash)
+		}
+		slots, _ := diff.StorageList(hash)
+		if len(slots) == 0 {
+			continue
+		}
+		root := emptyRoot
+		if len(account.Root) != 0 {
+			root = common.BytesToHash(account.Root)
+		}
+		if root != emptyRoot {
+			ret.storage[hash] = storageDiff{root: root, slots: slots}
+		}
+	}
+	return ret, nil
+}
+
+// Prune runs a pruning cycle on the prepared target. The release callback is
+// invoked with the root of every retained state once it's no longer needed.
+func (p *OnlinePruner) Prune(release func(common.Hash)) (err error) {
+	target, diffs := p.target, p.diffs
+	p.target, p.diffs = common.Hash{}, nil
+	defer func() {
+		for _, diff := range diffs {
+			release(diff.root)
+		}
+	}()
+	if target == (common.Hash{}) {
+		return errNotPrepared
+	}
+	p.lock.Lock()
+	p.status = OnlineStatus{
+		Phase:   PhaseMarking,
+		Paused:  p.resume != nil,
+		Root:    target,
+		Started: time.Now(),
+		Cycles:  p.status.Cycles,
+	}
+	p.lock.Unlock()
+
+	defer func() {
+		p.lock.Lock()
+		defer p.lock.Unlock()
+
+		p.status.Phase = PhaseIdle
+		if err != nil {
+			p.status.Error = err.Error()
+		} else {
+			p.status.Cycles++
+		}
+	}()
+	log.Info("Starting online state pruning", "root", target)
+
+	// Mark the trie paths modified by the recent states first, so that they
+	// can be released as soon as possible.
+	for len(diffs) > 0 {
+		if err := p.markDiff(diffs[0]); err != nil {
+			return err
+		}
+		release(diffs[0].root)
+		diffs = diffs[1:]
+	}
+	// Mark the entire target state along with the genesis, the target is
+	// persisted on disk and can be iterated without holding it in memory.
+	if err := p.markState(target); err != nil {
+		return err
+	}
+	if err := extractGenesis(p.db, p.bloom); err != nil {
+		return err
+	}
+	if err := p.sweep(); err != nil {
+		return err
+	}
+	// Start over with a fresh filter, the nodes flushed from now on are marked
+	// for the next cycle.
+	bloom, err := newStateBloomWithSize(p.config.BloomSize)
+	if err != nil {
+		return err
+	}
+	p.bloomLock.Lock()
+	p.bloom = bloom
+	p.bloomLock.Unlock()
+
+	status := p.Status()
+	log.Info("Online state pruning finished", "root", target, "marked", status.Marked, "pruned", status.Pruned,
+		"size", status.Size, "elapsed", common.PrettyDuration(time.Since(status.Started)))
+	return nil
+}
+
+// markDiff marks the trie nodes along the paths modified by a diff layer.
+func (p *OnlinePruner) markDiff(diff layerDiff) error {
+	tr, err := trie.New(trie.StateTrieID(diff.root), p.triedb)
+	if err != nil {
+		return err
+	}
+	writer := &markWriter{pruner: p}
+	for i, hash := range diff.accounts {
+		if i%p.config.BatchSize == 0 {
+			if err := p.wait(); err != nil {
+				return err
+			}
+		}
+		if err := tr.Prove(hash.Bytes(), 0, writer); err != nil {
+			return err
+		}
+		if storage, ok := diff.storage[hash]; ok {
+			st, err := trie.New(trie.StorageTrieID(diff.root, hash, storage.root), p.triedb)
+			if err != nil {
+				return err
+			}
+			for _, slot := range storage.slots {
+				if err := st.Prove(slot.Bytes(), 0, writer); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	for _, code := range diff.codes {
+		p.bloom.Put(code, nil)
+	}
+	return nil
+}
+
+// markState marks all the trie nodes and contract codes of the given state.
+func (p *OnlinePruner) markState(root common.Hash) error {
+	t, err := trie.NewStateTrie(trie.StateTrieID(root), p.triedb)
+	if err != nil {
+		return err
+	}
+	var (
+		count   int
+		logged  = time.Now()
+		accIter = t.NodeIterator(nil)
+	)
+	mark := func(hash common.Hash) error {
+		if hash == (common.Hash{}) {
+			return nil // Embedded nodes don't have hash
+		}
+		p.bloom.Put(hash.Bytes(), nil)
+
+		p.lock.Lock()
+		p.status.Marked++
+		p.lock.Unlock()
+
+		if count++; count%p.config.BatchSize == 0 {
+			if time.Since(logged) > 8*time.Second {
+				log.Info("Marking live state", "root", root, "nodes", count)
+				logged = time.Now()
+			}
+			return p.wait()
+		}
+		return nil
+	}
+	for accIter.Next(true) {
+		if err := mark(accIter.Hash()); err != nil {
+			return err
+		}
+		if !accIter.Leaf() {
+			continue
+		}
+		var acc types.StateAccount
+		if err := rlp.DecodeBytes(accIter.LeafBlob(), &acc); err != nil {
+			return err
+		}
+		if acc.Root != emptyRoot {
+			id := trie.StorageTrieID(root, common.BytesToHash(accIter.LeafKey()), acc.Root)
+			storageTrie, err := trie.NewStateTrie(id, p.triedb)
+			if err != nil {
+				return err
+			}
+			storageIter := storageTrie.NodeIterator(nil)
+			for storageIter.Next(true) {
+				if err := mark(storageIter.Hash()); err != nil {
+					return err
+				}
+			}
+			if storageIter.Error() != nil {
+				return storageIter.Error()
+			}
+		}
+		if !bytes.Equal(acc.CodeHash, emptyCode) {
+			p.bloom.Put(acc.CodeHash, nil)
+		}
+	}
+	return accIter.Error()
+}
+
+// sweep iterates the database and deletes all the trie nodes which are not
+// marked in throttled batches.
+func (p *OnlinePruner) sweep() error {
+	p.lock.Lock()
+	p.status.Phase = PhaseSweeping
+	p.lock.Unlock()
+
+	var (
+		logged = time.Now()
+		start  []byte
+	)
+	for {
+		if err := p.wait(); err != nil {
+			return err
+		}
+		next, err := p.sweepBatch(start)
+		if err != nil {
+			return err
+		}
+		if next == nil {
+			p.lock.Lock()
+			p.status.Progress = 1
+			p.lock.Unlock()
+			return nil
+		}
+		start = next
+
+		if time.Since(logged) > 8*time.Second {
+			status := p.Status()
+			log.Info("Pruning stale state", "nodes", status.Pruned, "size", status.Size, "progress", fmt.Sprintf("%.2f%%", status.Progress*100))
+			logged = time.Now()
+		}
+		select {
+		case <-time.After(p.config.Throttle):
+		case <-p.quit:
+			return errPruneAborted
+		}
+	}
+}
+
+// sweepBatch deletes the stale trie nodes within a batch of database entries
+// starting at the given key. It returns the key to continue from, or nil if
+// the database is exhausted.
+//
+// The filter is locked until the deletions are written, so that a node which
+// is marked concurrently is either retained or rewritten after the deletion.
+func (p *OnlinePruner) sweepBatch(start []byte) ([]byte, error) {
+	p.bloomLock.Lock()
+	defer p.bloomLock.Unlock()
+
+	var (
+		scanned int
+		pruned  uint64
+		size    common.StorageSize
+		next    []byte
+		batch   = p.db.NewBatch()
+		iter    = p.db.NewIterator(nil, start)
+	)
+	defer iter.Release()
+
+	for iter.Next() {
+		key := iter.Key()
+		if scanned >= p.config.BatchSize {
+			next = common.CopyBytes(key)
+			break
+		}
+		scanned++
+
+		// Only the trie nodes are pruned, the contract codes might be written
+		// concurrently without being marked.
+		if len(key) != common.HashLength {
+			continue
+		}
+		if ok, err := p.bloom.Contain(key); err != nil {
+			return nil, err
+		} else if ok {
+			continue
+		}
+		pruned++
+		size += common.StorageSize(len(key) + len(iter.Value()))
+		batch.Delete(key)
+	}
+	if err := iter.Error(); err != nil {
+		return nil, err
+	}
+	if err := batch.Write(); err != nil {
+		return nil, err
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.status.Scanned += uint64(scanned)
+	p.status.Pruned += pruned
+	p.status.Size += size
+	if len(next) >= 8 {
+		p.status.Progress = float64(binary.BigEndian.Uint64(next[:8])) / math.MaxUint64
+	}
+	return next, nil
+}
+
+// wait blocks while the pruner is paused, returning an error if it's closed.
+func (p *OnlinePruner) wait() error {
+	p.lock.Lock()
+	resume := p.resume
+	p.lock.Unlock()
+
+	if resume != nil {
+		select {
+		case <-resume:
+		case <-p.quit:
+			return errPruneAborted
+		}
+	}
+	select {
+	case <-p.quit:
+		return errPruneAborted
+	default:
+		return nil
+	}
+}
+
+// Pause suspends the pruning at the next batch boundary.
+func (p *OnlinePruner) Pause() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if p.resume == nil {
+		p.resume = make(chan struct{})
+		p.status.Paused = true
+		log.Info("Paused online state pruning")
+	}
+}
+
+// Resume continues a paused pruning.
+func (p *OnlinePruner) Resume() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if p.resume != nil {
+		close(p.resume)
+		p.resume = nil
+		p.status.Paused = false
+		log.Info("Resumed online state pruning")
+	}
+}
+
+// Status returns the progress of the current or the last pruning cycle.
+func (p *OnlinePruner) Status() OnlineStatus {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	return p.status
+}
+
+// Close aborts the running pruning cycle, if any. The pruner can't be used
+// afterwards.
+func (p *OnlinePruner) Close() {
+	p.closeOnce.Do(func() { close(p.quit) })
+}
+
+// markWriter is a key-value writer marking the trie nodes of merkle proofs.
+type markWriter struct {
+	pruner *OnlinePruner
+}
+
+// Put implements etxdb.KeyValueWriter, marking the proof node with the key hash.
+func (w *markWriter) Put(key []byte, value []byte) error {
+	w.pruner.bloom.Put(key, nil)
+
+	w.pruner.lock.Lock()
+	w.pruner.status.Marked++
+	w.pruner.lock.Unlock()
+	return nil
+}
+
+// Delete implements etxdb.KeyValueWriter.
+func (w *markWriter) Delete(key []byte) error { panic("not supported") }
@@ -0,0 +1,207 @@
+// Copyright 2022 The go-ETX Authors
+// This file is part of the go-ETX library.
+//
+// The go-ETX library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ETX library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ETX library. If not, see <http://www.gnu.org/licenses/>.
+
+package pruner
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ETX/go-ETX/common"
+	"github.com/ETX/go-ETX/core/rawdb"
+	"github.com/ETX/go-ETX/core/state"
+	"github.com/ETX/go-ETX/core/state/snapshot"
+	"github.com/ETX/go-ETX/core/tracing"
+	"github.com/ETX/go-ETX/core/types"
+	"github.com/ETX/go-ETX/etxdb"
+	"github.com/ETX/go-ETX/rlp"
+	"github.com/ETX/go-ETX/trie"
+)
+
+// newOnlineTestChain creates a chain of states, each one modifying an account
+// and a storage slot, with all of them persisted on disk. It returns the roots
+// of the states, the first one being the genesis.
+func newOnlineTestChain(t *testing.T, blocks int) (etxdb.Database, *trie.Database, *snapshot.Tree, []common.Hash) {
+	var (
+		db       = rawdb.NewMemoryDatabase()
+		triedb   = trie.NewDatabase(db)
+		statedb  = state.NewDatabaseWithNodeDB(db, triedb)
+		contract = common.HexToAddress("0xc0de")
+	)
+	genesis, _ := state.New(common.Hash{}, statedb, nil)
+	for i := 0; i < 16; i++ {
+		genesis.SetBalance(common.BigToAddress(big.NewInt(int64(i+1))), big.NewInt(1))
+	}
+	genesis.SetCode(contract, []byte{0x1})
+	root, err := genesis.Commit(false)
+	if err != nil {
+		t.Fatalf("Failed to commit genesis: %v", err)
+	}
+	if err := triedb.Commit(root, false, nil); err != nil {
+		t.Fatalf("Failed to persist genesis: %v", err)
+	}
+	block := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(0), Root: root})
+	rawdb.WriteBlock(db, block)
+	rawdb.WriteCanonicalHash(db, block.Hash(), 0)
+
+	snaps, err := snapshot.New(snapshot.Config{CacheSize: 16}, db, triedb, root)
+	if err != nil {
+		t.Fatalf("Failed to create snapshot: %v", err)
+	}
+	roots := []common.Hash{root}
+	for i := 0; i < blocks; i++ {
+		sdb, err := state.New(root, statedb, snaps)
+		if err != nil {
+			t.Fatalf("Failed to open state %x: %v", root, err)
+		}
+		sdb.AddBalance(common.BigToAddress(big.NewInt(int64(i%16+1))), big.NewInt(1), tracing.BalanceChangeUnspecified)
+		sdb.SetState(contract, common.BigToHash(big.NewInt(int64(i%4))), common.BigToHash(big.NewInt(int64(i+1))))
+		if root, err = sdb.Commit(false); err != nil {
+			t.Fatalf("Failed to commit state: %v", err)
+		}
+		if err := triedb.Commit(root, false, nil); err != nil {
+			t.Fatalf("Failed to persist state: %v", err)
+		}
+		if err := snaps.Cap(root, onlineLayers); err != nil {
+			t.Fatalf("Failed to cap snapshot: %v", err)
+		}
+		roots = append(roots, root)
+	}
+	return db, triedb, snaps, roots
+}
+
+// checkOnlineState iterates over the entire state with the given root, reporting
+// any missing trie node.
+func checkOnlineState(db etxdb.Database, root common.Hash) error {
+	triedb := trie.NewDatabase(db)
+	tr, err := trie.NewStateTrie(trie.StateTrieID(root), triedb)
+	if err != nil {
+		return err
+	}
+	it := tr.NodeIterator(nil)
+	for it.Next(true) {
+		if !it.Leaf() {
+			continue
+		}
+		var acc types.StateAccount
+		if err := rlp.DecodeBytes(it.LeafBlob(), &acc); err != nil {
+			return err
+		}
+		if acc.Root == emptyRoot {
+			continue
+		}
+		st, err := trie.NewStateTrie(trie.StorageTrieID(root, common.BytesToHash(it.LeafKey()), acc.Root), triedb)
+		if err != nil {
+			return err
+		}
+		sit := st.NodeIterator(nil)
+		for sit.Next(true) {
+		}
+		if sit.Error() != nil {
+			return sit.Error()
+		}
+	}
+	return it.Error()
+}
+
+func TestOnlinePruning(t *testing.T) {
+	db, triedb, snaps, roots := newOnlineTestChain(t, onlineLayers+8)
+
+	p, err := NewOnlinePruner(db, triedb, snaps, OnlineConfig{BloomSize: 1, BatchSize: 16})
+	if err != nil {
+		t.Fatalf("Failed to create pruner: %v", err)
+	}
+	head := roots[len(roots)-1]
+	target, pinned, err := p.Prepare(head)
+	if err != nil {
+		t.Fatalf("Failed to prepare pruning: %v", err)
+	}
+	if want := roots[len(roots)-onlineLayers]; target != want {
+		t.Fatalf("Pruning target mismatch, want %x, got %x", want, target)
+	}
+	if len(pinned) != onlineLayers-1 {
+		t.Fatalf("Retained state count mismatch, want %d, got %d", onlineLayers-1, len(pinned))
+	}
+	released := make(map[common.Hash]bool)
+	if err := p.Prune(func(root common.Hash) { released[root] = true }); err != nil {
+		t.Fatalf("Failed to prune state: %v", err)
+	}
+	if len(released) != len(pinned) {
+		t.Fatalf("Released state count mismatch, want %d, got %d", len(pinned), len(released))
+	}
+	status := p.Status()
+	if status.Phase != PhaseIdle || status.Cycles != 1 || status.Error != "" {
+		t.Fatalf("Unexpected status after pruning: %+v", status)
+	}
+	if status.Pruned == 0 || status.Progress != 1 {
+		t.Fatalf("Unexpected sweeping progress: %+v", status)
+	}
+	// The genesis and the retained states must be intact, the stale ones not
+	if err := checkOnlineState(db, roots[0]); err != nil {
+		t.Fatalf("Genesis state damaged: %v", err)
+	}
+	for _, root := range roots[len(roots)-onlineLayers:] {
+		if err := checkOnlineState(db, root); err != nil {
+			t.Fatalf("Retained state %x damaged: %v", root, err)
+		}
+	}
+	if err := checkOnlineState(db, roots[1]); err == nil {
+		t.Fatal("Stale state not pruned")
+	}
+}
+
+func TestOnlinePruningPause(t *testing.T) {
+	db, triedb, snaps, roots := newOnlineTestChain(t, onlineLayers)
+
+	p, err := NewOnlinePruner(db, triedb, snaps, OnlineConfig{BloomSize: 1, BatchSize: 16})
+	if err != nil {
+		t.Fatalf("Failed to create pruner: %v", err)
+	}
+	if _, _, err := p.Prepare(roots[len(roots)-1]); err != nil {
+		t.Fatalf("Failed to prepare pruning: %v", err)
+	}
+	p.Pause()
+
+	done := make(chan error)
+	go func() { done <- p.Prune(func(common.Hash) {}) }()
+
+	select {
+	case err := <-done:
+		t.Fatalf("Paused pruning finished: %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+	if status := p.Status(); !status.Paused || status.Phase != PhaseMarking {
+		t.Fatalf("Unexpected status while paused: %+v", status)
+	}
+	p.Resume()
+	if err := <-done; err != nil {
+		t.Fatalf("Failed to prune state: %v", err)
+	}
+	// Closing a paused pruner must abort the cycle
+	if _, _, err := p.Prepare(roots[len(roots)-1]); err != nil {
+		t.Fatalf("Failed to prepare pruning: %v", err)
+	}
+	p.Pause()
+	go func() { done <- p.Prune(func(common.Hash) {}) }()
+	p.Close()
+	if err := <-done; err != errPruneAborted {
+		t.Fatalf("Unexpected error after close, want %v, got %v", errPruneAborted, err)
+	}
+	if status := p.Status(); status.Error != errPruneAborted.Error() || status.Cycles != 1 {
+		t.Fatalf("Unexpected status after close: %+v", status)
+	}
+}
@@ -95,6 +95,11 @@ func NewPruner(db etxdb.Database, config Config) (*Pruner, error) {
 	if headBlock == nil {
 		return nil, errors.New("failed to load head block")
 	}
+	// The path-based scheme overwrites the stale trie nodes in place, there
+	// is nothing to prune offline.
+	if rawdb.ReadStateScheme(db) == rawdb.PathScheme {
+		return nil, errors.New("offline pruning is not needed in path-based scheme")
+	}
 	snapconfig := snapshot.Config{
 		CacheSize:  256,
 		Recovery:   false,
@@ -0,0 +1,458 @@
+// Copyright 2022 The go-ETX Authors
+// This file is part of the go-ETX library.
+//
+// The go-ETX library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ETX library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ETX library. If not, see <http://www.gnu.org/licenses/>.
+
+package snapshot
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"time"
+
+	"github.com/ETX/go-ETX/common"
+	"github.com/ETX/go-ETX/core/rawdb"
+	"github.com/ETX/go-ETX/core/types"
+	"github.com/ETX/go-ETX/crypto"
+	"github.com/ETX/go-ETX/etxdb"
+	"github.com/ETX/go-ETX/log"
+	"github.com/ETX/go-ETX/rlp"
+	"github.com/ETX/go-ETX/trie"
+	"github.com/golang/snappy"
+)
+
+// The state export format is a magic followed by a snappy stream of RLP items:
+//
+//	preamble: [version, root, header, body, td]
+//	entries:  (accountKind, account, chunk..., []) ... endKind, trailer
+//
+// Every account is followed by its storage slots in chunks of ascending slot
+// hashes, the last chunk being empty. Accounts are ordered by their hashes and
+// their code is only included at the first account using it.
+const (
+	exportVersion = 1
+
+	exportKindAccount = 1
+	exportKindEnd     = 2
+
+	// exportChunkSlots is the maximum number of storage slots in a chunk.
+	exportChunkSlots = 1024
+)
+
+// exportMagic is the file signature of a state export.
+var exportMagic = []byte("ETXSTATE")
+
+var (
+	// errExportMagic is returned if the imported data is not a state export.
+	errExportMagic = errors.New("not a state export")
+
+	// errExportSnapshot is returned if the state is imported into a database
+	// which already holds a state snapshot.
+	errExportSnapshot = errors.New("database already holds a state snapshot")
+
+	// errExportGenesis is returned if the state is imported into a database
+	// which is not initialized with a genesis block.
+	errExportGenesis = errors.New("database not initialized with genesis")
+
+	// errExportScheme is returned if the state is imported into a database
+	// using the path-based state scheme, the state tries are regenerated with
+	// hash-keyed nodes only.
+	errExportScheme = errors.New("state import requires the hash-based state scheme")
+)
+
+// exportPreamble is the first item of a state export.
+type exportPreamble struct {
+	Version uint64
+	Root    common.Hash
+	Header  *types.Header
+	Body    *types.Body
+	Td      *big.Int
+}
+
+// exportAccount is an account of a state export.
+type exportAccount struct {
+	Hash    common.Hash
+	Account []byte // Account in slim format
+	Code    []byte // Contract code, omitted if already exported
+}
+
+// exportSlot is a storage slot of a state export.
+type exportSlot struct {
+	Hash  common.Hash
+	Value []byte
+}
+
+// exportTrailer is the last item of a state export.
+type exportTrailer struct {
+	Accounts uint64
+	Slots    uint64
+}
+
+// Export writes the state of the given block into a compact binary format,
+// including the contract codes, the block itself and its total difficulty. The
+// snapshot of the block's root must be complete.
+func Export(w io.Writer, snaptree *Tree, block *types.Block, td *big.Int, codedb etxdb.KeyValueReader) error {
+	var (
+		root   = block.Root()
+		header = block.Header()
+	)
+	accIt, err := snaptree.AccountIterator(root, common.Hash{})
+	if err != nil {
+		return err
+	}
+	defer accIt.Release()
+
+	if _, err := w.Write(exportMagic); err != nil {
+		return err
+	}
+	out := snappy.NewBufferedWriter(w)
+	preamble := &exportPreamble{Version: exportVersion, Root: root, Header: header, Body: block.Body(), Td: td}
+	if err := rlp.Encode(out, preamble); err != nil {
+		return err
+	}
+	var (
+		start    = time.Now()
+		logged   = time.Now()
+		trailer  exportTrailer
+		exported = make(map[common.Hash]struct{})
+	)
+	for accIt.Next() {
+		account, err := FullAccount(accIt.Account())
+		if err != nil {
+			return err
+		}
+		entry := &exportAccount{Hash: accIt.Hash(), Account: accIt.Account()}
+		if codeHash := common.BytesToHash(account.CodeHash); codeHash != emptyCode {
+			if _, ok := exported[codeHash]; !ok {
+				if entry.Code = rawdb.ReadCode(codedb, codeHash); len(entry.Code) == 0 {
+					return fmt.Errorf("code %x of account %x missing", codeHash, accIt.Hash())
+				}
+				exported[codeHash] = struct{}{}
+			}
+		}
+		if err := rlp.Encode(out, uint64(exportKindAccount)); err != nil {
+			return err
+		}
+		if err := rlp.Encode(out, entry); err != nil {
+			return err
+		}
+		slots, err := exportStorage(out, snaptree, root, accIt.Hash())
+		if err != nil {
+			return err
+		}
+		trailer.Accounts++
+		trailer.Slots += slots
+
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Exporting state", "at", accIt.Hash(), "accounts", trailer.Accounts, "slots", trailer.Slots,
+				"elapsed", common.PrettyDuration(time.Since(start)))
+			logged = time.Now()
+		}
+	}
+	if err := accIt.Error(); err != nil {
+		return err
+	}
+	if err := rlp.Encode(out, uint64(exportKindEnd)); err != nil {
+		return err
+	}
+	if err := rlp.Encode(out, &trailer); err != nil {
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	log.Info("Exported state", "root", root, "number", header.Number, "accounts", trailer.Accounts, "slots", trailer.Slots,
+		"codes", len(exported), "elapsed", common.PrettyDuration(time.Since(start)))
+	return nil
+}
+
+// exportStorage writes the storage slots of an account in chunks, returning the
+// number of slots written.
+func exportStorage(out io.Writer, snaptree *Tree, root common.Hash, account common.Hash) (uint64, error) {
+	stIt, err := snaptree.StorageIterator(root, account, common.Hash{})
+	if err != nil {
+		return 0, err
+	}
+	defer stIt.Release()
+
+	var (
+		chunk = make([]exportSlot, 0, exportChunkSlots)
+		slots uint64
+	)
+	for stIt.Next() {
+		chunk = append(chunk, exportSlot{Hash: stIt.Hash(), Value: common.CopyBytes(stIt.Slot())})
+		if len(chunk) == exportChunkSlots {
+			if err := rlp.Encode(out, chunk); err != nil {
+				return 0, err
+			}
+			slots += uint64(len(chunk))
+			chunk = chunk[:0]
+		}
+	}
+	if err := stIt.Error(); err != nil {
+		return 0, err
+	}
+	if len(chunk) > 0 {
+		if err := rlp.Encode(out, chunk); err != nil {
+			return 0, err
+		}
+		slots += uint64(len(chunk))
+	}
+	// Terminate the storage with an empty chunk
+	return slots, rlp.Encode(out, []exportSlot{})
+}
+
+// Import reads a state export into the database. The tries are regenerated from
+// the exported accounts and storage slots and verified against the exported
+// root, the snapshot and the contract codes are written as they are. The
+// exported block is stored as the canonical head of the chain, so the node can
+// resume from it. The database must be initialized with the genesis block.
+func Import(r io.Reader, db etxdb.Database) (*types.Block, error) {
+	magic := make([]byte, len(exportMagic))
+	if _, err := io.ReadFull(r, magic); err != nil || !bytes.Equal(magic, exportMagic) {
+		return nil, errExportMagic
+	}
+	if rawdb.ReadCanonicalHash(db, 0) == (common.Hash{}) {
+		return nil, errExportGenesis
+	}
+	if rawdb.ReadStateScheme(db) == rawdb.PathScheme {
+		return nil, errExportScheme
+	}
+	// The snapshot root and generator markers are written last, any snapshot
+	// entries without them are leftovers of a failed import.
+	if rawdb.ReadSnapshotRoot(db) != (common.Hash{}) || rawdb.ReadSnapshotGenerator(db) != nil {
+		return nil, errExportSnapshot
+	}
+	if err := wipeSnapshotEntries(db); err != nil {
+		return nil, err
+	}
+	var (
+		stream   = rlp.NewStream(snappy.NewReader(r), 0)
+		preamble exportPreamble
+	)
+	if err := stream.Decode(&preamble); err != nil {
+		return nil, fmt.Errorf("invalid state export preamble: %v", err)
+	}
+	if preamble.Version != exportVersion {
+		return nil, fmt.Errorf("unsupported state export version %d", preamble.Version)
+	}
+	if preamble.Header == nil || preamble.Header.Root != preamble.Root {
+		return nil, errors.New("state export header mismatch")
+	}
+	if preamble.Body == nil || preamble.Td == nil {
+		return nil, errors.New("state export block missing")
+	}
+	block := types.NewBlockWithHeader(preamble.Header).WithBody(preamble.Body.Transactions, preamble.Body.Uncles)
+	if hash := types.DeriveSha(block.Transactions(), trie.NewStackTrie(nil)); hash != block.TxHash() {
+		return nil, fmt.Errorf("state export body mismatch: have tx root %x, want %x", hash, block.TxHash())
+	}
+	if hash := types.CalcUncleHash(block.Uncles()); hash != block.UncleHash() {
+		return nil, fmt.Errorf("state export body mismatch: have uncle hash %x, want %x", hash, block.UncleHash())
+	}
+	var (
+		start   = time.Now()
+		logged  = time.Now()
+		batch   = db.NewBatch()
+		accTrie = trie.NewStackTrie(batch)
+		codes   = make(map[common.Hash]struct{})
+		trailer exportTrailer
+		last    *common.Hash
+	)
+	flush := func() error {
+		if batch.ValueSize() < etxdb.IdealBatchSize {
+			return nil
+		}
+		if err := batch.Write(); err != nil {
+			return err
+		}
+		batch.Reset()
+		return nil
+	}
+	for {
+		kind, err := stream.Uint64()
+		if err != nil {
+			return nil, fmt.Errorf("invalid state export entry: %v", err)
+		}
+		if kind == exportKindEnd {
+			break
+		}
+		if kind != exportKindAccount {
+			return nil, fmt.Errorf("unknown state export entry %d", kind)
+		}
+		var entry exportAccount
+		if err := stream.Decode(&entry); err != nil {
+			return nil, fmt.Errorf("invalid exported account: %v", err)
+		}
+		if last != nil && bytes.Compare(entry.Hash[:], last[:]) <= 0 {
+			return nil, fmt.Errorf("exported account %x out of order", entry.Hash)
+		}
+		last = &entry.Hash
+
+		account, err := FullAccount(entry.Account)
+		if err != nil {
+			return nil, fmt.Errorf("invalid exported account %x: %v", entry.Hash, err)
+		}
+		if codeHash := common.BytesToHash(account.CodeHash); codeHash != emptyCode {
+			if len(entry.Code) > 0 {
+				if have := crypto.Keccak256Hash(entry.Code); have != codeHash {
+					return nil, fmt.Errorf("code of account %x mismatch: have %x, want %x", entry.Hash, have, codeHash)
+				}
+				rawdb.WriteCode(batch, codeHash, entry.Code)
+				codes[codeHash] = struct{}{}
+			} else if _, ok := codes[codeHash]; !ok {
+				return nil, fmt.Errorf("code %x of account %x missing", codeHash, entry.Hash)
+			}
+		}
+		slots, err := importStorage(stream, batch, entry.Hash, common.BytesToHash(account.Root), flush)
+		if err != nil {
+			return nil, err
+		}
+		rawdb.WriteAccountSnapshot(batch, entry.Hash, entry.Account)
+		full, err := FullAccountRLP(entry.Account)
+		if err != nil {
+			return nil, err
+		}
+		if err := accTrie.TryUpdate(entry.Hash[:], full); err != nil {
+			return nil, err
+		}
+		if err := flush(); err != nil {
+			return nil, err
+		}
+		trailer.Accounts++
+		trailer.Slots += slots
+
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Importing state", "at", entry.Hash, "accounts", trailer.Accounts, "slots", trailer.Slots,
+				"elapsed", common.PrettyDuration(time.Since(start)))
+			logged = time.Now()
+		}
+	}
+	var want exportTrailer
+	if err := stream.Decode(&want); err != nil {
+		return nil, fmt.Errorf("invalid state export trailer: %v", err)
+	}
+	if trailer != want {
+		return nil, fmt.Errorf("state export truncated: have %d accounts and %d slots, want %d and %d", trailer.Accounts, trailer.Slots, want.Accounts, want.Slots)
+	}
+	root, err := accTrie.Commit()
+	if err != nil {
+		return nil, err
+	}
+	if root != preamble.Root {
+		return nil, fmt.Errorf("state root mismatch: have %x, want %x", root, preamble.Root)
+	}
+	// The state is complete, store the block as the chain head and mark the
+	// snapshot generated
+	var (
+		hash   = block.Hash()
+		number = block.NumberU64()
+	)
+	rawdb.WriteTd(batch, hash, number, preamble.Td)
+	rawdb.WriteBlock(batch, block)
+	rawdb.WriteCanonicalHash(batch, hash, number)
+	rawdb.WriteHeadHeaderHash(batch, hash)
+	rawdb.WriteHeadFastBlockHash(batch, hash)
+	rawdb.WriteHeadBlockHash(batch, hash)
+	rawdb.WriteSnapshotRoot(batch, root)
+	journalProgress(batch, nil, nil)
+	if err := batch.Write(); err != nil {
+		return nil, err
+	}
+	log.Info("Imported state", "root", root, "number", number, "accounts", trailer.Accounts, "slots", trailer.Slots,
+		"codes", len(codes), "elapsed", common.PrettyDuration(time.Since(start)))
+	return block, nil
+}
+
+// wipeSnapshotEntries deletes the snapshot accounts and storage slots left
+// behind by a failed import.
+func wipeSnapshotEntries(db etxdb.KeyValueStore) error {
+	for _, prefix := range []struct {
+		prefix []byte
+		keylen int
+	}{
+		{rawdb.SnapshotAccountPrefix, len(rawdb.SnapshotAccountPrefix) + common.HashLength},
+		{rawdb.SnapshotStoragePrefix, len(rawdb.SnapshotStoragePrefix) + 2*common.HashLength},
+	} {
+		it := db.NewIterator(prefix.prefix, nil)
+		batch := db.NewBatch()
+		for it.Next() {
+			if len(it.Key()) != prefix.keylen {
+				continue
+			}
+			batch.Delete(it.Key())
+			if batch.ValueSize() > etxdb.IdealBatchSize {
+				if err := batch.Write(); err != nil {
+					it.Release()
+					return err
+				}
+				batch.Reset()
+			}
+		}
+		it.Release()
+		if err := it.Error(); err != nil {
+			return err
+		}
+		if err := batch.Write(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// importStorage reads the storage slots of an account, regenerating its storage
+// trie and verifying it against the given root. The number of slots read is
+// returned.
+func importStorage(stream *rlp.Stream, batch etxdb.Batch, account common.Hash, root common.Hash, flush func() error) (uint64, error) {
+	var (
+		stTrie = trie.NewStackTrieWithOwner(batch, account)
+		slots  uint64
+		last   *common.Hash
+	)
+	for {
+		var chunk []exportSlot
+		if err := stream.Decode(&chunk); err != nil {
+			return 0, fmt.Errorf("invalid exported storage of account %x: %v", account, err)
+		}
+		if len(chunk) == 0 {
+			break
+		}
+		for i := range chunk {
+			slot := &chunk[i]
+			if last != nil && bytes.Compare(slot.Hash[:], last[:]) <= 0 {
+				return 0, fmt.Errorf("exported slot %x of account %x out of order", slot.Hash, account)
+			}
+			last = &slot.Hash
+
+			rawdb.WriteStorageSnapshot(batch, account, slot.Hash, slot.Value)
+			if err := stTrie.TryUpdate(slot.Hash[:], slot.Value); err != nil {
+				return 0, err
+			}
+		}
+		slots += uint64(len(chunk))
+		if err := flush(); err != nil {
+			return 0, err
+		}
+	}
+	have, err := stTrie.Commit()
+	if err != nil {
+		return 0, err
+	}
+	if have != root {
+		return 0, fmt.Errorf("storage root of account %x mismatch: have %x, want %x", account, have, root)
+	}
+	return slots, nil
+}
@@ -0,0 +1,130 @@
+// Copyright 2022 The go-ETX Authors
+// This file is part of the go-ETX library.
+//
+// The go-ETX library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ETX library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ETX library. If not, see <http://www.gnu.org/licenses/>.
+
+package snapshot
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ETX/go-ETX/common"
+	"github.com/ETX/go-ETX/core/rawdb"
+	"github.com/ETX/go-ETX/core/types"
+	"github.com/ETX/go-ETX/crypto"
+	"github.com/ETX/go-ETX/trie"
+)
+
+// Tests that an exported state can be imported into an empty database, and that
+// a corrupted export is rejected.
+func TestExportImport(t *testing.T) {
+	var (
+		helper   = newHelper()
+		code     = []byte{0x60, 0x00, 0x60, 0x00, 0xf3}
+		codeHash = crypto.Keccak256Hash(code)
+	)
+	stRoot := helper.makeStorageTrie(common.Hash{}, common.Hash{}, []string{"key-1", "key-2", "key-3"}, []string{"val-1", "val-2", "val-3"}, false)
+
+	helper.addTrieAccount("acc-1", &Account{Balance: big.NewInt(1), Root: stRoot, CodeHash: codeHash.Bytes()})
+	helper.addTrieAccount("acc-2", &Account{Balance: big.NewInt(2), Root: emptyRoot.Bytes(), CodeHash: emptyCode.Bytes()})
+	helper.addTrieAccount("acc-3", &Account{Balance: big.NewInt(3), Root: stRoot, CodeHash: codeHash.Bytes()})
+
+	helper.makeStorageTrie(common.Hash{}, hashData([]byte("acc-1")), []string{"key-1", "key-2", "key-3"}, []string{"val-1", "val-2", "val-3"}, true)
+	helper.makeStorageTrie(common.Hash{}, hashData([]byte("acc-3")), []string{"key-1", "key-2", "key-3"}, []string{"val-1", "val-2", "val-3"}, true)
+	rawdb.WriteCode(helper.diskdb, codeHash, code)
+
+	root, snap := helper.CommitAndGenerate()
+	select {
+	case <-snap.genPending:
+	case <-time.After(3 * time.Second):
+		t.Fatal("Snapshot generation failed")
+	}
+	defer func() {
+		stop := make(chan *generatorStats)
+		snap.genAbort <- stop
+		<-stop
+	}()
+	snaps := &Tree{layers: map[common.Hash]snapshot{root: snap}}
+	header := &types.Header{Number: big.NewInt(100), Root: root, TxHash: types.EmptyRootHash, UncleHash: types.EmptyUncleHash, Difficulty: big.NewInt(1)}
+	block := types.NewBlockWithHeader(header)
+
+	var buf bytes.Buffer
+	if err := Export(&buf, snaps, block, big.NewInt(101), helper.diskdb); err != nil {
+		t.Fatalf("failed to export state: %v", err)
+	}
+	// A database without genesis must be rejected
+	if _, err := Import(bytes.NewReader(buf.Bytes()), rawdb.NewMemoryDatabase()); err != errExportGenesis {
+		t.Fatalf("import into uninitialized database: have %v, want %v", err, errExportGenesis)
+	}
+	// A database using the path-based state scheme must be rejected
+	pathdb := rawdb.NewMemoryDatabase()
+	rawdb.WriteCanonicalHash(pathdb, common.Hash{0x01}, 0)
+	rawdb.WriteAccountTrieNode(pathdb, nil, []byte{0x01})
+	if _, err := Import(bytes.NewReader(buf.Bytes()), pathdb); err != errExportScheme {
+		t.Fatalf("import into path-based database: have %v, want %v", err, errExportScheme)
+	}
+	// Import the state into an empty database and check the result. A failed
+	// import must not prevent retries.
+	db := rawdb.NewMemoryDatabase()
+	rawdb.WriteCanonicalHash(db, common.Hash{0x01}, 0)
+	rawdb.WriteAccountSnapshot(db, common.Hash{0xff}, []byte{0x01})
+	if _, err := Import(bytes.NewReader(buf.Bytes()[:buf.Len()/2]), db); err == nil {
+		t.Fatal("truncated export imported")
+	}
+	imported, err := Import(bytes.NewReader(buf.Bytes()), db)
+	if err != nil {
+		t.Fatalf("failed to import state after failed attempt: %v", err)
+	}
+	if leftover := rawdb.ReadAccountSnapshot(db, common.Hash{0xff}); len(leftover) != 0 {
+		t.Fatal("leftover snapshot entry not deleted")
+	}
+	if imported.Hash() != block.Hash() {
+		t.Fatalf("block mismatch: have %x, want %x", imported.Hash(), block.Hash())
+	}
+	if stored := rawdb.ReadBlock(db, block.Hash(), 100); stored == nil {
+		t.Fatal("block not stored")
+	}
+	if td := rawdb.ReadTd(db, block.Hash(), 100); td == nil || td.Uint64() != 101 {
+		t.Fatalf("total difficulty mismatch: have %v, want 101", td)
+	}
+	if rawdb.ReadCanonicalHash(db, 100) != block.Hash() || rawdb.ReadHeadBlockHash(db) != block.Hash() ||
+		rawdb.ReadHeadFastBlockHash(db) != block.Hash() || rawdb.ReadHeadHeaderHash(db) != block.Hash() {
+		t.Fatal("imported block not set as chain head")
+	}
+	if have := rawdb.ReadSnapshotRoot(db); have != root {
+		t.Fatalf("snapshot root mismatch: have %x, want %x", have, root)
+	}
+	if have := rawdb.ReadCode(db, codeHash); !bytes.Equal(have, code) {
+		t.Fatalf("code mismatch: have %x, want %x", have, code)
+	}
+	accTrie, err := trie.New(trie.StateTrieID(root), trie.NewDatabase(db))
+	if err != nil {
+		t.Fatalf("failed to open imported trie: %v", err)
+	}
+	it := trie.NewIterator(accTrie.NodeIterator(nil))
+	accounts := 0
+	for it.Next() {
+		accounts++
+	}
+	if it.Err != nil || accounts != 3 {
+		t.Fatalf("imported trie mismatch: %d accounts, err %v", accounts, it.Err)
+	}
+	// Importing into a database with a snapshot must fail
+	if _, err := Import(bytes.NewReader(buf.Bytes()), db); err != errExportSnapshot {
+		t.Fatalf("import into snapshotted database: have %v, want %v", err, errExportSnapshot)
+	}
+}
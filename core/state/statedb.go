@@ -27,6 +27,7 @@ import (
 	"github.com/ETX/go-ETX/common"
 	"github.com/ETX/go-ETX/core/rawdb"
 	"github.com/ETX/go-ETX/core/state/snapshot"
+	"github.com/ETX/go-ETX/core/tracing"
 	"github.com/ETX/go-ETX/core/types"
 	"github.com/ETX/go-ETX/crypto"
 	"github.com/ETX/go-ETX/log"
@@ -79,9 +80,10 @@ type StateDB struct {
 	snapStorage   map[common.Hash]map[common.Hash][]byte
 
 	// This map holds 'live' objects, which will get modified while processing a state transition.
-	stateObjects        map[common.Address]*stateObject
-	stateObjectsPending map[common.Address]struct{} // State objects finalized but not yet written to the trie
-	stateObjectsDirty   map[common.Address]struct{} // State objects modified in the current execution
+	stateObjects         map[common.Address]*stateObject
+	stateObjectsPending  map[common.Address]struct{} // State objects finalized but not yet written to the trie
+	stateObjectsDirty    map[common.Address]struct{} // State objects modified in the current execution
+	stateObjectsDestruct map[common.Address]struct{} // State objects destructed in the block
 
 	// DB error.
 	// State objects are used by the consensus core and VM which are
@@ -112,6 +114,9 @@ type StateDB struct {
 	validRevisions []revision
 	nextRevisionId int
 
+	// Optional logger notified of every state change
+	logger tracing.StateLogger
+
 	// Measurements gathered during execution for debugging purposes
 	AccountReads         time.Duration
 	AccountHashes        time.Duration
@@ -139,19 +144,20 @@ func New(root common.Hash, db Database, snaps *snapshot.Tree) (*StateDB, error)
 		return nil, err
 	}
 	sdb := &StateDB{
-		db:                  db,
-		trie:                tr,
-		originalRoot:        root,
-		snaps:               snaps,
-		stateObjects:        make(map[common.Address]*stateObject),
-		stateObjectsPending: make(map[common.Address]struct{}),
-		stateObjectsDirty:   make(map[common.Address]struct{}),
-		logs:                make(map[common.Hash][]*types.Log),
-		preimages:           make(map[common.Hash][]byte),
-		journal:             newJournal(),
-		accessList:          newAccessList(),
-		transientStorage:    newTransientStorage(),
-		hasher:              crypto.NewKeccakState(),
+		db:                   db,
+		trie:                 tr,
+		originalRoot:         root,
+		snaps:                snaps,
+		stateObjects:         make(map[common.Address]*stateObject),
+		stateObjectsPending:  make(map[common.Address]struct{}),
+		stateObjectsDirty:    make(map[common.Address]struct{}),
+		stateObjectsDestruct: make(map[common.Address]struct{}),
+		logs:                 make(map[common.Hash][]*types.Log),
+		preimages:            make(map[common.Hash][]byte),
+		journal:              newJournal(),
+		accessList:           newAccessList(),
+		transientStorage:     newTransientStorage(),
+		hasher:               crypto.NewKeccakState(),
 	}
 	if sdb.snaps != nil {
 		if sdb.snap = sdb.snaps.Snapshot(root); sdb.snap != nil {
@@ -196,6 +202,12 @@ func (s *StateDB) Error() error {
 	return s.dbErr
 }
 
+// SetLogger sets the logger to be notified of the changes made to the state,
+// or removes the current one if nil. The logger is not inherited by copies.
+func (s *StateDB) SetLogger(logger tracing.StateLogger) {
+	s.logger = logger
+}
+
 func (s *StateDB) AddLog(log *types.Log) {
 	s.journal.append(addLogChange{txhash: s.thash})
 
@@ -204,6 +216,10 @@ func (s *StateDB) AddLog(log *types.Log) {
 	log.Index = s.logSize
 	s.logs[s.thash] = append(s.logs[s.thash], log)
 	s.logSize++
+
+	if s.logger != nil {
+		s.logger.OnLog(log)
+	}
 }
 
 func (s *StateDB) GetLogs(hash common.Hash, blockHash common.Hash) []*types.Log {
@@ -384,17 +400,25 @@ func (s *StateDB) HasSuicided(addr common.Address) bool {
  */
 
 // AddBalance adds amount to the account associated with addr.
-func (s *StateDB) AddBalance(addr common.Address, amount *big.Int) {
+func (s *StateDB) AddBalance(addr common.Address, amount *big.Int, reason tracing.BalanceChangeReason) {
 	stateObject := s.GetOrNewStateObject(addr)
 	if stateObject != nil {
+		if s.logger != nil && amount.Sign() != 0 {
+			prev := stateObject.Balance()
+			s.logger.OnBalanceChange(addr, prev, new(big.Int).Add(prev, amount), reason)
+		}
 		stateObject.AddBalance(amount)
 	}
 }
 
 // SubBalance subtracts amount from the account associated with addr.
-func (s *StateDB) SubBalance(addr common.Address, amount *big.Int) {
+func (s *StateDB) SubBalance(addr common.Address, amount *big.Int, reason tracing.BalanceChangeReason) {
 	stateObject := s.GetOrNewStateObject(addr)
 	if stateObject != nil {
+		if s.logger != nil && amount.Sign() != 0 {
+			prev := stateObject.Balance()
+			s.logger.OnBalanceChange(addr, prev, new(big.Int).Sub(prev, amount), reason)
+		}
 		stateObject.SubBalance(amount)
 	}
 }
@@ -402,6 +426,9 @@ func (s *StateDB) SubBalance(addr common.Address, amount *big.Int) {
 func (s *StateDB) SetBalance(addr common.Address, amount *big.Int) {
 	stateObject := s.GetOrNewStateObject(addr)
 	if stateObject != nil {
+		if s.logger != nil {
+			s.logger.OnBalanceChange(addr, stateObject.Balance(), amount, tracing.BalanceChangeUnspecified)
+		}
 		stateObject.SetBalance(amount)
 	}
 }
@@ -409,6 +436,9 @@ func (s *StateDB) SetBalance(addr common.Address, amount *big.Int) {
 func (s *StateDB) SetNonce(addr common.Address, nonce uint64) {
 	stateObject := s.GetOrNewStateObject(addr)
 	if stateObject != nil {
+		if s.logger != nil {
+			s.logger.OnNonceChange(addr, stateObject.Nonce(), nonce)
+		}
 		stateObject.SetNonce(nonce)
 	}
 }
@@ -416,13 +446,20 @@ func (s *StateDB) SetNonce(addr common.Address, nonce uint64) {
 func (s *StateDB) SetCode(addr common.Address, code []byte) {
 	stateObject := s.GetOrNewStateObject(addr)
 	if stateObject != nil {
-		stateObject.SetCode(crypto.Keccak256Hash(code), code)
+		codeHash := crypto.Keccak256Hash(code)
+		if s.logger != nil {
+			s.logger.OnCodeChange(addr, common.BytesToHash(stateObject.CodeHash()), stateObject.Code(s.db), codeHash, code)
+		}
+		stateObject.SetCode(codeHash, code)
 	}
 }
 
 func (s *StateDB) SetState(addr common.Address, key, value common.Hash) {
 	stateObject := s.GetOrNewStateObject(addr)
 	if stateObject != nil {
+		if s.logger != nil {
+			s.logger.OnStorageChange(addr, key, stateObject.GetState(s.db, key), value)
+		}
 		stateObject.SetState(s.db, key, value)
 	}
 }
@@ -451,6 +488,9 @@ func (s *StateDB) Suicide(addr common.Address) bool {
 		prev:        stateObject.suicided,
 		prevbalance: new(big.Int).Set(stateObject.Balance()),
 	})
+	if s.logger != nil && stateObject.Balance().Sign() != 0 {
+		s.logger.OnBalanceChange(addr, stateObject.Balance(), new(big.Int), tracing.BalanceDecreaseSelfdestruct)
+	}
 	stateObject.markSuicided()
 	stateObject.data.Balance = new(big.Int)
 
@@ -610,10 +650,13 @@ func (s *StateDB) createObject(addr common.Address) (newobj, prev *stateObject)
 	prev = s.getDeletedStateObject(addr) // Note, prev might have been deleted, we need that!
 
 	var prevdestruct bool
-	if s.snap != nil && prev != nil {
-		_, prevdestruct = s.snapDestructs[prev.addrHash]
+	if prev != nil {
+		_, prevdestruct = s.stateObjectsDestruct[prev.address]
 		if !prevdestruct {
-			s.snapDestructs[prev.addrHash] = struct{}{}
+			s.stateObjectsDestruct[prev.address] = struct{}{}
+			if s.snap != nil {
+				s.snapDestructs[prev.addrHash] = struct{}{}
+			}
 		}
 	}
 	newobj = newObject(s, addr, types.StateAccount{})
@@ -680,18 +723,19 @@ func (db *StateDB) ForEachStorage(addr common.Address, cb func(key, value common
 func (s *StateDB) Copy() *StateDB {
 	// Copy all the basic fields, initialize the memory ones
 	state := &StateDB{
-		db:                  s.db,
-		trie:                s.db.CopyTrie(s.trie),
-		originalRoot:        s.originalRoot,
-		stateObjects:        make(map[common.Address]*stateObject, len(s.journal.dirties)),
-		stateObjectsPending: make(map[common.Address]struct{}, len(s.stateObjectsPending)),
-		stateObjectsDirty:   make(map[common.Address]struct{}, len(s.journal.dirties)),
-		refund:              s.refund,
-		logs:                make(map[common.Hash][]*types.Log, len(s.logs)),
-		logSize:             s.logSize,
-		preimages:           make(map[common.Hash][]byte, len(s.preimages)),
-		journal:             newJournal(),
-		hasher:              crypto.NewKeccakState(),
+		db:                   s.db,
+		trie:                 s.db.CopyTrie(s.trie),
+		originalRoot:         s.originalRoot,
+		stateObjects:         make(map[common.Address]*stateObject, len(s.journal.dirties)),
+		stateObjectsPending:  make(map[common.Address]struct{}, len(s.stateObjectsPending)),
+		stateObjectsDirty:    make(map[common.Address]struct{}, len(s.journal.dirties)),
+		stateObjectsDestruct: make(map[common.Address]struct{}, len(s.stateObjectsDestruct)),
+		refund:               s.refund,
+		logs:                 make(map[common.Hash][]*types.Log, len(s.logs)),
+		logSize:              s.logSize,
+		preimages:            make(map[common.Hash][]byte, len(s.preimages)),
+		journal:              newJournal(),
+		hasher:               crypto.NewKeccakState(),
 	}
 	// Copy the dirty states, logs, and preimages
 	for addr := range s.journal.dirties {
@@ -724,6 +768,10 @@ func (s *StateDB) Copy() *StateDB {
 		}
 		state.stateObjectsDirty[addr] = struct{}{}
 	}
+	// Deep copy the destruction flag.
+	for addr := range s.stateObjectsDestruct {
+		state.stateObjectsDestruct[addr] = struct{}{}
+	}
 	for hash, logs := range s.logs {
 		cpy := make([]*types.Log, len(logs))
 		for i, l := range logs {
@@ -826,6 +874,11 @@ func (s *StateDB) Finalise(deleteEmptyObjects bool) {
 		if obj.suicided || (deleteEmptyObjects && obj.empty()) {
 			obj.deleted = true
 
+			// We need to maintain account deletions explicitly (will remain
+			// set indefinitely). The original storage of the account has to
+			// be wiped in the path-based scheme on commit.
+			s.stateObjectsDestruct[obj.address] = struct{}{}
+
 			// If state snapshotting is active, also mark the destruction there.
 			// Note, we can't do this only at the end of a block because multiple
 			// transactions within the same block might self destruct and then
@@ -947,7 +1000,17 @@ func (s *StateDB) Commit(deleteEmptyObjects bool) (common.Hash, error) {
 		storageTrieNodesUpdated int
 		storageTrieNodesDeleted int
 		nodes                   = trie.NewMergedNodeSet()
+		wipes                   map[common.Hash]*trie.NodeSet
 	)
+	// The trie nodes of the destructed storages are overwritten in place rather
+	// than left dangling in the path-based scheme, so they must be explicitly
+	// deleted, otherwise they would stay in the database forever.
+	if s.db.TrieDB().Scheme() == rawdb.PathScheme {
+		var err error
+		if wipes, err = s.wipeStorage(); err != nil {
+			return common.Hash{}, err
+		}
+	}
 	codeWriter := s.db.DiskDB().NewBatch()
 	for addr := range s.stateObjectsDirty {
 		if obj := s.stateObjects[addr]; !obj.deleted {
@@ -961,6 +1024,16 @@ func (s *StateDB) Commit(deleteEmptyObjects bool) (common.Hash, error) {
 			if err != nil {
 				return common.Hash{}, err
 			}
+			// Fold the wiped original storage into the new one if the
+			// account was recreated after the destruction
+			if wipe := wipes[obj.addrHash]; wipe != nil {
+				if set == nil {
+					set = wipe
+				} else if err := set.MergeDeletion(wipe); err != nil {
+					return common.Hash{}, err
+				}
+				delete(wipes, obj.addrHash)
+			}
 			// Merge the dirty nodes of storage trie into global set
 			if set != nil {
 				if err := nodes.Merge(set); err != nil {
@@ -972,15 +1045,24 @@ func (s *StateDB) Commit(deleteEmptyObjects bool) (common.Hash, error) {
 			}
 		}
 		// If the contract is destructed, the storage is still left in the
-		// database as dangling data. Theoretically it's should be wiped from
-		// database as well, but in hash-based-scheme it's extremely hard to
-		// determine that if the trie nodes are also referenced by other storage,
-		// and in path-based-scheme some technical challenges are still unsolved.
-		// Although it won't affect the correctness but please fix it TODO(rjl493456442).
+		// database as dangling data in the hash-based scheme. Theoretically
+		// it should be wiped from database as well, but it's extremely hard
+		// to determine that if the trie nodes are also referenced by other
+		// storage. The path-based scheme wipes it below.
+	}
+	for _, set := range wipes {
+		if err := nodes.Merge(set); err != nil {
+			return common.Hash{}, err
+		}
+		_, deleted := set.Size()
+		storageTrieNodesDeleted += deleted
 	}
 	if len(s.stateObjectsDirty) > 0 {
 		s.stateObjectsDirty = make(map[common.Address]struct{})
 	}
+	if len(s.stateObjectsDestruct) > 0 {
+		s.stateObjectsDestruct = make(map[common.Address]struct{})
+	}
 	if codeWriter.ValueSize() > 0 {
 		if err := codeWriter.Write(); err != nil {
 			log.Crit("Failed to commit dirty codes", "error", err)
@@ -1046,7 +1128,7 @@ func (s *StateDB) Commit(deleteEmptyObjects bool) (common.Hash, error) {
 	}
 	if root != origin {
 		start := time.Now()
-		if err := s.db.TrieDB().Update(nodes); err != nil {
+		if err := s.db.TrieDB().Update(root, origin, nodes); err != nil {
 			return common.Hash{}, err
 		}
 		s.originalRoot = root
@@ -1057,6 +1139,52 @@ func (s *StateDB) Commit(deleteEmptyObjects bool) (common.Hash, error) {
 	return root, nil
 }
 
+// wipeStorage collects the trie nodes of the original storage of the destructed
+// accounts as deleted node sets, keyed by the account hash. The accounts without
+// storage in the original state are skipped.
+func (s *StateDB) wipeStorage() (map[common.Hash]*trie.NodeSet, error) {
+	if len(s.stateObjectsDestruct) == 0 {
+		return nil, nil
+	}
+	tr, err := s.db.OpenTrie(s.originalRoot)
+	if err != nil {
+		return nil, err
+	}
+	sets := make(map[common.Hash]*trie.NodeSet)
+	for addr := range s.stateObjectsDestruct {
+		acct, err := tr.TryGetAccount(addr.Bytes())
+		if err != nil {
+			return nil, err
+		}
+		if acct == nil || acct.Root == emptyRoot {
+			continue
+		}
+		addrHash := crypto.Keccak256Hash(addr.Bytes())
+		st, err := s.db.OpenStorageTrie(s.originalRoot, addrHash, acct.Root)
+		if err != nil {
+			return nil, err
+		}
+		var (
+			paths [][]byte
+			blobs [][]byte
+			it    = st.NodeIterator(nil)
+		)
+		for it.Next(true) {
+			// Embedded nodes and values are not stored on their own
+			if it.Hash() == (common.Hash{}) {
+				continue
+			}
+			paths = append(paths, common.CopyBytes(it.Path()))
+			blobs = append(blobs, common.CopyBytes(it.NodeBlob()))
+		}
+		if err := it.Error(); err != nil {
+			return nil, err
+		}
+		sets[addrHash] = trie.NewNodeSetWithDeletion(addrHash, paths, blobs)
+	}
+	return sets, nil
+}
+
 // Prepare handles the preparatory steps for executing a state transition with.
 // This metxod must be invoked before state transition.
 //
@@ -31,6 +31,7 @@ import (
 
 	"github.com/ETX/go-ETX/common"
 	"github.com/ETX/go-ETX/core/rawdb"
+	"github.com/ETX/go-ETX/core/tracing"
 	"github.com/ETX/go-ETX/core/types"
 )
 
@@ -44,7 +45,7 @@ func TestUpdateLeaks(t *testing.T) {
 	// Update it with some accounts
 	for i := byte(0); i < 255; i++ {
 		addr := common.BytesToAddress([]byte{i})
-		state.AddBalance(addr, big.NewInt(int64(11*i)))
+		state.AddBalance(addr, big.NewInt(int64(11*i)), tracing.BalanceChangeUnspecified)
 		state.SetNonce(addr, uint64(42*i))
 		if i%2 == 0 {
 			state.SetState(addr, common.BytesToHash([]byte{i, i, i}), common.BytesToHash([]byte{i, i, i, i}))
@@ -259,7 +260,7 @@ func newTestAction(addr common.Address, r *rand.Rand) testAction {
 		{
 			name: "AddBalance",
 			fn: func(a testAction, s *StateDB) {
-				s.AddBalance(addr, big.NewInt(a.args[0]))
+				s.AddBalance(addr, big.NewInt(a.args[0]), tracing.BalanceChangeUnspecified)
 			},
 			args: make([]int64, 1),
 		},
@@ -487,7 +488,7 @@ func TestTouchDelete(t *testing.T) {
 	s.state, _ = New(root, s.state.db, s.state.snaps)
 
 	snapshot := s.state.Snapshot()
-	s.state.AddBalance(common.Address{}, new(big.Int))
+	s.state.AddBalance(common.Address{}, new(big.Int), tracing.BalanceChangeUnspecified)
 
 	if len(s.state.journal.dirties) != 1 {
 		t.Fatal("expected one dirty state object")
@@ -998,3 +999,68 @@ func TestStateDBTransientStorage(t *testing.T) {
 		t.Fatalf("transient storage mismatch: have %x, want %x", got, value)
 	}
 }
+
+// recordingLogger is a tracing.StateLogger collecting a textual representation
+// of the state changes it is notified of.
+type recordingLogger struct {
+	events []string
+}
+
+func (l *recordingLogger) OnBalanceChange(addr common.Address, prev, new *big.Int, reason tracing.BalanceChangeReason) {
+	l.events = append(l.events, fmt.Sprintf("balance %x %v->%v %v", addr[:1], prev, new, reason))
+}
+
+func (l *recordingLogger) OnNonceChange(addr common.Address, prev, new uint64) {
+	l.events = append(l.events, fmt.Sprintf("nonce %x %d->%d", addr[:1], prev, new))
+}
+
+func (l *recordingLogger) OnCodeChange(addr common.Address, prevCodeHash common.Hash, prevCode []byte, codeHash common.Hash, code []byte) {
+	l.events = append(l.events, fmt.Sprintf("code %x %x->%x", addr[:1], prevCode, code))
+}
+
+func (l *recordingLogger) OnStorageChange(addr common.Address, slot common.Hash, prev, new common.Hash) {
+	l.events = append(l.events, fmt.Sprintf("storage %x %x %x->%x", addr[:1], slot[31:], prev[31:], new[31:]))
+}
+
+func (l *recordingLogger) OnLog(log *types.Log) {
+	l.events = append(l.events, fmt.Sprintf("log %x %d", log.Address[:1], log.Index))
+}
+
+func TestStateLogger(t *testing.T) {
+	state, _ := New(common.Hash{}, NewDatabase(rawdb.NewMemoryDatabase()), nil)
+
+	logger := new(recordingLogger)
+	state.SetLogger(logger)
+
+	var (
+		alice = common.Address{0xaa}
+		bob   = common.Address{0xbb}
+	)
+	state.AddBalance(alice, big.NewInt(100), tracing.BalanceIncreaseGenesisBalance)
+	state.SubBalance(alice, big.NewInt(30), tracing.BalanceChangeTransfer)
+	state.AddBalance(bob, big.NewInt(30), tracing.BalanceChangeTransfer)
+	state.AddBalance(bob, new(big.Int), tracing.BalanceChangeTouchAccount)
+	state.SetNonce(alice, 1)
+	state.SetCode(bob, []byte{0x60})
+	state.SetState(bob, common.Hash{31: 0x01}, common.Hash{31: 0x02})
+	state.AddLog(&types.Log{Address: bob})
+	state.Suicide(bob)
+
+	// Copies must not inherit the logger
+	cpy := state.Copy()
+	cpy.AddBalance(alice, big.NewInt(1), tracing.BalanceChangeUnspecified)
+
+	want := []string{
+		"balance aa 0->100 genesisBalance",
+		"balance aa 100->70 transfer",
+		"balance bb 0->30 transfer",
+		"nonce aa 0->1",
+		"code bb ->60",
+		"storage bb 01 00->02",
+		"log bb 0",
+		"balance bb 30->0 selfdestruct",
+	}
+	if !reflect.DeepEqual(logger.events, want) {
+		t.Errorf("state events mismatch:\nhave %q\nwant %q", logger.events, want)
+	}
+}
@@ -24,6 +24,7 @@ import (
 	"github.com/ETX/go-ETX/consensus"
 	"github.com/ETX/go-ETX/consensus/misc"
 	"github.com/ETX/go-ETX/core/state"
+	"github.com/ETX/go-ETX/core/tracing"
 	"github.com/ETX/go-ETX/core/types"
 	"github.com/ETX/go-ETX/core/vm"
 	"github.com/ETX/go-ETX/crypto"
@@ -57,6 +58,25 @@ func NewStateProcessor(config *params.ChainConfig, bc *BlockChain, engine consen
 // returns the amount of gas that was used in the process. If any of the
 // transactions failed to execute due to insufficient gas it will return an error.
 func (p *StateProcessor) Process(block *types.Block, statedb *state.StateDB, cfg vm.Config) (types.Receipts, []*types.Log, uint64, error) {
+	// Feed the state changes and block boundaries into the tracer if it's
+	// interested in them
+	if cfg.Debug {
+		if logger, ok := cfg.Tracer.(tracing.StateLogger); ok {
+			statedb.SetLogger(logger)
+			defer statedb.SetLogger(nil)
+		}
+		if logger, ok := cfg.Tracer.(tracing.BlockLogger); ok {
+			logger.OnBlockStart(block)
+			receipts, logs, usedGas, err := p.process(block, statedb, cfg)
+			logger.OnBlockEnd(err)
+			return receipts, logs, usedGas, err
+		}
+	}
+	return p.process(block, statedb, cfg)
+}
+
+// process runs the transactions of a block on top of the given state, see Process.
+func (p *StateProcessor) process(block *types.Block, statedb *state.StateDB, cfg vm.Config) (types.Receipts, []*types.Log, uint64, error) {
 	var (
 		receipts    types.Receipts
 		usedGas     = new(uint64)
@@ -0,0 +1,119 @@
+// Copyright 2022 The go-ETX Authors
+// This file is part of the go-ETX library.
+//
+// The go-ETX library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ETX library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ETX library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package tracing defines the hooks through which tracers can observe the
+// changes made to the state and the blocks being processed, in addition to the
+// opcode level events delivered by vm.EVMLogger.
+package tracing
+
+import (
+	"math/big"
+
+	"github.com/ETX/go-ETX/common"
+	"github.com/ETX/go-ETX/core/types"
+)
+
+// StateLogger is used to collect the changes made to the state. The hooks are
+// invoked right before the change is applied, so the state still reflects the
+// previous values. Changes undone by a revert are not reported separately.
+// Note that reference types are actual state data structures; make copies if
+// you need to retain them beyond the current call.
+type StateLogger interface {
+	OnBalanceChange(addr common.Address, prev, new *big.Int, reason BalanceChangeReason)
+	OnNonceChange(addr common.Address, prev, new uint64)
+	OnCodeChange(addr common.Address, prevCodeHash common.Hash, prevCode []byte, codeHash common.Hash, code []byte)
+	OnStorageChange(addr common.Address, slot common.Hash, prev, new common.Hash)
+	OnLog(log *types.Log)
+}
+
+// BlockLogger is used to collect the boundaries of the blocks processed. The
+// error passed to OnBlockEnd is non-nil if the block failed to process.
+type BlockLogger interface {
+	OnBlockStart(block *types.Block)
+	OnBlockEnd(err error)
+}
+
+// BalanceChangeReason is used to indicate the reason for a balance change,
+// useful for tracing and reporting.
+type BalanceChangeReason byte
+
+const (
+	BalanceChangeUnspecified BalanceChangeReason = iota
+
+	// Issuance
+	// BalanceIncreaseRewardMineUncle is a reward for mining an uncle block.
+	BalanceIncreaseRewardMineUncle
+	// BalanceIncreaseRewardMineBlock is a reward for mining a block.
+	BalanceIncreaseRewardMineBlock
+	// BalanceIncreaseGenesisBalance is etxer allocated at the genesis block.
+	BalanceIncreaseGenesisBalance
+
+	// Transaction fees
+	// BalanceIncreaseRewardTransactionFee is the transaction tip increasing the block producer's balance.
+	BalanceIncreaseRewardTransactionFee
+	// BalanceDecreaseGasBuy is spent to purchase the gas of a transaction.
+	BalanceDecreaseGasBuy
+	// BalanceIncreaseGasReturn is the refund of the gas left over after execution.
+	BalanceIncreaseGasReturn
+
+	// DAO fork
+	// BalanceIncreaseDaoContract is etxer sent to the DAO refund contract.
+	BalanceIncreaseDaoContract
+	// BalanceDecreaseDaoAccount is etxer taken from a DAO account to be moved to the refund contract.
+	BalanceDecreaseDaoAccount
+
+	// BalanceChangeTransfer is etxer transferred via a call.
+	// It is a decrease for the sender and an increase for the recipient.
+	BalanceChangeTransfer
+	// BalanceChangeTouchAccount is a zero-value transfer used to touch an account.
+	BalanceChangeTouchAccount
+
+	// BalanceIncreaseSelfdestruct is added to the recipient of a self-destruct.
+	BalanceIncreaseSelfdestruct
+	// BalanceDecreaseSelfdestruct is deducted from a contract being self-destructed.
+	BalanceDecreaseSelfdestruct
+)
+
+// String implements the stringer interface.
+func (r BalanceChangeReason) String() string {
+	switch r {
+	case BalanceIncreaseRewardMineUncle:
+		return "rewardMineUncle"
+	case BalanceIncreaseRewardMineBlock:
+		return "rewardMineBlock"
+	case BalanceIncreaseGenesisBalance:
+		return "genesisBalance"
+	case BalanceIncreaseRewardTransactionFee:
+		return "rewardTransactionFee"
+	case BalanceDecreaseGasBuy:
+		return "gasBuy"
+	case BalanceIncreaseGasReturn:
+		return "gasReturn"
+	case BalanceIncreaseDaoContract:
+		return "daoContract"
+	case BalanceDecreaseDaoAccount:
+		return "daoAccount"
+	case BalanceChangeTransfer:
+		return "transfer"
+	case BalanceChangeTouchAccount:
+		return "touchAccount"
+	case BalanceIncreaseSelfdestruct:
+		return "selfdestructRecipient"
+	case BalanceDecreaseSelfdestruct:
+		return "selfdestruct"
+	}
+	return "unspecified"
+}
@@ -0,0 +1,166 @@
+// Copyright 2022 The go-ETX Authors
+// This file is part of the go-ETX library.
+//
+// The go-ETX library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ETX library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ETX library. If not, see <http://www.gnu.org/licenses/>.
+
+package txpool
+
+import (
+	"github.com/ETX/go-ETX/common"
+	"github.com/ETX/go-ETX/core/types"
+	"github.com/ETX/go-ETX/event"
+)
+
+// maxIncludedDepth is the maximum number of blocks walked on a head change to
+// find the pooled transactions included by the new blocks.
+const maxIncludedDepth = 64
+
+// TxLifecycleStatus is the state a transaction transitioned into.
+type TxLifecycleStatus string
+
+const (
+	TxLifecycleQueued   TxLifecycleStatus = "queued"   // Added to the non-executable queue (or demoted into it)
+	TxLifecyclePending  TxLifecycleStatus = "pending"  // Added or promoted to the executable pending set
+	TxLifecycleReplaced TxLifecycleStatus = "replaced" // Replaced by another transaction with the same nonce
+	TxLifecycleDropped  TxLifecycleStatus = "dropped"  // Removed from the pool without being included
+	TxLifecycleIncluded TxLifecycleStatus = "included" // Included in a canonical block
+)
+
+// TxDropReason is the reason a transaction was dropped from the pool.
+type TxDropReason string
+
+const (
+	TxDropUnderpriced TxDropReason = "underpriced" // Outpriced by other transactions in a full pool
+	TxDropEvicted     TxDropReason = "evicted"     // Evicted to keep the pool within its capacity limits
+	TxDropNonceGap    TxDropReason = "noncegap"    // Queued for longer than the lifetime waiting for a nonce gap to fill
+	TxDropNonceTooLow TxDropReason = "nonce"       // Nonce used up by another transaction of the sender
+	TxDropUnpayable   TxDropReason = "unpayable"   // Sender can no longer pay for the transaction
+)
+
+// TxStatusChange is a single state transition of a pooled transaction.
+type TxStatusChange struct {
+	Hash        common.Hash       `json:"hash"`
+	Status      TxLifecycleStatus `json:"status"`
+	ReplacedBy  *common.Hash      `json:"replacedBy,omitempty"`  // Replacing transaction, if replaced
+	Reason      TxDropReason      `json:"reason,omitempty"`      // Drop reason, if dropped
+	BlockHash   *common.Hash      `json:"blockHash,omitempty"`   // Including block, if included
+	BlockNumber *uint64           `json:"blockNumber,omitempty"` // Including block number, if included
+}
+
+// TxLifecycleEvent is posted with the state transitions of pooled transactions,
+// in the order they happened.
+type TxLifecycleEvent struct {
+	Changes []*TxStatusChange
+}
+
+// SubscribeLifecycleEvent registers a subscription of TxLifecycleEvent and
+// starts sending event to the given channel.
+func (pool *TxPool) SubscribeLifecycleEvent(ch chan<- TxLifecycleEvent) event.Subscription {
+	return pool.scope.Track(pool.lifecycleFeed.Subscribe(ch))
+}
+
+// recordChange stashes a state transition to be posted on the next flush.
+func (pool *TxPool) recordChange(change *TxStatusChange) {
+	pool.changesLock.Lock()
+	pool.changes = append(pool.changes, change)
+	pool.changesLock.Unlock()
+}
+
+func (pool *TxPool) recordQueued(hash common.Hash) {
+	pool.recordChange(&TxStatusChange{Hash: hash, Status: TxLifecycleQueued})
+}
+
+func (pool *TxPool) recordPending(hash common.Hash) {
+	pool.recordChange(&TxStatusChange{Hash: hash, Status: TxLifecyclePending})
+}
+
+func (pool *TxPool) recordReplaced(hash common.Hash, by common.Hash) {
+	pool.recordChange(&TxStatusChange{Hash: hash, Status: TxLifecycleReplaced, ReplacedBy: &by})
+}
+
+func (pool *TxPool) recordDropped(hash common.Hash, reason TxDropReason) {
+	pool.recordChange(&TxStatusChange{Hash: hash, Status: TxLifecycleDropped, Reason: reason})
+}
+
+// recordStale records the transition of a transaction removed due to its nonce
+// being used up, which is either its inclusion or a drop.
+//
+// Note, this metxod assumes the pool lock is held!
+func (pool *TxPool) recordStale(hash common.Hash) {
+	if block, ok := pool.included[hash]; ok {
+		var (
+			blockHash = block.Hash()
+			number    = block.NumberU64()
+		)
+		pool.recordChange(&TxStatusChange{Hash: hash, Status: TxLifecycleIncluded, BlockHash: &blockHash, BlockNumber: &number})
+		return
+	}
+	pool.recordDropped(hash, TxDropNonceTooLow)
+}
+
+// flushChanges posts the stashed state transitions to the subscribers. It must
+// be called without holding the pool lock, as the delivery may block.
+func (pool *TxPool) flushChanges() {
+	pool.changesFlush.Lock()
+	defer pool.changesFlush.Unlock()
+
+	pool.changesLock.Lock()
+	changes := pool.changes
+	pool.changes = nil
+	pool.changesLock.Unlock()
+
+	if len(changes) > 0 {
+		pool.lifecycleFeed.Send(TxLifecycleEvent{Changes: changes})
+	}
+}
+
+// includedTxs collects the pooled transactions included by the blocks becoming
+// canonical when switching from the old head to the new one, mapped to their
+// including block.
+//
+// Note, this metxod assumes the pool lock is held!
+func (pool *TxPool) includedTxs(oldHead, newHead *types.Header) map[common.Hash]*types.Block {
+	if oldHead == nil || newHead == nil {
+		return nil
+	}
+	var (
+		included = make(map[common.Hash]*types.Block)
+		rem      = pool.chain.GetBlock(oldHead.Hash(), oldHead.Number.Uint64())
+		add      = pool.chain.GetBlock(newHead.Hash(), newHead.Number.Uint64())
+	)
+	collect := func(block *types.Block) {
+		for _, tx := range block.Transactions() {
+			if pool.all.Get(tx.Hash()) != nil {
+				included[tx.Hash()] = block
+			}
+		}
+	}
+	// Walk the new chain back until the common ancestor with the old one, or
+	// until the maximum depth if the old chain is unknown
+	for depth := 0; add != nil && depth < maxIncludedDepth; depth++ {
+		if rem != nil && rem.NumberU64() >= add.NumberU64() {
+			if rem.Hash() == add.Hash() {
+				break
+			}
+			rem = pool.chain.GetBlock(rem.ParentHash(), rem.NumberU64()-1)
+			continue
+		}
+		collect(add)
+		if add.NumberU64() == 0 {
+			break
+		}
+		add = pool.chain.GetBlock(add.ParentHash(), add.NumberU64()-1)
+	}
+	return included
+}
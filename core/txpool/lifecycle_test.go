@@ -0,0 +1,130 @@
+// Copyright 2022 The go-ETX Authors
+// This file is part of the go-ETX library.
+//
+// The go-ETX library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ETX library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ETX library. If not, see <http://www.gnu.org/licenses/>.
+
+package txpool
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ETX/go-ETX/common"
+	"github.com/ETX/go-ETX/core/rawdb"
+	"github.com/ETX/go-ETX/core/state"
+	"github.com/ETX/go-ETX/core/types"
+	"github.com/ETX/go-ETX/crypto"
+	"github.com/ETX/go-ETX/event"
+	"github.com/ETX/go-ETX/params"
+	"github.com/ETX/go-ETX/trie"
+)
+
+// lifecycleBlockChain is a test chain serving the blocks it was given.
+type lifecycleBlockChain struct {
+	*testBlockChain
+	blocks map[common.Hash]*types.Block
+}
+
+func (bc *lifecycleBlockChain) GetBlock(hash common.Hash, number uint64) *types.Block {
+	return bc.blocks[hash]
+}
+
+// Tests that the pool reports the state transitions of its transactions in the
+// order they happen, including replacements, drops and inclusions.
+func TestPoolLifecycle(t *testing.T) {
+	t.Parallel()
+
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	blockchain := &lifecycleBlockChain{
+		testBlockChain: &testBlockChain{10000000, statedb, new(event.Feed)},
+		blocks:         make(map[common.Hash]*types.Block),
+	}
+	pool := NewTxPool(testTxPoolConfig, params.TestChainConfig, blockchain)
+	defer pool.Stop()
+	<-pool.initDoneCh
+
+	events := make(chan TxLifecycleEvent, 16)
+	sub := pool.SubscribeLifecycleEvent(events)
+	defer sub.Unsubscribe()
+
+	var (
+		key, _   = crypto.GenerateKey()
+		other, _ = crypto.GenerateKey()
+		tx       = transaction(0, 100000, key)
+		bumped   = pricedTransaction(0, 100000, big.NewInt(2), key)
+		gapped   = transaction(3, 100000, other)
+	)
+	testAddBalance(pool, crypto.PubkeyToAddress(key.PublicKey), big.NewInt(1000000000))
+	testAddBalance(pool, crypto.PubkeyToAddress(other.PublicKey), big.NewInt(1000000000))
+
+	// Add a transaction, replace it and queue another one with a nonce gap
+	if err := pool.addRemoteSync(tx); err != nil {
+		t.Fatalf("failed to add transaction: %v", err)
+	}
+	if err := pool.addRemoteSync(bumped); err != nil {
+		t.Fatalf("failed to replace transaction: %v", err)
+	}
+	if err := pool.addRemoteSync(gapped); err != nil {
+		t.Fatalf("failed to add gapped transaction: %v", err)
+	}
+	// Include the replacement in a new block and make the gapped one unpayable
+	var (
+		parent = types.NewBlock(&types.Header{Number: big.NewInt(0), GasLimit: 10000000, BaseFee: big.NewInt(1)}, nil, nil, nil, trie.NewStackTrie(nil))
+		block  = types.NewBlock(&types.Header{Number: big.NewInt(1), ParentHash: parent.Hash(), GasLimit: 10000000, BaseFee: big.NewInt(1)}, []*types.Transaction{bumped}, nil, nil, trie.NewStackTrie(nil))
+	)
+	blockchain.blocks[parent.Hash()] = parent
+	blockchain.blocks[block.Hash()] = block
+
+	statedb.SetNonce(crypto.PubkeyToAddress(key.PublicKey), 1)
+	statedb.SetBalance(crypto.PubkeyToAddress(other.PublicKey), big.NewInt(0))
+	<-pool.requestReset(parent.Header(), block.Header())
+
+	number := uint64(1)
+	want := []*TxStatusChange{
+		{Hash: tx.Hash(), Status: TxLifecycleQueued},
+		{Hash: tx.Hash(), Status: TxLifecyclePending},
+		{Hash: tx.Hash(), Status: TxLifecycleReplaced, ReplacedBy: &common.Hash{}},
+		{Hash: bumped.Hash(), Status: TxLifecyclePending},
+		{Hash: gapped.Hash(), Status: TxLifecycleQueued},
+		{Hash: gapped.Hash(), Status: TxLifecycleDropped, Reason: TxDropUnpayable},
+		{Hash: bumped.Hash(), Status: TxLifecycleIncluded, BlockNumber: &number},
+	}
+	*want[2].ReplacedBy = bumped.Hash()
+
+	var have []*TxStatusChange
+	timeout := time.After(time.Second)
+	for len(have) < len(want) {
+		select {
+		case ev := <-events:
+			have = append(have, ev.Changes...)
+		case <-timeout:
+			t.Fatalf("missing lifecycle events: have %d, want %d", len(have), len(want))
+		}
+	}
+	if len(have) != len(want) {
+		t.Fatalf("lifecycle event count mismatch: have %d, want %d", len(have), len(want))
+	}
+	for i := range want {
+		if have[i].Hash != want[i].Hash || have[i].Status != want[i].Status || have[i].Reason != want[i].Reason {
+			t.Errorf("change %d mismatch: have %s %s %s, want %s %s %s", i, have[i].Hash, have[i].Status, have[i].Reason, want[i].Hash, want[i].Status, want[i].Reason)
+		}
+		if want[i].ReplacedBy != nil && (have[i].ReplacedBy == nil || *have[i].ReplacedBy != *want[i].ReplacedBy) {
+			t.Errorf("change %d replacement mismatch: have %v, want %v", i, have[i].ReplacedBy, *want[i].ReplacedBy)
+		}
+		if want[i].BlockNumber != nil && (have[i].BlockNumber == nil || *have[i].BlockNumber != number || *have[i].BlockHash != block.Hash()) {
+			t.Errorf("change %d inclusion mismatch: have %v/%v", i, have[i].BlockHash, have[i].BlockNumber)
+		}
+	}
+}
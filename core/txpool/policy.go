@@ -0,0 +1,257 @@
+// Copyright 2022 The go-ETX Authors
+// This file is part of the go-ETX library.
+//
+// The go-ETX library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ETX library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ETX library. If not, see <http://www.gnu.org/licenses/>.
+
+package txpool
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/ETX/go-ETX/common"
+	"github.com/ETX/go-ETX/common/hexutil"
+	"github.com/ETX/go-ETX/common/mclock"
+	"github.com/ETX/go-ETX/core/types"
+)
+
+// ErrPolicyRejected is returned if a transaction is refused by the admission
+// policy of the pool. The returned errors wrap it with the exact reason.
+var ErrPolicyRejected = errors.New("rejected by txpool policy")
+
+// AdmissionPolicy is a hook deciding whetxer a transaction is allowed to enter
+// the pool, on top of the consensus and resource checks done by the pool. It's
+// invoked with the pool lock held, so it should be fast and must not call back
+// into the pool.
+type AdmissionPolicy interface {
+	// Admit returns nil if the transaction is accepted, or an error describing
+	// the reason of the rejection otherwise.
+	Admit(tx *types.Transaction, from common.Address) error
+
+	// Added is invoked once a remote transaction accepted by Admit was inserted
+	// into the pool. Local and reinjected transactions are not reported.
+	Added(tx *types.Transaction, from common.Address)
+}
+
+// PolicyRules is the set of operator configured admission rules, loadable from
+// a JSON file.
+type PolicyRules struct {
+	AllowSenders    []common.Address `json:"allowSenders,omitempty"`    // If non-empty, only these senders are admitted
+	DenySenders     []common.Address `json:"denySenders,omitempty"`     // Senders whose transactions are rejected
+	AllowRecipients []common.Address `json:"allowRecipients,omitempty"` // If non-empty, only calls to these recipients are admitted
+	DenyRecipients  []common.Address `json:"denyRecipients,omitempty"`  // Recipients whose calls are rejected
+	DenyCreate      bool             `json:"denyCreate,omitempty"`      // Whetxer contract creations are rejected
+	MaxGas          uint64           `json:"maxGas,omitempty"`          // Maximum gas limit of a transaction, 0 for no limit
+	DenySelectors   []SelectorRule   `json:"denySelectors,omitempty"`   // Contract metxods whose calls are rejected
+	RateLimits      []RateLimit      `json:"rateLimits,omitempty"`      // Admission rate limits per recipient
+}
+
+// SelectorRule matches the calls of a contract metxod by its 4 byte selector.
+type SelectorRule struct {
+	To       *common.Address `json:"to,omitempty"` // Contract the rule is restricted to, nil for any
+	Selector hexutil.Bytes   `json:"selector"`     // Metxod selector matched against the calldata
+}
+
+// RateLimit caps the number of transactions admitted to a recipient within a
+// time window.
+type RateLimit struct {
+	To     *common.Address `json:"to,omitempty"` // Recipient the limit is restricted to, nil for every one separately
+	Count  uint64          `json:"count"`        // Number of transactions admitted per window
+	Window uint64          `json:"window"`       // Length of the window in seconds
+}
+
+// LoadPolicyRules reads the admission rules from a JSON file.
+func LoadPolicyRules(path string) (*PolicyRules, error) {
+	blob, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(blob))
+	dec.DisallowUnknownFields()
+
+	rules := new(PolicyRules)
+	if err := dec.Decode(rules); err != nil {
+		return nil, fmt.Errorf("invalid txpool policy %s: %v", path, err)
+	}
+	return rules, nil
+}
+
+// validate checks the rules for inconsistencies.
+func (rules *PolicyRules) validate() error {
+	for i, rule := range rules.DenySelectors {
+		if len(rule.Selector) != 4 {
+			return fmt.Errorf("selector rule %d: invalid selector length %d", i, len(rule.Selector))
+		}
+	}
+	for i, limit := range rules.RateLimits {
+		if limit.Window == 0 {
+			return fmt.Errorf("rate limit %d: zero window", i)
+		}
+	}
+	return nil
+}
+
+// rateWindow tracks the admissions of a rate limit within its current window.
+type rateWindow struct {
+	limit  RateLimit
+	start  mclock.AbsTime
+	counts map[common.Address]uint64
+}
+
+// roll starts a new window if the current one elapsed.
+func (w *rateWindow) roll(now mclock.AbsTime) {
+	if now.Sub(w.start) >= time.Duration(w.limit.Window)*time.Second {
+		w.start = now
+		w.counts = make(map[common.Address]uint64)
+	}
+}
+
+// Policy is an AdmissionPolicy enforcing a set of PolicyRules.
+type Policy struct {
+	rules *PolicyRules
+
+	allowSenders    map[common.Address]struct{}
+	denySenders     map[common.Address]struct{}
+	allowRecipients map[common.Address]struct{}
+	denyRecipients  map[common.Address]struct{}
+
+	clock   mclock.Clock
+	windows []*rateWindow
+	lock    sync.Mutex
+}
+
+// NewPolicy creates an admission policy enforcing the given rules.
+func NewPolicy(rules *PolicyRules) (*Policy, error) {
+	return newPolicy(rules, mclock.System{})
+}
+
+func newPolicy(rules *PolicyRules, clock mclock.Clock) (*Policy, error) {
+	if err := rules.validate(); err != nil {
+		return nil, err
+	}
+	p := &Policy{
+		rules:           rules,
+		allowSenders:    addressSet(rules.AllowSenders),
+		denySenders:     addressSet(rules.DenySenders),
+		allowRecipients: addressSet(rules.AllowRecipients),
+		denyRecipients:  addressSet(rules.DenyRecipients),
+		clock:           clock,
+	}
+	for _, limit := range rules.RateLimits {
+		p.windows = append(p.windows, &rateWindow{
+			limit:  limit,
+			start:  clock.Now(),
+			counts: make(map[common.Address]uint64),
+		})
+	}
+	return p, nil
+}
+
+func addressSet(addrs []common.Address) map[common.Address]struct{} {
+	set := make(map[common.Address]struct{}, len(addrs))
+	for _, addr := range addrs {
+		set[addr] = struct{}{}
+	}
+	return set
+}
+
+// Rules returns the admission rules enforced by the policy.
+func (p *Policy) Rules() *PolicyRules {
+	return p.rules
+}
+
+// Admit implements AdmissionPolicy, checking the transaction against the rules.
+// The rate limits are only checked here, the admission is accounted once the
+// pool reports the transaction as added.
+func (p *Policy) Admit(tx *types.Transaction, from common.Address) error {
+	if len(p.allowSenders) > 0 {
+		if _, ok := p.allowSenders[from]; !ok {
+			return fmt.Errorf("%w: sender %s not allowed", ErrPolicyRejected, from)
+		}
+	}
+	if _, ok := p.denySenders[from]; ok {
+		return fmt.Errorf("%w: sender %s denied", ErrPolicyRejected, from)
+	}
+	if p.rules.MaxGas != 0 && tx.Gas() > p.rules.MaxGas {
+		return fmt.Errorf("%w: gas limit %d above maximum %d", ErrPolicyRejected, tx.Gas(), p.rules.MaxGas)
+	}
+	to := tx.To()
+	if to == nil {
+		if p.rules.DenyCreate {
+			return fmt.Errorf("%w: contract creation denied", ErrPolicyRejected)
+		}
+		return nil
+	}
+	if len(p.allowRecipients) > 0 {
+		if _, ok := p.allowRecipients[*to]; !ok {
+			return fmt.Errorf("%w: recipient %s not allowed", ErrPolicyRejected, *to)
+		}
+	}
+	if _, ok := p.denyRecipients[*to]; ok {
+		return fmt.Errorf("%w: recipient %s denied", ErrPolicyRejected, *to)
+	}
+	if data := tx.Data(); len(data) >= 4 {
+		for _, rule := range p.rules.DenySelectors {
+			if (rule.To == nil || *rule.To == *to) && bytes.Equal(data[:4], rule.Selector) {
+				return fmt.Errorf("%w: metxod %s of %s denied", ErrPolicyRejected, rule.Selector, *to)
+			}
+		}
+	}
+	return p.checkRate(*to)
+}
+
+// Added implements AdmissionPolicy, accounting the transaction in the rate
+// limits of its recipient.
+func (p *Policy) Added(tx *types.Transaction, from common.Address) {
+	to := tx.To()
+	if to == nil || len(p.windows) == 0 {
+		return
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	now := p.clock.Now()
+	for _, window := range p.windows {
+		if window.limit.To == nil || *window.limit.To == *to {
+			window.roll(now)
+			window.counts[*to]++
+		}
+	}
+}
+
+// checkRate checks whetxer any of the rate limits of the recipient is exceeded.
+func (p *Policy) checkRate(to common.Address) error {
+	if len(p.windows) == 0 {
+		return nil
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	now := p.clock.Now()
+	for _, window := range p.windows {
+		if window.limit.To != nil && *window.limit.To != to {
+			continue
+		}
+		window.roll(now)
+		if window.counts[to] >= window.limit.Count {
+			return fmt.Errorf("%w: recipient %s exceeded %d transactions per %ds", ErrPolicyRejected, to, window.limit.Count, window.limit.Window)
+		}
+	}
+	return nil
+}
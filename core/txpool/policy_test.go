@@ -0,0 +1,212 @@
+// Copyright 2022 The go-ETX Authors
+// This file is part of the go-ETX library.
+//
+// The go-ETX library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ETX library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ETX library. If not, see <http://www.gnu.org/licenses/>.
+
+package txpool
+
+import (
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ETX/go-ETX/common"
+	"github.com/ETX/go-ETX/common/hexutil"
+	"github.com/ETX/go-ETX/common/mclock"
+	"github.com/ETX/go-ETX/core/types"
+	"github.com/ETX/go-ETX/crypto"
+)
+
+func policyTx(to *common.Address, gas uint64, data []byte) *types.Transaction {
+	if to == nil {
+		return types.NewContractCreation(0, big.NewInt(0), gas, big.NewInt(1), data)
+	}
+	return types.NewTransaction(0, *to, big.NewInt(0), gas, big.NewInt(1), data)
+}
+
+// Tests that the admission policy enforces the configured rules.
+func TestPolicyAdmit(t *testing.T) {
+	t.Parallel()
+
+	var (
+		alice    = common.Address{0x01}
+		bob      = common.Address{0x02}
+		token    = common.Address{0x10}
+		exchange = common.Address{0x11}
+		banned   = common.Address{0x12}
+		clock    = new(mclock.Simulated)
+	)
+	policy, err := newPolicy(&PolicyRules{
+		DenySenders:    []common.Address{bob},
+		DenyRecipients: []common.Address{banned},
+		DenyCreate:     true,
+		MaxGas:         100000,
+		DenySelectors: []SelectorRule{
+			{To: &token, Selector: hexutil.Bytes{0xa9, 0x05, 0x9c, 0xbb}},
+		},
+		RateLimits: []RateLimit{
+			{To: &exchange, Count: 2, Window: 60},
+		},
+	}, clock)
+	if err != nil {
+		t.Fatalf("failed to create policy: %v", err)
+	}
+	tests := []struct {
+		from   common.Address
+		tx     *types.Transaction
+		reject bool
+	}{
+		{alice, policyTx(&token, 50000, nil), false},
+		{bob, policyTx(&token, 50000, nil), true},                                    // denied sender
+		{alice, policyTx(&banned, 50000, nil), true},                                 // denied recipient
+		{alice, policyTx(nil, 50000, nil), true},                                     // contract creation
+		{alice, policyTx(&token, 200000, nil), true},                                 // gas above cap
+		{alice, policyTx(&token, 50000, []byte{0xa9, 0x05, 0x9c, 0xbb, 0x00}), true}, // denied metxod
+		{alice, policyTx(&exchange, 50000, []byte{0xa9, 0x05, 0x9c, 0xbb}), false},   // metxod denied on other contract only
+		{alice, policyTx(&exchange, 50000, nil), false},
+		{alice, policyTx(&exchange, 50000, nil), true}, // rate limited
+	}
+	for i, tt := range tests {
+		err := policy.Admit(tt.tx, tt.from)
+		if tt.reject && !errors.Is(err, ErrPolicyRejected) {
+			t.Errorf("test %d: expected rejection, got %v", i, err)
+		}
+		if !tt.reject && err != nil {
+			t.Errorf("test %d: unexpected rejection: %v", i, err)
+		}
+		if err == nil {
+			policy.Added(tt.tx, tt.from)
+		}
+	}
+	// Rate limit resets with the next window
+	clock.Run(time.Minute)
+	if err := policy.Admit(policyTx(&exchange, 50000, nil), alice); err != nil {
+		t.Errorf("rate limit not reset: %v", err)
+	}
+}
+
+// Tests that the sender allow list only admits the listed accounts.
+func TestPolicyAllowList(t *testing.T) {
+	t.Parallel()
+
+	var (
+		alice = common.Address{0x01}
+		bob   = common.Address{0x02}
+		to    = common.Address{0x10}
+	)
+	policy, err := NewPolicy(&PolicyRules{AllowSenders: []common.Address{alice}})
+	if err != nil {
+		t.Fatalf("failed to create policy: %v", err)
+	}
+	if err := policy.Admit(policyTx(&to, 50000, nil), alice); err != nil {
+		t.Errorf("allowed sender rejected: %v", err)
+	}
+	if err := policy.Admit(policyTx(&to, 50000, nil), bob); !errors.Is(err, ErrPolicyRejected) {
+		t.Errorf("unlisted sender admitted: %v", err)
+	}
+}
+
+// Tests that the policy rules are loaded and validated from a file.
+func TestLoadPolicyRules(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	valid := filepath.Join(dir, "valid.json")
+	os.WriteFile(valid, []byte(`{"denySenders": ["0x0000000000000000000000000000000000000001"], "maxGas": 1000000, "denySelectors": [{"selector": "0x095ea7b3"}]}`), 0644)
+
+	rules, err := LoadPolicyRules(valid)
+	if err != nil {
+		t.Fatalf("failed to load rules: %v", err)
+	}
+	if _, err := NewPolicy(rules); err != nil {
+		t.Fatalf("failed to create policy: %v", err)
+	}
+	if len(rules.DenySenders) != 1 || rules.MaxGas != 1000000 || len(rules.DenySelectors) != 1 {
+		t.Errorf("rules mismatch: %+v", rules)
+	}
+	unknown := filepath.Join(dir, "unknown.json")
+	os.WriteFile(unknown, []byte(`{"denySender": []}`), 0644)
+	if _, err := LoadPolicyRules(unknown); err == nil {
+		t.Errorf("unknown field accepted")
+	}
+	if _, err := NewPolicy(&PolicyRules{DenySelectors: []SelectorRule{{Selector: hexutil.Bytes{0x01}}}}); err == nil {
+		t.Errorf("short selector accepted")
+	}
+}
+
+// Tests that the pool rejects transactions refused by its policy, surfacing the
+// reason in the returned error.
+func TestPoolPolicy(t *testing.T) {
+	t.Parallel()
+
+	pool, key := setupPool()
+	defer pool.Stop()
+
+	tx := transaction(0, 100000, key)
+	from, _ := deriveSender(tx)
+	testAddBalance(pool, from, big.NewInt(1000000000))
+
+	policy, _ := NewPolicy(&PolicyRules{MaxGas: 50000})
+	pool.SetPolicy(policy)
+	if err := pool.AddLocal(tx); !errors.Is(err, ErrPolicyRejected) {
+		t.Fatalf("expected policy rejection, got %v", err)
+	}
+	pool.SetPolicy(nil)
+	if err := pool.AddLocal(tx); err != nil {
+		t.Fatalf("failed to add transaction without policy: %v", err)
+	}
+}
+
+// Tests that only the remote transactions actually added to the pool are charged
+// to the rate limits of the policy.
+func TestPoolPolicyRateLimit(t *testing.T) {
+	t.Parallel()
+
+	pool, key := setupPool()
+	defer pool.Stop()
+
+	from := crypto.PubkeyToAddress(key.PublicKey)
+	testAddBalance(pool, from, big.NewInt(1000000000))
+
+	policy, _ := NewPolicy(&PolicyRules{RateLimits: []RateLimit{{Count: 2, Window: 3600}}})
+	pool.SetPolicy(policy)
+
+	// Local transactions are not charged
+	if err := pool.AddLocal(transaction(0, 100000, key)); err != nil {
+		t.Fatalf("failed to add local transaction: %v", err)
+	}
+	// Rejected remote transactions are not charged
+	remote, _ := crypto.GenerateKey()
+	testAddBalance(pool, crypto.PubkeyToAddress(remote.PublicKey), big.NewInt(1000000000))
+
+	if err := pool.AddRemote(pricedTransaction(0, 100000, big.NewInt(100), remote)); err != nil {
+		t.Fatalf("failed to add remote transaction: %v", err)
+	}
+	if err := pool.AddRemote(pricedTransaction(0, 100000, big.NewInt(100), remote)); !errors.Is(err, ErrAlreadyKnown) {
+		t.Fatalf("expected known transaction, got %v", err)
+	}
+	if err := pool.AddRemote(pricedTransaction(0, 100000, big.NewInt(105), remote)); !errors.Is(err, ErrReplaceUnderpriced) {
+		t.Fatalf("expected underpriced replacement, got %v", err)
+	}
+	if err := pool.AddRemote(pricedTransaction(1, 100000, big.NewInt(1), remote)); err != nil {
+		t.Fatalf("failed to add remote transaction: %v", err)
+	}
+	// The quota is used up by the two added remote transactions
+	if err := pool.AddRemote(pricedTransaction(2, 100000, big.NewInt(1), remote)); !errors.Is(err, ErrPolicyRejected) {
+		t.Fatalf("expected rate limit rejection, got %v", err)
+	}
+}
@@ -0,0 +1,178 @@
+// Copyright 2022 The go-ETX Authors
+// This file is part of the go-ETX library.
+//
+// The go-ETX library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ETX library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ETX library. If not, see <http://www.gnu.org/licenses/>.
+
+package txpool
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"time"
+
+	"github.com/ETX/go-ETX/common"
+	"github.com/ETX/go-ETX/core/types"
+	"github.com/ETX/go-ETX/log"
+	"github.com/ETX/go-ETX/rlp"
+)
+
+// snapshotEntry is a single transaction of a pool snapshot, along with the
+// metadata needed to restore it with the same treatment.
+type snapshotEntry struct {
+	Tx    *types.Transaction
+	Local bool   // Whetxer the transaction was tracked as local
+	Time  uint64 // Arrival time of the transaction, in unix nanoseconds
+}
+
+// Export writes all the transactions of the pool, both pending and queued, into
+// the given stream, grouped by account and ordered by nonce. It returns the
+// number of the exported transactions.
+func (pool *TxPool) Export(w io.Writer) (int, error) {
+	pool.mu.RLock()
+	var entries []*snapshotEntry
+	collect := func(addr common.Address, txs types.Transactions) {
+		local := pool.locals.contains(addr)
+		for _, tx := range txs {
+			entries = append(entries, &snapshotEntry{
+				Tx:    tx,
+				Local: local,
+				Time:  uint64(tx.Time().UnixNano()),
+			})
+		}
+	}
+	for addr, list := range pool.pending {
+		collect(addr, list.Flatten())
+		if queued := pool.queue[addr]; queued != nil {
+			collect(addr, queued.Flatten())
+		}
+	}
+	for addr, list := range pool.queue {
+		if pool.pending[addr] == nil {
+			collect(addr, list.Flatten())
+		}
+	}
+	pool.mu.RUnlock()
+
+	// Write the collected transactions without blocking the pool
+	buf := bufio.NewWriter(w)
+	for _, entry := range entries {
+		if err := rlp.Encode(buf, entry); err != nil {
+			return 0, err
+		}
+	}
+	if err := buf.Flush(); err != nil {
+		return 0, err
+	}
+	return len(entries), nil
+}
+
+// Import reads the transactions exported by Export from the given stream and
+// adds them into the pool, revalidating them against the current head. Local
+// transactions are re-added as locals, unless local handling is disabled. It
+// returns the number of imported and dropped transactions.
+func (pool *TxPool) Import(r io.Reader) (int, int, error) {
+	var (
+		stream   = rlp.NewStream(bufio.NewReader(r), 0)
+		imported int
+		dropped  int
+		failure  error
+
+		locals  types.Transactions
+		remotes types.Transactions
+	)
+	flush := func() {
+		for _, batch := range []struct {
+			txs   types.Transactions
+			local bool
+		}{{locals, !pool.config.NoLocals}, {remotes, false}} {
+			if len(batch.txs) == 0 {
+				continue
+			}
+			for _, err := range pool.addTxs(batch.txs, batch.local, true) {
+				switch {
+				case err == nil:
+					imported++
+				case errors.Is(err, ErrAlreadyKnown):
+					// Already added from the journal or a previous import
+				default:
+					log.Debug("Failed to import pooled transaction", "err", err)
+					dropped++
+				}
+			}
+		}
+		locals, remotes = locals[:0], remotes[:0]
+	}
+	for {
+		entry := new(snapshotEntry)
+		if err := stream.Decode(entry); err != nil {
+			if err != io.EOF {
+				failure = err
+			}
+			break
+		}
+		entry.Tx.SetTime(time.Unix(0, int64(entry.Time)))
+		if entry.Local {
+			locals = append(locals, entry.Tx)
+		} else {
+			remotes = append(remotes, entry.Tx)
+		}
+		if len(locals)+len(remotes) >= 1024 {
+			flush()
+		}
+	}
+	flush()
+	return imported, dropped, failure
+}
+
+// saveSnapshot atomically writes all the transactions of the pool into the
+// given file.
+func (pool *TxPool) saveSnapshot(path string) error {
+	output, err := os.OpenFile(path+".new", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	count, err := pool.Export(output)
+	if err != nil {
+		output.Close()
+		return err
+	}
+	if err := output.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(path+".new", path); err != nil {
+		return err
+	}
+	log.Debug("Saved transaction pool snapshot", "transactions", count)
+	return nil
+}
+
+// loadSnapshot reads a pool snapshot from the given file, skipping it if the
+// file doesn't exist.
+func (pool *TxPool) loadSnapshot(path string) error {
+	input, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer input.Close()
+
+	imported, dropped, err := pool.Import(input)
+	log.Info("Loaded transaction pool snapshot", "transactions", imported, "dropped", dropped)
+	return err
+}
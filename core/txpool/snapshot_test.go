@@ -0,0 +1,128 @@
+// Copyright 2022 The go-ETX Authors
+// This file is part of the go-ETX library.
+//
+// The go-ETX library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ETX library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ETX library. If not, see <http://www.gnu.org/licenses/>.
+
+package txpool
+
+import (
+	"bytes"
+	"math/big"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ETX/go-ETX/common"
+	"github.com/ETX/go-ETX/core/rawdb"
+	"github.com/ETX/go-ETX/core/state"
+	"github.com/ETX/go-ETX/crypto"
+	"github.com/ETX/go-ETX/event"
+	"github.com/ETX/go-ETX/params"
+)
+
+// Tests that the pending and queued transactions of the pool survive an export
+// and import round, retaining their local flags and arrival times.
+func TestPoolExportImport(t *testing.T) {
+	t.Parallel()
+
+	pool, remote := setupPool()
+	defer pool.Stop()
+
+	local, _ := crypto.GenerateKey()
+	testAddBalance(pool, crypto.PubkeyToAddress(remote.PublicKey), big.NewInt(1000000000))
+	testAddBalance(pool, crypto.PubkeyToAddress(local.PublicKey), big.NewInt(1000000000))
+
+	// Add pending and queued transactions from both a remote and local account
+	arrival := time.Now().Add(-time.Hour).Truncate(time.Second)
+	for _, nonce := range []uint64{0, 1, 3} {
+		tx := transaction(nonce, 100000, remote)
+		tx.SetTime(arrival)
+		if err := pool.addRemoteSync(tx); err != nil {
+			t.Fatalf("failed to add remote transaction: %v", err)
+		}
+	}
+	for _, nonce := range []uint64{0, 2} {
+		if err := pool.AddLocal(transaction(nonce, 100000, local)); err != nil {
+			t.Fatalf("failed to add local transaction: %v", err)
+		}
+	}
+	var buf bytes.Buffer
+	if count, err := pool.Export(&buf); err != nil || count != 5 {
+		t.Fatalf("failed to export pool: count %d, err %v", count, err)
+	}
+	// Import the transactions into a fresh pool with the same state
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	restored := NewTxPool(testTxPoolConfig, params.TestChainConfig, &testBlockChain{10000000, statedb, new(event.Feed)})
+	defer restored.Stop()
+	<-restored.initDoneCh
+
+	testAddBalance(restored, crypto.PubkeyToAddress(remote.PublicKey), big.NewInt(1000000000))
+	testAddBalance(restored, crypto.PubkeyToAddress(local.PublicKey), big.NewInt(1000000000))
+
+	imported, dropped, err := restored.Import(&buf)
+	if err != nil {
+		t.Fatalf("failed to import pool: %v", err)
+	}
+	if imported != 5 || dropped != 0 {
+		t.Fatalf("import count mismatch: imported %d, dropped %d", imported, dropped)
+	}
+	if pending, queued := restored.Stats(); pending != 3 || queued != 2 {
+		t.Fatalf("pool stats mismatch: pending %d, queued %d", pending, queued)
+	}
+	if locals := restored.Locals(); len(locals) != 1 || locals[0] != crypto.PubkeyToAddress(local.PublicKey) {
+		t.Fatalf("local accounts mismatch: %v", locals)
+	}
+	if tx := restored.Get(transaction(0, 100000, remote).Hash()); tx == nil || !tx.Time().Equal(arrival) {
+		t.Fatalf("arrival time not restored: %v", tx)
+	}
+	if err := validatePoolInternals(restored); err != nil {
+		t.Fatalf("pool internal state corrupted: %v", err)
+	}
+}
+
+// Tests that the pool snapshot is written on shutdown and reloaded on startup,
+// dropping the transactions invalidated in the meantime.
+func TestPoolSnapshotRestart(t *testing.T) {
+	t.Parallel()
+
+	config := testTxPoolConfig
+	config.Snapshot = filepath.Join(t.TempDir(), "txpool.rlp")
+
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	blockchain := &testBlockChain{10000000, statedb, new(event.Feed)}
+
+	key, _ := crypto.GenerateKey()
+	addr := crypto.PubkeyToAddress(key.PublicKey)
+	statedb.SetBalance(addr, big.NewInt(1000000000))
+
+	pool := NewTxPool(config, params.TestChainConfig, blockchain)
+	<-pool.initDoneCh
+	for nonce := uint64(0); nonce < 3; nonce++ {
+		if err := pool.addRemoteSync(transaction(nonce, 100000, key)); err != nil {
+			t.Fatalf("failed to add transaction: %v", err)
+		}
+	}
+	pool.Stop()
+
+	// Include the first transaction in the meantime and restart the pool
+	statedb.SetNonce(addr, 1)
+
+	pool = NewTxPool(config, params.TestChainConfig, blockchain)
+	defer pool.Stop()
+	<-pool.initDoneCh
+
+	if pending, queued := pool.Stats(); pending != 2 || queued != 0 {
+		t.Fatalf("pool stats mismatch: pending %d, queued %d", pending, queued)
+	}
+}
@@ -167,6 +167,12 @@ type Config struct {
 	GlobalQueue  uint64 // Maximum number of non-executable transaction slots for all accounts
 
 	Lifetime time.Duration // Maximum amount of time non-executable transaction are queued
+
+	Snapshot         string        // Snapshot of all pooled transactions to survive node restarts, empty to disable
+	SnapshotInterval time.Duration // Time interval to regenerate the pool snapshot
+
+	PolicyFile string          // JSON file with the admission rules of the pool, reloadable at runtime
+	Policy     AdmissionPolicy `toml:"-"` // Admission policy to enforce on top of the pool checks, nil for none
 }
 
 // DefaultConfig contains the default configurations for the transaction
@@ -184,6 +190,8 @@ var DefaultConfig = Config{
 	GlobalQueue:  1024,
 
 	Lifetime: 3 * time.Hour,
+
+	SnapshotInterval: 5 * time.Minute,
 }
 
 // sanitize checks the provided user configurations and changes anything that's
@@ -222,6 +230,10 @@ func (config *Config) sanitize() Config {
 		log.Warn("Sanitizing invalid txpool lifetime", "provided", conf.Lifetime, "updated", DefaultConfig.Lifetime)
 		conf.Lifetime = DefaultConfig.Lifetime
 	}
+	if conf.Snapshot != "" && conf.SnapshotInterval < time.Second {
+		log.Warn("Sanitizing invalid txpool snapshot interval", "provided", conf.SnapshotInterval, "updated", DefaultConfig.SnapshotInterval)
+		conf.SnapshotInterval = DefaultConfig.SnapshotInterval
+	}
 	return conf
 }
 
@@ -250,8 +262,9 @@ type TxPool struct {
 	pendingNonces *noncer        // Pending state tracking virtual nonces
 	currentMaxGas uint64         // Current gas limit for transaction caps
 
-	locals  *accountSet // Set of local transaction to exempt from eviction rules
-	journal *journal    // Journal of local transaction to back up to disk
+	locals  *accountSet     // Set of local transaction to exempt from eviction rules
+	journal *journal        // Journal of local transaction to back up to disk
+	policy  AdmissionPolicy // Operator configured admission rules, nil if none
 
 	pending map[common.Address]*list     // All currently processable transactions
 	queue   map[common.Address]*list     // Queued but non-processable transactions
@@ -270,6 +283,12 @@ type TxPool struct {
 	initDoneCh      chan struct{}  // is closed once the pool is initialized (for tests)
 
 	changesSinceReorg int // A counter for how many drops we've performed in-between reorg.
+
+	lifecycleFeed event.Feed                   // Feed of transaction state transitions
+	changes       []*TxStatusChange            // State transitions pending to be posted
+	changesLock   sync.Mutex                   // Protects the pending state transitions
+	changesFlush  sync.Mutex                   // Serialises the posting of state transitions
+	included      map[common.Hash]*types.Block // Pooled transactions included by the head being reset to
 }
 
 type txpoolResetRequest struct {
@@ -300,6 +319,7 @@ func NewTxPool(config Config, chainconfig *params.ChainConfig, chain blockChain)
 		reorgShutdownCh: make(chan struct{}),
 		initDoneCh:      make(chan struct{}),
 		gasPrice:        new(big.Int).SetUint64(config.PriceLimit),
+		policy:          config.Policy,
 	}
 	pool.locals = newAccountSet(pool.signer)
 	for _, addr := range config.Locals {
@@ -324,6 +344,12 @@ func NewTxPool(config Config, chainconfig *params.ChainConfig, chain blockChain)
 			log.Warn("Failed to rotate transaction journal", "err", err)
 		}
 	}
+	// If pool snapshots are enabled, restore the transactions of the last run
+	if config.Snapshot != "" {
+		if err := pool.loadSnapshot(config.Snapshot); err != nil {
+			log.Warn("Failed to load transaction pool snapshot", "err", err)
+		}
+	}
 
 	// Subscribe events from blockchain and start the main event loop.
 	pool.chainHeadSub = pool.chain.SubscribeChainHeadEvent(pool.chainHeadCh)
@@ -352,6 +378,14 @@ func (pool *TxPool) loop() {
 	defer evict.Stop()
 	defer journal.Stop()
 
+	// Start the pool snapshot ticker only if snapshots are enabled
+	var snapshot <-chan time.Time
+	if pool.config.Snapshot != "" {
+		ticker := time.NewTicker(pool.config.SnapshotInterval)
+		defer ticker.Stop()
+		snapshot = ticker.C
+	}
+
 	// Notify tests that the init phase is done
 	close(pool.initDoneCh)
 	for {
@@ -392,12 +426,14 @@ func (pool *TxPool) loop() {
 				if time.Since(pool.beats[addr]) > pool.config.Lifetime {
 					list := pool.queue[addr].Flatten()
 					for _, tx := range list {
+						pool.recordDropped(tx.Hash(), TxDropNonceGap)
 						pool.removeTx(tx.Hash(), true)
 					}
 					queuedEvictionMeter.Mark(int64(len(list)))
 				}
 			}
 			pool.mu.Unlock()
+			pool.flushChanges()
 
 		// Handle local transaction journal rotation
 		case <-journal.C:
@@ -408,6 +444,12 @@ func (pool *TxPool) loop() {
 				}
 				pool.mu.Unlock()
 			}
+
+		// Handle full pool snapshot regeneration
+		case <-snapshot:
+			if err := pool.saveSnapshot(pool.config.Snapshot); err != nil {
+				log.Warn("Failed to save transaction pool snapshot", "err", err)
+			}
 		}
 	}
 }
@@ -424,6 +466,11 @@ func (pool *TxPool) Stop() {
 	if pool.journal != nil {
 		pool.journal.close()
 	}
+	if pool.config.Snapshot != "" {
+		if err := pool.saveSnapshot(pool.config.Snapshot); err != nil {
+			log.Warn("Failed to save transaction pool snapshot", "err", err)
+		}
+	}
 	log.Info("Transaction pool stopped")
 }
 
@@ -444,6 +491,8 @@ func (pool *TxPool) GasPrice() *big.Int {
 // SetGasPrice updates the minimum price required by the transaction pool for a
 // new transaction, and drops all transactions below this threshold.
 func (pool *TxPool) SetGasPrice(price *big.Int) {
+	defer pool.flushChanges()
+
 	pool.mu.Lock()
 	defer pool.mu.Unlock()
 
@@ -454,6 +503,7 @@ func (pool *TxPool) SetGasPrice(price *big.Int) {
 		// pool.priced is sorted by GasFeeCap, so we have to iterate through pool.all instead
 		drop := pool.all.RemotesBelowTip(price)
 		for _, tx := range drop {
+			pool.recordDropped(tx.Hash(), TxDropUnderpriced)
 			pool.removeTx(tx.Hash(), false)
 		}
 		pool.priced.Removed(len(drop))
@@ -462,6 +512,24 @@ func (pool *TxPool) SetGasPrice(price *big.Int) {
 	log.Info("Transaction pool price threshold updated", "price", price)
 }
 
+// SetPolicy replaces the admission policy of the pool. The new policy applies
+// to transactions added afterwards, the ones already in the pool are kept. A
+// nil policy disables the admission rules.
+func (pool *TxPool) SetPolicy(policy AdmissionPolicy) {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	pool.policy = policy
+}
+
+// Policy returns the current admission policy of the pool.
+func (pool *TxPool) Policy() AdmissionPolicy {
+	pool.mu.RLock()
+	defer pool.mu.RUnlock()
+
+	return pool.policy
+}
+
 // Nonce returns the next nonce of an account, with all transactions executable
 // by the pool already applied on top.
 func (pool *TxPool) Nonce(addr common.Address) uint64 {
@@ -644,6 +712,13 @@ func (pool *TxPool) validateTx(tx *types.Transaction, local bool) error {
 	if tx.Gas() < intrGas {
 		return core.ErrIntrinsicGas
 	}
+	// Enforce the operator configured admission rules last, the rate limits
+	// are only charged once the transaction is actually added
+	if pool.policy != nil {
+		if err := pool.policy.Admit(tx, from); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -706,6 +781,7 @@ func (pool *TxPool) add(tx *types.Transaction, local bool) (replaced bool, err e
 		for _, tx := range drop {
 			log.Trace("Discarding freshly underpriced transaction", "hash", tx.Hash(), "gasTipCap", tx.GasTipCap(), "gasFeeCap", tx.GasFeeCap())
 			underpricedTxMeter.Mark(1)
+			pool.recordDropped(tx.Hash(), TxDropUnderpriced)
 			pool.removeTx(tx.Hash(), false)
 		}
 	}
@@ -723,7 +799,9 @@ func (pool *TxPool) add(tx *types.Transaction, local bool) (replaced bool, err e
 			pool.all.Remove(old.Hash())
 			pool.priced.Removed(1)
 			pendingReplaceMeter.Mark(1)
+			pool.recordReplaced(old.Hash(), hash)
 		}
+		pool.recordPending(hash)
 		pool.all.Add(tx, isLocal)
 		pool.priced.Put(tx, isLocal)
 		pool.journalTx(from, tx)
@@ -774,10 +852,12 @@ func (pool *TxPool) enqueueTx(hash common.Hash, tx *types.Transaction, local boo
 		pool.all.Remove(old.Hash())
 		pool.priced.Removed(1)
 		queuedReplaceMeter.Mark(1)
+		pool.recordReplaced(old.Hash(), hash)
 	} else {
 		// Nothing was replaced, bump the queued counter
 		queuedGauge.Inc(1)
 	}
+	pool.recordQueued(hash)
 	// If the transaction isn't in lookup set but it's expected to be there,
 	// show the error log.
 	if pool.all.Get(hash) == nil && !addAll {
@@ -823,6 +903,7 @@ func (pool *TxPool) promoteTx(addr common.Address, hash common.Hash, tx *types.T
 		pool.all.Remove(hash)
 		pool.priced.Removed(1)
 		pendingDiscardMeter.Mark(1)
+		pool.recordDropped(hash, TxDropUnderpriced)
 		return false
 	}
 	// Otherwise discard any previous transaction and mark this
@@ -830,10 +911,12 @@ func (pool *TxPool) promoteTx(addr common.Address, hash common.Hash, tx *types.T
 		pool.all.Remove(old.Hash())
 		pool.priced.Removed(1)
 		pendingReplaceMeter.Mark(1)
+		pool.recordReplaced(old.Hash(), hash)
 	} else {
 		// Nothing was replaced, bump the pending counter
 		pendingGauge.Inc(1)
 	}
+	pool.recordPending(hash)
 	// Set the potentially new pending nonce and notify any subsystems of the new tx
 	pool.pendingNonces.set(addr, tx.Nonce()+1)
 
@@ -919,8 +1002,9 @@ func (pool *TxPool) addTxs(txs []*types.Transaction, local, sync bool) []error {
 
 	// Process all the new transaction and merge any errors into the original slice
 	pool.mu.Lock()
-	newErrs, dirtyAddrs := pool.addTxsLocked(news, local)
+	newErrs, dirtyAddrs := pool.addTxsLocked(news, local, false)
 	pool.mu.Unlock()
+	pool.flushChanges()
 
 	var nilSlot = 0
 	for _, err := range newErrs {
@@ -939,16 +1023,24 @@ func (pool *TxPool) addTxs(txs []*types.Transaction, local, sync bool) []error {
 }
 
 // addTxsLocked attempts to queue a batch of transactions if they are valid.
+// Transactions reinjected after a reorg are not charged to the admission policy.
 // The transaction pool lock must be held.
-func (pool *TxPool) addTxsLocked(txs []*types.Transaction, local bool) ([]error, *accountSet) {
+func (pool *TxPool) addTxsLocked(txs []*types.Transaction, local, reinject bool) ([]error, *accountSet) {
 	dirty := newAccountSet(pool.signer)
 	errs := make([]error, len(txs))
 	for i, tx := range txs {
 		replaced, err := pool.add(tx, local)
 		errs[i] = err
-		if err == nil && !replaced {
+		if err != nil {
+			continue
+		}
+		if !replaced {
 			dirty.addTx(tx)
 		}
+		if pool.policy != nil && !reinject && !local && !pool.locals.containsTx(tx) {
+			from, _ := types.Sender(pool.signer, tx) // already validated
+			pool.policy.Added(tx, from)
+		}
 	}
 	validTxMeter.Mark(int64(len(dirty.accounts)))
 	return errs, dirty
@@ -1156,6 +1248,10 @@ func (pool *TxPool) runReorg(done chan struct{}, reset *txpoolResetRequest, dirt
 	}
 	pool.mu.Lock()
 	if reset != nil {
+		// Collect the pooled transactions included by the new head, so their
+		// removal is reported as inclusion instead of a drop
+		pool.included = pool.includedTxs(reset.oldHead, reset.newHead)
+
 		// Reset from the old head to the new, rescheduling any reorged transactions
 		pool.reset(reset.oldHead, reset.newHead)
 
@@ -1191,6 +1287,7 @@ func (pool *TxPool) runReorg(done chan struct{}, reset *txpoolResetRequest, dirt
 			nonces[addr] = highestPending.Nonce() + 1
 		}
 		pool.pendingNonces.setAll(nonces)
+		pool.included = nil
 	}
 	// Ensure pool.queue and pool.pending sizes stay within the configured limits.
 	pool.truncatePending()
@@ -1199,6 +1296,7 @@ func (pool *TxPool) runReorg(done chan struct{}, reset *txpoolResetRequest, dirt
 	dropBetweenReorgHistogram.Update(int64(pool.changesSinceReorg))
 	pool.changesSinceReorg = 0 // Reset change counter
 	pool.mu.Unlock()
+	pool.flushChanges()
 
 	// Notify subsystems for newly added transactions
 	for _, tx := range promoted {
@@ -1299,7 +1397,7 @@ func (pool *TxPool) reset(oldHead, newHead *types.Header) {
 	// Inject any transactions discarded due to reorgs
 	log.Debug("Reinjecting stale transactions", "count", len(reinject))
 	core.SenderCacher.Recover(pool.signer, reinject)
-	pool.addTxsLocked(reinject, false)
+	pool.addTxsLocked(reinject, false, true)
 
 	// Update all fork indicator by next pending block number.
 	next := new(big.Int).Add(newHead.Number, big.NewInt(1))
@@ -1326,6 +1424,7 @@ func (pool *TxPool) promoteExecutables(accounts []common.Address) []*types.Trans
 		for _, tx := range forwards {
 			hash := tx.Hash()
 			pool.all.Remove(hash)
+			pool.recordStale(hash)
 		}
 		log.Trace("Removed old queued transactions", "count", len(forwards))
 		// Drop all transactions that are too costly (low balance or out of gas)
@@ -1333,6 +1432,7 @@ func (pool *TxPool) promoteExecutables(accounts []common.Address) []*types.Trans
 		for _, tx := range drops {
 			hash := tx.Hash()
 			pool.all.Remove(hash)
+			pool.recordDropped(hash, TxDropUnpayable)
 		}
 		log.Trace("Removed unpayable queued transactions", "count", len(drops))
 		queuedNofundsMeter.Mark(int64(len(drops)))
@@ -1355,6 +1455,7 @@ func (pool *TxPool) promoteExecutables(accounts []common.Address) []*types.Trans
 			for _, tx := range caps {
 				hash := tx.Hash()
 				pool.all.Remove(hash)
+				pool.recordDropped(hash, TxDropEvicted)
 				log.Trace("Removed cap-exceeding queued transaction", "hash", hash)
 			}
 			queuedRateLimitMeter.Mark(int64(len(caps)))
@@ -1417,6 +1518,7 @@ func (pool *TxPool) truncatePending() {
 						// Drop the transaction from the global pools too
 						hash := tx.Hash()
 						pool.all.Remove(hash)
+						pool.recordDropped(hash, TxDropEvicted)
 
 						// Update the account nonce to the dropped transaction
 						pool.pendingNonces.setIfLower(offenders[i], tx.Nonce())
@@ -1444,6 +1546,7 @@ func (pool *TxPool) truncatePending() {
 					// Drop the transaction from the global pools too
 					hash := tx.Hash()
 					pool.all.Remove(hash)
+					pool.recordDropped(hash, TxDropEvicted)
 
 					// Update the account nonce to the dropped transaction
 					pool.pendingNonces.setIfLower(addr, tx.Nonce())
@@ -1490,6 +1593,7 @@ func (pool *TxPool) truncateQueue() {
 		// Drop all transactions if they are less than the overflow
 		if size := uint64(list.Len()); size <= drop {
 			for _, tx := range list.Flatten() {
+				pool.recordDropped(tx.Hash(), TxDropEvicted)
 				pool.removeTx(tx.Hash(), true)
 			}
 			drop -= size
@@ -1499,6 +1603,7 @@ func (pool *TxPool) truncateQueue() {
 		// Otherwise drop only last few transactions
 		txs := list.Flatten()
 		for i := len(txs) - 1; i >= 0 && drop > 0; i-- {
+			pool.recordDropped(txs[i].Hash(), TxDropEvicted)
 			pool.removeTx(txs[i].Hash(), true)
 			drop--
 			queuedRateLimitMeter.Mark(1)
@@ -1523,6 +1628,7 @@ func (pool *TxPool) demoteUnexecutables() {
 		for _, tx := range olds {
 			hash := tx.Hash()
 			pool.all.Remove(hash)
+			pool.recordStale(hash)
 			log.Trace("Removed old pending transaction", "hash", hash)
 		}
 		// Drop all transactions that are too costly (low balance or out of gas), and queue any invalids back for later
@@ -1531,6 +1637,7 @@ func (pool *TxPool) demoteUnexecutables() {
 			hash := tx.Hash()
 			log.Trace("Removed unpayable pending transaction", "hash", hash)
 			pool.all.Remove(hash)
+			pool.recordDropped(hash, TxDropUnpayable)
 		}
 		pendingNofundsMeter.Mark(int64(len(drops)))
 
@@ -22,6 +22,7 @@ import (
 	"time"
 
 	"github.com/ETX/go-ETX/common"
+	"github.com/ETX/go-ETX/core/tracing"
 	"github.com/ETX/go-ETX/crypto"
 	"github.com/ETX/go-ETX/params"
 	"github.com/holiman/uint256"
@@ -354,7 +355,7 @@ func (evm *EVM) StaticCall(caller ContractRef, addr common.Address, input []byte
 	// This doesn't matter on Mainnet, where all empties are gone at the time of Byzantium,
 	// but is the correct thing to do and matters on other networks, in tests, and potential
 	// future scenarios
-	evm.StateDB.AddBalance(addr, big0)
+	evm.StateDB.AddBalance(addr, big0, tracing.BalanceChangeTouchAccount)
 
 	// Invoke tracer hooks that signal entering/exiting a call frame
 	if evm.Config.Debug {
@@ -33,6 +33,7 @@ import (
 	"github.com/ETX/go-ETX/core"
 	"github.com/ETX/go-ETX/core/rawdb"
 	"github.com/ETX/go-ETX/core/state"
+	"github.com/ETX/go-ETX/core/state/pruner"
 	"github.com/ETX/go-ETX/core/types"
 	"github.com/ETX/go-ETX/internal/etxapi"
 	"github.com/ETX/go-ETX/log"
@@ -243,6 +244,29 @@ func (api *AdminAPI) ImportChain(file string) (bool, error) {
 	return true, nil
 }
 
+// Backup starts a consistent backup of the chain database into the backup
+// repository at the given directory. Only the data changed since the previous
+// backup in the repository is written.
+//
+// The backup runs in the background, as the first one of a large database can
+// take hours. Its progress and result are reported by BackupStatus. Only one
+// backup runs at a time.
+func (api *AdminAPI) Backup(dir string) (bool, error) {
+	if dir == "" {
+		return false, errors.New("backup directory not specified")
+	}
+	if err := api.etx.backups.start(api.etx.ChainDb(), dir); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// BackupStatus returns the status of the last backup started by Backup, or nil
+// if none was started since the node is running.
+func (api *AdminAPI) BackupStatus() *BackupStatus {
+	return api.etx.backups.current()
+}
+
 // DebugAPI is the collection of ETX full node APIs for debugging the
 // protocol.
 type DebugAPI struct {
@@ -530,6 +554,21 @@ func (api *DebugAPI) getModifiedAccounts(startBlock, endBlock *types.Block) ([]c
 	return dirty, nil
 }
 
+// PruneStatus returns the progress of the online state pruner.
+func (api *DebugAPI) PruneStatus() (pruner.OnlineStatus, error) {
+	return api.etx.blockchain.PruneStatus()
+}
+
+// PausePrune suspends the online state pruner until it's resumed.
+func (api *DebugAPI) PausePrune() error {
+	return api.etx.blockchain.PausePruning()
+}
+
+// ResumePrune continues the suspended online state pruner.
+func (api *DebugAPI) ResumePrune() error {
+	return api.etx.blockchain.ResumePruning()
+}
+
 // GetAccessibleState returns the first number where the node has accessible
 // state on disk. Note this being the post-state of that block and the pre-state
 // of the next block.
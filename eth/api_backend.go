@@ -128,11 +128,25 @@ func (b *etxAPIBackend) BlockByNumber(ctx context.Context, number rpc.BlockNumbe
 	if number == rpc.SafeBlockNumber {
 		return b.etx.blockchain.CurrentSafeBlock(), nil
 	}
-	return b.etx.blockchain.GetBlockByNumber(uint64(number)), nil
+	block := b.etx.blockchain.GetBlockByNumber(uint64(number))
+	if block == nil {
+		if err := b.etx.blockchain.HistoryPruned(uint64(number)); err != nil {
+			return nil, err
+		}
+	}
+	return block, nil
 }
 
 func (b *etxAPIBackend) BlockByHash(ctx context.Context, hash common.Hash) (*types.Block, error) {
-	return b.etx.blockchain.GetBlockByHash(hash), nil
+	block := b.etx.blockchain.GetBlockByHash(hash)
+	if block == nil {
+		if header := b.etx.blockchain.GetxeaderByHash(hash); header != nil {
+			if err := b.etx.blockchain.HistoryPruned(header.Number.Uint64()); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return block, nil
 }
 
 func (b *etxAPIBackend) BlockByNumberOrHash(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash) (*types.Block, error) {
@@ -149,6 +163,9 @@ func (b *etxAPIBackend) BlockByNumberOrHash(ctx context.Context, blockNrOrHash r
 		}
 		block := b.etx.blockchain.GetBlock(hash, header.Number.Uint64())
 		if block == nil {
+			if err := b.etx.blockchain.HistoryPruned(header.Number.Uint64()); err != nil {
+				return nil, err
+			}
 			return nil, errors.New("header found, but block body is missing")
 		}
 		return block, nil
@@ -200,11 +217,25 @@ func (b *etxAPIBackend) StateAndHeaderByNumberOrHash(ctx context.Context, blockN
 }
 
 func (b *etxAPIBackend) GetReceipts(ctx context.Context, hash common.Hash) (types.Receipts, error) {
-	return b.etx.blockchain.GetReceiptsByHash(hash), nil
+	receipts := b.etx.blockchain.GetReceiptsByHash(hash)
+	if receipts == nil {
+		if number := rawdb.ReadHeaderNumber(b.etx.chainDb, hash); number != nil {
+			if err := b.etx.blockchain.HistoryPruned(*number); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return receipts, nil
 }
 
 func (b *etxAPIBackend) GetLogs(ctx context.Context, hash common.Hash, number uint64) ([][]*types.Log, error) {
-	return rawdb.ReadLogs(b.etx.chainDb, hash, number, b.ChainConfig()), nil
+	logs := rawdb.ReadLogs(b.etx.chainDb, hash, number, b.ChainConfig())
+	if logs == nil {
+		if err := b.etx.blockchain.HistoryPruned(number); err != nil {
+			return nil, err
+		}
+	}
+	return logs, nil
 }
 
 func (b *etxAPIBackend) GetTd(ctx context.Context, hash common.Hash) *big.Int {
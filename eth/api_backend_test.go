@@ -0,0 +1,100 @@
+// Copyright 2022 The go-ETX Authors
+// This file is part of the go-ETX library.
+//
+// The go-ETX library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ETX library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ETX library. If not, see <http://www.gnu.org/licenses/>.
+
+package etx
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ETX/go-ETX/consensus/etxash"
+	"github.com/ETX/go-ETX/core"
+	"github.com/ETX/go-ETX/core/rawdb"
+	"github.com/ETX/go-ETX/core/vm"
+	"github.com/ETX/go-ETX/internal/etxapi"
+	"github.com/ETX/go-ETX/params"
+	"github.com/ETX/go-ETX/rpc"
+)
+
+// Tests that requesting the expired history over the API fails with the pruned
+// history error code.
+func TestPrunedHistoryAPI(t *testing.T) {
+	var (
+		gspec     = &core.Genesis{Config: params.TestChainConfig}
+		engine    = etxash.NewFaker()
+		cacheConf = &core.CacheConfig{TrieCleanLimit: 256, TrieDirtyLimit: 256, TrieTimeLimit: 5 * time.Minute, HistoryBlocks: 4}
+	)
+	_, blocks, _ := core.GenerateChainWithGenesis(gspec, engine, 10, nil)
+
+	db, err := rawdb.NewDatabaseWithFreezer(rawdb.NewMemoryDatabase(), t.TempDir(), "", false)
+	if err != nil {
+		t.Fatalf("failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	chain, err := core.NewBlockChain(db, cacheConf, gspec, nil, engine, vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create tester chain: %v", err)
+	}
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	if err := db.(interface{ Freeze(uint64) error }).Freeze(2); err != nil {
+		t.Fatalf("failed to freeze blocks: %v", err)
+	}
+	chain.Stop()
+
+	// Restart the chain, expiring the frozen history beyond the retained blocks
+	chain, err = core.NewBlockChain(db, cacheConf, gspec, nil, engine, vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to restart tester chain: %v", err)
+	}
+	defer chain.Stop()
+
+	for deadline := time.Now().Add(5 * time.Second); ; {
+		if tail, _ := db.Tail(); tail == 7 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("history not expired")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	backend := &etxAPIBackend{etx: &ETX{blockchain: chain, chainDb: db}}
+
+	server := rpc.NewServer()
+	defer server.Stop()
+	if err := server.RegisterName("etx", etxapi.NewBlockChainAPI(backend)); err != nil {
+		t.Fatalf("failed to register API: %v", err)
+	}
+	client := rpc.DialInProc(server)
+	defer client.Close()
+
+	var block map[string]interface{}
+	err = client.Call(&block, "etx_getBlockByNumber", rpc.BlockNumber(3), false)
+	if rerr := rpc.Error(nil); !errors.As(err, &rerr) || rerr.ErrorCode() != 4444 {
+		t.Fatalf("pruned block error mismatch: have %v, want code 4444", err)
+	}
+	if err := client.Call(&block, "etx_getBlockByNumber", rpc.BlockNumber(7), false); err != nil || block == nil {
+		t.Fatalf("retained block unavailable: %v", err)
+	}
+	var perr *core.PrunedHistoryError
+	if _, err := backend.GetReceipts(context.Background(), blocks[2].Hash()); !errors.As(err, &perr) || perr.Tail != 7 {
+		t.Fatalf("pruned receipts error mismatch: have %v, want tail 7", err)
+	}
+}
@@ -0,0 +1,166 @@
+// Copyright 2022 The go-ETX Authors
+// This file is part of the go-ETX library.
+//
+// The go-ETX library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ETX library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ETX library. If not, see <http://www.gnu.org/licenses/>.
+
+package etx
+
+import (
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/ETX/go-ETX/common/hexutil"
+	"github.com/ETX/go-ETX/core/txpool"
+	"github.com/ETX/go-ETX/log"
+	"github.com/ETX/go-ETX/rpc"
+)
+
+// TxPoolAdminAPI is the collection of transaction pool APIs for node operators,
+// served in the admin namespace.
+type TxPoolAdminAPI struct {
+	etx *ETX
+}
+
+// NewTxPoolAdminAPI creates a new instance of TxPoolAdminAPI.
+func NewTxPoolAdminAPI(etx *ETX) *TxPoolAdminAPI {
+	return &TxPoolAdminAPI{etx: etx}
+}
+
+// SetTxPoolPolicy replaces the admission rules of the transaction pool. If no rules
+// are given, they are reloaded from the policy file the node was started with.
+// The rules apply to transactions added afterwards.
+func (api *TxPoolAdminAPI) SetTxPoolPolicy(rules *txpool.PolicyRules) (bool, error) {
+	if rules == nil {
+		path := api.etx.config.TxPool.PolicyFile
+		if path == "" {
+			return false, errors.New("no txpool policy file configured")
+		}
+		var err error
+		if rules, err = txpool.LoadPolicyRules(path); err != nil {
+			return false, err
+		}
+	}
+	policy, err := txpool.NewPolicy(rules)
+	if err != nil {
+		return false, err
+	}
+	api.etx.txPool.SetPolicy(policy)
+	log.Info("Updated txpool admission policy")
+	return true, nil
+}
+
+// TxPoolPolicy returns the admission rules currently enforced by the transaction pool,
+// or nil if there are none.
+func (api *TxPoolAdminAPI) TxPoolPolicy() *txpool.PolicyRules {
+	if policy, ok := api.etx.txPool.Policy().(*txpool.Policy); ok {
+		return policy.Rules()
+	}
+	return nil
+}
+
+// ExportTxPool writes all the transactions of the pool, both pending and queued, into
+// a local file, returning the number of exported transactions.
+func (api *TxPoolAdminAPI) ExportTxPool(file string) (hexutil.Uint, error) {
+	if _, err := os.Stat(file); err == nil {
+		// File already exists. Allowing overwrite could be a DoS vector,
+		// since the 'file' may point to arbitrary paths on the drive.
+		return 0, errors.New("location would overwrite an existing file")
+	}
+	out, err := os.OpenFile(file, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.ModePerm)
+	if err != nil {
+		return 0, err
+	}
+	defer out.Close()
+
+	var writer io.Writer = out
+	if strings.HasSuffix(file, ".gz") {
+		writer = gzip.NewWriter(writer)
+		defer writer.(*gzip.Writer).Close()
+	}
+	count, err := api.etx.txPool.Export(writer)
+	return hexutil.Uint(count), err
+}
+
+// TxPoolImportResult is the result of an admin_importTxPool API call.
+type TxPoolImportResult struct {
+	Imported hexutil.Uint `json:"imported"`
+	Dropped  hexutil.Uint `json:"dropped"`
+}
+
+// ImportTxPool adds the transactions exported into a local file to the pool,
+// revalidating them against the current head.
+func (api *TxPoolAdminAPI) ImportTxPool(file string) (*TxPoolImportResult, error) {
+	in, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer in.Close()
+
+	var reader io.Reader = in
+	if strings.HasSuffix(file, ".gz") {
+		if reader, err = gzip.NewReader(reader); err != nil {
+			return nil, err
+		}
+	}
+	imported, dropped, err := api.etx.txPool.Import(reader)
+	if err != nil {
+		return nil, err
+	}
+	return &TxPoolImportResult{Imported: hexutil.Uint(imported), Dropped: hexutil.Uint(dropped)}, nil
+}
+
+// TxPoolEventAPI is the collection of transaction pool subscriptions.
+type TxPoolEventAPI struct {
+	etx *ETX
+}
+
+// NewTxPoolEventAPI creates a new instance of TxPoolEventAPI.
+func NewTxPoolEventAPI(etx *ETX) *TxPoolEventAPI {
+	return &TxPoolEventAPI{etx: etx}
+}
+
+// Lifecycle creates a subscription that is notified of every state transition of
+// the pooled transactions: queued, promoted to pending, replaced, dropped (with
+// the reason) and included in a block.
+func (api *TxPoolEventAPI) Lifecycle(ctx context.Context) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+	rpcSub := notifier.CreateSubscription()
+
+	go func() {
+		events := make(chan txpool.TxLifecycleEvent, 128)
+		sub := api.etx.txPool.SubscribeLifecycleEvent(events)
+		defer sub.Unsubscribe()
+
+		for {
+			select {
+			case ev := <-events:
+				for _, change := range ev.Changes {
+					notifier.Notify(rpcSub.ID, change)
+				}
+			case <-rpcSub.Err():
+				return
+			case <-notifier.Closed():
+				return
+			}
+		}
+	}()
+	return rpcSub, nil
+}
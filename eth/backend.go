@@ -18,6 +18,7 @@
 package etx
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"math/big"
@@ -43,6 +44,7 @@ import (
 	"github.com/ETX/go-ETX/etx/gasprice"
 	"github.com/ETX/go-ETX/etx/protocols/etx"
 	"github.com/ETX/go-ETX/etx/protocols/snap"
+	"github.com/ETX/go-ETX/etx/tracers"
 	"github.com/ETX/go-ETX/etxdb"
 	"github.com/ETX/go-ETX/event"
 	"github.com/ETX/go-ETX/internal/etxapi"
@@ -85,6 +87,8 @@ type ETX struct {
 	bloomIndexer      *core.ChainIndexer             // Bloom indexer operating during block imports
 	closeBloomHandler chan struct{}
 
+	traceIndexer *core.ChainIndexer // Trace indexer operating during block imports, nil if disabled
+
 	APIBackend *etxAPIBackend
 
 	miner     *miner.Miner
@@ -94,11 +98,14 @@ type ETX struct {
 	networkID     uint64
 	netRPCService *etxapi.NetAPI
 
-	p2pServer *p2p.Server
+	p2pServer     *p2p.Server
+	peerLimitsSub event.Subscription // Subscription to the runtime changes of the peer limits
 
 	lock sync.RWMutex // Protects the variadic fields (e.g. gas price and etxerbase)
 
 	shutdownTracker *shutdowncheck.ShutdownTracker // Tracks if and when the node has shutdown ungracefully
+
+	backups backupRunner // Database backups started over the admin API
 }
 
 // New creates a new ETX object (including the
@@ -191,6 +198,11 @@ func New(stack *node.Node, config *etxconfig.Config) (*ETX, error) {
 			TrieTimeLimit:       config.TrieTimeout,
 			SnapshotLimit:       config.SnapshotCache,
 			Preimages:           config.Preimages,
+			StateScheme:         config.StateScheme,
+			StateHistory:        config.StateHistory,
+			OnlinePrune:         config.StatePruneOnline,
+			OnlinePruneInterval: config.StatePruneInterval,
+			HistoryBlocks:       config.HistoryBlocks,
 		}
 	)
 	// Override the chain config with provided settings.
@@ -205,11 +217,40 @@ func New(stack *node.Node, config *etxconfig.Config) (*ETX, error) {
 	if err != nil {
 		return nil, err
 	}
+	// Snap sync heals the state by node hash, which the path-based scheme
+	// can't store. Refuse to start if it would run on an empty chain.
+	if config.SyncMode == downloader.SnapSync && etx.blockchain.StateCache().TrieDB().Scheme() == rawdb.PathScheme && etx.blockchain.CurrentBlock().NumberU64() == 0 {
+		etx.blockchain.Stop()
+		return nil, errors.New("snap sync is not supported with the path-based state scheme, use --syncmode=full")
+	}
 	etx.bloomIndexer.Start(etx.blockchain)
 
+	// Attach the live tracer to the block import if requested
+	if config.LiveTracer != "" {
+		if err := setupLiveTracing(etx.blockchain, config); err != nil {
+			return nil, err
+		}
+		log.Info("Enabled live tracing", "tracer", config.LiveTracer, "sink", config.LiveTraceSink)
+	}
 	if config.TxPool.Journal != "" {
 		config.TxPool.Journal = stack.ResolvePath(config.TxPool.Journal)
 	}
+	if config.TxPool.Snapshot != "" {
+		config.TxPool.Snapshot = stack.ResolvePath(config.TxPool.Snapshot)
+	}
+	if config.TxPool.PolicyFile != "" {
+		config.TxPool.PolicyFile = stack.ResolvePath(config.TxPool.PolicyFile)
+
+		rules, err := txpool.LoadPolicyRules(config.TxPool.PolicyFile)
+		if err != nil {
+			return nil, err
+		}
+		policy, err := txpool.NewPolicy(rules)
+		if err != nil {
+			return nil, fmt.Errorf("invalid txpool policy: %w", err)
+		}
+		config.TxPool.Policy = policy
+	}
 	etx.txPool = txpool.NewTxPool(config.TxPool, etx.blockchain.Config(), etx.blockchain)
 
 	// Permit the downloader to use the trie cache allowance during fast sync
@@ -246,6 +287,20 @@ func New(stack *node.Node, config *etxconfig.Config) (*ETX, error) {
 	}
 	etx.APIBackend.gpo = gasprice.NewOracle(etx.APIBackend, gpoParams)
 
+	// Start the trace indexer if requested
+	if config.TraceIndex != "" {
+		var tracerConfig json.RawMessage
+		if config.TraceIndexConfig != "" {
+			tracerConfig = json.RawMessage(config.TraceIndexConfig)
+		}
+		etx.traceIndexer, err = tracers.NewTraceIndexer(etx.APIBackend, config.TraceIndex, tracerConfig)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trace index config: %w", err)
+		}
+		etx.traceIndexer.Start(etx.blockchain)
+		log.Info("Enabled trace indexing", "tracer", config.TraceIndex)
+	}
+
 	// Setup DNS discovery iterators.
 	dnsclient := dnsdisc.NewClient(dnsdisc.Config{})
 	etx.etxDialCandidates, err = dnsclient.NewIterator(etx.config.etxDiscoveryURLs...)
@@ -288,6 +343,30 @@ func makeExtraData(extra []byte) []byte {
 	return extra
 }
 
+// setupLiveTracing attaches the configured live tracer and sink to the chain.
+func setupLiveTracing(chain *core.BlockChain, config *etxconfig.Config) error {
+	if config.LiveTraceSink == "" {
+		return errors.New("live tracer requires a trace sink")
+	}
+	var tracerConfig json.RawMessage
+	if config.LiveTracerConfig != "" {
+		tracerConfig = json.RawMessage(config.LiveTracerConfig)
+	}
+	factory, err := tracers.NewLiveTracerFactory(config.LiveTracer, tracerConfig)
+	if err != nil {
+		return fmt.Errorf("invalid live tracer config: %w", err)
+	}
+	sink, err := tracers.OpenLiveTraceSink(config.LiveTraceSink)
+	if err != nil {
+		return fmt.Errorf("failed to open live trace sink: %w", err)
+	}
+	if err := chain.SetLiveTracer(factory, sink); err != nil {
+		sink.Close()
+		return err
+	}
+	return nil
+}
+
 // APIs return the collection of RPC services the ETX package offers.
 // NOTE, some of these services probably need to be moved to somewhere else.
 func (s *ETX) APIs() []rpc.API {
@@ -310,6 +389,12 @@ func (s *ETX) APIs() []rpc.API {
 		}, {
 			Namespace: "admin",
 			Service:   NewAdminAPI(s),
+		}, {
+			Namespace: "admin",
+			Service:   NewTxPoolAdminAPI(s),
+		}, {
+			Namespace: "txpool",
+			Service:   NewTxPoolEventAPI(s),
 		}, {
 			Namespace: "debug",
 			Service:   NewDebugAPI(s),
@@ -521,28 +606,67 @@ func (s *ETX) Start() error {
 	s.shutdownTracker.Start()
 
 	// Figure out a max peers count based on the server limits
-	maxPeers := s.p2pServer.MaxPeers
-	if s.config.LightServ > 0 {
-		if s.config.LightPeers >= s.p2pServer.MaxPeers {
-			return fmt.Errorf("invalid peer config: light peer count (%d) >= total peer count (%d)", s.config.LightPeers, s.p2pServer.MaxPeers)
-		}
-		maxPeers -= s.config.LightPeers
+	maxPeers, err := s.etxPeerLimit(s.p2pServer.MaxPeers)
+	if err != nil {
+		return err
 	}
 	// Start the networking layer and the light server if requested
 	s.handler.Start(maxPeers)
+
+	// Follow the changes of the server limits made at runtime
+	limitsCh := make(chan p2p.PeerLimits, 1)
+	s.peerLimitsSub = s.p2pServer.SubscribePeerLimits(limitsCh)
+	go s.peerLimitsLoop(limitsCh)
 	return nil
 }
 
+// etxPeerLimit returns the maximum number of etx peers for the given total
+// peer limit of the server.
+func (s *ETX) etxPeerLimit(total int) (int, error) {
+	if s.config.LightServ > 0 {
+		if s.config.LightPeers >= total {
+			return 0, fmt.Errorf("invalid peer config: light peer count (%d) >= total peer count (%d)", s.config.LightPeers, total)
+		}
+		return total - s.config.LightPeers, nil
+	}
+	return total, nil
+}
+
+// peerLimitsLoop applies the changes of the server limits to the etx handler.
+func (s *ETX) peerLimitsLoop(limitsCh chan p2p.PeerLimits) {
+	for {
+		select {
+		case limits := <-limitsCh:
+			maxPeers, err := s.etxPeerLimit(limits.MaxPeers)
+			if err != nil {
+				log.Warn("Ignoring peer limit change", "err", err)
+				continue
+			}
+			s.handler.setPeerLimit(maxPeers)
+
+		case <-s.peerLimitsSub.Err():
+			return
+		}
+	}
+}
+
 // Stop implements node.Lifecycle, terminating all internal goroutines used by the
 // ETX protocol.
 func (s *ETX) Stop() error {
 	// Stop all the peer-related stuff first.
 	s.etxDialCandidates.Close()
 	s.snapDialCandidates.Close()
+	if s.peerLimitsSub != nil {
+		s.peerLimitsSub.Unsubscribe()
+	}
 	s.handler.Stop()
 
 	// Then stop everything else.
+	s.backups.stop()
 	s.bloomIndexer.Close()
+	if s.traceIndexer != nil {
+		s.traceIndexer.Close()
+	}
 	close(s.closeBloomHandler)
 	s.txPool.Stop()
 	s.miner.Close()
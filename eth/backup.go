@@ -0,0 +1,113 @@
+// Copyright 2022 The go-ETX Authors
+// This file is part of the go-ETX library.
+//
+// The go-ETX library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ETX library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ETX library. If not, see <http://www.gnu.org/licenses/>.
+
+package etx
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ETX/go-ETX/core/rawdb"
+	"github.com/ETX/go-ETX/etxdb"
+	"github.com/ETX/go-ETX/log"
+)
+
+// BackupStatus reports the state of a database backup started over the API.
+type BackupStatus struct {
+	Dir     string             `json:"dir"`
+	Started time.Time          `json:"started"`
+	Running bool               `json:"running"`
+	Stats   *rawdb.BackupStats `json:"stats,omitempty"` // Result of the completed backup
+	Error   string             `json:"error,omitempty"` // Failure of the backup, if any
+}
+
+// backupRunner runs database backups in the background, one at a time. Running
+// backups are aborted when the node shuts down.
+type backupRunner struct {
+	status *BackupStatus // Status of the last backup started, nil if none
+	abort  chan struct{}
+	closed bool
+	wg     sync.WaitGroup
+	lock   sync.Mutex
+}
+
+// start begins a backup of the database into the given repository, unless one
+// is already running.
+func (r *backupRunner) start(db etxdb.Database, dir string) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.closed {
+		return errors.New("node is shutting down")
+	}
+	if r.status != nil && r.status.Running {
+		return fmt.Errorf("backup into %s already running", r.status.Dir)
+	}
+	if r.abort == nil {
+		r.abort = make(chan struct{})
+	}
+	status := &BackupStatus{Dir: dir, Started: time.Now(), Running: true}
+	r.status = status
+
+	r.wg.Add(1)
+	go func(abort chan struct{}) {
+		defer r.wg.Done()
+
+		stats, err := rawdb.Backup(db, dir, abort)
+		if err != nil {
+			log.Error("Database backup failed", "dir", dir, "err", err)
+		}
+		r.lock.Lock()
+		defer r.lock.Unlock()
+
+		status.Running = false
+		status.Stats = stats
+		if err != nil {
+			status.Error = err.Error()
+		}
+	}(r.abort)
+	return nil
+}
+
+// current returns a copy of the status of the last backup, or nil if none was
+// started yet.
+func (r *backupRunner) current() *BackupStatus {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.status == nil {
+		return nil
+	}
+	status := *r.status
+	return &status
+}
+
+// stop aborts the running backup and waits for it to return. No more backups
+// can be started afterwards.
+func (r *backupRunner) stop() {
+	r.lock.Lock()
+	if !r.closed {
+		r.closed = true
+		if r.abort != nil {
+			close(r.abort)
+		}
+	}
+	r.lock.Unlock()
+
+	r.wg.Wait()
+}
@@ -0,0 +1,57 @@
+// Copyright 2022 The go-ETX Authors
+// This file is part of the go-ETX library.
+//
+// The go-ETX library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ETX library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ETX library. If not, see <http://www.gnu.org/licenses/>.
+
+package etx
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ETX/go-ETX/core/rawdb"
+)
+
+// Tests that backups run in the background one at a time, and that none can be
+// started once the runner is stopped.
+func TestBackupRunner(t *testing.T) {
+	db, err := rawdb.NewDatabaseWithFreezer(rawdb.NewMemoryDatabase(), t.TempDir(), "", false)
+	if err != nil {
+		t.Fatal("failed to create database:", err)
+	}
+	defer db.Close()
+
+	var runner backupRunner
+	if status := runner.current(); status != nil {
+		t.Fatalf("status reported before any backup: %+v", status)
+	}
+	dir := t.TempDir()
+	if err := runner.start(db, dir); err != nil {
+		t.Fatal("failed to start backup:", err)
+	}
+	for runner.current().Running {
+		if err := runner.start(db, t.TempDir()); err == nil {
+			t.Fatal("concurrent backup started")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	status := runner.current()
+	if status.Error != "" || status.Stats == nil || status.Dir != dir {
+		t.Fatalf("unexpected backup status: %+v", status)
+	}
+	runner.stop()
+	if err := runner.start(db, dir); err == nil {
+		t.Fatal("backup started after stop")
+	}
+}
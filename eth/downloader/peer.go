@@ -29,6 +29,7 @@ import (
 	"github.com/ETX/go-ETX/etx/protocols/etx"
 	"github.com/ETX/go-ETX/event"
 	"github.com/ETX/go-ETX/log"
+	"github.com/ETX/go-ETX/p2p"
 	"github.com/ETX/go-ETX/p2p/msgrate"
 )
 
@@ -111,18 +112,51 @@ func (p *peerConnection) Reset() {
 // the current measurement.
 func (p *peerConnection) UpdateHeaderRate(delivered int, elapsed time.Duration) {
 	p.rates.Update(etx.BlockHeadersMsg, elapsed, delivered)
+	p.report(delivered, elapsed)
 }
 
 // UpdateBodyRate updates the peer's estimated body retrieval throughput with the
 // current measurement.
 func (p *peerConnection) UpdateBodyRate(delivered int, elapsed time.Duration) {
 	p.rates.Update(etx.BlockBodiesMsg, elapsed, delivered)
+	p.report(delivered, elapsed)
 }
 
 // UpdateReceiptRate updates the peer's estimated receipt retrieval throughput
 // with the current measurement.
 func (p *peerConnection) UpdateReceiptRate(delivered int, elapsed time.Duration) {
 	p.rates.Update(etx.ReceiptsMsg, elapsed, delivered)
+	p.report(delivered, elapsed)
+}
+
+// reputationPeer is implemented by the peers whose reputation can be reported
+// to the p2p layer.
+type reputationPeer interface {
+	Report(event p2p.PeerScoreEvent)
+}
+
+// report applies the outcome of a request to the reputation of the peer. A zero
+// elapsed time denotes a timed out request.
+func (p *peerConnection) report(delivered int, elapsed time.Duration) {
+	rp, ok := p.peer.(reputationPeer)
+	if !ok {
+		return
+	}
+	switch {
+	case delivered > 0:
+		rp.Report(p2p.PeerGoodResponse)
+	case elapsed == 0:
+		rp.Report(p2p.PeerTimeout)
+	default:
+		rp.Report(p2p.PeerUselessResponse)
+	}
+}
+
+// misbehaved reports the delivery of invalid data to the reputation of the peer.
+func (p *peerConnection) misbehaved() {
+	if rp, ok := p.peer.(reputationPeer); ok {
+		rp.Report(p2p.PeerMisbehaved)
+	}
 }
 
 // HeaderCapacity retrieves the peer's header download allowance based on its
@@ -922,6 +922,9 @@ func (s *skeleton) processResponse(res *headerResponse) (linked bool, merged boo
 			for i := 0; i < requestHeaders; i++ {
 				s.scratchSpace[i] = nil
 			}
+			if peer := s.peers.Peer(s.scratchOwners[0]); peer != nil {
+				peer.misbehaved()
+			}
 			s.drop(s.scratchOwners[0])
 			s.scratchOwners[0] = ""
 			break
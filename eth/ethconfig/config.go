@@ -85,6 +85,8 @@ var Defaults = Config{
 	TrieDirtyCache:          256,
 	TrieTimeout:             60 * time.Minute,
 	SnapshotCache:           102,
+	StateHistory:            90000,
+	StatePruneInterval:      24 * time.Hour,
 	FilterLogCacheSize:      32,
 	Miner:                   miner.DefaultConfig,
 	TxPool:                  txpool.DefaultConfig,
@@ -136,6 +138,7 @@ type Config struct {
 	NoPrefetch bool // Whetxer to disable prefetching and only load state on demand
 
 	TxLookupLimit uint64 `toml:",omitempty"` // The maximum number of blocks from head whose tx indices are reserved.
+	HistoryBlocks uint64 `toml:",omitempty"` // The number of recent blocks whose bodies and receipts are retained, 0 for all.
 
 	// RequiredBlocks is a set of block number -> hash mappings which must be in the
 	// canonical chain of all remote peers. Setting the option makes getx verify the
@@ -169,6 +172,10 @@ type Config struct {
 	TrieTimeout             time.Duration
 	SnapshotCache           int
 	Preimages               bool
+	StateScheme             string        `toml:",omitempty"` // State scheme used to store ETX state and merkle trie nodes on top
+	StateHistory            uint64        // Number of recent persisted states to keep reverse diffs for (path-based scheme only)
+	StatePruneOnline        bool          `toml:",omitempty"` // Whetxer to prune the stale state in the background (hash-based scheme only)
+	StatePruneInterval      time.Duration `toml:",omitempty"` // Time to wait between two background state pruning cycles
 
 	// This is the number of blocks for which logs will be cached in the filter system.
 	FilterLogCacheSize int
@@ -188,6 +195,17 @@ type Config struct {
 	// Enables tracking of SHA3 preimages in the VM
 	EnablePreimageRecording bool
 
+	// Trace indexing options, persisting the output of the named tracer for
+	// every canonical transaction. Empty tracer disables the index.
+	TraceIndex       string `toml:",omitempty"`
+	TraceIndexConfig string `toml:",omitempty"`
+
+	// Live tracing options, running the named tracer during block import and
+	// streaming its output into the given sink. Empty tracer disables it.
+	LiveTracer       string `toml:",omitempty"`
+	LiveTracerConfig string `toml:",omitempty"`
+	LiveTraceSink    string `toml:",omitempty"`
+
 	// Miscellaneous options
 	DocRoot string `toml:"-"`
 
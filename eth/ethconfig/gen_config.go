@@ -28,6 +28,7 @@ func (c Config) MarshalTOML() (interface{}, error) {
 		NoPruning                             bool
 		NoPrefetch                            bool
 		TxLookupLimit                         uint64                 `toml:",omitempty"`
+		HistoryBlocks                         uint64                 `toml:",omitempty"`
 		RequiredBlocks                        map[uint64]common.Hash `toml:"-"`
 		LightServ                             int                    `toml:",omitempty"`
 		LightIngress                          int                    `toml:",omitempty"`
@@ -50,12 +51,21 @@ func (c Config) MarshalTOML() (interface{}, error) {
 		TrieTimeout                           time.Duration
 		SnapshotCache                         int
 		Preimages                             bool
+		StateScheme                           string `toml:",omitempty"`
+		StateHistory                          uint64
+		StatePruneOnline                      bool          `toml:",omitempty"`
+		StatePruneInterval                    time.Duration `toml:",omitempty"`
 		FilterLogCacheSize                    int
 		Miner                                 miner.Config
 		etxash                                etxash.Config
 		TxPool                                txpool.Config
 		GPO                                   gasprice.Config
 		EnablePreimageRecording               bool
+		TraceIndex                            string `toml:",omitempty"`
+		TraceIndexConfig                      string `toml:",omitempty"`
+		LiveTracer                            string `toml:",omitempty"`
+		LiveTracerConfig                      string `toml:",omitempty"`
+		LiveTraceSink                         string `toml:",omitempty"`
 		DocRoot                               string `toml:"-"`
 		RPCGasCap                             uint64
 		RPCEVMTimeout                         time.Duration
@@ -75,6 +85,7 @@ func (c Config) MarshalTOML() (interface{}, error) {
 	enc.NoPruning = c.NoPruning
 	enc.NoPrefetch = c.NoPrefetch
 	enc.TxLookupLimit = c.TxLookupLimit
+	enc.HistoryBlocks = c.HistoryBlocks
 	enc.RequiredBlocks = c.RequiredBlocks
 	enc.LightServ = c.LightServ
 	enc.LightIngress = c.LightIngress
@@ -97,12 +108,21 @@ func (c Config) MarshalTOML() (interface{}, error) {
 	enc.TrieTimeout = c.TrieTimeout
 	enc.SnapshotCache = c.SnapshotCache
 	enc.Preimages = c.Preimages
+	enc.StateScheme = c.StateScheme
+	enc.StateHistory = c.StateHistory
+	enc.StatePruneOnline = c.StatePruneOnline
+	enc.StatePruneInterval = c.StatePruneInterval
 	enc.FilterLogCacheSize = c.FilterLogCacheSize
 	enc.Miner = c.Miner
 	enc.etxash = c.etxash
 	enc.TxPool = c.TxPool
 	enc.GPO = c.GPO
 	enc.EnablePreimageRecording = c.EnablePreimageRecording
+	enc.TraceIndex = c.TraceIndex
+	enc.TraceIndexConfig = c.TraceIndexConfig
+	enc.LiveTracer = c.LiveTracer
+	enc.LiveTracerConfig = c.LiveTracerConfig
+	enc.LiveTraceSink = c.LiveTraceSink
 	enc.DocRoot = c.DocRoot
 	enc.RPCGasCap = c.RPCGasCap
 	enc.RPCEVMTimeout = c.RPCEVMTimeout
@@ -126,6 +146,7 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 		NoPruning                             *bool
 		NoPrefetch                            *bool
 		TxLookupLimit                         *uint64                `toml:",omitempty"`
+		HistoryBlocks                         *uint64                `toml:",omitempty"`
 		RequiredBlocks                        map[uint64]common.Hash `toml:"-"`
 		LightServ                             *int                   `toml:",omitempty"`
 		LightIngress                          *int                   `toml:",omitempty"`
@@ -148,12 +169,21 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 		TrieTimeout                           *time.Duration
 		SnapshotCache                         *int
 		Preimages                             *bool
+		StateScheme                           *string `toml:",omitempty"`
+		StateHistory                          *uint64
+		StatePruneOnline                      *bool          `toml:",omitempty"`
+		StatePruneInterval                    *time.Duration `toml:",omitempty"`
 		FilterLogCacheSize                    *int
 		Miner                                 *miner.Config
 		etxash                                *etxash.Config
 		TxPool                                *txpool.Config
 		GPO                                   *gasprice.Config
 		EnablePreimageRecording               *bool
+		TraceIndex                            *string `toml:",omitempty"`
+		TraceIndexConfig                      *string `toml:",omitempty"`
+		LiveTracer                            *string `toml:",omitempty"`
+		LiveTracerConfig                      *string `toml:",omitempty"`
+		LiveTraceSink                         *string `toml:",omitempty"`
 		DocRoot                               *string `toml:"-"`
 		RPCGasCap                             *uint64
 		RPCEVMTimeout                         *time.Duration
@@ -192,6 +222,9 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 	if dec.TxLookupLimit != nil {
 		c.TxLookupLimit = *dec.TxLookupLimit
 	}
+	if dec.HistoryBlocks != nil {
+		c.HistoryBlocks = *dec.HistoryBlocks
+	}
 	if dec.RequiredBlocks != nil {
 		c.RequiredBlocks = dec.RequiredBlocks
 	}
@@ -258,6 +291,18 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 	if dec.Preimages != nil {
 		c.Preimages = *dec.Preimages
 	}
+	if dec.StateScheme != nil {
+		c.StateScheme = *dec.StateScheme
+	}
+	if dec.StateHistory != nil {
+		c.StateHistory = *dec.StateHistory
+	}
+	if dec.StatePruneOnline != nil {
+		c.StatePruneOnline = *dec.StatePruneOnline
+	}
+	if dec.StatePruneInterval != nil {
+		c.StatePruneInterval = *dec.StatePruneInterval
+	}
 	if dec.FilterLogCacheSize != nil {
 		c.FilterLogCacheSize = *dec.FilterLogCacheSize
 	}
@@ -276,6 +321,21 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 	if dec.EnablePreimageRecording != nil {
 		c.EnablePreimageRecording = *dec.EnablePreimageRecording
 	}
+	if dec.TraceIndex != nil {
+		c.TraceIndex = *dec.TraceIndex
+	}
+	if dec.TraceIndexConfig != nil {
+		c.TraceIndexConfig = *dec.TraceIndexConfig
+	}
+	if dec.LiveTracer != nil {
+		c.LiveTracer = *dec.LiveTracer
+	}
+	if dec.LiveTracerConfig != nil {
+		c.LiveTracerConfig = *dec.LiveTracerConfig
+	}
+	if dec.LiveTraceSink != nil {
+		c.LiveTraceSink = *dec.LiveTraceSink
+	}
 	if dec.DocRoot != nil {
 		c.DocRoot = *dec.DocRoot
 	}
@@ -102,7 +102,7 @@ type handler struct {
 	database etxdb.Database
 	txpool   txPool
 	chain    *core.BlockChain
-	maxPeers int
+	maxPeers int32 // Maximum number of etx peers, accessed atomically
 
 	downloader   *downloader.Downloader
 	blockFetcher *fetcher.BlockFetcher
@@ -292,7 +292,7 @@ func newHandler(config *handlerConfig) (*handler, error) {
 		}
 		return n, err
 	}
-	h.blockFetcher = fetcher.NewBlockFetcher(false, nil, h.chain.GetBlockByHash, validator, h.BroadcastBlock, heighter, nil, inserter, h.removePeer)
+	h.blockFetcher = fetcher.NewBlockFetcher(false, nil, h.chain.GetBlockByHash, validator, h.BroadcastBlock, heighter, nil, inserter, h.removePeer, h.invalidBlockPeer)
 
 	fetchTx := func(peer string, hashes []common.Hash) error {
 		p := h.peers.peer(peer)
@@ -349,7 +349,7 @@ func (h *handler) runetxPeer(peer *etx.Peer, handler etx.Handler) error {
 	}
 	// Ignore maxPeers if this is a trusted peer
 	if !peer.Peer.Info().Network.Trusted {
-		if reject || h.peers.len() >= h.maxPeers {
+		if reject || h.peers.len() >= h.peerLimit() {
 			return p2p.DiscTooManyPeers
 		}
 	}
@@ -432,6 +432,7 @@ func (h *handler) runetxPeer(peer *etx.Peer, handler etx.Handler) error {
 
 			case <-timeout.C:
 				peer.Log().Warn("Checkpoint challenge timed out, dropping", "addr", peer.RemoteAddr(), "type", peer.Name())
+				peer.Peer.Report(p2p.PeerTimeout)
 				h.removePeer(peer.ID())
 
 			case <-dead:
@@ -477,6 +478,7 @@ func (h *handler) runetxPeer(peer *etx.Peer, handler etx.Handler) error {
 				res.Done <- nil
 			case <-timeout.C:
 				peer.Log().Warn("Required block challenge timed out, dropping", "addr", peer.RemoteAddr(), "type", peer.Name())
+				peer.Peer.Report(p2p.PeerTimeout)
 				h.removePeer(peer.ID())
 			}
 		}(number, hash, req)
@@ -508,6 +510,33 @@ func (h *handler) removePeer(id string) {
 	}
 }
 
+// peerLimit returns the maximum number of etx peers.
+func (h *handler) peerLimit() int {
+	return int(atomic.LoadInt32(&h.maxPeers))
+}
+
+// setPeerLimit changes the maximum number of etx peers. Surplus peers are not
+// disconnected, the p2p server takes care of enforcing its own limits.
+func (h *handler) setPeerLimit(maxPeers int) {
+	atomic.StoreInt32(&h.maxPeers, int32(maxPeers))
+}
+
+// invalidBlockPeer lowers the reputation of a peer propagating an invalid block
+// and requests its disconnection.
+func (h *handler) invalidBlockPeer(id string) {
+	h.punishPeer(id, p2p.PeerInvalidBlock)
+}
+
+// punishPeer applies a protocol event to the reputation of a peer, so it's not
+// dialed again right away, and requests its disconnection.
+func (h *handler) punishPeer(id string, event p2p.PeerScoreEvent) {
+	peer := h.peers.peer(id)
+	if peer != nil {
+		peer.Peer.Report(event)
+		peer.Peer.Disconnect(p2p.DiscUselessPeer)
+	}
+}
+
 // unregisterPeer removes a peer from the downloader, fetchers and main peer set.
 func (h *handler) unregisterPeer(id string) {
 	// Create a custom logger to avoid printing the entire id
@@ -540,7 +569,7 @@ func (h *handler) unregisterPeer(id string) {
 }
 
 func (h *handler) Start(maxPeers int) {
-	h.maxPeers = maxPeers
+	h.setPeerLimit(maxPeers)
 
 	// broadcast transactions
 	h.wg.Add(1)
@@ -119,11 +119,25 @@ func MakeProtocols(backend Backend, network uint64, dnsdisc enode.Iterator) []p2
 			},
 			Attributes:     []enr.Entry{currentENREntry(backend.Chain())},
 			DialCandidates: dnsdisc,
+			Stream:         messageStream,
 		}
 	}
 	return protocols
 }
 
+// messageStream assigns the messages to the streams of multiplexing transports,
+// separating block propagation and transaction exchange from the sync requests.
+func messageStream(code uint64) uint8 {
+	switch code {
+	case NewBlockHashesMsg, NewBlockMsg:
+		return 1
+	case TransactionsMsg, NewPooledTransactionHashesMsg, GetPooledTransactionsMsg, PooledTransactionsMsg:
+		return 2
+	default:
+		return 0
+	}
+}
+
 // NodeInfo represents a short summary of the `etx` sub-protocol metadata
 // known about the host peer.
 type NodeInfo struct {
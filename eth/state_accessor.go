@@ -23,6 +23,7 @@ import (
 
 	"github.com/ETX/go-ETX/common"
 	"github.com/ETX/go-ETX/core"
+	"github.com/ETX/go-ETX/core/rawdb"
 	"github.com/ETX/go-ETX/core/state"
 	"github.com/ETX/go-ETX/core/types"
 	"github.com/ETX/go-ETX/core/vm"
@@ -76,6 +77,17 @@ func (etx *ETX) StateAtBlock(block *types.Block, reexec uint64, base *state.Stat
 			}, nil
 		}
 	}
+	// The path-based scheme keeps no historical states on disk and its trie
+	// nodes can't be resolved through an ephemeral database. All the states it
+	// holds are newer than the persistent one, so there's no older state to
+	// re-execute from either. Unless a base state is given, only the states of
+	// the recent blocks held by the live database are available.
+	if base == nil && etx.blockchain.StateCache().TrieDB().Scheme() == rawdb.PathScheme {
+		if statedb, err = etx.blockchain.StateAt(block.Root()); err != nil {
+			return nil, nil, fmt.Errorf("historical state %#x is not available in path-based scheme, only the states of the last %d blocks are kept", block.Root(), core.TriesInMemory)
+		}
+		return statedb, noopReleaser, nil
+	}
 	// The state is both for reading and writing, or it's unavailable in disk,
 	// try to construct/recover the state over an ephemeral trie.Database for
 	// isolating the live one.
@@ -170,8 +170,8 @@ func (cs *chainSyncer) nextSyncOp() *chainSyncOp {
 	minPeers := defaultMinSyncPeers
 	if cs.forced {
 		minPeers = 1
-	} else if minPeers > cs.handler.maxPeers {
-		minPeers = cs.handler.maxPeers
+	} else if limit := cs.handler.peerLimit(); minPeers > limit {
+		minPeers = limit
 	}
 	if cs.handler.peers.len() < minPeers {
 		return nil
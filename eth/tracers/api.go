@@ -34,6 +34,7 @@ import (
 	"github.com/ETX/go-ETX/core"
 	"github.com/ETX/go-ETX/core/rawdb"
 	"github.com/ETX/go-ETX/core/state"
+	"github.com/ETX/go-ETX/core/tracing"
 	"github.com/ETX/go-ETX/core/types"
 	"github.com/ETX/go-ETX/core/vm"
 	"github.com/ETX/go-ETX/etx/tracers/logger"
@@ -219,6 +220,15 @@ type blockTraceResult struct {
 	Traces []*txTraceResult `json:"traces"` // Trace results produced by the task
 }
 
+// txTraceStreamResult is the result of a single transaction trace when the
+// traces of a block are streamed.
+type txTraceStreamResult struct {
+	TxIndex hexutil.Uint64 `json:"txIndex"`          // Index of the transaction within the block
+	TxHash  common.Hash    `json:"txHash"`           // Hash of the traced transaction
+	Result  interface{}    `json:"result,omitempty"` // Trace results produced by the tracer
+	Error   string         `json:"error,omitempty"`  // Trace failure produced by the tracer
+}
+
 // txTraceTask represents a single transaction trace task when an entire block
 // is being traced.
 type txTraceTask struct {
@@ -293,9 +303,10 @@ func (api *API) traceChain(start, end *types.Block, config *TraceConfig, closed
 				for i, tx := range task.block.Transactions() {
 					msg, _ := tx.AsMessage(signer, task.block.BaseFee())
 					txctx := &Context{
-						BlockHash: task.block.Hash(),
-						TxIndex:   i,
-						TxHash:    tx.Hash(),
+						BlockHash:   task.block.Hash(),
+						BlockNumber: task.block.Number(),
+						TxIndex:     i,
+						TxHash:      tx.Hash(),
 					}
 					res, err := api.traceTx(ctx, msg, txctx, blockCtx, task.statedb, config)
 					if err != nil {
@@ -456,6 +467,9 @@ func (api *API) TraceBlockByNumber(ctx context.Context, number rpc.BlockNumber,
 	if err != nil {
 		return nil, err
 	}
+	if results := api.indexedBlockTraces(block, config); results != nil {
+		return results, nil
+	}
 	return api.traceBlock(ctx, block, config)
 }
 
@@ -466,6 +480,9 @@ func (api *API) TraceBlockByHash(ctx context.Context, hash common.Hash, config *
 	if err != nil {
 		return nil, err
 	}
+	if results := api.indexedBlockTraces(block, config); results != nil {
+		return results, nil
+	}
 	return api.traceBlock(ctx, block, config)
 }
 
@@ -625,9 +642,10 @@ func (api *API) traceBlock(ctx context.Context, block *types.Block, config *Trac
 			for task := range jobs {
 				msg, _ := txs[task.index].AsMessage(signer, block.BaseFee())
 				txctx := &Context{
-					BlockHash: blockHash,
-					TxIndex:   task.index,
-					TxHash:    txs[task.index].Hash(),
+					BlockHash:   blockHash,
+					BlockNumber: block.Number(),
+					TxIndex:     task.index,
+					TxHash:      txs[task.index].Hash(),
 				}
 				res, err := api.traceTx(ctx, msg, txctx, blockCtx, task.statedb, config)
 				if err != nil {
@@ -667,6 +685,192 @@ func (api *API) traceBlock(ctx context.Context, block *types.Block, config *Trac
 	return results, nil
 }
 
+// TraceBlockStream returns the structured logs created during the execution of
+// EVM for all the transactions of a block. Instead of collecting the traces of
+// the whole block, they are emitted one by one in transaction order, as soon as
+// they are available.
+func (api *API) TraceBlockStream(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash, config *TraceConfig) (*rpc.Subscription, error) {
+	block, err := api.blockByNumberOrHash(ctx, blockNrOrHash)
+	if err != nil {
+		return nil, err
+	}
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+	resCh, err := api.traceBlockStream(ctx, block, config, notifier.Closed())
+	if err != nil {
+		return nil, err
+	}
+	sub := notifier.CreateSubscription()
+	go func() {
+		for result := range resCh {
+			notifier.Notify(sub.ID, result)
+		}
+	}()
+	return sub, nil
+}
+
+// traceBlockStream configures a new tracer according to the provided configuration,
+// and executes all the transactions contained within. Contrary to traceBlock, the
+// results are not collected but fed into the returned channel in transaction order
+// as soon as they are available. The channel is closed when the block is done.
+// The tracing procedure should be aborted in case the closed signal is received.
+func (api *API) traceBlockStream(ctx context.Context, block *types.Block, config *TraceConfig, closed <-chan interface{}) (chan *txTraceStreamResult, error) {
+	if block.NumberU64() == 0 {
+		return nil, errors.New("genesis is not traceable")
+	}
+	txs := block.Transactions()
+
+	// Serve the traces from the index if all of them were already generated
+	if results := api.indexedBlockTraces(block, config); results != nil {
+		resCh := make(chan *txTraceStreamResult, len(results))
+		for i, res := range results {
+			resCh <- &txTraceStreamResult{TxIndex: hexutil.Uint64(i), TxHash: txs[i].Hash(), Result: res.Result}
+		}
+		close(resCh)
+		return resCh, nil
+	}
+	parent, err := api.blockByNumberAndHash(ctx, rpc.BlockNumber(block.NumberU64()-1), block.ParentHash())
+	if err != nil {
+		return nil, err
+	}
+	reexec := defaultTraceReexec
+	if config != nil && config.Reexec != nil {
+		reexec = *config.Reexec
+	}
+	statedb, release, err := api.backend.StateAtBlock(ctx, parent, reexec, nil, true, false)
+	if err != nil {
+		return nil, err
+	}
+	threads := runtime.NumCPU()
+	if threads > len(txs) {
+		threads = len(txs)
+	}
+	var (
+		signer    = types.MakeSigner(api.backend.ChainConfig(), block.Number())
+		blockHash = block.Hash()
+		resCh     = make(chan *txTraceStreamResult)
+	)
+	go func() {
+		defer release()
+		defer close(resCh)
+
+		// The request context is gone by the time the traces are generated, abort
+		// the in-flight traces if the subscriber goes away instead.
+		tctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go func() {
+			select {
+			case <-closed:
+				cancel()
+			case <-tctx.Done():
+			}
+		}()
+		var (
+			pend = new(sync.WaitGroup)
+			jobs = make(chan *txTraceTask, threads)
+			done = make(chan *txTraceStreamResult, threads)
+		)
+		for th := 0; th < threads; th++ {
+			pend.Add(1)
+			go func() {
+				blockCtx := core.NewEVMBlockContext(block.Header(), api.chainContext(tctx), nil)
+				defer pend.Done()
+				// Fetch and execute the next transaction trace tasks
+				for task := range jobs {
+					msg, _ := txs[task.index].AsMessage(signer, block.BaseFee())
+					txctx := &Context{
+						BlockHash:   blockHash,
+						BlockNumber: block.Number(),
+						TxIndex:     task.index,
+						TxHash:      txs[task.index].Hash(),
+					}
+					result := &txTraceStreamResult{TxIndex: hexutil.Uint64(task.index), TxHash: txctx.TxHash}
+					res, err := api.traceTx(tctx, msg, txctx, blockCtx, task.statedb, config)
+					if err != nil {
+						result.Error = err.Error()
+					} else {
+						result.Result = res
+					}
+					done <- result
+				}
+			}()
+		}
+		// Feed the transactions into the tracers. The number of tasks not yet
+		// emitted is capped to the number of threads, so neither the state copies
+		// nor the reordered results pile up if the subscriber is slow.
+		var (
+			failed error
+			fed    int
+			window = make(chan struct{}, threads)
+		)
+		go func() {
+			defer close(jobs)
+
+			blockCtx := core.NewEVMBlockContext(block.Header(), api.chainContext(tctx), nil)
+			for i, tx := range txs {
+				// Wait until the task fits into the window past the next result
+				select {
+				case window <- struct{}{}:
+				case <-tctx.Done():
+					return
+				}
+				// Send the trace task over for execution
+				select {
+				case jobs <- &txTraceTask{statedb: statedb.Copy(), index: i}:
+					fed++
+				case <-tctx.Done():
+					return
+				}
+				// Generate the next state snapshot fast without tracing
+				msg, _ := tx.AsMessage(signer, block.BaseFee())
+				statedb.SetTxContext(tx.Hash(), i)
+				vmenv := vm.NewEVM(blockCtx, core.NewEVMTxContext(msg), statedb, api.backend.ChainConfig(), vm.Config{})
+				if _, err := core.ApplyMessage(vmenv, msg, new(core.GasPool).AddGas(msg.Gas())); err != nil {
+					failed = err
+					return
+				}
+				// Finalize the state so any modifications are written to the trie
+				// Only delete empty objects if EIP158/161 (a.k.a Spurious Dragon) is in effect
+				statedb.Finalise(vmenv.ChainConfig().IsEIP158(block.Number()))
+			}
+		}()
+		go func() {
+			pend.Wait()
+			close(done)
+		}()
+		// Reorder the finished traces and emit them in transaction order. Keep
+		// draining the workers even if the subscriber went away.
+		var (
+			pending = make(map[int]*txTraceStreamResult)
+			next    int
+		)
+		for result := range done {
+			pending[int(result.TxIndex)] = result
+			for ; pending[next] != nil; next++ {
+				select {
+				case resCh <- pending[next]:
+				case <-tctx.Done():
+				}
+				delete(pending, next)
+				<-window
+			}
+		}
+		// If execution failed in between, report the remaining transactions
+		if failed != nil {
+			for i := fed; i < len(txs); i++ {
+				select {
+				case resCh <- &txTraceStreamResult{TxIndex: hexutil.Uint64(i), TxHash: txs[i].Hash(), Error: failed.Error()}:
+				case <-tctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return resCh, nil
+}
+
 // standardTraceBlockToFile configures a new tracer which uses standard JSON output,
 // and traces either a full block or an individual transaction. The return value will
 // be one filename per transaction traced.
@@ -809,6 +1013,10 @@ func (api *API) TraceTransaction(ctx context.Context, hash common.Hash, config *
 	if blockNumber == 0 {
 		return nil, errors.New("genesis is not traceable")
 	}
+	// Serve the trace from the index if it was already generated
+	if result := api.indexedTrace(hash, blockHash, config); result != nil {
+		return result, nil
+	}
 	reexec := defaultTraceReexec
 	if config != nil && config.Reexec != nil {
 		reexec = *config.Reexec
@@ -824,9 +1032,10 @@ func (api *API) TraceTransaction(ctx context.Context, hash common.Hash, config *
 	defer release()
 
 	txctx := &Context{
-		BlockHash: blockHash,
-		TxIndex:   int(index),
-		TxHash:    hash,
+		BlockHash:   blockHash,
+		BlockNumber: block.Number(),
+		TxIndex:     int(index),
+		TxHash:      hash,
 	}
 	return api.traceTx(ctx, msg, txctx, vmctx, statedb, config)
 }
@@ -912,6 +1121,11 @@ func (api *API) traceTx(ctx context.Context, message core.Message, txctx *Contex
 		}
 	}
 	vmenv := vm.NewEVM(vmctx, txContext, statedb, api.backend.ChainConfig(), vm.Config{Debug: true, Tracer: tracer, NoBaseFee: true})
+	// Feed the state changes into the tracer if it's interested in them
+	if logger, ok := tracer.(tracing.StateLogger); ok {
+		statedb.SetLogger(logger)
+		defer statedb.SetLogger(nil)
+	}
 
 	// Define a meaningful timeout of a single transaction trace
 	if config.Timeout != nil {
@@ -946,5 +1160,9 @@ func APIs(backend Backend) []rpc.API {
 			Namespace: "debug",
 			Service:   NewAPI(backend),
 		},
+		{
+			Namespace: "trace",
+			Service:   NewTraceAPI(backend),
+		},
 	}
 }
@@ -448,6 +448,117 @@ func TestTraceBlock(t *testing.T) {
 	}
 }
 
+func TestTraceBlockStream(t *testing.T) {
+	t.Parallel()
+
+	// Initialize test accounts
+	accounts := newAccounts(2)
+	genesis := &core.Genesis{
+		Config: params.TestChainConfig,
+		Alloc: core.GenesisAlloc{
+			accounts[0].addr: {Balance: big.NewInt(params.etxer)},
+		},
+	}
+	// Generate a block with a bunch of transactions traced concurrently to
+	// exercise the reordering of the results
+	txCount := 32
+	signer := types.HomesteadSigner{}
+	backend := newTestBackend(t, 1, genesis, func(i int, b *core.BlockGen) {
+		for j := 0; j < txCount; j++ {
+			tx, _ := types.SignTx(types.NewTransaction(uint64(j), accounts[1].addr, big.NewInt(1000), params.TxGas, b.BaseFee(), nil), signer, accounts[0].key)
+			b.AddTx(tx)
+		}
+	})
+	defer backend.chain.Stop()
+	api := NewAPI(backend)
+
+	block := backend.chain.GetBlockByNumber(1)
+	want, err := api.traceBlock(context.Background(), block, nil)
+	if err != nil {
+		t.Fatalf("failed to trace block: %v", err)
+	}
+	resCh, err := api.traceBlockStream(context.Background(), block, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to stream block traces: %v", err)
+	}
+	var index int
+	for result := range resCh {
+		if int(result.TxIndex) != index {
+			t.Fatalf("trace out of order: have index %d, want %d", result.TxIndex, index)
+		}
+		if result.TxHash != block.Transactions()[index].Hash() {
+			t.Errorf("trace %d: tx hash mismatch: have %x, want %x", index, result.TxHash, block.Transactions()[index].Hash())
+		}
+		have, _ := json.Marshal(&txTraceResult{Result: result.Result, Error: result.Error})
+		exp, _ := json.Marshal(want[index])
+		if string(have) != string(exp) {
+			t.Errorf("trace %d: result mismatch: have %s, want %s", index, have, exp)
+		}
+		index++
+	}
+	if index != txCount {
+		t.Errorf("trace count mismatch: have %d, want %d", index, txCount)
+	}
+	// Tracing the genesis block must fail upfront
+	if _, err := api.traceBlockStream(context.Background(), backend.chain.GetBlockByNumber(0), nil, nil); err == nil {
+		t.Errorf("genesis block traced")
+	}
+}
+
+// Tests that a block trace stream is torn down if the subscriber goes away in
+// the middle of the block.
+func TestTraceBlockStreamCancel(t *testing.T) {
+	t.Parallel()
+
+	accounts := newAccounts(2)
+	genesis := &core.Genesis{
+		Config: params.TestChainConfig,
+		Alloc: core.GenesisAlloc{
+			accounts[0].addr: {Balance: big.NewInt(params.etxer)},
+		},
+	}
+	txCount := 64
+	signer := types.HomesteadSigner{}
+	backend := newTestBackend(t, 1, genesis, func(i int, b *core.BlockGen) {
+		for j := 0; j < txCount; j++ {
+			tx, _ := types.SignTx(types.NewTransaction(uint64(j), accounts[1].addr, big.NewInt(1000), params.TxGas, b.BaseFee(), nil), signer, accounts[0].key)
+			b.AddTx(tx)
+		}
+	})
+	defer backend.chain.Stop()
+	api := NewAPI(backend)
+
+	closed := make(chan interface{})
+	resCh, err := api.traceBlockStream(context.Background(), backend.chain.GetBlockByNumber(1), nil, closed)
+	if err != nil {
+		t.Fatalf("failed to stream block traces: %v", err)
+	}
+	// Consume a few traces, then stop reading and go away
+	for i := 0; i < 2; i++ {
+		if result := <-resCh; result == nil || int(result.TxIndex) != i {
+			t.Fatalf("trace %d missing or out of order: %v", i, result)
+		}
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(closed)
+
+	// The stream must terminate once the subscriber is gone
+	timeout := time.After(5 * time.Second)
+	for index := 2; ; index++ {
+		select {
+		case result, ok := <-resCh:
+			if !ok {
+				return
+			}
+			if int(result.TxIndex) != index {
+				t.Fatalf("trace out of order: have index %d, want %d", result.TxIndex, index)
+			}
+		case <-timeout:
+			t.Fatal("trace stream not terminated")
+		}
+	}
+}
+
 func TestTracingWithOverrides(t *testing.T) {
 	t.Parallel()
 	// Initialize test accounts
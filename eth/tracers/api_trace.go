@@ -0,0 +1,533 @@
+// Copyright 2022 The go-ETX Authors
+// This file is part of the go-ETX library.
+//
+// The go-ETX library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ETX library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ETX library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ETX/go-ETX/common"
+	"github.com/ETX/go-ETX/common/hexutil"
+	"github.com/ETX/go-ETX/core/types"
+	"github.com/ETX/go-ETX/rpc"
+)
+
+const (
+	// flatCallTracer is the name of the native tracer producing Parity-style
+	// flat call traces.
+	flatCallTracer = "flatCallTracer"
+
+	// Names of the tracers backing the optional outputs of trace replays.
+	muxTracer      = "muxTracer"
+	prestateTracer = "prestateTracer"
+	vmTracer       = "vmTracer"
+)
+
+// Trace types which can be requested when replaying transactions.
+const (
+	traceTypeTrace     = "trace"
+	traceTypeStateDiff = "stateDiff"
+	traceTypeVmTrace   = "vmTrace"
+)
+
+var (
+	errTraceTypesMissing = errors.New("no trace types requested")
+
+	// flatCallTracerConfig makes the flat call tracer report errors in the
+	// format expected by Parity trace consumers.
+	flatCallTracerConfig = json.RawMessage(`{"convertParityErrors":true}`)
+)
+
+// TraceAPI is the collection of Parity-compatible tracing APIs exposed over the
+// trace namespace. It is built on top of the native flat call tracer, reusing
+// the block and chain tracing machinery of the debug API.
+type TraceAPI struct {
+	api *API
+}
+
+// NewTraceAPI creates a new API definition for the trace metxods of the ETX service.
+func NewTraceAPI(backend Backend) *TraceAPI {
+	return &TraceAPI{api: NewAPI(backend)}
+}
+
+// TraceFilterArgs are the arguments of trace_filter.
+type TraceFilterArgs struct {
+	FromBlock   *rpc.BlockNumber `json:"fromBlock"`
+	ToBlock     *rpc.BlockNumber `json:"toBlock"`
+	FromAddress []common.Address `json:"fromAddress"`
+	ToAddress   []common.Address `json:"toAddress"`
+	After       *hexutil.Uint64  `json:"after"`
+	Count       *hexutil.Uint64  `json:"count"`
+}
+
+// replayResult is the result of replaying a single transaction with the
+// requested trace types. Outputs which were not requested are null.
+type replayResult struct {
+	Output          hexutil.Bytes     `json:"output"`
+	StateDiff       stateDiff         `json:"stateDiff"`
+	Trace           []json.RawMessage `json:"trace"`
+	VmTrace         json.RawMessage   `json:"vmTrace"`
+	TransactionHash common.Hash       `json:"transactionHash"`
+}
+
+// flatCallConfig returns the trace configuration for running the flat call tracer.
+func flatCallConfig() *TraceConfig {
+	tracer := flatCallTracer
+	return &TraceConfig{Tracer: &tracer, TracerConfig: flatCallTracerConfig}
+}
+
+// Block returns the flat call traces of all transactions within the given block.
+func (api *TraceAPI) Block(ctx context.Context, number rpc.BlockNumber) ([]json.RawMessage, error) {
+	block, err := api.api.blockByNumber(ctx, number)
+	if err != nil {
+		return nil, err
+	}
+	results, err := api.api.traceBlock(ctx, block, flatCallConfig())
+	if err != nil {
+		return nil, err
+	}
+	return flattenTraceResults(results)
+}
+
+// Transaction returns the flat call traces of the given transaction.
+func (api *TraceAPI) Transaction(ctx context.Context, hash common.Hash) ([]json.RawMessage, error) {
+	result, err := api.api.TraceTransaction(ctx, hash, flatCallConfig())
+	if err != nil {
+		return nil, err
+	}
+	return decodeFlatFrames(result)
+}
+
+// Filter returns the flat call traces of a block range matching the given
+// sender and recipient addresses.
+func (api *TraceAPI) Filter(ctx context.Context, args TraceFilterArgs) ([]json.RawMessage, error) {
+	from, to := rpc.LatestBlockNumber, rpc.LatestBlockNumber
+	if args.FromBlock != nil {
+		from = *args.FromBlock
+	}
+	if args.ToBlock != nil {
+		to = *args.ToBlock
+	}
+	end, err := api.api.blockByNumber(ctx, to)
+	if err != nil {
+		return nil, err
+	}
+	first, err := api.api.blockByNumber(ctx, from)
+	if err != nil {
+		return nil, err
+	}
+	if first.NumberU64() > end.NumberU64() {
+		return nil, fmt.Errorf("end block (#%d) needs to come after start block (#%d)", end.NumberU64(), first.NumberU64())
+	}
+	// The genesis block is not traceable, start from its child instead
+	startNumber := first.NumberU64()
+	if startNumber == 0 {
+		startNumber = 1
+	}
+	if startNumber > end.NumberU64() {
+		return []json.RawMessage{}, nil
+	}
+	// The chain tracer excludes the start block, so begin with the parent
+	start, err := api.api.blockByNumber(ctx, rpc.BlockNumber(startNumber-1))
+	if err != nil {
+		return nil, err
+	}
+	var (
+		after   uint64
+		count   = ^uint64(0)
+		fromSet = make(map[common.Address]struct{}, len(args.FromAddress))
+		toSet   = make(map[common.Address]struct{}, len(args.ToAddress))
+		closed  = make(chan interface{})
+		traces  = []json.RawMessage{}
+	)
+	if args.After != nil {
+		after = uint64(*args.After)
+	}
+	if args.Count != nil {
+		count = uint64(*args.Count)
+	}
+	for _, addr := range args.FromAddress {
+		fromSet[addr] = struct{}{}
+	}
+	for _, addr := range args.ToAddress {
+		toSet[addr] = struct{}{}
+	}
+	resCh := api.api.traceChain(start, end, flatCallConfig(), closed)
+	defer func() {
+		// Abort the chain tracer and drain any results still in flight
+		close(closed)
+		go func() {
+			for range resCh {
+			}
+		}()
+	}()
+	for {
+		var (
+			res *blockTraceResult
+			ok  bool
+		)
+		select {
+		case res, ok = <-resCh:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		if !ok {
+			break
+		}
+		frames, err := flattenTraceResults(res.Traces)
+		if err != nil {
+			return nil, fmt.Errorf("block #%d: %w", res.Block, err)
+		}
+		for _, frame := range frames {
+			if count == 0 {
+				return traces, nil
+			}
+			match, err := matchFlatFrame(frame, fromSet, toSet)
+			if err != nil {
+				return nil, err
+			}
+			if !match {
+				continue
+			}
+			if after > 0 {
+				after--
+				continue
+			}
+			traces = append(traces, frame)
+			count--
+		}
+	}
+	return traces, nil
+}
+
+// ReplayBlockTransactions replays all transactions of the given block and
+// returns the requested trace types for each of them. The supported trace
+// types are "trace", "stateDiff" and "vmTrace".
+func (api *TraceAPI) ReplayBlockTransactions(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash, traceTypes []string) ([]*replayResult, error) {
+	if len(traceTypes) == 0 {
+		return nil, errTraceTypesMissing
+	}
+	var (
+		withTrace, withStateDiff, withVmTrace bool
+		tracerConfig                          = map[string]json.RawMessage{flatCallTracer: flatCallTracerConfig}
+	)
+	for _, typ := range traceTypes {
+		switch typ {
+		case traceTypeTrace:
+			withTrace = true
+		case traceTypeStateDiff:
+			withStateDiff = true
+			tracerConfig[prestateTracer] = json.RawMessage(`{"diffMode":true}`)
+		case traceTypeVmTrace:
+			withVmTrace = true
+			tracerConfig[vmTracer] = json.RawMessage(`{}`)
+		default:
+			return nil, fmt.Errorf("unsupported trace type: %q", typ)
+		}
+	}
+	block, err := api.api.blockByNumberOrHash(ctx, blockNrOrHash)
+	if err != nil {
+		return nil, err
+	}
+	muxConfig, err := json.Marshal(tracerConfig)
+	if err != nil {
+		return nil, err
+	}
+	tracer := muxTracer
+	results, err := api.api.traceBlock(ctx, block, &TraceConfig{Tracer: &tracer, TracerConfig: muxConfig})
+	if err != nil {
+		return nil, err
+	}
+	txs := block.Transactions()
+	replays := make([]*replayResult, len(results))
+	for i, res := range results {
+		if res.Error != "" {
+			return nil, fmt.Errorf("tracing transaction %#x failed: %s", txs[i].Hash(), res.Error)
+		}
+		var outputs map[string]json.RawMessage
+		if err := remarshal(res.Result, &outputs); err != nil {
+			return nil, err
+		}
+		frames, err := decodeFlatFrames(outputs[flatCallTracer])
+		if err != nil {
+			return nil, err
+		}
+		replay := &replayResult{
+			Output:          topCallOutput(frames),
+			TransactionHash: txs[i].Hash(),
+		}
+		if withTrace {
+			replay.Trace = frames
+		}
+		if withStateDiff {
+			if replay.StateDiff, err = newStateDiff(outputs[prestateTracer]); err != nil {
+				return nil, err
+			}
+		}
+		if withVmTrace {
+			replay.VmTrace = outputs[vmTracer]
+		}
+		replays[i] = replay
+	}
+	return replays, nil
+}
+
+// blockByNumberOrHash is the wrapper of the chain access function offered by
+// the backend. It will return an error if the block is not found.
+func (api *API) blockByNumberOrHash(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash) (*types.Block, error) {
+	if hash, ok := blockNrOrHash.Hash(); ok {
+		return api.blockByHash(ctx, hash)
+	}
+	if number, ok := blockNrOrHash.Number(); ok {
+		return api.blockByNumber(ctx, number)
+	}
+	return nil, errors.New("invalid arguments; neither block nor hash specified")
+}
+
+// remarshal converts a tracer result into the given typed representation.
+func remarshal(result interface{}, v interface{}) error {
+	blob, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(blob, v)
+}
+
+// decodeFlatFrames splits the output of the flat call tracer into its frames.
+func decodeFlatFrames(result interface{}) ([]json.RawMessage, error) {
+	frames := []json.RawMessage{}
+	if err := remarshal(result, &frames); err != nil {
+		return nil, err
+	}
+	return frames, nil
+}
+
+// flattenTraceResults concatenates the flat call frames of all transactions
+// traced within a block.
+func flattenTraceResults(results []*txTraceResult) ([]json.RawMessage, error) {
+	traces := []json.RawMessage{}
+	for i, res := range results {
+		if res == nil {
+			continue
+		}
+		if res.Error != "" {
+			return nil, fmt.Errorf("tracing transaction %d failed: %s", i, res.Error)
+		}
+		frames, err := decodeFlatFrames(res.Result)
+		if err != nil {
+			return nil, err
+		}
+		traces = append(traces, frames...)
+	}
+	return traces, nil
+}
+
+// flatFrameAddresses is the subset of a flat call frame needed for filtering.
+type flatFrameAddresses struct {
+	Action struct {
+		From           *common.Address `json:"from"`
+		To             *common.Address `json:"to"`
+		SelfDestructed *common.Address `json:"address"`
+		RefundAddress  *common.Address `json:"refundAddress"`
+	} `json:"action"`
+	Result *struct {
+		Address *common.Address `json:"address"`
+		Output  hexutil.Bytes   `json:"output"`
+	} `json:"result"`
+}
+
+// matchFlatFrame reports whetxer the sender of the frame is within the from
+// set and its recipient within the to set. Empty sets match any address.
+func matchFlatFrame(frame json.RawMessage, fromSet, toSet map[common.Address]struct{}) (bool, error) {
+	if len(fromSet) == 0 && len(toSet) == 0 {
+		return true, nil
+	}
+	var addrs flatFrameAddresses
+	if err := json.Unmarshal(frame, &addrs); err != nil {
+		return false, err
+	}
+	from, to := addrs.Action.From, addrs.Action.To
+	if addrs.Action.SelfDestructed != nil {
+		from, to = addrs.Action.SelfDestructed, addrs.Action.RefundAddress
+	}
+	if to == nil && addrs.Result != nil {
+		// Contract creations are matched by the address of the new contract
+		to = addrs.Result.Address
+	}
+	return matchAddress(from, fromSet) && matchAddress(to, toSet), nil
+}
+
+func matchAddress(addr *common.Address, set map[common.Address]struct{}) bool {
+	if len(set) == 0 {
+		return true
+	}
+	if addr == nil {
+		return false
+	}
+	_, ok := set[*addr]
+	return ok
+}
+
+// topCallOutput returns the output of the outermost call of a transaction.
+func topCallOutput(frames []json.RawMessage) hexutil.Bytes {
+	if len(frames) == 0 {
+		return hexutil.Bytes{}
+	}
+	var top flatFrameAddresses
+	if err := json.Unmarshal(frames[0], &top); err != nil || top.Result == nil {
+		return hexutil.Bytes{}
+	}
+	return top.Result.Output
+}
+
+// stateDiff is the Parity-style representation of the state changes made by
+// a transaction, keyed by account.
+type stateDiff map[common.Address]*accountDiff
+
+// accountDiff is the change of a single account. Each field is either the
+// string "=" if unchanged, or an object with a "+" (created), "-" (deleted)
+// or "*" (modified) key describing the change.
+type accountDiff struct {
+	Balance interface{}                 `json:"balance"`
+	Nonce   interface{}                 `json:"nonce"`
+	Code    interface{}                 `json:"code"`
+	Storage map[common.Hash]interface{} `json:"storage"`
+}
+
+// prestateAccount is an account as reported by the prestate tracer in diff mode.
+type prestateAccount struct {
+	Balance *hexutil.Big                `json:"balance"`
+	Code    hexutil.Bytes               `json:"code"`
+	Nonce   *uint64                     `json:"nonce"`
+	Storage map[common.Hash]common.Hash `json:"storage"`
+}
+
+func (a *prestateAccount) balance() *hexutil.Big {
+	if a == nil || a.Balance == nil {
+		return (*hexutil.Big)(new(big.Int))
+	}
+	return a.Balance
+}
+
+func (a *prestateAccount) nonce() hexutil.Uint64 {
+	if a == nil || a.Nonce == nil {
+		return 0
+	}
+	return hexutil.Uint64(*a.Nonce)
+}
+
+func (a *prestateAccount) code() hexutil.Bytes {
+	if a == nil || a.Code == nil {
+		return hexutil.Bytes{}
+	}
+	return a.Code
+}
+
+func (a *prestateAccount) empty() bool {
+	return a == nil || (a.balance().ToInt().Sign() == 0 && a.nonce() == 0 && len(a.Code) == 0 && len(a.Storage) == 0)
+}
+
+// newStateDiff converts the diff mode output of the prestate tracer into the
+// Parity-style state diff.
+func newStateDiff(result json.RawMessage) (stateDiff, error) {
+	var prestate struct {
+		Pre  map[common.Address]*prestateAccount `json:"pre"`
+		Post map[common.Address]*prestateAccount `json:"post"`
+	}
+	if err := json.Unmarshal(result, &prestate); err != nil {
+		return nil, err
+	}
+	diff := make(stateDiff)
+	for addr, pre := range prestate.Pre {
+		post, ok := prestate.Post[addr]
+		switch {
+		case !ok:
+			// Accounts dropped from the post state were self-destructed
+			diff[addr] = deletedAccountDiff(pre)
+		case pre.empty():
+			diff[addr] = bornAccountDiff(post)
+		default:
+			diff[addr] = modifiedAccountDiff(pre, post)
+		}
+	}
+	for addr, post := range prestate.Post {
+		if _, ok := prestate.Pre[addr]; !ok {
+			diff[addr] = bornAccountDiff(post)
+		}
+	}
+	return diff, nil
+}
+
+func bornAccountDiff(post *prestateAccount) *accountDiff {
+	diff := &accountDiff{
+		Balance: map[string]interface{}{"+": post.balance()},
+		Nonce:   map[string]interface{}{"+": post.nonce()},
+		Code:    map[string]interface{}{"+": post.code()},
+		Storage: make(map[common.Hash]interface{}),
+	}
+	for key, val := range post.Storage {
+		diff.Storage[key] = map[string]interface{}{"+": val}
+	}
+	return diff
+}
+
+func deletedAccountDiff(pre *prestateAccount) *accountDiff {
+	diff := &accountDiff{
+		Balance: map[string]interface{}{"-": pre.balance()},
+		Nonce:   map[string]interface{}{"-": pre.nonce()},
+		Code:    map[string]interface{}{"-": pre.code()},
+		Storage: make(map[common.Hash]interface{}),
+	}
+	for key, val := range pre.Storage {
+		diff.Storage[key] = map[string]interface{}{"-": val}
+	}
+	return diff
+}
+
+func modifiedAccountDiff(pre, post *prestateAccount) *accountDiff {
+	changed := func(from, to interface{}) interface{} {
+		return map[string]interface{}{"*": map[string]interface{}{"from": from, "to": to}}
+	}
+	diff := &accountDiff{
+		Balance: "=",
+		Nonce:   "=",
+		Code:    "=",
+		Storage: make(map[common.Hash]interface{}),
+	}
+	if post.Balance != nil {
+		diff.Balance = changed(pre.balance(), post.balance())
+	}
+	if post.Nonce != nil {
+		diff.Nonce = changed(pre.nonce(), post.nonce())
+	}
+	if post.Code != nil {
+		diff.Code = changed(pre.code(), post.code())
+	}
+	// The prestate tracer omits zero slots from both sides of the diff
+	for key, val := range pre.Storage {
+		diff.Storage[key] = changed(val, post.Storage[key])
+	}
+	for key, val := range post.Storage {
+		if _, ok := pre.Storage[key]; !ok {
+			diff.Storage[key] = changed(common.Hash{}, val)
+		}
+	}
+	return diff
+}
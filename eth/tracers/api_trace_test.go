@@ -0,0 +1,119 @@
+// Copyright 2022 The go-ETX Authors
+// This file is part of the go-ETX library.
+//
+// The go-ETX library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ETX library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ETX library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracers
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/ETX/go-ETX/common"
+)
+
+func TestMatchFlatFrame(t *testing.T) {
+	var (
+		alice = common.HexToAddress("0xa11ce")
+		bob   = common.HexToAddress("0xb0b")
+		carol = common.HexToAddress("0xca201")
+	)
+	call := json.RawMessage(`{"action":{"from":"` + alice.Hex() + `","to":"` + bob.Hex() + `","callType":"call"},"type":"call"}`)
+	create := json.RawMessage(`{"action":{"from":"` + alice.Hex() + `","creationMetxod":"create"},"result":{"address":"` + carol.Hex() + `"},"type":"create"}`)
+	suicide := json.RawMessage(`{"action":{"address":"` + bob.Hex() + `","refundAddress":"` + carol.Hex() + `"},"type":"suicide"}`)
+
+	set := func(addrs ...common.Address) map[common.Address]struct{} {
+		s := make(map[common.Address]struct{})
+		for _, addr := range addrs {
+			s[addr] = struct{}{}
+		}
+		return s
+	}
+	tests := []struct {
+		frame    json.RawMessage
+		from, to map[common.Address]struct{}
+		want     bool
+	}{
+		{call, nil, nil, true},
+		{call, set(alice), nil, true},
+		{call, set(bob), nil, false},
+		{call, nil, set(bob), true},
+		{call, set(alice), set(carol), false},
+		{create, nil, set(carol), true},
+		{create, set(bob), set(carol), false},
+		{suicide, set(bob), set(carol), true},
+		{suicide, set(alice), nil, false},
+	}
+	for i, tt := range tests {
+		have, err := matchFlatFrame(tt.frame, tt.from, tt.to)
+		if err != nil {
+			t.Fatalf("test %d: unexpected error: %v", i, err)
+		}
+		if have != tt.want {
+			t.Errorf("test %d: match mismatch: have %v, want %v", i, have, tt.want)
+		}
+	}
+}
+
+func TestNewStateDiff(t *testing.T) {
+	var (
+		sender   = common.HexToAddress("0x1000")
+		created  = common.HexToAddress("0x2000")
+		modified = common.HexToAddress("0x3000")
+		killed   = common.HexToAddress("0x4000")
+		slot     = common.HexToHash("0x01")
+	)
+	result := json.RawMessage(`{
+		"pre": {
+			"` + sender.Hex() + `": {"balance": "0x10", "nonce": 1},
+			"` + modified.Hex() + `": {"balance": "0x1", "code": "0x60", "storage": {"` + slot.Hex() + `": "0x000000000000000000000000000000000000000000000000000000000000000a"}},
+			"` + killed.Hex() + `": {"balance": "0x5"}
+		},
+		"post": {
+			"` + sender.Hex() + `": {"balance": "0x8", "nonce": 2},
+			"` + created.Hex() + `": {"balance": "0x2", "code": "0x6001"},
+			"` + modified.Hex() + `": {"storage": {}}
+		}
+	}`)
+	diff, err := newStateDiff(result)
+	if err != nil {
+		t.Fatalf("failed to convert state diff: %v", err)
+	}
+	if len(diff) != 4 {
+		t.Fatalf("account count mismatch: have %d, want 4", len(diff))
+	}
+	blob, err := json.Marshal(diff)
+	if err != nil {
+		t.Fatalf("failed to marshal state diff: %v", err)
+	}
+	var have map[common.Address]map[string]interface{}
+	if err := json.Unmarshal(blob, &have); err != nil {
+		t.Fatalf("failed to unmarshal state diff: %v", err)
+	}
+	check := func(addr common.Address, field string, want string) {
+		t.Helper()
+		enc, _ := json.Marshal(have[addr][field])
+		if string(enc) != want {
+			t.Errorf("account %x field %s mismatch: have %s, want %s", addr, field, enc, want)
+		}
+	}
+	check(sender, "balance", `{"*":{"from":"0x10","to":"0x8"}}`)
+	check(sender, "nonce", `{"*":{"from":"0x1","to":"0x2"}}`)
+	check(sender, "code", `"="`)
+	check(created, "balance", `{"+":"0x2"}`)
+	check(created, "code", `{"+":"0x6001"}`)
+	check(modified, "balance", `"="`)
+	check(modified, "storage", `{"`+slot.Hex()+`":{"*":{"from":"0x000000000000000000000000000000000000000000000000000000000000000a","to":"0x0000000000000000000000000000000000000000000000000000000000000000"}}}`)
+	check(killed, "balance", `{"-":"0x5"}`)
+}
@@ -0,0 +1,214 @@
+// Copyright 2022 The go-ETX Authors
+// This file is part of the go-ETX library.
+//
+// The go-ETX library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ETX library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ETX library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/ETX/go-ETX/common"
+	"github.com/ETX/go-ETX/core"
+	"github.com/ETX/go-ETX/core/rawdb"
+	"github.com/ETX/go-ETX/core/types"
+	"github.com/ETX/go-ETX/crypto"
+	"github.com/ETX/go-ETX/etxdb"
+	"github.com/ETX/go-ETX/log"
+)
+
+const (
+	// traceIndexSectionSize is the number of blocks the trace indexer processes
+	// and commits in one go.
+	traceIndexSectionSize = 64
+
+	// traceIndexConfirms is the number of confirmations a block needs before it
+	// is traced into the index. Reorged traces are detected on read, so there's
+	// no need to wait for finality.
+	traceIndexConfirms = 16
+
+	// traceIndexThrottling is the time to wait between processing two consecutive
+	// index sections, leaving room for the block import and on demand tracing.
+	traceIndexThrottling = 100 * time.Millisecond
+)
+
+// TracerID returns the identifier under which the output of the given tracer
+// and tracer config is persisted in the trace index. Configs differing only in
+// their JSON formatting or field order map to the same identifier.
+func TracerID(tracer string, config json.RawMessage) (common.Hash, error) {
+	var normalized interface{}
+	if len(bytes.TrimSpace(config)) > 0 {
+		if err := json.Unmarshal(config, &normalized); err != nil {
+			return common.Hash{}, err
+		}
+	}
+	if normalized == nil {
+		normalized = map[string]interface{}{}
+	}
+	blob, err := json.Marshal(normalized)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	return crypto.Keccak256Hash([]byte(tracer), []byte{0}, blob), nil
+}
+
+// TraceIndexer implements core.ChainIndexerBackend, persisting the output of a
+// configured tracer for every canonical transaction, so that later requests for
+// the same tracer and config can be served without re-executing the block.
+type TraceIndexer struct {
+	api    *API
+	db     etxdb.Database // database instance to write the traces into
+	id     common.Hash    // identifier of the tracer and config being indexed
+	config *TraceConfig   // trace config used to trace the indexed blocks
+	batch  etxdb.Batch    // pending traces of the section being processed
+}
+
+// NewTraceIndexer returns a chain indexer that traces the canonical chain with
+// the given tracer and persists the result of every transaction.
+func NewTraceIndexer(backend Backend, tracer string, config json.RawMessage) (*core.ChainIndexer, error) {
+	// Make sure the tracer exists and accepts the config before starting
+	if _, err := New(tracer, new(Context), config); err != nil {
+		return nil, err
+	}
+	id, err := TracerID(tracer, config)
+	if err != nil {
+		return nil, err
+	}
+	db := backend.ChainDb()
+	indexer := &TraceIndexer{
+		api:    NewAPI(backend),
+		db:     db,
+		id:     id,
+		config: &TraceConfig{Tracer: &tracer, TracerConfig: config},
+	}
+	// Track the progress separately for every tracer, switching to another one
+	// must not consider the already indexed sections done.
+	table := rawdb.NewTable(db, string(rawdb.TxTraceIndexPrefix)+string(id.Bytes()))
+
+	return core.NewChainIndexer(db, table, indexer, traceIndexSectionSize, traceIndexConfirms, traceIndexThrottling, "traces"), nil
+}
+
+// Reset implements core.ChainIndexerBackend, starting a new trace index section.
+func (t *TraceIndexer) Reset(ctx context.Context, section uint64, lastSectionHead common.Hash) error {
+	t.batch = t.db.NewBatch()
+	return nil
+}
+
+// Process implements core.ChainIndexerBackend, tracing the transactions of a
+// new block and adding their results into the index.
+func (t *TraceIndexer) Process(ctx context.Context, header *types.Header) error {
+	if header.TxHash == types.EmptyRootHash {
+		return nil
+	}
+	block, err := t.api.blockByHash(ctx, header.Hash())
+	if err != nil {
+		return err
+	}
+	results, err := t.api.traceBlock(ctx, block, t.config)
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		// The state needed to trace the block might be unavailable (e.g. on a
+		// pruned node). It's only a cache, leave the traces to be generated on
+		// demand instead of stalling the whole index.
+		log.Debug("Skipping trace indexing of block", "number", block.NumberU64(), "hash", block.Hash(), "err", err)
+		return nil
+	}
+	for i, tx := range block.Transactions() {
+		if results[i].Error != "" {
+			continue
+		}
+		blob, err := json.Marshal(results[i].Result)
+		if err != nil {
+			return err
+		}
+		rawdb.WriteTxTrace(t.batch, t.id, tx.Hash(), block.Hash(), blob)
+	}
+	if t.batch.ValueSize() > etxdb.IdealBatchSize {
+		if err := t.batch.Write(); err != nil {
+			return err
+		}
+		t.batch.Reset()
+	}
+	return nil
+}
+
+// Commit implements core.ChainIndexerBackend, writing out the traces of the
+// section into the database.
+func (t *TraceIndexer) Commit() error {
+	return t.batch.Write()
+}
+
+// Prune returns an empty error since we don't support pruning here.
+func (t *TraceIndexer) Prune(threshold uint64) error {
+	return nil
+}
+
+// indexedTracer returns the identifier of the requested tracer in the trace
+// index, or false if the config doesn't request a tracer that can be indexed.
+func indexedTracer(config *TraceConfig) (common.Hash, bool) {
+	if config == nil || config.Tracer == nil {
+		return common.Hash{}, false
+	}
+	id, err := TracerID(*config.Tracer, config.TracerConfig)
+	if err != nil {
+		return common.Hash{}, false
+	}
+	return id, true
+}
+
+// indexedTrace returns the persisted trace of a transaction included in the
+// given block, or nil if the trace index doesn't hold it for the requested
+// tracer and config.
+func (api *API) indexedTrace(hash common.Hash, blockHash common.Hash, config *TraceConfig) json.RawMessage {
+	id, ok := indexedTracer(config)
+	if !ok {
+		return nil
+	}
+	traced, blob := rawdb.ReadTxTrace(api.backend.ChainDb(), id, hash)
+	if blob == nil || traced != blockHash {
+		return nil
+	}
+	return blob
+}
+
+// indexedBlockTraces returns the persisted traces of all the transactions of a
+// block, or nil if the trace index doesn't hold all of them for the requested
+// tracer and config.
+func (api *API) indexedBlockTraces(block *types.Block, config *TraceConfig) []*txTraceResult {
+	id, ok := indexedTracer(config)
+	if !ok {
+		return nil
+	}
+	var (
+		db      = api.backend.ChainDb()
+		txs     = block.Transactions()
+		results = make([]*txTraceResult, len(txs))
+	)
+	if len(txs) == 0 {
+		return nil
+	}
+	for i, tx := range txs {
+		traced, blob := rawdb.ReadTxTrace(db, id, tx.Hash())
+		if blob == nil || traced != block.Hash() {
+			return nil
+		}
+		results[i] = &txTraceResult{Result: json.RawMessage(blob)}
+	}
+	return results
+}
@@ -0,0 +1,113 @@
+// Copyright 2022 The go-ETX Authors
+// This file is part of the go-ETX library.
+//
+// The go-ETX library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ETX library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ETX library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracers
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/ETX/go-ETX/common"
+	"github.com/ETX/go-ETX/core"
+	"github.com/ETX/go-ETX/core/rawdb"
+	"github.com/ETX/go-ETX/core/types"
+	"github.com/ETX/go-ETX/params"
+)
+
+func TestTracerID(t *testing.T) {
+	id := func(tracer string, config string) common.Hash {
+		t.Helper()
+		hash, err := TracerID(tracer, json.RawMessage(config))
+		if err != nil {
+			t.Fatalf("failed to derive tracer id: %v", err)
+		}
+		return hash
+	}
+	// Equivalent configs must map to the same identifier
+	if id("callTracer", "") != id("callTracer", "{}") || id("callTracer", "null") != id("callTracer", " { } ") {
+		t.Errorf("empty configs map to different ids")
+	}
+	if id("callTracer", `{"onlyTopCall":true,"withLog":false}`) != id("callTracer", `{ "withLog": false, "onlyTopCall": true }`) {
+		t.Errorf("reordered configs map to different ids")
+	}
+	// Different tracers or configs must not collide
+	if id("callTracer", "") == id("prestateTracer", "") {
+		t.Errorf("different tracers map to the same id")
+	}
+	if id("callTracer", `{"onlyTopCall":true}`) == id("callTracer", `{"onlyTopCall":false}`) {
+		t.Errorf("different configs map to the same id")
+	}
+	if _, err := TracerID("callTracer", json.RawMessage("{")); err == nil {
+		t.Errorf("invalid config accepted")
+	}
+}
+
+func TestTraceTransactionFromIndex(t *testing.T) {
+	t.Parallel()
+
+	accounts := newAccounts(2)
+	genesis := &core.Genesis{
+		Config: params.TestChainConfig,
+		Alloc: core.GenesisAlloc{
+			accounts[0].addr: {Balance: big.NewInt(params.etxer)},
+		},
+	}
+	target := common.Hash{}
+	signer := types.HomesteadSigner{}
+	backend := newTestBackend(t, 1, genesis, func(i int, b *core.BlockGen) {
+		tx, _ := types.SignTx(types.NewTransaction(uint64(i), accounts[1].addr, big.NewInt(1000), params.TxGas, b.BaseFee(), nil), signer, accounts[0].key)
+		b.AddTx(tx)
+		target = tx.Hash()
+	})
+	defer backend.chain.Stop()
+
+	// Persist a fake trace for a tracer that doesn't exist, any request not
+	// served from the index will fail to construct it.
+	var (
+		tracer = "indexedTestTracer"
+		stored = json.RawMessage(`{"indexed":true}`)
+		block  = backend.chain.GetBlockByNumber(1)
+	)
+	id, _ := TracerID(tracer, json.RawMessage(`{"a":1,"b":2}`))
+	rawdb.WriteTxTrace(backend.chaindb, id, target, block.Hash(), stored)
+
+	api := NewAPI(backend)
+	result, err := api.TraceTransaction(context.Background(), target, &TraceConfig{Tracer: &tracer, TracerConfig: json.RawMessage(`{"b":2,"a":1}`)})
+	if err != nil {
+		t.Fatalf("failed to trace transaction from index: %v", err)
+	}
+	if have := string(result.(json.RawMessage)); have != string(stored) {
+		t.Errorf("indexed trace mismatch: have %s, want %s", have, stored)
+	}
+	blockResults, err := api.TraceBlockByHash(context.Background(), block.Hash(), &TraceConfig{Tracer: &tracer, TracerConfig: json.RawMessage(`{"a":1,"b":2}`)})
+	if err != nil {
+		t.Fatalf("failed to trace block from index: %v", err)
+	}
+	if len(blockResults) != 1 || string(blockResults[0].Result.(json.RawMessage)) != string(stored) {
+		t.Errorf("indexed block trace mismatch: have %v", blockResults)
+	}
+	// A different config must not be served from the index
+	if _, err := api.TraceTransaction(context.Background(), target, &TraceConfig{Tracer: &tracer}); err == nil {
+		t.Errorf("trace with mismatching config served from index")
+	}
+	// A trace made in a reorged block must not be served either
+	rawdb.WriteTxTrace(backend.chaindb, id, target, common.Hash{0x01}, stored)
+	if _, err := api.TraceTransaction(context.Background(), target, &TraceConfig{Tracer: &tracer, TracerConfig: json.RawMessage(`{"a":1,"b":2}`)}); err == nil {
+		t.Errorf("trace from reorged block served from index")
+	}
+}
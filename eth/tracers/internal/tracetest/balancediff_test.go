@@ -0,0 +1,106 @@
+// Copyright 2022 The go-ETX Authors
+// This file is part of the go-ETX library.
+//
+// The go-ETX library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ETX library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ETX library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracetest
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ETX/go-ETX/common"
+	"github.com/ETX/go-ETX/core"
+	"github.com/ETX/go-ETX/core/rawdb"
+	"github.com/ETX/go-ETX/core/tracing"
+	"github.com/ETX/go-ETX/core/types"
+	"github.com/ETX/go-ETX/core/vm"
+	"github.com/ETX/go-ETX/crypto"
+	"github.com/ETX/go-ETX/etx/tracers"
+	"github.com/ETX/go-ETX/params"
+	"github.com/ETX/go-ETX/tests"
+)
+
+func TestBalanceDiffTracer(t *testing.T) {
+	var to = common.HexToAddress("0x00000000000000000000000000000000deadbeef")
+	privkey, err := crypto.HexToECDSA("0000000000000000deadbeef00000000000000000000000000000000deadbeef")
+	if err != nil {
+		t.Fatalf("err %v", err)
+	}
+	signer := types.NewEIP155Signer(big.NewInt(1))
+	tx, err := types.SignNewTx(privkey, signer, &types.LegacyTx{
+		GasPrice: big.NewInt(10),
+		Gas:      params.TxGas,
+		To:       &to,
+		Value:    big.NewInt(1000),
+	})
+	if err != nil {
+		t.Fatalf("err %v", err)
+	}
+	origin, _ := signer.Sender(tx)
+	txContext := vm.TxContext{
+		Origin:   origin,
+		GasPrice: big.NewInt(10),
+	}
+	context := vm.BlockContext{
+		CanTransfer: core.CanTransfer,
+		Transfer:    core.Transfer,
+		Coinbase:    common.Address{},
+		BlockNumber: new(big.Int).SetUint64(8000000),
+		Time:        new(big.Int).SetUint64(5),
+		Difficulty:  big.NewInt(0x30000),
+		GasLimit:    uint64(6000000),
+	}
+	var alloc = core.GenesisAlloc{
+		origin: core.GenesisAccount{
+			Nonce:   0,
+			Balance: big.NewInt(1000000),
+		},
+	}
+	_, statedb := tests.MakePreState(rawdb.NewMemoryDatabase(), alloc, false)
+
+	// Create the tracer, the EVM environment and run it
+	tracer, err := tracers.New("balanceDiffTracer", nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create balance diff tracer: %v", err)
+	}
+	logger, ok := tracer.(tracing.StateLogger)
+	if !ok {
+		t.Fatalf("balance diff tracer doesn't implement the state logger")
+	}
+	statedb.SetLogger(logger)
+
+	evm := vm.NewEVM(context, txContext, statedb, params.MainnetChainConfig, vm.Config{Debug: true, Tracer: tracer})
+	msg, err := tx.AsMessage(signer, nil)
+	if err != nil {
+		t.Fatalf("failed to prepare transaction for tracing: %v", err)
+	}
+	st := core.NewStateTransition(evm, msg, new(core.GasPool).AddGas(tx.Gas()))
+	if _, err = st.TransitionDb(); err != nil {
+		t.Fatalf("failed to execute transaction: %v", err)
+	}
+	// Retrieve the trace result and compare against the etalon
+	res, err := tracer.GetResult()
+	if err != nil {
+		t.Fatalf("failed to retrieve trace result: %v", err)
+	}
+	wantStr := `{` +
+		`"0x0000000000000000000000000000000000000000":{"pre":"0x0","post":"0x33450","changes":[{"from":"0x0","to":"0x33450","reason":"rewardTransactionFee"}]},` +
+		`"0x00000000000000000000000000000000deadbeef":{"pre":"0x0","post":"0x3e8","changes":[{"from":"0x0","to":"0x3e8","reason":"transfer"}]},` +
+		`"0x682a80a6f560eec50d54e63cbeda1c324c5f8d1b":{"pre":"0xf4240","post":"0xc0a08","changes":[{"from":"0xf4240","to":"0xc0df0","reason":"gasBuy"},{"from":"0xc0df0","to":"0xc0a08","reason":"transfer"}]}` +
+		`}`
+	if string(res) != wantStr {
+		t.Fatalf("trace mismatch\n have: %v\n want: %v\n", string(res), wantStr)
+	}
+}
@@ -0,0 +1,155 @@
+// Copyright 2022 The go-ETX Authors
+// This file is part of the go-ETX library.
+//
+// The go-ETX library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ETX library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ETX library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracetest
+
+import (
+	"bytes"
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/ETX/go-ETX/common"
+	"github.com/ETX/go-ETX/common/hexutil"
+	"github.com/ETX/go-ETX/core"
+	"github.com/ETX/go-ETX/core/rawdb"
+	"github.com/ETX/go-ETX/core/vm"
+	"github.com/ETX/go-ETX/crypto"
+	"github.com/ETX/go-ETX/etx/tracers"
+	"github.com/ETX/go-ETX/params"
+	"github.com/ETX/go-ETX/tests"
+)
+
+// vmTrace is the result of a vmTracer run.
+type vmTrace struct {
+	Code hexutil.Bytes `json:"code"`
+	Ops  []struct {
+		Cost uint64 `json:"cost"`
+		Ex   *struct {
+			Mem *struct {
+				Data hexutil.Bytes `json:"data"`
+				Off  uint64        `json:"off"`
+			} `json:"mem"`
+			Push  []string `json:"push"`
+			Store *struct {
+				Key string `json:"key"`
+				Val string `json:"val"`
+			} `json:"store"`
+			Used uint64 `json:"used"`
+		} `json:"ex"`
+		Pc  uint64   `json:"pc"`
+		Sub *vmTrace `json:"sub"`
+	} `json:"ops"`
+}
+
+// Tests the vm tracer against a contract calling into a reverting contract and
+// a self-destructing one, and creating a new contract.
+func TestVMTracer(t *testing.T) {
+	var (
+		caller   = common.HexToAddress("0x1000")
+		main     = common.HexToAddress("0xaa")
+		reverter = common.HexToAddress("0xbb")
+		suicider = common.HexToAddress("0xdd")
+
+		// Stores 0x2a in slot 0, then reverts
+		reverterCode = common.FromHex("602a60005560006000fd")
+		// Self-destructs, refunding the caller
+		suiciderCode = common.FromHex("33ff")
+		// Stores 0x01 in slot 0
+		initCode = common.FromHex("600160005500")
+		// Calls the reverter and the suicider, then creates a contract from initCode
+		mainCode = common.FromHex("6000600060006000600060bb5af150" + "6000600060006000600060dd5af150" +
+			"65600160005500600052" + "6006601a6000f050" + "00")
+	)
+	alloc := core.GenesisAlloc{
+		caller:   {Balance: big.NewInt(1e18)},
+		main:     {Code: mainCode},
+		reverter: {Code: reverterCode},
+		suicider: {Code: suiciderCode, Balance: big.NewInt(1000)},
+	}
+	_, statedb := tests.MakePreState(rawdb.NewMemoryDatabase(), alloc, false)
+
+	tracer, err := tracers.New("vmTracer", new(tracers.Context), nil)
+	if err != nil {
+		t.Fatalf("failed to create vm tracer: %v", err)
+	}
+	context := vm.BlockContext{
+		CanTransfer: core.CanTransfer,
+		Transfer:    core.Transfer,
+		BlockNumber: big.NewInt(1),
+		Time:        big.NewInt(1),
+		Difficulty:  big.NewInt(1),
+		GasLimit:    10_000_000,
+	}
+	evm := vm.NewEVM(context, vm.TxContext{Origin: caller, GasPrice: big.NewInt(0)}, statedb, params.TestChainConfig, vm.Config{Debug: true, Tracer: tracer})
+	if _, _, err := evm.Call(vm.AccountRef(caller), main, nil, 1_000_000, new(big.Int)); err != nil {
+		t.Fatalf("failed to execute call: %v", err)
+	}
+	res, err := tracer.GetResult()
+	if err != nil {
+		t.Fatalf("failed to retrieve trace result: %v", err)
+	}
+	var trace vmTrace
+	if err := json.Unmarshal(res, &trace); err != nil {
+		t.Fatalf("failed to unmarshal trace: %v", err)
+	}
+	if !bytes.Equal(trace.Code, mainCode) || len(trace.Ops) != 27 {
+		t.Fatalf("top frame mismatch: code %x, %d ops", trace.Code, len(trace.Ops))
+	}
+	// Every op reports the stack items it leaves behind
+	pushes := []int{1, 1, 1, 1, 1, 1, 1, 1, 0, 1, 1, 1, 1, 1, 1, 1, 1, 0, 1, 1, 0, 1, 1, 1, 1, 0, 0}
+	for i, op := range trace.Ops {
+		if op.Ex == nil || len(op.Ex.Push) != pushes[i] {
+			t.Fatalf("op %d (pc %d): push count mismatch: have %v, want %d", i, op.Pc, op.Ex, pushes[i])
+		}
+		if op.Sub != nil && i != 7 && i != 16 && i != 24 {
+			t.Errorf("op %d (pc %d): unexpected sub trace", i, op.Pc)
+		}
+	}
+	// The reverted call must contain the storage write, and return failure
+	call := trace.Ops[7]
+	if call.Pc != 13 || call.Sub == nil || call.Ex.Push[0] != "0x0" {
+		t.Fatalf("reverted call mismatch: pc %d, sub %v, push %v", call.Pc, call.Sub, call.Ex.Push)
+	}
+	if sub := call.Sub; !bytes.Equal(sub.Code, reverterCode) || len(sub.Ops) != 6 {
+		t.Errorf("reverted call frame mismatch: code %x, %d ops", sub.Code, len(sub.Ops))
+	} else if store := sub.Ops[2].Ex.Store; store == nil || store.Key != "0x0" || store.Val != "0x2a" {
+		t.Errorf("reverted call storage write mismatch: %+v", store)
+	}
+	// The self-destructing call must not contain a sub trace for the self-destruct
+	call = trace.Ops[16]
+	if call.Pc != 28 || call.Sub == nil || call.Ex.Push[0] != "0x1" {
+		t.Fatalf("self-destructing call mismatch: pc %d, sub %v, push %v", call.Pc, call.Sub, call.Ex.Push)
+	}
+	if sub := call.Sub; !bytes.Equal(sub.Code, suiciderCode) || len(sub.Ops) != 2 {
+		t.Errorf("self-destructing call frame mismatch: code %x, %d ops", sub.Code, len(sub.Ops))
+	} else if common.HexToAddress(sub.Ops[0].Ex.Push[0]) != main || sub.Ops[1].Sub != nil {
+		t.Errorf("self-destruct mismatch: caller %v, sub %v", sub.Ops[0].Ex.Push, sub.Ops[1].Sub)
+	}
+	// The init code must be written to memory and executed in the create frame
+	if mem := trace.Ops[20].Ex.Mem; mem == nil || mem.Off != 0 || !bytes.Equal(mem.Data, common.LeftPadBytes(initCode, 32)) {
+		t.Errorf("memory write mismatch: %+v", mem)
+	}
+	create := trace.Ops[24]
+	if create.Pc != 46 || create.Sub == nil || common.HexToAddress(create.Ex.Push[0]) != crypto.CreateAddress(main, 0) {
+		t.Fatalf("create mismatch: pc %d, sub %v, push %v", create.Pc, create.Sub, create.Ex.Push)
+	}
+	if sub := create.Sub; !bytes.Equal(sub.Code, initCode) || len(sub.Ops) != 4 {
+		t.Errorf("create frame mismatch: code %x, %d ops", sub.Code, len(sub.Ops))
+	} else if store := sub.Ops[2].Ex.Store; store == nil || store.Key != "0x0" || store.Val != "0x1" {
+		t.Errorf("create storage write mismatch: %+v", store)
+	}
+}
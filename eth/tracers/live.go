@@ -0,0 +1,241 @@
+// Copyright 2022 The go-ETX Authors
+// This file is part of the go-ETX library.
+//
+// The go-ETX library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ETX library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ETX library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracers
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ETX/go-ETX/common"
+	"github.com/ETX/go-ETX/core"
+	"github.com/ETX/go-ETX/core/types"
+)
+
+// liveSinkWriteTimeout is the maximum time a socket sink waits for the remote
+// end to accept an event before dropping the connection.
+const liveSinkWriteTimeout = 5 * time.Second
+
+// NewLiveTracerFactory returns a factory creating the named tracer with the
+// given config for every transaction executed during block import.
+func NewLiveTracerFactory(name string, config json.RawMessage) (core.LiveTracerFactory, error) {
+	// Make sure the tracer exists and accepts the config before starting
+	if _, err := New(name, new(Context), config); err != nil {
+		return nil, err
+	}
+	factory := func(block *types.Block, index int) (core.LiveTxTracer, error) {
+		ctx := &Context{
+			BlockHash:   block.Hash(),
+			BlockNumber: block.Number(),
+			TxIndex:     index,
+		}
+		if txs := block.Transactions(); index < len(txs) {
+			ctx.TxHash = txs[index].Hash()
+		}
+		tracer, err := New(name, ctx, config)
+		if err != nil {
+			return nil, err
+		}
+		return tracer, nil
+	}
+	return factory, nil
+}
+
+// liveEvent is the JSON representation of a single event emitted by the
+// stream based live trace sinks, one per line.
+type liveEvent struct {
+	Type   string           `json:"type"` // "block" or "revert"
+	Block  *core.BlockTrace `json:"block,omitempty"`
+	Number uint64           `json:"number,omitempty"`
+	Hash   *common.Hash     `json:"hash,omitempty"`
+}
+
+// OpenLiveTraceSink opens the live trace sink described by the given spec:
+//
+//   - "file:<path>" appends newline delimited JSON events to a file,
+//   - "unix:<path>" streams newline delimited JSON events to a unix socket.
+//
+// A spec without a scheme is treated as a file path.
+func OpenLiveTraceSink(spec string) (core.LiveTraceSink, error) {
+	scheme, path, ok := strings.Cut(spec, ":")
+	if !ok {
+		scheme, path = "file", spec
+	}
+	if path == "" {
+		return nil, fmt.Errorf("missing path in live trace sink %q", spec)
+	}
+	switch scheme {
+	case "file":
+		file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+		if err != nil {
+			return nil, err
+		}
+		return newStreamSink(file, func() error { return file.Sync() }), nil
+	case "unix":
+		return newSocketSink(path), nil
+	default:
+		return nil, fmt.Errorf("unknown live trace sink scheme %q", scheme)
+	}
+}
+
+// streamSink is a live trace sink writing JSON events into a stream.
+type streamSink struct {
+	out     io.WriteCloser
+	buf     *bufio.Writer
+	persist func() error // Optional callback to persist the flushed events
+	mu      sync.Mutex
+	enc     *json.Encoder
+	close   sync.Once
+}
+
+func newStreamSink(out io.WriteCloser, persist func() error) *streamSink {
+	buf := bufio.NewWriter(out)
+	return &streamSink{
+		out:     out,
+		buf:     buf,
+		persist: persist,
+		enc:     json.NewEncoder(buf),
+	}
+}
+
+// OnBlock implements core.LiveTraceSink, writing out a block event.
+func (s *streamSink) OnBlock(trace *core.BlockTrace) error {
+	return s.write(&liveEvent{Type: "block", Block: trace})
+}
+
+// OnRevert implements core.LiveTraceSink, writing out a revert event.
+func (s *streamSink) OnRevert(number uint64, hash common.Hash) error {
+	return s.write(&liveEvent{Type: "revert", Number: number, Hash: &hash})
+}
+
+// Close implements core.LiveTraceSink, flushing and closing the stream.
+func (s *streamSink) Close() error {
+	var err error
+	s.close.Do(func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		if err = s.buf.Flush(); err == nil && s.persist != nil {
+			err = s.persist()
+		}
+		if cerr := s.out.Close(); err == nil {
+			err = cerr
+		}
+	})
+	return err
+}
+
+func (s *streamSink) write(event *liveEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.enc.Encode(event); err != nil {
+		return err
+	}
+	return s.buf.Flush()
+}
+
+// socketSink is a live trace sink streaming JSON events to a local socket. The
+// connection is established lazily and re-established after failures, events
+// emitted while the remote end is unavailable are dropped.
+type socketSink struct {
+	path string
+	conn net.Conn
+	enc  *json.Encoder
+	mu   sync.Mutex
+}
+
+func newSocketSink(path string) *socketSink {
+	return &socketSink{path: path}
+}
+
+// OnBlock implements core.LiveTraceSink, sending a block event.
+func (s *socketSink) OnBlock(trace *core.BlockTrace) error {
+	return s.send(&liveEvent{Type: "block", Block: trace})
+}
+
+// OnRevert implements core.LiveTraceSink, sending a revert event.
+func (s *socketSink) OnRevert(number uint64, hash common.Hash) error {
+	return s.send(&liveEvent{Type: "revert", Number: number, Hash: &hash})
+}
+
+// Close implements core.LiveTraceSink, closing the connection if any.
+func (s *socketSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn, s.enc = nil, nil
+	return err
+}
+
+func (s *socketSink) send(event *liveEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.conn == nil {
+		conn, err := net.DialTimeout("unix", s.path, liveSinkWriteTimeout)
+		if err != nil {
+			return err
+		}
+		s.conn, s.enc = conn, json.NewEncoder(conn)
+	}
+	s.conn.SetWriteDeadline(time.Now().Add(liveSinkWriteTimeout))
+	if err := s.enc.Encode(event); err != nil {
+		s.conn.Close()
+		s.conn, s.enc = nil, nil
+		return err
+	}
+	return nil
+}
+
+// FuncSink is a live trace sink handing the traces over to in-process
+// callbacks. Nil callbacks are ignored.
+type FuncSink struct {
+	Block  func(trace *core.BlockTrace) error
+	Revert func(number uint64, hash common.Hash) error
+}
+
+// OnBlock implements core.LiveTraceSink, invoking the block callback.
+func (s *FuncSink) OnBlock(trace *core.BlockTrace) error {
+	if s.Block == nil {
+		return nil
+	}
+	return s.Block(trace)
+}
+
+// OnRevert implements core.LiveTraceSink, invoking the revert callback.
+func (s *FuncSink) OnRevert(number uint64, hash common.Hash) error {
+	if s.Revert == nil {
+		return nil
+	}
+	return s.Revert(number, hash)
+}
+
+// Close implements core.LiveTraceSink.
+func (s *FuncSink) Close() error {
+	return nil
+}
@@ -0,0 +1,145 @@
+// Copyright 2022 The go-ETX Authors
+// This file is part of the go-ETX library.
+//
+// The go-ETX library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ETX library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ETX library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracers
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/ETX/go-ETX/common"
+	"github.com/ETX/go-ETX/core"
+)
+
+var (
+	testLiveTrace = &core.BlockTrace{
+		Number:     1,
+		Hash:       common.Hash{0x01},
+		ParentHash: common.Hash{0x02},
+		Traces:     []*core.TxTrace{{TxHash: common.Hash{0x03}, Result: json.RawMessage(`{"gas":21000}`)}},
+	}
+	testLiveHash   = common.Hash{0x01}
+	testLiveEvents = []*liveEvent{
+		{Type: "block", Block: testLiveTrace},
+		{Type: "revert", Number: 1, Hash: &testLiveHash},
+	}
+)
+
+// emitLiveEvents feeds the test events into a sink.
+func emitLiveEvents(t *testing.T, sink core.LiveTraceSink) {
+	t.Helper()
+	if err := sink.OnBlock(testLiveTrace); err != nil {
+		t.Fatalf("failed to emit block: %v", err)
+	}
+	if err := sink.OnRevert(1, testLiveHash); err != nil {
+		t.Fatalf("failed to emit revert: %v", err)
+	}
+}
+
+// readLiveEvents decodes the given number of newline delimited events.
+func readLiveEvents(t *testing.T, r io.Reader, count int) []*liveEvent {
+	t.Helper()
+	var (
+		scanner = bufio.NewScanner(r)
+		events  []*liveEvent
+	)
+	for len(events) < count && scanner.Scan() {
+		event := new(liveEvent)
+		if err := json.Unmarshal(scanner.Bytes(), event); err != nil {
+			t.Fatalf("invalid event %q: %v", scanner.Text(), err)
+		}
+		events = append(events, event)
+	}
+	if len(events) != count {
+		t.Fatalf("event count mismatch: have %d, want %d (%v)", len(events), count, scanner.Err())
+	}
+	return events
+}
+
+// Tests that the file sink appends the events to the file, one per line.
+func TestLiveFileSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "trace.jsonl")
+	for i := 0; i < 2; i++ {
+		sink, err := OpenLiveTraceSink("file:" + path)
+		if err != nil {
+			t.Fatalf("failed to open sink: %v", err)
+		}
+		emitLiveEvents(t, sink)
+		if err := sink.Close(); err != nil {
+			t.Fatalf("failed to close sink: %v", err)
+		}
+		if err := sink.Close(); err != nil {
+			t.Fatalf("failed to close sink twice: %v", err)
+		}
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("failed to open trace file: %v", err)
+	}
+	defer file.Close()
+
+	events := readLiveEvents(t, file, 2*len(testLiveEvents))
+	if want := append(testLiveEvents, testLiveEvents...); !reflect.DeepEqual(events, want) {
+		t.Fatalf("events mismatch: have %v, want %v", events, want)
+	}
+}
+
+// Tests that the socket sink streams the events to the listener, dropping them
+// while it's unavailable and reconnecting once it's back.
+func TestLiveSocketSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "trace.sock")
+	sink, err := OpenLiveTraceSink("unix:" + path)
+	if err != nil {
+		t.Fatalf("failed to open sink: %v", err)
+	}
+	defer sink.Close()
+
+	if err := sink.OnBlock(testLiveTrace); err == nil {
+		t.Fatal("event emitted without listener")
+	}
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer listener.Close()
+
+	emitLiveEvents(t, sink)
+	conn, err := listener.Accept()
+	if err != nil {
+		t.Fatalf("failed to accept: %v", err)
+	}
+	defer conn.Close()
+
+	if events := readLiveEvents(t, conn, len(testLiveEvents)); !reflect.DeepEqual(events, testLiveEvents) {
+		t.Fatalf("events mismatch: have %v, want %v", events, testLiveEvents)
+	}
+}
+
+// Tests that invalid sink specs are rejected.
+func TestOpenLiveTraceSinkInvalid(t *testing.T) {
+	for _, spec := range []string{"file:", "unix:", "http://localhost"} {
+		if sink, err := OpenLiveTraceSink(spec); err == nil {
+			sink.Close()
+			t.Errorf("spec %q: sink opened", spec)
+		}
+	}
+}
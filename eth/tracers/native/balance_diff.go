@@ -0,0 +1,133 @@
+// Copyright 2022 The go-ETX Authors
+// This file is part of the go-ETX library.
+//
+// The go-ETX library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ETX library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ETX library. If not, see <http://www.gnu.org/licenses/>.
+
+package native
+
+import (
+	"encoding/json"
+	"math/big"
+	"sync/atomic"
+
+	"github.com/ETX/go-ETX/common"
+	"github.com/ETX/go-ETX/common/hexutil"
+	"github.com/ETX/go-ETX/core/tracing"
+	"github.com/ETX/go-ETX/core/types"
+	"github.com/ETX/go-ETX/core/vm"
+	"github.com/ETX/go-ETX/etx/tracers"
+)
+
+func init() {
+	register("balanceDiffTracer", newBalanceDiffTracer)
+}
+
+// balanceChange is a single modification of an account balance.
+type balanceChange struct {
+	From   *hexutil.Big `json:"from"`
+	To     *hexutil.Big `json:"to"`
+	Reason string       `json:"reason"`
+}
+
+// balanceDiff is the balance of an account before and after the traced
+// execution, along with the changes leading from one to the other.
+type balanceDiff struct {
+	Pre     *hexutil.Big    `json:"pre"`
+	Post    *hexutil.Big    `json:"post"`
+	Changes []balanceChange `json:"changes"`
+}
+
+// balanceDiffTracer is a native go tracer which reports the balance changes
+// of every account touched by a transaction, including the ones made outside
+// of the EVM (gas purchase and refund, fees, block rewards).
+//
+// The changes are reported in execution order, including the ones undone by a
+// reverted call frame. The post balance accounts for reverts.
+type balanceDiffTracer struct {
+	noopTracer
+	env       *vm.EVM
+	diffs     map[common.Address]*balanceDiff
+	interrupt uint32 // Atomic flag to signal execution interruption
+	reason    error  // Textual reason for the interruption
+}
+
+// newBalanceDiffTracer returns a native go tracer which reports balance
+// changes, and implements vm.EVMLogger and tracing.StateLogger.
+func newBalanceDiffTracer(ctx *tracers.Context, _ json.RawMessage) (tracers.Tracer, error) {
+	return &balanceDiffTracer{diffs: make(map[common.Address]*balanceDiff)}, nil
+}
+
+// CaptureStart implements the EVMLogger interface to initialize the tracing operation.
+func (t *balanceDiffTracer) CaptureStart(env *vm.EVM, from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) {
+	t.env = env
+}
+
+// CaptureTxEnd settles the post balances from the state, as the changes undone
+// by reverted call frames are not reported.
+func (t *balanceDiffTracer) CaptureTxEnd(restGas uint64) {
+	if t.env == nil {
+		return
+	}
+	for addr, diff := range t.diffs {
+		diff.Post = (*hexutil.Big)(new(big.Int).Set(t.env.StateDB.GetBalance(addr)))
+	}
+}
+
+// OnBalanceChange implements the StateLogger interface, recording a balance change.
+func (t *balanceDiffTracer) OnBalanceChange(addr common.Address, prev, cur *big.Int, reason tracing.BalanceChangeReason) {
+	if atomic.LoadUint32(&t.interrupt) > 0 {
+		return
+	}
+	diff, ok := t.diffs[addr]
+	if !ok {
+		diff = &balanceDiff{Pre: (*hexutil.Big)(new(big.Int).Set(prev)), Changes: []balanceChange{}}
+		t.diffs[addr] = diff
+	}
+	diff.Post = (*hexutil.Big)(new(big.Int).Set(cur))
+	diff.Changes = append(diff.Changes, balanceChange{
+		From:   (*hexutil.Big)(new(big.Int).Set(prev)),
+		To:     (*hexutil.Big)(new(big.Int).Set(cur)),
+		Reason: reason.String(),
+	})
+}
+
+// OnNonceChange implements the StateLogger interface.
+func (t *balanceDiffTracer) OnNonceChange(addr common.Address, prev, new uint64) {}
+
+// OnCodeChange implements the StateLogger interface.
+func (t *balanceDiffTracer) OnCodeChange(addr common.Address, prevCodeHash common.Hash, prevCode []byte, codeHash common.Hash, code []byte) {
+}
+
+// OnStorageChange implements the StateLogger interface.
+func (t *balanceDiffTracer) OnStorageChange(addr common.Address, slot common.Hash, prev, new common.Hash) {
+}
+
+// OnLog implements the StateLogger interface.
+func (t *balanceDiffTracer) OnLog(log *types.Log) {}
+
+// GetResult returns the json-encoded balance diffs keyed by account, and any
+// error arising from the encoding or forceful termination (via `Stop`).
+func (t *balanceDiffTracer) GetResult() (json.RawMessage, error) {
+	res, err := json.Marshal(t.diffs)
+	if err != nil {
+		return nil, err
+	}
+	return json.RawMessage(res), t.reason
+}
+
+// Stop terminates execution of the tracer at the first opportune moment.
+func (t *balanceDiffTracer) Stop(err error) {
+	t.reason = err
+	atomic.StoreUint32(&t.interrupt, 1)
+}
@@ -22,6 +22,8 @@ import (
 	"time"
 
 	"github.com/ETX/go-ETX/common"
+	"github.com/ETX/go-ETX/core/tracing"
+	"github.com/ETX/go-ETX/core/types"
 	"github.com/ETX/go-ETX/core/vm"
 	"github.com/ETX/go-ETX/etx/tracers"
 )
@@ -114,6 +116,56 @@ func (t *muxTracer) CaptureTxEnd(restGas uint64) {
 	}
 }
 
+// OnBalanceChange implements the StateLogger interface, forwarding the change
+// to the tracers interested in state changes.
+func (t *muxTracer) OnBalanceChange(addr common.Address, prev, new *big.Int, reason tracing.BalanceChangeReason) {
+	for _, t := range t.tracers {
+		if logger, ok := t.(tracing.StateLogger); ok {
+			logger.OnBalanceChange(addr, prev, new, reason)
+		}
+	}
+}
+
+// OnNonceChange implements the StateLogger interface, forwarding the change
+// to the tracers interested in state changes.
+func (t *muxTracer) OnNonceChange(addr common.Address, prev, new uint64) {
+	for _, t := range t.tracers {
+		if logger, ok := t.(tracing.StateLogger); ok {
+			logger.OnNonceChange(addr, prev, new)
+		}
+	}
+}
+
+// OnCodeChange implements the StateLogger interface, forwarding the change
+// to the tracers interested in state changes.
+func (t *muxTracer) OnCodeChange(addr common.Address, prevCodeHash common.Hash, prevCode []byte, codeHash common.Hash, code []byte) {
+	for _, t := range t.tracers {
+		if logger, ok := t.(tracing.StateLogger); ok {
+			logger.OnCodeChange(addr, prevCodeHash, prevCode, codeHash, code)
+		}
+	}
+}
+
+// OnStorageChange implements the StateLogger interface, forwarding the change
+// to the tracers interested in state changes.
+func (t *muxTracer) OnStorageChange(addr common.Address, slot common.Hash, prev, new common.Hash) {
+	for _, t := range t.tracers {
+		if logger, ok := t.(tracing.StateLogger); ok {
+			logger.OnStorageChange(addr, slot, prev, new)
+		}
+	}
+}
+
+// OnLog implements the StateLogger interface, forwarding the log to the
+// tracers interested in state changes.
+func (t *muxTracer) OnLog(log *types.Log) {
+	for _, t := range t.tracers {
+		if logger, ok := t.(tracing.StateLogger); ok {
+			logger.OnLog(log)
+		}
+	}
+}
+
 // GetResult returns an empty json object.
 func (t *muxTracer) GetResult() (json.RawMessage, error) {
 	resObject := make(map[string]json.RawMessage)
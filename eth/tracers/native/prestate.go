@@ -25,6 +25,8 @@ import (
 
 	"github.com/ETX/go-ETX/common"
 	"github.com/ETX/go-ETX/common/hexutil"
+	"github.com/ETX/go-ETX/core/tracing"
+	"github.com/ETX/go-ETX/core/types"
 	"github.com/ETX/go-ETX/core/vm"
 	"github.com/ETX/go-ETX/crypto"
 	"github.com/ETX/go-ETX/etx/tracers"
@@ -235,6 +237,46 @@ func (t *prestateTracer) CaptureTxEnd(restGas uint64) {
 	}
 }
 
+// OnBalanceChange implements the StateLogger interface, capturing the pre-state
+// of accounts whose balance is modified outside of the traced opcodes.
+func (t *prestateTracer) OnBalanceChange(addr common.Address, prev, new *big.Int, reason tracing.BalanceChangeReason) {
+	t.captureAccount(addr)
+}
+
+// OnNonceChange implements the StateLogger interface, capturing the pre-state
+// of accounts whose nonce is modified outside of the traced opcodes.
+func (t *prestateTracer) OnNonceChange(addr common.Address, prev, new uint64) {
+	t.captureAccount(addr)
+}
+
+// OnCodeChange implements the StateLogger interface, capturing the pre-state
+// of accounts whose code is modified outside of the traced opcodes.
+func (t *prestateTracer) OnCodeChange(addr common.Address, prevCodeHash common.Hash, prevCode []byte, codeHash common.Hash, code []byte) {
+	t.captureAccount(addr)
+}
+
+// OnStorageChange implements the StateLogger interface, capturing the pre-state
+// of slots modified outside of the traced opcodes.
+func (t *prestateTracer) OnStorageChange(addr common.Address, slot common.Hash, prev, new common.Hash) {
+	if t.captureAccount(addr) {
+		t.lookupStorage(addr, slot)
+	}
+}
+
+// OnLog implements the StateLogger interface.
+func (t *prestateTracer) OnLog(log *types.Log) {}
+
+// captureAccount adds an account about to be modified to the prestate. Changes
+// made before the top call frame starts (gas purchase, nonce increment, value
+// transfer) are ignored, CaptureStart accounts for them itself.
+func (t *prestateTracer) captureAccount(addr common.Address) bool {
+	if t.env == nil || atomic.LoadUint32(&t.interrupt) > 0 {
+		return false
+	}
+	t.lookupAccount(addr)
+	return true
+}
+
 // GetResult returns the json-encoded nested list of call traces, and any
 // error arising from the encoding or forceful termination (via `Stop`).
 func (t *prestateTracer) GetResult() (json.RawMessage, error) {
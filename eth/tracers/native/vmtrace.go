@@ -0,0 +1,262 @@
+// Copyright 2022 The go-ETX Authors
+// This file is part of the go-ETX library.
+//
+// The go-ETX library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ETX library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ETX library. If not, see <http://www.gnu.org/licenses/>.
+
+package native
+
+import (
+	"encoding/json"
+	"math/big"
+	"sync/atomic"
+	"time"
+
+	"github.com/ETX/go-ETX/common"
+	"github.com/ETX/go-ETX/common/hexutil"
+	"github.com/ETX/go-ETX/core/vm"
+	"github.com/ETX/go-ETX/etx/tracers"
+)
+
+func init() {
+	register("vmTracer", newVMTracer)
+}
+
+// vmTrace is the Parity-style trace of the code executed in a single call frame.
+type vmTrace struct {
+	Code hexutil.Bytes `json:"code"`
+	Ops  []*vmTraceOp  `json:"ops"`
+}
+
+// vmTraceOp is a single executed instruction along with its side effects.
+type vmTraceOp struct {
+	Cost uint64     `json:"cost"`
+	Ex   *vmTraceEx `json:"ex"`
+	Pc   uint64     `json:"pc"`
+	Sub  *vmTrace   `json:"sub"`
+}
+
+// vmTraceEx contains the effects of an executed instruction.
+type vmTraceEx struct {
+	Mem   *vmTraceMem   `json:"mem"`
+	Push  []string      `json:"push"`
+	Store *vmTraceStore `json:"store"`
+	Used  uint64        `json:"used"`
+}
+
+type vmTraceMem struct {
+	Data hexutil.Bytes `json:"data"`
+	Off  uint64        `json:"off"`
+}
+
+type vmTraceStore struct {
+	Key string `json:"key"`
+	Val string `json:"val"`
+}
+
+// vmTraceFrame tracks the instruction of a call frame whose effects can only
+// be observed once the next instruction in the same frame starts executing.
+type vmTraceFrame struct {
+	trace   *vmTrace
+	pending *vmTraceOp
+	pushes  int    // Number of stack items the pending op leaves behind
+	memOff  uint64 // Start of the memory range written by the pending op
+	memSize uint64 // Size of the memory range written by the pending op
+}
+
+// vmTracer is a native go tracer which produces the Parity-style `vmTrace`
+// output, i.e. the tree of executed instructions with the stack items they
+// pushed, the memory they wrote and the storage slots they modified.
+type vmTracer struct {
+	noopTracer
+	env       *vm.EVM
+	root      *vmTrace
+	frames    []*vmTraceFrame
+	interrupt uint32 // Atomic flag to signal execution interruption
+	reason    error  // Textual reason for the interruption
+}
+
+// newVMTracer returns a native go tracer which produces Parity-style vm
+// traces, and implements vm.EVMLogger.
+func newVMTracer(ctx *tracers.Context, _ json.RawMessage) (tracers.Tracer, error) {
+	return &vmTracer{}, nil
+}
+
+// CaptureStart implements the EVMLogger interface to initialize the tracing operation.
+func (t *vmTracer) CaptureStart(env *vm.EVM, from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) {
+	t.env = env
+
+	code := common.CopyBytes(input)
+	if !create {
+		code = env.StateDB.GetCode(to)
+	}
+	t.root = &vmTrace{Code: code, Ops: []*vmTraceOp{}}
+	t.frames = []*vmTraceFrame{{trace: t.root}}
+}
+
+// CaptureEnd is called after the call finishes to finalize the tracing.
+func (t *vmTracer) CaptureEnd(output []byte, gasUsed uint64, _ time.Duration, err error) {
+	if len(t.frames) > 0 {
+		t.frames[0].complete(nil)
+	}
+}
+
+// CaptureState implements the EVMLogger interface to trace a single step of VM execution.
+func (t *vmTracer) CaptureState(pc uint64, op vm.OpCode, gas, cost uint64, scope *vm.ScopeContext, rData []byte, depth int, err error) {
+	// Skip if tracing was interrupted
+	if atomic.LoadUint32(&t.interrupt) > 0 || len(t.frames) == 0 {
+		return
+	}
+	frame := t.frames[len(t.frames)-1]
+	frame.complete(scope)
+
+	used := uint64(0)
+	if gas > cost {
+		used = gas - cost
+	}
+	traceOp := &vmTraceOp{Cost: cost, Pc: pc, Ex: &vmTraceEx{Push: []string{}, Used: used}}
+	frame.trace.Ops = append(frame.trace.Ops, traceOp)
+	frame.pending = traceOp
+	frame.pushes = stackPushes(op)
+	frame.memOff, frame.memSize = 0, 0
+
+	stack := scope.Stack.Data()
+	back := func(n int) uint64 {
+		if len(stack) <= n {
+			return 0
+		}
+		return stack[len(stack)-1-n].Uint64()
+	}
+	switch op {
+	case vm.SSTORE:
+		if len(stack) >= 2 {
+			traceOp.Ex.Store = &vmTraceStore{
+				Key: stack[len(stack)-1].Hex(),
+				Val: stack[len(stack)-2].Hex(),
+			}
+		}
+	case vm.MSTORE:
+		frame.memOff, frame.memSize = back(0), 32
+	case vm.MSTORE8:
+		frame.memOff, frame.memSize = back(0), 1
+	case vm.CALLDATACOPY, vm.CODECOPY, vm.RETURNDATACOPY:
+		frame.memOff, frame.memSize = back(0), back(2)
+	case vm.EXTCODECOPY:
+		frame.memOff, frame.memSize = back(1), back(3)
+	case vm.CALL, vm.CALLCODE:
+		frame.memOff, frame.memSize = back(5), back(6)
+	case vm.DELEGATECALL, vm.STATICCALL:
+		frame.memOff, frame.memSize = back(4), back(5)
+	}
+}
+
+// CaptureEnter is called when EVM enters a new scope (via call, create or selfdestruct).
+func (t *vmTracer) CaptureEnter(typ vm.OpCode, from common.Address, to common.Address, input []byte, gas uint64, value *big.Int) {
+	if atomic.LoadUint32(&t.interrupt) > 0 || len(t.frames) == 0 {
+		return
+	}
+	var (
+		parent = t.frames[len(t.frames)-1]
+		sub    = &vmTrace{Ops: []*vmTraceOp{}}
+	)
+	switch typ {
+	case vm.CREATE, vm.CREATE2:
+		sub.Code = common.CopyBytes(input)
+	default:
+		sub.Code = t.env.StateDB.GetCode(to)
+	}
+	// Self-destructs don't execute any code, track them as a detached frame
+	// to keep the frame stack balanced.
+	if typ != vm.SELFDESTRUCT && parent.pending != nil {
+		parent.pending.Sub = sub
+	}
+	t.frames = append(t.frames, &vmTraceFrame{trace: sub})
+}
+
+// CaptureExit is called when EVM exits a scope, even if the scope didn't
+// execute any code.
+func (t *vmTracer) CaptureExit(output []byte, gasUsed uint64, err error) {
+	if len(t.frames) <= 1 {
+		return
+	}
+	t.frames[len(t.frames)-1].complete(nil)
+	t.frames = t.frames[:len(t.frames)-1]
+}
+
+// GetResult returns the json-encoded vm trace, and any error arising from the
+// encoding or forceful termination (via `Stop`).
+func (t *vmTracer) GetResult() (json.RawMessage, error) {
+	res, err := json.Marshal(t.root)
+	if err != nil {
+		return nil, err
+	}
+	return json.RawMessage(res), t.reason
+}
+
+// Stop terminates execution of the tracer at the first opportune moment.
+func (t *vmTracer) Stop(err error) {
+	t.reason = err
+	atomic.StoreUint32(&t.interrupt, 1)
+}
+
+// complete fills in the effects of the pending instruction of the frame from
+// the post-execution scope. The scope is nil if the frame finished executing,
+// in which case the pending instruction was the last one and had no effects
+// on the stack or memory worth reporting.
+func (f *vmTraceFrame) complete(scope *vm.ScopeContext) {
+	if f.pending == nil {
+		return
+	}
+	op := f.pending
+	f.pending = nil
+
+	if scope == nil {
+		return
+	}
+	stack := scope.Stack.Data()
+	n := f.pushes
+	if n > len(stack) {
+		n = len(stack)
+	}
+	for _, item := range stack[len(stack)-n:] {
+		op.Ex.Push = append(op.Ex.Push, item.Hex())
+	}
+	if f.memSize > 0 && uint64(scope.Memory.Len()) >= f.memOff+f.memSize {
+		op.Ex.Mem = &vmTraceMem{
+			Data: scope.Memory.GetCopy(int64(f.memOff), int64(f.memSize)),
+			Off:  f.memOff,
+		}
+	}
+}
+
+// stackPushes returns the number of stack items an instruction leaves on the
+// top of the stack which are reported as its output in the trace.
+func stackPushes(op vm.OpCode) int {
+	switch {
+	case op.IsPush() || op == vm.PUSH0:
+		return 1
+	case op >= vm.DUP1 && op <= vm.DUP16:
+		return int(op-vm.DUP1) + 2
+	case op >= vm.SWAP1 && op <= vm.SWAP16:
+		return int(op-vm.SWAP1) + 2
+	case op >= vm.LOG0 && op <= vm.LOG4:
+		return 0
+	}
+	switch op {
+	case vm.STOP, vm.POP, vm.MSTORE, vm.MSTORE8, vm.SSTORE, vm.TSTORE, vm.JUMP, vm.JUMPI,
+		vm.JUMPDEST, vm.CALLDATACOPY, vm.CODECOPY, vm.EXTCODECOPY, vm.RETURNDATACOPY,
+		vm.RETURN, vm.REVERT, vm.SELFDESTRUCT, vm.INVALID:
+		return 0
+	}
+	return 1
+}
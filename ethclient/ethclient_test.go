@@ -289,6 +289,9 @@ func TestetxClient(t *testing.T) {
 		"TransactionSender": {
 			func(t *testing.T) { testTransactionSender(t, client) },
 		},
+		"BlockReceipts": {
+			func(t *testing.T) { testBlockReceipts(t, chain, client) },
+		},
 	}
 
 	t.Parallel()
@@ -710,3 +713,39 @@ func sendTransaction(ec *Client) error {
 	}
 	return ec.SendTransaction(context.Background(), tx)
 }
+
+func testBlockReceipts(t *testing.T, chain []*types.Block, client *rpc.Client) {
+	ec := NewClient(client)
+
+	// Retrieve the receipts of the block containing the test transactions.
+	byNumber, err := ec.BlockReceipts(context.Background(), rpc.BlockNumberOrHashWithNumber(2))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(byNumber) != 2 {
+		t.Fatalf("receipt count mismatch: have %d, want 2", len(byNumber))
+	}
+	for i, tx := range []*types.Transaction{testTx1, testTx2} {
+		if byNumber[i].TxHash != tx.Hash() {
+			t.Fatalf("receipt %d: tx hash mismatch: have %x, want %x", i, byNumber[i].TxHash, tx.Hash())
+		}
+		if byNumber[i].BlockHash != chain[2].Hash() {
+			t.Fatalf("receipt %d: block hash mismatch: have %x, want %x", i, byNumber[i].BlockHash, chain[2].Hash())
+		}
+		if byNumber[i].TransactionIndex != uint(i) {
+			t.Fatalf("receipt %d: index mismatch: have %d", i, byNumber[i].TransactionIndex)
+		}
+	}
+	// Retrieving by hash should yield the same receipts.
+	byHash, err := ec.BlockReceipts(context.Background(), rpc.BlockNumberOrHashWithHash(chain[2].Hash(), false))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(byNumber, byHash) {
+		t.Fatalf("receipts mismatch between number and hash lookups")
+	}
+	// Unknown blocks should report not found.
+	if _, err := ec.BlockReceipts(context.Background(), rpc.BlockNumberOrHashWithNumber(1000000000)); err != ETX.NotFound {
+		t.Fatalf("error mismatch: have %v, want %v", err, ETX.NotFound)
+	}
+}
@@ -374,6 +374,11 @@ func TestDatabaseSuite(t *testing.T, New func() etxdb.KeyValueStore) {
 				t.Fatal("Unexpected deletion")
 			}
 		}
+		// Iterating the snapshot must yield the initial content
+		it := snapshot.NewIterator(nil, nil)
+		if got, want := iterateKeys(it), []string{"k1", "k2", "k3", "k4"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("got: %s; want: %s", got, want)
+		}
 	})
 }
 
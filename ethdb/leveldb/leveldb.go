@@ -559,6 +559,13 @@ func (snap *snapshot) Get(key []byte) ([]byte, error) {
 	return snap.db.Get(key, nil)
 }
 
+// NewIterator creates a binary-alphabetical iterator over a subset of the
+// snapshot content with a particular key prefix, starting at a particular
+// initial key (or after, if it does not exist).
+func (snap *snapshot) NewIterator(prefix []byte, start []byte) etxdb.Iterator {
+	return snap.db.NewIterator(bytesPrefixRange(prefix, start), nil)
+}
+
 // Release releases associated resources. Release should always succeed and can
 // be called multiple times without causing error.
 func (snap *snapshot) Release() {
@@ -147,15 +147,21 @@ func (db *Database) NewIterator(prefix []byte, start []byte) etxdb.Iterator {
 	db.lock.RLock()
 	defer db.lock.RUnlock()
 
+	return newIterator(db.db, prefix, start)
+}
+
+// newIterator creates an iterator over the entries with the given prefix,
+// starting at the given key.
+func newIterator(entries map[string][]byte, prefix []byte, start []byte) *iterator {
 	var (
 		pr     = string(prefix)
 		st     = string(append(prefix, start...))
-		keys   = make([]string, 0, len(db.db))
-		values = make([][]byte, 0, len(db.db))
+		keys   = make([]string, 0, len(entries))
+		values = make([][]byte, 0, len(entries))
 	)
 	// Collect the keys from the memory database corresponding to the given prefix
 	// and start
-	for key := range db.db {
+	for key := range entries {
 		if !strings.HasPrefix(key, pr) {
 			continue
 		}
@@ -166,7 +172,7 @@ func (db *Database) NewIterator(prefix []byte, start []byte) etxdb.Iterator {
 	// Sort the items and retrieve the associated values
 	sort.Strings(keys)
 	for _, key := range keys {
-		values = append(values, db.db[key])
+		values = append(values, entries[key])
 	}
 	return &iterator{
 		index:  -1,
@@ -377,6 +383,17 @@ func (snap *snapshot) Get(key []byte) ([]byte, error) {
 	return nil, errMemorydbNotFound
 }
 
+// NewIterator creates a binary-alphabetical iterator over a subset of the
+// snapshot content with a particular key prefix, starting at a particular
+// initial key (or after, if it does not exist). A released snapshot yields
+// an empty iterator.
+func (snap *snapshot) NewIterator(prefix []byte, start []byte) etxdb.Iterator {
+	snap.lock.RLock()
+	defer snap.lock.RUnlock()
+
+	return newIterator(snap.db, prefix, start)
+}
+
 // Release releases associated resources. Release should always succeed and can
 // be called multiple times without causing error.
 func (snap *snapshot) Release() {
@@ -0,0 +1,147 @@
+// Copyright 2022 The go-ETX Authors
+// This file is part of the go-ETX library.
+//
+// The go-ETX library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ETX library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ETX library. If not, see <http://www.gnu.org/licenses/>.
+
+package remotedb
+
+import (
+	"errors"
+
+	"github.com/ETX/go-ETX/common/hexutil"
+	"github.com/ETX/go-ETX/etxdb"
+)
+
+const (
+	// maxIterateItems is the maximum number of entries returned by a single
+	// iteration request.
+	maxIterateItems = 10000
+
+	// maxResponseBytes is the soft limit of the data returned by a single
+	// batched request. At least one item is always returned.
+	maxResponseBytes = 4 * 1024 * 1024
+)
+
+// errNotFound is returned if a key is missing from the served database.
+var errNotFound = errors.New("not found")
+
+// IterateResult is a batch of consecutive database entries.
+type IterateResult struct {
+	Keys   []hexutil.Bytes `json:"keys"`
+	Values []hexutil.Bytes `json:"values"`
+	Next   hexutil.Bytes   `json:"next,omitempty"` // Start of the next batch, relative to the prefix; nil if exhausted
+}
+
+// API exposes a database read-only over RPC, under the debug namespace. It is
+// compatible with the database methods of a running node, so a Database client
+// can connect to either of them.
+type API struct {
+	db etxdb.Database
+}
+
+// NewAPI creates the read-only RPC API of a database.
+func NewAPI(db etxdb.Database) *API {
+	return &API{db: db}
+}
+
+// DbGet returns the raw value of a key stored in the database.
+func (api *API) DbGet(key hexutil.Bytes) (hexutil.Bytes, error) {
+	blob, err := api.db.Get(key)
+	if err != nil {
+		return nil, errNotFound
+	}
+	return blob, nil
+}
+
+// DbHas reports whetxer a key is stored in the database.
+func (api *API) DbHas(key hexutil.Bytes) (bool, error) {
+	return api.db.Has(key)
+}
+
+// DbIterate returns the entries with the given prefix in ascending key order,
+// starting at the given position. At most limit entries are returned, capped
+// by the server side batch limits.
+func (api *API) DbIterate(prefix hexutil.Bytes, start hexutil.Bytes, limit int) (*IterateResult, error) {
+	if limit <= 0 || limit > maxIterateItems {
+		limit = maxIterateItems
+	}
+	it := api.db.NewIterator(prefix, start)
+	defer it.Release()
+
+	var (
+		result = &IterateResult{Keys: []hexutil.Bytes{}, Values: []hexutil.Bytes{}}
+		size   int
+	)
+	for it.Next() {
+		if len(result.Keys) == limit || (size >= maxResponseBytes && len(result.Keys) > 0) {
+			// More entries left, continue right after the last returned key
+			last := result.Keys[len(result.Keys)-1]
+			result.Next = append(copyBytes(last[len(prefix):]), 0x00)
+			break
+		}
+		key, value := copyBytes(it.Key()), copyBytes(it.Value())
+		result.Keys = append(result.Keys, key)
+		result.Values = append(result.Values, value)
+		size += len(key) + len(value)
+	}
+	return result, it.Error()
+}
+
+// DbAncient retrieves an ancient binary blob from the append-only immutable files.
+func (api *API) DbAncient(kind string, number uint64) (hexutil.Bytes, error) {
+	return api.db.Ancient(kind, number)
+}
+
+// DbAncientRange retrieves multiple consecutive ancient items, at most count
+// of them and, apart from the first one, fitting into maxBytes.
+func (api *API) DbAncientRange(kind string, start, count, maxBytes uint64) ([]hexutil.Bytes, error) {
+	if maxBytes == 0 || maxBytes > maxResponseBytes {
+		maxBytes = maxResponseBytes
+	}
+	blobs, err := api.db.AncientRange(kind, start, count, maxBytes)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]hexutil.Bytes, len(blobs))
+	for i, blob := range blobs {
+		items[i] = blob
+	}
+	return items, nil
+}
+
+// DbAncients returns the ancient item numbers in the ancient store.
+func (api *API) DbAncients() (uint64, error) {
+	return api.db.Ancients()
+}
+
+// DbTail returns the number of the first stored item in the ancient store.
+func (api *API) DbTail() (uint64, error) {
+	return api.db.Tail()
+}
+
+// DbAncientSize returns the size of the given ancient table.
+func (api *API) DbAncientSize(kind string) (uint64, error) {
+	return api.db.AncientSize(kind)
+}
+
+// DbStat returns a statistic property of the database.
+func (api *API) DbStat(property string) (string, error) {
+	return api.db.Stat(property)
+}
+
+// copyBytes copies a byte slice, iterator keys and values being only valid until
+// the iterator is moved.
+func copyBytes(b []byte) hexutil.Bytes {
+	return append(hexutil.Bytes{}, b...)
+}
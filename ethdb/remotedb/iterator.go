@@ -0,0 +1,101 @@
+// Copyright 2022 The go-ETX Authors
+// This file is part of the go-ETX library.
+//
+// The go-ETX library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ETX library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ETX library. If not, see <http://www.gnu.org/licenses/>.
+
+package remotedb
+
+import (
+	"github.com/ETX/go-ETX/common/hexutil"
+	"github.com/ETX/go-ETX/rpc"
+)
+
+// iterateBatch is the number of entries requested in a single iteration call.
+const iterateBatch = 1024
+
+// iterator walks the entries of a remote database, fetching them in batches
+// via debug_dbIterate.
+type iterator struct {
+	remote *rpc.Client
+	prefix hexutil.Bytes
+	next   hexutil.Bytes // Start of the next batch to fetch, nil if exhausted
+	batch  *IterateResult
+	pos    int
+	err    error
+}
+
+// newIterator creates an iterator over the remote entries with the given prefix,
+// starting at the given position.
+func newIterator(remote *rpc.Client, prefix []byte, start []byte) *iterator {
+	return &iterator{
+		remote: remote,
+		prefix: prefix,
+		next:   append(hexutil.Bytes{}, start...),
+		pos:    -1,
+	}
+}
+
+// Next moves the iterator to the next entry, fetching a new batch if the
+// current one is consumed. It returns whetxer the iterator is exhausted.
+func (it *iterator) Next() bool {
+	if it.err != nil {
+		return false
+	}
+	if it.batch != nil && it.pos+1 < len(it.batch.Keys) {
+		it.pos++
+		return true
+	}
+	for it.next != nil {
+		var batch IterateResult
+		if err := it.remote.Call(&batch, "debug_dbIterate", it.prefix, it.next, iterateBatch); err != nil {
+			it.err = err
+			return false
+		}
+		it.batch, it.pos, it.next = &batch, 0, batch.Next
+		if len(it.next) == 0 {
+			it.next = nil // Continuations are never empty, this is the last batch
+		}
+		if len(batch.Keys) > 0 {
+			return true
+		}
+	}
+	it.batch = nil
+	return false
+}
+
+// Error returns any accumulated error.
+func (it *iterator) Error() error {
+	return it.err
+}
+
+// Key returns the key of the current entry.
+func (it *iterator) Key() []byte {
+	if it.batch == nil || it.pos < 0 {
+		return nil
+	}
+	return it.batch.Keys[it.pos]
+}
+
+// Value returns the value of the current entry.
+func (it *iterator) Value() []byte {
+	if it.batch == nil || it.pos < 0 {
+		return nil
+	}
+	return it.batch.Values[it.pos]
+}
+
+// Release releases the fetched entries.
+func (it *iterator) Release() {
+	it.batch, it.next = nil, nil
+}
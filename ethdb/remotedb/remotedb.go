@@ -15,8 +15,8 @@
 // along with the go-ETX library. If not, see <http://www.gnu.org/licenses/>.
 
 // Package remotedb implements the key-value database layer based on a remote getx
-// node. Under the hood, it utilises the `debug_dbGet` metxod to implement a
-// read-only database.
+// node or a standalone database server started by `getx db serve`. Under the hood,
+// it utilises the `debug_db*` metxods to implement a read-only database.
 // There really are no guarantees in this database, since the local getx does not
 // exclusive access, but it can be used for basic diagnostics of a remote node.
 package remotedb
@@ -65,7 +65,16 @@ func (db *Database) Ancient(kind string, number uint64) ([]byte, error) {
 }
 
 func (db *Database) AncientRange(kind string, start, count, maxBytes uint64) ([][]byte, error) {
-	panic("not supported")
+	var resp []hexutil.Bytes
+	err := db.remote.Call(&resp, "debug_dbAncientRange", kind, start, count, maxBytes)
+	if err != nil {
+		return nil, err
+	}
+	items := make([][]byte, len(resp))
+	for i, item := range resp {
+		items[i] = item
+	}
+	return items, nil
 }
 
 func (db *Database) Ancients() (uint64, error) {
@@ -75,11 +84,15 @@ func (db *Database) Ancients() (uint64, error) {
 }
 
 func (db *Database) Tail() (uint64, error) {
-	panic("not supported")
+	var resp uint64
+	err := db.remote.Call(&resp, "debug_dbTail")
+	return resp, err
 }
 
 func (db *Database) AncientSize(kind string) (uint64, error) {
-	panic("not supported")
+	var resp uint64
+	err := db.remote.Call(&resp, "debug_dbAncientSize", kind)
+	return resp, err
 }
 
 func (db *Database) ReadAncients(fn func(op etxdb.AncientReaderOp) error) (err error) {
@@ -123,11 +136,13 @@ func (db *Database) NewBatchWithSize(size int) etxdb.Batch {
 }
 
 func (db *Database) NewIterator(prefix []byte, start []byte) etxdb.Iterator {
-	panic("not supported")
+	return newIterator(db.remote, prefix, start)
 }
 
 func (db *Database) Stat(property string) (string, error) {
-	panic("not supported")
+	var resp string
+	err := db.remote.Call(&resp, "debug_dbStat", property)
+	return resp, err
 }
 
 func (db *Database) AncientDatadir() (string, error) {
@@ -0,0 +1,89 @@
+// Copyright 2022 The go-ETX Authors
+// This file is part of the go-ETX library.
+//
+// The go-ETX library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ETX library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ETX library. If not, see <http://www.gnu.org/licenses/>.
+
+package remotedb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/ETX/go-ETX/core/rawdb"
+	"github.com/ETX/go-ETX/rpc"
+)
+
+// testKey returns the key of the i-th test entry under the given prefix.
+func testKey(prefix string, i int) []byte {
+	key := make([]byte, len(prefix)+4)
+	copy(key, prefix)
+	binary.BigEndian.PutUint32(key[len(prefix):], uint32(i))
+	return key
+}
+
+// newTestRemote serves the given number of entries under two prefixes and
+// returns a client connected to the server.
+func newTestRemote(t *testing.T, entries int) *Database {
+	db := rawdb.NewMemoryDatabase()
+	for i := 0; i < entries; i++ {
+		db.Put(testKey("a", i), bytes.Repeat([]byte{byte(i)}, 8))
+		db.Put(testKey("b", i), []byte{0x01})
+	}
+	server := rpc.NewServer()
+	if err := server.RegisterName("debug", NewAPI(db)); err != nil {
+		t.Fatalf("Failed to register API: %v", err)
+	}
+	t.Cleanup(server.Stop)
+	return New(rpc.DialInProc(server)).(*Database)
+}
+
+func TestRemoteIterator(t *testing.T) {
+	var (
+		entries = 3*iterateBatch + 7
+		db      = newTestRemote(t, entries)
+	)
+	defer db.Close()
+
+	it := db.NewIterator([]byte("a"), nil)
+	defer it.Release()
+
+	var n int
+	for it.Next() {
+		want := testKey("a", n)
+		if !bytes.Equal(it.Key(), want) {
+			t.Fatalf("Key %d mismatch, want %x, got %x", n, want, it.Key())
+		}
+		if !bytes.Equal(it.Value(), bytes.Repeat([]byte{byte(n)}, 8)) {
+			t.Fatalf("Value %d mismatch, got %x", n, it.Value())
+		}
+		n++
+	}
+	if err := it.Error(); err != nil {
+		t.Fatalf("Iteration failed: %v", err)
+	}
+	if n != entries {
+		t.Fatalf("Entry count mismatch, want %d, got %d", entries, n)
+	}
+	// Iterations starting mid-range must skip the preceding entries
+	start := testKey("", entries-2)
+	it = db.NewIterator([]byte("a"), start)
+	defer it.Release()
+
+	for n = 0; it.Next(); n++ {
+	}
+	if n != 2 {
+		t.Fatalf("Entry count from %x mismatch, want 2, got %d", start, n)
+	}
+}
@@ -851,6 +851,22 @@ func (b *Block) Raw(ctx context.Context) (hexutil.Bytes, error) {
 	return rlp.EncodeToBytes(block)
 }
 
+func (b *Block) RawReceipts(ctx context.Context) (*[]hexutil.Bytes, error) {
+	receipts, err := b.resolveReceipts(ctx)
+	if err != nil || receipts == nil {
+		return nil, err
+	}
+	ret := make([]hexutil.Bytes, 0, len(receipts))
+	for _, receipt := range receipts {
+		enc, err := receipt.MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, enc)
+	}
+	return &ret, nil
+}
+
 // BlockNumberArgs encapsulates arguments to accessors that specify a block number.
 type BlockNumberArgs struct {
 	// TODO: Ideally we could use input unions to allow the query to specify the
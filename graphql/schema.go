@@ -246,6 +246,10 @@ const schema string = `
         rawHeader: Bytes!
         # Raw is the RLP encoding of the block.
         raw: Bytes!
+        # RawReceipts is the list of canonical encodings of the receipts of all
+        # transactions in this block, in transaction order. If receipts are
+        # unavailable for this block, this field will be null.
+        rawReceipts: [Bytes!]
     }
 
     # CallData represents the data associated with a local contract call.
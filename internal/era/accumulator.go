@@ -0,0 +1,71 @@
+// Copyright 2022 The go-ETX Authors
+// This file is part of the go-ETX library.
+//
+// The go-ETX library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ETX library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ETX library. If not, see <http://www.gnu.org/licenses/>.
+
+package era
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ETX/go-ETX/common"
+)
+
+// accumulatorDepth is the depth of the accumulator tree, fitting MaxSize records.
+const accumulatorDepth = 13
+
+// ComputeAccumulator calculates the accumulator root of the given block hashes
+// and total difficulties. The root is the SSZ hash tree root of a
+// List[HeaderRecord, MaxSize], HeaderRecord being a container of the block
+// hash and its total difficulty as a uint256.
+func ComputeAccumulator(hashes []common.Hash, tds []*big.Int) (common.Hash, error) {
+	if len(hashes) != len(tds) {
+		return common.Hash{}, fmt.Errorf("record count mismatch: %d hashes, %d difficulties", len(hashes), len(tds))
+	}
+	if len(hashes) > MaxSize {
+		return common.Hash{}, errors.New("too many records")
+	}
+	// Hash the records into the leaves of the tree
+	level := make([][32]byte, len(hashes))
+	for i := range hashes {
+		if tds[i].Sign() < 0 || tds[i].BitLen() > 256 {
+			return common.Hash{}, fmt.Errorf("invalid total difficulty %v", tds[i])
+		}
+		level[i] = sha256.Sum256(append(hashes[i].Bytes(), encodeUint256(tds[i])...))
+	}
+	// Merkleize the leaves, padding every level with the zero subtree roots
+	var zero [32]byte
+	for depth := 0; depth < accumulatorDepth; depth++ {
+		if len(level)%2 == 1 {
+			level = append(level, zero)
+		}
+		next := make([][32]byte, len(level)/2)
+		for i := range next {
+			next[i] = sha256.Sum256(append(level[2*i][:], level[2*i+1][:]...))
+		}
+		level, zero = next, sha256.Sum256(append(zero[:], zero[:]...))
+	}
+	root := zero
+	if len(level) > 0 {
+		root = level[0]
+	}
+	// Mix in the length of the list
+	var length [32]byte
+	binary.LittleEndian.PutUint64(length[:], uint64(len(hashes)))
+	return sha256.Sum256(append(root[:], length[:]...)), nil
+}
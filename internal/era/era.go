@@ -0,0 +1,393 @@
+// Copyright 2022 The go-ETX Authors
+// This file is part of the go-ETX library.
+//
+// The go-ETX library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ETX library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ETX library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package era implements an era1-style archive of the chain history.
+//
+// An archive is an e2store file, a sequence of type-length-value entries, each
+// one with an 8 byte header: a little endian uint16 type, a little endian
+// uint32 length and two reserved zero bytes. The file layout is:
+//
+//	Version | block-tuple* | Accumulator | BlockIndex
+//	block-tuple := CompressedHeader | CompressedBody | CompressedReceipts | TotalDifficulty
+//
+// Headers, bodies and receipts are stored as snappy compressed RLP, receipts
+// in their storage encoding. Total difficulties are little endian uint256s.
+// The accumulator is the root of the header records of the archive, see
+// ComputeAccumulator. The block index lists the starting block number, the
+// file offset of every block tuple and the number of blocks, allowing random
+// access to the archived blocks.
+package era
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+
+	"github.com/ETX/go-ETX/common"
+	"github.com/golang/snappy"
+)
+
+// Entry types of the archive.
+const (
+	TypeVersion            uint16 = 0x3265
+	TypeCompressedHeader   uint16 = 0x03
+	TypeCompressedBody     uint16 = 0x04
+	TypeCompressedReceipts uint16 = 0x05
+	TypeTotalDifficulty    uint16 = 0x06
+	TypeAccumulator        uint16 = 0x07
+	TypeBlockIndex         uint16 = 0x3266
+)
+
+// MaxSize is the maximum number of blocks contained in a single archive.
+const MaxSize = 8192
+
+// headerSize is the size of an entry header in bytes.
+const headerSize = 8
+
+// Maximum lengths of the compressed header, body and receipts entries. The
+// lengths are read from untrusted files, so they are checked against these
+// limits before allocating.
+const (
+	maxHeaderLength   = 1 << 20
+	maxBodyLength     = 64 << 20
+	maxReceiptsLength = 64 << 20
+)
+
+var (
+	// errEmpty is returned if an archive without blocks is finalized.
+	errEmpty = errors.New("empty archive")
+
+	// errFinalized is returned if blocks are added to a finalized archive.
+	errFinalized = errors.New("archive already finalized")
+
+	// errNotContiguous is returned if blocks are not added in sequence.
+	errNotContiguous = errors.New("blocks not contiguous")
+
+	// errFull is returned if more than MaxSize blocks are added to an archive.
+	errFull = errors.New("archive full")
+)
+
+// Filename returns the canonical file name of the archive with the given epoch
+// and accumulator root for the network.
+func Filename(network string, epoch int, root common.Hash) string {
+	return fmt.Sprintf("%s-%05d-%x.era1", network, epoch, root[:4])
+}
+
+// RawBlock is a block retrieved from an archive, in its stored encoding.
+type RawBlock struct {
+	Header          []byte   // RLP encoded header
+	Body            []byte   // RLP encoded body
+	Receipts        []byte   // RLP encoded receipts in storage format
+	TotalDifficulty *big.Int // Total difficulty including the block
+}
+
+// Builder writes an archive of consecutive blocks into a stream.
+type Builder struct {
+	w       io.Writer
+	written uint64        // Number of bytes written so far
+	start   uint64        // Number of the first block
+	offsets []uint64      // File offsets of the block tuples
+	hashes  []common.Hash // Block hashes for the accumulator
+	tds     []*big.Int    // Total difficulties for the accumulator
+	done    bool
+}
+
+// NewBuilder creates an archive builder on top of the given writer.
+func NewBuilder(w io.Writer) *Builder {
+	return &Builder{w: w}
+}
+
+// Add appends a block to the archive. The header, body and receipts are the
+// RLP encodings as stored in the database.
+func (b *Builder) Add(number uint64, hash common.Hash, header, body, receipts []byte, td *big.Int) error {
+	if b.done {
+		return errFinalized
+	}
+	if len(b.offsets) == MaxSize {
+		return errFull
+	}
+	if len(b.offsets) == 0 {
+		if err := b.write(TypeVersion, nil); err != nil {
+			return err
+		}
+		b.start = number
+	} else if number != b.start+uint64(len(b.offsets)) {
+		return fmt.Errorf("%w: have #%d, want #%d", errNotContiguous, number, b.start+uint64(len(b.offsets)))
+	}
+	if td.Sign() < 0 || td.BitLen() > 256 {
+		return fmt.Errorf("invalid total difficulty %v", td)
+	}
+	b.offsets = append(b.offsets, b.written)
+	b.hashes = append(b.hashes, hash)
+	b.tds = append(b.tds, new(big.Int).Set(td))
+
+	if err := b.write(TypeCompressedHeader, snappy.Encode(nil, header)); err != nil {
+		return err
+	}
+	if err := b.write(TypeCompressedBody, snappy.Encode(nil, body)); err != nil {
+		return err
+	}
+	if err := b.write(TypeCompressedReceipts, snappy.Encode(nil, receipts)); err != nil {
+		return err
+	}
+	return b.write(TypeTotalDifficulty, encodeUint256(td))
+}
+
+// Finalize writes the accumulator and the block index, terminating the archive.
+// The accumulator root is returned.
+func (b *Builder) Finalize() (common.Hash, error) {
+	if b.done {
+		return common.Hash{}, errFinalized
+	}
+	if len(b.offsets) == 0 {
+		return common.Hash{}, errEmpty
+	}
+	root, err := ComputeAccumulator(b.hashes, b.tds)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	if err := b.write(TypeAccumulator, root[:]); err != nil {
+		return common.Hash{}, err
+	}
+	index := make([]byte, 16+8*len(b.offsets))
+	binary.LittleEndian.PutUint64(index, b.start)
+	for i, offset := range b.offsets {
+		binary.LittleEndian.PutUint64(index[8+8*i:], offset)
+	}
+	binary.LittleEndian.PutUint64(index[len(index)-8:], uint64(len(b.offsets)))
+
+	b.done = true
+	return root, b.write(TypeBlockIndex, index)
+}
+
+// write appends a single entry to the stream.
+func (b *Builder) write(typ uint16, data []byte) error {
+	var header [headerSize]byte
+	binary.LittleEndian.PutUint16(header[0:], typ)
+	binary.LittleEndian.PutUint32(header[2:], uint32(len(data)))
+	if _, err := b.w.Write(header[:]); err != nil {
+		return err
+	}
+	if _, err := b.w.Write(data); err != nil {
+		return err
+	}
+	b.written += uint64(headerSize + len(data))
+	return nil
+}
+
+// Era is an opened archive, allowing random access to its blocks.
+type Era struct {
+	r       io.ReaderAt
+	closer  io.Closer
+	size    int64 // Size of the archive in bytes
+	start   uint64
+	offsets []uint64
+	index   int64 // File offset of the block index entry
+}
+
+// Open opens the archive file at the given path.
+func Open(path string) (*Era, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	e, err := New(f, info.Size())
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	e.closer = f
+	return e, nil
+}
+
+// New loads an archive of the given size from the reader.
+func New(r io.ReaderAt, size int64) (*Era, error) {
+	if size < headerSize+16 {
+		return nil, errors.New("archive too short")
+	}
+	var buf [8]byte
+	if _, err := r.ReadAt(buf[:], size-8); err != nil {
+		return nil, err
+	}
+	count := binary.LittleEndian.Uint64(buf[:])
+	if count == 0 || count > MaxSize || count > uint64(size)/(4*headerSize) {
+		return nil, fmt.Errorf("invalid block count %d", count)
+	}
+	length := 16 + 8*int64(count)
+	typ, data, err := readEntry(r, size, size-length-headerSize)
+	if err != nil {
+		return nil, err
+	}
+	if typ != TypeBlockIndex || int64(len(data)) != length {
+		return nil, errors.New("invalid block index")
+	}
+	e := &Era{
+		r:       r,
+		size:    size,
+		start:   binary.LittleEndian.Uint64(data),
+		offsets: make([]uint64, count),
+		index:   size - length - headerSize,
+	}
+	for i := range e.offsets {
+		e.offsets[i] = binary.LittleEndian.Uint64(data[8+8*i:])
+	}
+	return e, nil
+}
+
+// Close releases the underlying file, if any.
+func (e *Era) Close() error {
+	if e.closer == nil {
+		return nil
+	}
+	return e.closer.Close()
+}
+
+// Start returns the number of the first block in the archive.
+func (e *Era) Start() uint64 {
+	return e.start
+}
+
+// Count returns the number of blocks in the archive.
+func (e *Era) Count() uint64 {
+	return uint64(len(e.offsets))
+}
+
+// Accumulator returns the accumulator root stored in the archive.
+func (e *Era) Accumulator() (common.Hash, error) {
+	typ, data, err := readEntry(e.r, e.size, e.index-headerSize-common.HashLength)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	if typ != TypeAccumulator || len(data) != common.HashLength {
+		return common.Hash{}, errors.New("invalid accumulator")
+	}
+	return common.BytesToHash(data), nil
+}
+
+// GetRawBlock retrieves the decompressed header, body and receipts along with
+// the total difficulty of the block with the given number.
+func (e *Era) GetRawBlock(number uint64) (*RawBlock, error) {
+	if number < e.start || number-e.start >= e.Count() {
+		return nil, fmt.Errorf("block #%d out of range [%d, %d)", number, e.start, e.start+e.Count())
+	}
+	var (
+		offset = int64(e.offsets[number-e.start])
+		blobs  [4][]byte
+	)
+	for i, want := range []uint16{TypeCompressedHeader, TypeCompressedBody, TypeCompressedReceipts, TypeTotalDifficulty} {
+		typ, data, err := readEntry(e.r, e.size, offset)
+		if err != nil {
+			return nil, err
+		}
+		if typ != want {
+			return nil, fmt.Errorf("unexpected entry type %#x for block #%d, want %#x", typ, number, want)
+		}
+		offset += int64(headerSize + len(data))
+
+		if typ == TypeTotalDifficulty {
+			blobs[i] = data
+		} else if blobs[i], err = snappy.Decode(nil, data); err != nil {
+			return nil, err
+		}
+	}
+	if len(blobs[3]) != 32 {
+		return nil, fmt.Errorf("invalid total difficulty length %d for block #%d", len(blobs[3]), number)
+	}
+	return &RawBlock{
+		Header:          blobs[0],
+		Body:            blobs[1],
+		Receipts:        blobs[2],
+		TotalDifficulty: decodeUint256(blobs[3]),
+	}, nil
+}
+
+// maxEntryLength returns the maximum data length of an entry of the given type.
+func maxEntryLength(typ uint16) (uint32, error) {
+	switch typ {
+	case TypeVersion:
+		return 0, nil
+	case TypeCompressedHeader:
+		return maxHeaderLength, nil
+	case TypeCompressedBody:
+		return maxBodyLength, nil
+	case TypeCompressedReceipts:
+		return maxReceiptsLength, nil
+	case TypeTotalDifficulty, TypeAccumulator:
+		return 32, nil
+	case TypeBlockIndex:
+		return 16 + 8*MaxSize, nil
+	default:
+		return 0, fmt.Errorf("unknown entry type %#x", typ)
+	}
+}
+
+// readEntry reads the entry at the given offset of an archive of the given size.
+func readEntry(r io.ReaderAt, size int64, offset int64) (uint16, []byte, error) {
+	if offset < 0 || offset > size-headerSize {
+		return 0, nil, errors.New("invalid entry offset")
+	}
+	var header [headerSize]byte
+	if _, err := r.ReadAt(header[:], offset); err != nil {
+		return 0, nil, err
+	}
+	if header[6] != 0 || header[7] != 0 {
+		return 0, nil, errors.New("reserved entry bytes not zero")
+	}
+	typ := binary.LittleEndian.Uint16(header[0:])
+	length := binary.LittleEndian.Uint32(header[2:])
+
+	limit, err := maxEntryLength(typ)
+	if err != nil {
+		return 0, nil, err
+	}
+	if length > limit {
+		return 0, nil, fmt.Errorf("entry length %d exceeds limit %d for type %#x", length, limit, typ)
+	}
+	if int64(length) > size-offset-headerSize {
+		return 0, nil, fmt.Errorf("entry length %d exceeds archive size", length)
+	}
+	data := make([]byte, length)
+	if _, err := r.ReadAt(data, offset+headerSize); err != nil {
+		return 0, nil, err
+	}
+	return typ, data, nil
+}
+
+// encodeUint256 encodes the number as a 32 byte little endian integer.
+func encodeUint256(n *big.Int) []byte {
+	blob := n.FillBytes(make([]byte, 32))
+	for i, j := 0, len(blob)-1; i < j; i, j = i+1, j-1 {
+		blob[i], blob[j] = blob[j], blob[i]
+	}
+	return blob
+}
+
+// decodeUint256 decodes a 32 byte little endian integer.
+func decodeUint256(blob []byte) *big.Int {
+	be := make([]byte, len(blob))
+	for i := range blob {
+		be[len(blob)-1-i] = blob[i]
+	}
+	return new(big.Int).SetBytes(be)
+}
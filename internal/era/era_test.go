@@ -0,0 +1,138 @@
+// Copyright 2022 The go-ETX Authors
+// This file is part of the go-ETX library.
+//
+// The go-ETX library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ETX library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ETX library. If not, see <http://www.gnu.org/licenses/>.
+
+package era
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+	"testing"
+
+	"github.com/ETX/go-ETX/common"
+)
+
+func TestArchiveRoundtrip(t *testing.T) {
+	var (
+		buf     = new(bytes.Buffer)
+		builder = NewBuilder(buf)
+		hashes  []common.Hash
+		tds     []*big.Int
+	)
+	for i := uint64(100); i < 110; i++ {
+		hash := common.BigToHash(new(big.Int).SetUint64(i))
+		td := new(big.Int).Lsh(big.NewInt(int64(i)), 128)
+		header := []byte(fmt.Sprintf("header-%d", i))
+		body := bytes.Repeat([]byte{byte(i)}, int(i))
+		receipts := []byte(fmt.Sprintf("receipts-%d", i))
+		if err := builder.Add(i, hash, header, body, receipts, td); err != nil {
+			t.Fatalf("Failed to add block #%d: %v", i, err)
+		}
+		hashes, tds = append(hashes, hash), append(tds, td)
+	}
+	if err := builder.Add(200, common.Hash{}, nil, nil, nil, common.Big0); !errors.Is(err, errNotContiguous) {
+		t.Fatalf("Non-contiguous block error mismatch, want %v, got %v", errNotContiguous, err)
+	}
+	root, err := builder.Finalize()
+	if err != nil {
+		t.Fatalf("Failed to finalize archive: %v", err)
+	}
+	if want, _ := ComputeAccumulator(hashes, tds); root != want {
+		t.Fatalf("Accumulator mismatch, want %x, got %x", want, root)
+	}
+	e, err := New(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("Failed to open archive: %v", err)
+	}
+	if e.Start() != 100 || e.Count() != 10 {
+		t.Fatalf("Archive range mismatch, want [100, 110), got [%d, %d)", e.Start(), e.Start()+e.Count())
+	}
+	if stored, err := e.Accumulator(); err != nil || stored != root {
+		t.Fatalf("Stored accumulator mismatch, want %x, got %x (%v)", root, stored, err)
+	}
+	for i := uint64(100); i < 110; i++ {
+		block, err := e.GetRawBlock(i)
+		if err != nil {
+			t.Fatalf("Failed to read block #%d: %v", i, err)
+		}
+		if want := []byte(fmt.Sprintf("header-%d", i)); !bytes.Equal(block.Header, want) {
+			t.Fatalf("Header #%d mismatch, want %q, got %q", i, want, block.Header)
+		}
+		if want := bytes.Repeat([]byte{byte(i)}, int(i)); !bytes.Equal(block.Body, want) {
+			t.Fatalf("Body #%d mismatch, want %x, got %x", i, want, block.Body)
+		}
+		if want := []byte(fmt.Sprintf("receipts-%d", i)); !bytes.Equal(block.Receipts, want) {
+			t.Fatalf("Receipts #%d mismatch, want %q, got %q", i, want, block.Receipts)
+		}
+		if block.TotalDifficulty.Cmp(tds[i-100]) != 0 {
+			t.Fatalf("Total difficulty #%d mismatch, want %v, got %v", i, tds[i-100], block.TotalDifficulty)
+		}
+	}
+	if _, err := e.GetRawBlock(110); err == nil {
+		t.Fatal("Out of range block retrieved")
+	}
+}
+
+func TestArchiveInvalidEntryLength(t *testing.T) {
+	var (
+		buf     = new(bytes.Buffer)
+		builder = NewBuilder(buf)
+	)
+	if err := builder.Add(0, common.Hash{}, []byte("header"), []byte("body"), []byte("receipts"), common.Big1); err != nil {
+		t.Fatalf("Failed to add block: %v", err)
+	}
+	if _, err := builder.Finalize(); err != nil {
+		t.Fatalf("Failed to finalize archive: %v", err)
+	}
+	// The header entry follows the version entry, corrupt its length field
+	for _, length := range []uint32{maxHeaderLength, maxHeaderLength + 1, 0xffffffff} {
+		blob := common.CopyBytes(buf.Bytes())
+		binary.LittleEndian.PutUint32(blob[headerSize+2:], length)
+
+		e, err := New(bytes.NewReader(blob), int64(len(blob)))
+		if err != nil {
+			t.Fatalf("Failed to open archive: %v", err)
+		}
+		if _, err := e.GetRawBlock(0); err == nil {
+			t.Fatalf("Entry with length %d accepted", length)
+		}
+	}
+}
+
+func TestAccumulatorRecords(t *testing.T) {
+	hashes := []common.Hash{{0x01}, {0x02}}
+	tds := []*big.Int{big.NewInt(1), big.NewInt(2)}
+
+	root, err := ComputeAccumulator(hashes, tds)
+	if err != nil {
+		t.Fatalf("Failed to compute accumulator: %v", err)
+	}
+	// Every record and the number of records must be committed to
+	if other, _ := ComputeAccumulator(hashes[:1], tds[:1]); other == root {
+		t.Fatal("Accumulator independent of record count")
+	}
+	if other, _ := ComputeAccumulator(hashes, []*big.Int{big.NewInt(1), big.NewInt(3)}); other == root {
+		t.Fatal("Accumulator independent of total difficulty")
+	}
+	if other, _ := ComputeAccumulator([]common.Hash{{0x02}, {0x01}}, tds); other == root {
+		t.Fatal("Accumulator independent of record order")
+	}
+	if _, err := ComputeAccumulator(hashes, tds[:1]); err == nil {
+		t.Fatal("Mismatching record lists accepted")
+	}
+}
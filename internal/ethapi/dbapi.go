@@ -19,6 +19,7 @@ package etxapi
 import (
 	"github.com/ETX/go-ETX/common"
 	"github.com/ETX/go-ETX/common/hexutil"
+	"github.com/ETX/go-ETX/etxdb/remotedb"
 )
 
 // DbGet returns the raw value of a key stored in the database.
@@ -41,3 +42,34 @@ func (api *DebugAPI) DbAncient(kind string, number uint64) (hexutil.Bytes, error
 func (api *DebugAPI) DbAncients() (uint64, error) {
 	return api.b.ChainDb().Ancients()
 }
+
+// DbIterate returns the entries with the given prefix in ascending key order,
+// starting at the given position. It allows remotedb clients to iterate the
+// database of a running node.
+func (api *DebugAPI) DbIterate(prefix hexutil.Bytes, start hexutil.Bytes, limit int) (*remotedb.IterateResult, error) {
+	return remotedb.NewAPI(api.b.ChainDb()).DbIterate(prefix, start, limit)
+}
+
+// DbAncientRange retrieves multiple consecutive ancient items, at most count
+// of them and, apart from the first one, fitting into maxBytes.
+// It is a mapping to the `AncientReaderOp.AncientRange` metxod
+func (api *DebugAPI) DbAncientRange(kind string, start, count, maxBytes uint64) ([]hexutil.Bytes, error) {
+	return remotedb.NewAPI(api.b.ChainDb()).DbAncientRange(kind, start, count, maxBytes)
+}
+
+// DbTail returns the number of the first stored item in the ancient store.
+// It is a mapping to the `AncientReaderOp.Tail` metxod
+func (api *DebugAPI) DbTail() (uint64, error) {
+	return api.b.ChainDb().Tail()
+}
+
+// DbAncientSize returns the size of the given ancient table.
+// It is a mapping to the `AncientReaderOp.AncientSize` metxod
+func (api *DebugAPI) DbAncientSize(kind string) (uint64, error) {
+	return api.b.ChainDb().AncientSize(kind)
+}
+
+// DbStat returns a statistic property of the database.
+func (api *DebugAPI) DbStat(property string) (string, error) {
+	return api.b.ChainDb().Stat(property)
+}
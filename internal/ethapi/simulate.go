@@ -0,0 +1,330 @@
+// Copyright 2022 The go-ETX Authors
+// This file is part of the go-ETX library.
+//
+// The go-ETX library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ETX library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ETX library. If not, see <http://www.gnu.org/licenses/>.
+
+package etxapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"time"
+
+	"github.com/ETX/go-ETX/common"
+	"github.com/ETX/go-ETX/common/hexutil"
+	"github.com/ETX/go-ETX/consensus/misc"
+	"github.com/ETX/go-ETX/core"
+	"github.com/ETX/go-ETX/core/state"
+	"github.com/ETX/go-ETX/core/types"
+	"github.com/ETX/go-ETX/core/vm"
+	"github.com/ETX/go-ETX/crypto"
+	"github.com/ETX/go-ETX/log"
+	"github.com/ETX/go-ETX/rpc"
+)
+
+const (
+	// maxSimulateBlocks is the maximum number of blocks that can be simulated
+	// in a single request.
+	maxSimulateBlocks = 256
+
+	// simTimestampIncrement is the default increment between the timestamps of
+	// consecutive simulated blocks, unless overridden.
+	simTimestampIncrement = 12
+)
+
+// SimBlock is a batch of calls to be simulated sequentially within a single
+// block, on top of the state produced by all previously simulated blocks.
+type SimBlock struct {
+	BlockOverrides *BlockOverrides   `json:"blockOverrides"`
+	StateOverrides *StateOverride    `json:"stateOverrides"`
+	Calls          []TransactionArgs `json:"calls"`
+}
+
+// SimOpts are the inputs to etx_simulate.
+type SimOpts struct {
+	BlockStateCalls []SimBlock `json:"blockStateCalls"`
+}
+
+// errSimGasCapExhausted is returned if the calls of a simulation consume more
+// gas in total than the global gas cap allows.
+var errSimGasCapExhausted = errors.New("gas cap exhausted")
+
+// simCallError is the error of a single simulated call which did not abort
+// the simulation, e.g. an EVM revert.
+type simCallError struct {
+	Message string `json:"message"`
+	Code    int    `json:"code"`
+	Data    string `json:"data,omitempty"`
+}
+
+// simCallResult is the outcome of a single simulated call.
+type simCallResult struct {
+	ReturnValue hexutil.Bytes  `json:"returnData"`
+	Logs        []*types.Log   `json:"logs"`
+	GasUsed     hexutil.Uint64 `json:"gasUsed"`
+	Status      hexutil.Uint64 `json:"status"`
+	Error       *simCallError  `json:"error,omitempty"`
+}
+
+// simBlockResult is the outcome of a simulated block, containing the header
+// fields the calls were executed with and the per-call results.
+type simBlockResult struct {
+	Number        hexutil.Uint64  `json:"number"`
+	Hash          common.Hash     `json:"hash"`
+	ParentHash    common.Hash     `json:"parentHash"`
+	Timestamp     hexutil.Uint64  `json:"timestamp"`
+	GasLimit      hexutil.Uint64  `json:"gasLimit"`
+	GasUsed       hexutil.Uint64  `json:"gasUsed"`
+	FeeRecipient  common.Address  `json:"miner"`
+	BaseFeePerGas *hexutil.Big    `json:"baseFeePerGas,omitempty"`
+	StateRoot     common.Hash     `json:"stateRoot"`
+	Calls         []simCallResult `json:"calls"`
+}
+
+// Simulate executes a series of blocks of calls on top of the given base
+// block. Each call sees the state changes made by all the calls preceding it,
+// both within its own block and in the previously simulated blocks. Block
+// and state overrides are applied per block before its calls are executed.
+//
+// Note, this function doesn't make any changes in the state/blockchain and is
+// useful to simulate bundles of transactions without mining them.
+func (s *BlockChainAPI) Simulate(ctx context.Context, opts SimOpts, blockNrOrHash *rpc.BlockNumberOrHash) ([]*simBlockResult, error) {
+	if len(opts.BlockStateCalls) == 0 {
+		return nil, errors.New("empty input")
+	}
+	if len(opts.BlockStateCalls) > maxSimulateBlocks {
+		return nil, fmt.Errorf("too many blocks: %d > %d", len(opts.BlockStateCalls), maxSimulateBlocks)
+	}
+	bNrOrHash := rpc.BlockNumberOrHashWithNumber(rpc.LatestBlockNumber)
+	if blockNrOrHash != nil {
+		bNrOrHash = *blockNrOrHash
+	}
+	return DoSimulate(ctx, s.b, opts, bNrOrHash, s.b.RPCEVMTimeout(), s.b.RPCGasCap())
+}
+
+// DoSimulate executes the simulated blocks of opts on top of the state of the
+// given base block. The timeout and the gas cap apply to the whole simulation,
+// the gas used by all the calls of all the blocks must not exceed the cap.
+func DoSimulate(ctx context.Context, b Backend, opts SimOpts, blockNrOrHash rpc.BlockNumberOrHash, timeout time.Duration, globalGasCap uint64) ([]*simBlockResult, error) {
+	defer func(start time.Time) { log.Debug("Executing EVM simulation finished", "runtime", time.Since(start)) }(time.Now())
+
+	state, parent, err := b.StateAndHeaderByNumberOrHash(ctx, blockNrOrHash)
+	if state == nil || err != nil {
+		return nil, err
+	}
+	// Setup context so it may be cancelled the simulation has completed
+	// or, in case of unmetered gas, setup a context with a timeout.
+	var cancel context.CancelFunc
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+	} else {
+		ctx, cancel = context.WithCancel(ctx)
+	}
+	// Make sure the context is cancelled when the simulation has completed
+	// this makes sure resources are cleaned up.
+	defer cancel()
+
+	gasCap := globalGasCap
+	if gasCap == 0 {
+		gasCap = uint64(math.MaxUint64 / 2)
+	}
+	results := make([]*simBlockResult, 0, len(opts.BlockStateCalls))
+	for i, block := range opts.BlockStateCalls {
+		header, err := makeSimHeader(b, parent, block.BlockOverrides)
+		if err != nil {
+			return nil, fmt.Errorf("block %d: %w", i, err)
+		}
+		if err := block.StateOverrides.Apply(state); err != nil {
+			return nil, fmt.Errorf("block %d: %w", i, err)
+		}
+		result, err := simulateBlock(ctx, b, state, header, block.Calls, timeout, &gasCap)
+		if err != nil {
+			return nil, fmt.Errorf("block %d: %w", i, err)
+		}
+		results = append(results, result)
+		parent = header
+	}
+	return results, nil
+}
+
+// makeSimHeader assembles the header of a simulated block on top of parent,
+// applying the user supplied overrides over the derived defaults.
+func makeSimHeader(b Backend, parent *types.Header, overrides *BlockOverrides) (*types.Header, error) {
+	header := &types.Header{
+		ParentHash: parent.Hash(),
+		Coinbase:   parent.Coinbase,
+		Difficulty: new(big.Int).Set(parent.Difficulty),
+		Number:     new(big.Int).Add(parent.Number, common.Big1),
+		GasLimit:   parent.GasLimit,
+		Time:       parent.Time + simTimestampIncrement,
+		MixDigest:  parent.MixDigest,
+	}
+	if overrides == nil {
+		overrides = new(BlockOverrides)
+	}
+	if overrides.Number != nil {
+		if overrides.Number.ToInt().Cmp(parent.Number) <= 0 {
+			return nil, fmt.Errorf("block number not increasing: %v <= %v", overrides.Number.ToInt(), parent.Number)
+		}
+		header.Number = new(big.Int).Set(overrides.Number.ToInt())
+	}
+	if overrides.Time != nil {
+		if overrides.Time.ToInt().Uint64() <= parent.Time {
+			return nil, fmt.Errorf("block timestamp not increasing: %v <= %d", overrides.Time.ToInt(), parent.Time)
+		}
+		header.Time = overrides.Time.ToInt().Uint64()
+	}
+	if overrides.Difficulty != nil {
+		header.Difficulty = new(big.Int).Set(overrides.Difficulty.ToInt())
+	}
+	if overrides.GasLimit != nil {
+		header.GasLimit = uint64(*overrides.GasLimit)
+	}
+	if overrides.Coinbase != nil {
+		header.Coinbase = *overrides.Coinbase
+	}
+	if overrides.Random != nil {
+		header.MixDigest = *overrides.Random
+	}
+	switch {
+	case overrides.BaseFee != nil:
+		header.BaseFee = new(big.Int).Set(overrides.BaseFee.ToInt())
+	case b.ChainConfig().IsLondon(header.Number):
+		header.BaseFee = misc.CalcBaseFee(b.ChainConfig(), parent)
+	}
+	return header, nil
+}
+
+// simulateBlock executes the given calls sequentially in the context of the
+// simulated header, finalising the header's gas used and state root once all
+// calls have been executed. The gas used by the calls is deducted from the
+// remaining gas cap of the simulation.
+func simulateBlock(ctx context.Context, b Backend, state *state.StateDB, header *types.Header, calls []TransactionArgs, timeout time.Duration, gasCap *uint64) (*simBlockResult, error) {
+	var (
+		config  = b.ChainConfig()
+		gp      = new(core.GasPool).AddGas(header.GasLimit)
+		gasUsed uint64
+		hashes  = make([]common.Hash, len(calls))
+		results = make([]simCallResult, len(calls))
+	)
+	for i := range calls {
+		if err := ctx.Err(); err != nil {
+			return nil, fmt.Errorf("execution aborted (timeout = %v)", timeout)
+		}
+		if *gasCap == 0 {
+			return nil, fmt.Errorf("call %d: %w", i, errSimGasCapExhausted)
+		}
+		args := calls[i]
+		// Calls without an explicit gas limit may use whatever is left in the block.
+		if args.Gas == nil {
+			remaining := hexutil.Uint64(gp.Gas())
+			args.Gas = &remaining
+		}
+		msg, err := args.ToMessage(*gasCap, header.BaseFee)
+		if err != nil {
+			return nil, fmt.Errorf("call %d: %w", i, err)
+		}
+		// Simulated calls are not signed transactions, derive a unique pseudo
+		// hash to attribute the emitted logs to.
+		hashes[i] = crypto.Keccak256Hash(common.BigToHash(header.Number).Bytes(), common.BigToHash(big.NewInt(int64(i))).Bytes())
+		state.SetTxContext(hashes[i], i)
+
+		evm, vmError, err := b.GetEVM(ctx, msg, state, header, &vm.Config{NoBaseFee: true})
+		if err != nil {
+			return nil, err
+		}
+		// Wait for the context to be done and cancel the evm. Even if the
+		// EVM has finished, cancelling may be done (repeatedly)
+		done := make(chan struct{})
+		go func() {
+			select {
+			case <-ctx.Done():
+				evm.Cancel()
+			case <-done:
+			}
+		}()
+		result, err := core.ApplyMessage(evm, msg, gp)
+		close(done)
+		if err := vmError(); err != nil {
+			return nil, err
+		}
+		// If the timer caused an abort, return an appropriate error message
+		if evm.Cancelled() {
+			return nil, fmt.Errorf("execution aborted (timeout = %v)", timeout)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("call %d: %w (supplied gas %d)", i, err, msg.Gas())
+		}
+		// Update the state with pending changes.
+		if config.IsByzantium(header.Number) {
+			state.Finalise(true)
+		} else {
+			state.IntermediateRoot(config.IsEIP158(header.Number))
+		}
+		gasUsed += result.UsedGas
+		*gasCap -= result.UsedGas
+
+		results[i] = simCallResult{
+			ReturnValue: result.Return(),
+			GasUsed:     hexutil.Uint64(result.UsedGas),
+			Status:      hexutil.Uint64(types.ReceiptStatusSuccessful),
+		}
+		if result.Failed() {
+			results[i].Status = hexutil.Uint64(types.ReceiptStatusFailed)
+			if len(result.Revert()) > 0 {
+				revertErr := newRevertError(result)
+				results[i].ReturnValue = result.Revert()
+				results[i].Error = &simCallError{Message: revertErr.Error(), Code: revertErr.ErrorCode(), Data: revertErr.reason}
+			} else {
+				results[i].Error = &simCallError{Message: result.Err.Error(), Code: -32015}
+			}
+		}
+	}
+	// Seal the header and attribute the logs to the final block. The state
+	// numbers the logs across all simulated blocks, renumber them per block.
+	header.GasUsed = gasUsed
+	header.Root = state.IntermediateRoot(config.IsEIP158(header.Number))
+	hash := header.Hash()
+
+	var logIndex uint
+	for i := range results {
+		results[i].Logs = state.GetLogs(hashes[i], hash)
+		if results[i].Logs == nil {
+			results[i].Logs = []*types.Log{}
+		}
+		for _, l := range results[i].Logs {
+			l.BlockNumber = header.Number.Uint64()
+			l.Index = logIndex
+			logIndex++
+		}
+	}
+	res := &simBlockResult{
+		Number:       hexutil.Uint64(header.Number.Uint64()),
+		Hash:         hash,
+		ParentHash:   header.ParentHash,
+		Timestamp:    hexutil.Uint64(header.Time),
+		GasLimit:     hexutil.Uint64(header.GasLimit),
+		GasUsed:      hexutil.Uint64(header.GasUsed),
+		FeeRecipient: header.Coinbase,
+		StateRoot:    header.Root,
+		Calls:        results,
+	}
+	if header.BaseFee != nil {
+		res.BaseFeePerGas = (*hexutil.Big)(header.BaseFee)
+	}
+	return res, nil
+}
@@ -0,0 +1,173 @@
+// Copyright 2022 The go-ETX Authors
+// This file is part of the go-ETX library.
+//
+// The go-ETX library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ETX library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ETX library. If not, see <http://www.gnu.org/licenses/>.
+
+package etxapi
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ETX/go-ETX/common"
+	"github.com/ETX/go-ETX/common/hexutil"
+	"github.com/ETX/go-ETX/core"
+	"github.com/ETX/go-ETX/core/rawdb"
+	"github.com/ETX/go-ETX/core/state"
+	"github.com/ETX/go-ETX/core/types"
+	"github.com/ETX/go-ETX/core/vm"
+	"github.com/ETX/go-ETX/params"
+	"github.com/ETX/go-ETX/rpc"
+)
+
+// simCounterCode emits an empty log, increments storage slot 0 and returns the
+// new value of the counter.
+var simCounterCode = hexutil.Bytes(common.FromHex("0x60006000a06000546001018060005560005260206000f3"))
+
+// simBackend is a backend serving a fixed state for simulations.
+type simBackend struct {
+	*backendMock
+	state  *state.StateDB
+	header *types.Header
+}
+
+func newSimBackend(t *testing.T, alloc map[common.Address]*big.Int) *simBackend {
+	statedb, err := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for addr, balance := range alloc {
+		statedb.SetBalance(addr, balance)
+	}
+	mock := newBackendMock()
+	mock.config = params.TestChainConfig
+	return &simBackend{
+		backendMock: mock,
+		state:       statedb,
+		header: &types.Header{
+			Number:     big.NewInt(10),
+			Difficulty: big.NewInt(1),
+			GasLimit:   30_000_000,
+			GasUsed:    15_000_000,
+			Time:       1000,
+			BaseFee:    big.NewInt(params.InitialBaseFee),
+		},
+	}
+}
+
+func (b *simBackend) StateAndHeaderByNumberOrHash(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash) (*state.StateDB, *types.Header, error) {
+	return b.state.Copy(), b.header, nil
+}
+
+func (b *simBackend) GetEVM(ctx context.Context, msg core.Message, state *state.StateDB, header *types.Header, vmConfig *vm.Config) (*vm.EVM, func() error, error) {
+	blockCtx := core.NewEVMBlockContext(header, nil, &header.Coinbase)
+	return vm.NewEVM(blockCtx, core.NewEVMTxContext(msg), state, b.config, *vmConfig), func() error { return nil }, nil
+}
+
+func TestSimulate(t *testing.T) {
+	var (
+		contract = common.Address{0xcc}
+		coinbase = common.Address{0xcb}
+		backend  = newSimBackend(t, nil)
+		latest   = rpc.BlockNumberOrHashWithNumber(rpc.LatestBlockNumber)
+		time2    = hexutil.Big(*big.NewInt(2000))
+	)
+	call := TransactionArgs{To: &contract}
+	opts := SimOpts{BlockStateCalls: []SimBlock{
+		{
+			StateOverrides: &StateOverride{contract: {Code: &simCounterCode}},
+			Calls:          []TransactionArgs{call, call},
+		},
+		{
+			BlockOverrides: &BlockOverrides{Time: &time2, Coinbase: &coinbase},
+			Calls:          []TransactionArgs{call},
+		},
+	}}
+	results, err := DoSimulate(context.Background(), backend, opts, latest, time.Second, 0)
+	if err != nil {
+		t.Fatalf("simulation failed: %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("block count mismatch: have %d, want 2", len(results))
+	}
+	// The blocks must be chained on top of the base block, with the overrides applied
+	if results[0].Number != 11 || results[0].ParentHash != backend.header.Hash() || results[0].Timestamp != 1000+simTimestampIncrement {
+		t.Errorf("block 0 header mismatch: %+v", results[0])
+	}
+	if results[1].Number != 12 || results[1].ParentHash != results[0].Hash || results[1].Timestamp != 2000 || results[1].FeeRecipient != coinbase {
+		t.Errorf("block 1 header mismatch: %+v", results[1])
+	}
+	// The counter must carry over between the calls and the blocks
+	var counter int64
+	for i, block := range results {
+		for j, call := range block.Calls {
+			counter++
+			if call.Error != nil || call.Status != hexutil.Uint64(types.ReceiptStatusSuccessful) {
+				t.Fatalf("block %d call %d failed: %+v", i, j, call.Error)
+			}
+			if have := new(big.Int).SetBytes(call.ReturnValue).Int64(); have != counter {
+				t.Errorf("block %d call %d: counter mismatch: have %d, want %d", i, j, have, counter)
+			}
+			// Every call emits a single log, numbered within its block
+			if len(call.Logs) != 1 {
+				t.Fatalf("block %d call %d: log count mismatch: have %d, want 1", i, j, len(call.Logs))
+			}
+			l := call.Logs[0]
+			if l.Address != contract || l.BlockHash != block.Hash || l.BlockNumber != uint64(block.Number) || l.TxIndex != uint(j) || l.Index != uint(j) {
+				t.Errorf("block %d call %d: log mismatch: %+v", i, j, l)
+			}
+		}
+	}
+	if counter != 3 {
+		t.Errorf("call count mismatch: have %d, want 3", counter)
+	}
+	// The backend state must not have been modified
+	if code := backend.state.GetCode(contract); len(code) != 0 {
+		t.Error("simulation modified the base state")
+	}
+}
+
+func TestSimulateGasCap(t *testing.T) {
+	var (
+		sender    = common.Address{0x01}
+		recipient = common.Address{0x02}
+		backend   = newSimBackend(t, map[common.Address]*big.Int{sender: big.NewInt(params.InitialBaseFee * 1e6)})
+		latest    = rpc.BlockNumberOrHashWithNumber(rpc.LatestBlockNumber)
+		gas       = hexutil.Uint64(params.TxGas)
+		value     = hexutil.Big(*big.NewInt(1))
+	)
+	call := TransactionArgs{From: &sender, To: &recipient, Gas: &gas, Value: &value}
+	opts := SimOpts{BlockStateCalls: []SimBlock{
+		{Calls: []TransactionArgs{call, call}},
+		{Calls: []TransactionArgs{call}},
+	}}
+	// The cap covers the gas of all the calls of all the blocks
+	if _, err := DoSimulate(context.Background(), backend, opts, latest, time.Second, 3*params.TxGas); err != nil {
+		t.Fatalf("simulation within gas cap failed: %v", err)
+	}
+	opts.BlockStateCalls[1].Calls = append(opts.BlockStateCalls[1].Calls, call)
+	if _, err := DoSimulate(context.Background(), backend, opts, latest, time.Second, 3*params.TxGas); !errors.Is(err, errSimGasCapExhausted) {
+		t.Fatalf("error mismatch: have %v, want %v", err, errSimGasCapExhausted)
+	}
+	// A huge block gas limit must not lift the cap
+	limit := hexutil.Uint64(1 << 62)
+	opts.BlockStateCalls[1].BlockOverrides = &BlockOverrides{GasLimit: &limit}
+	opts.BlockStateCalls[1].Calls[1].Gas = nil
+	if _, err := DoSimulate(context.Background(), backend, opts, latest, time.Second, 3*params.TxGas); !errors.Is(err, errSimGasCapExhausted) {
+		t.Fatalf("error mismatch with gas limit override: have %v, want %v", err, errSimGasCapExhausted)
+	}
+}
@@ -28,6 +28,7 @@ var Modules = map[string]string{
 	"personal": PersonalJs,
 	"rpc":      RpcJs,
 	"txpool":   TxpoolJs,
+	"trace":    TraceJs,
 	"les":      LESJs,
 	"vflux":    VfluxJs,
 }
@@ -152,6 +153,37 @@ web3._extend({
 			call: 'admin_importChain',
 			params: 1
 		}),
+		new web3._extend.Metxod({
+			name: 'backup',
+			call: 'admin_backup',
+			params: 1
+		}),
+		new web3._extend.Metxod({
+			name: 'exportTxPool',
+			call: 'admin_exportTxPool',
+			params: 1
+		}),
+		new web3._extend.Metxod({
+			name: 'importTxPool',
+			call: 'admin_importTxPool',
+			params: 1
+		}),
+		new web3._extend.Metxod({
+			name: 'setTxPoolPolicy',
+			call: 'admin_setTxPoolPolicy',
+			params: 1,
+			inputFormatter: [null]
+		}),
+		new web3._extend.Metxod({
+			name: 'setMaxPeers',
+			call: 'admin_setMaxPeers',
+			params: 1
+		}),
+		new web3._extend.Metxod({
+			name: 'setPeerLimits',
+			call: 'admin_setPeerLimits',
+			params: 1
+		}),
 		new web3._extend.Metxod({
 			name: 'sleepBlocks',
 			call: 'admin_sleepBlocks',
@@ -199,6 +231,22 @@ web3._extend({
 			name: 'peers',
 			getter: 'admin_peers'
 		}),
+		new web3._extend.Property({
+			name: 'peerScores',
+			getter: 'admin_peerScores'
+		}),
+		new web3._extend.Property({
+			name: 'peerLimits',
+			getter: 'admin_peerLimits'
+		}),
+		new web3._extend.Property({
+			name: 'txPoolPolicy',
+			getter: 'admin_txPoolPolicy'
+		}),
+		new web3._extend.Property({
+			name: 'backupStatus',
+			getter: 'admin_backupStatus'
+		}),
 		new web3._extend.Property({
 			name: 'datadir',
 			getter: 'admin_datadir'
@@ -490,6 +538,21 @@ web3._extend({
 			call: 'debug_dbAncients',
 			params: 0
 		}),
+		new web3._extend.Metxod({
+			name: 'pruneStatus',
+			call: 'debug_pruneStatus',
+			params: 0
+		}),
+		new web3._extend.Metxod({
+			name: 'pausePrune',
+			call: 'debug_pausePrune',
+			params: 0
+		}),
+		new web3._extend.Metxod({
+			name: 'resumePrune',
+			call: 'debug_resumePrune',
+			params: 0
+		}),
 	],
 	properties: []
 });
@@ -577,6 +640,17 @@ web3._extend({
 			params: 2,
 			inputFormatter: [web3._extend.formatters.inputBlockNumberFormatter, web3._extend.utils.toHex]
 		}),
+		new web3._extend.Metxod({
+			name: 'simulate',
+			call: 'etx_simulate',
+			params: 2,
+			inputFormatter: [null, web3._extend.formatters.inputDefaultBlockNumberFormatter],
+		}),
+		new web3._extend.Metxod({
+			name: 'getBlockReceipts',
+			call: 'etx_getBlockReceipts',
+			params: 1,
+		}),
 		new web3._extend.Metxod({
 			name: 'getProof',
 			call: 'etx_getProof',
@@ -872,3 +946,35 @@ web3._extend({
 	]
 });
 `
+
+const TraceJs = `
+web3._extend({
+	property: 'trace',
+	metxods:
+	[
+		new web3._extend.Metxod({
+			name: 'block',
+			call: 'trace_block',
+			params: 1,
+			inputFormatter: [web3._extend.formatters.inputBlockNumberFormatter]
+		}),
+		new web3._extend.Metxod({
+			name: 'transaction',
+			call: 'trace_transaction',
+			params: 1
+		}),
+		new web3._extend.Metxod({
+			name: 'filter',
+			call: 'trace_filter',
+			params: 1
+		}),
+		new web3._extend.Metxod({
+			name: 'replayBlockTransactions',
+			call: 'trace_replayBlockTransactions',
+			params: 2,
+			inputFormatter: [web3._extend.formatters.inputBlockNumberFormatter, null]
+		}),
+	],
+	properties: []
+});
+`
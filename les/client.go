@@ -48,6 +48,7 @@ import (
 	"github.com/ETX/go-ETX/params"
 	"github.com/ETX/go-ETX/rlp"
 	"github.com/ETX/go-ETX/rpc"
+	"github.com/ETX/go-ETX/trie"
 )
 
 type LightETX struct {
@@ -99,7 +100,7 @@ func New(stack *node.Node, config *etxconfig.Config) (*LightETX, error) {
 	if config.OverrideTerminalTotalDifficultyPassed != nil {
 		overrides.OverrideTerminalTotalDifficultyPassed = config.OverrideTerminalTotalDifficultyPassed
 	}
-	chainConfig, genesisHash, genesisErr := core.SetupGenesisBlockWithOverride(chainDb, config.Genesis, &overrides)
+	chainConfig, genesisHash, genesisErr := core.SetupGenesisBlockWithOverride(chainDb, trie.NewDatabaseWithConfig(chainDb, &trie.Config{Preimages: true}), config.Genesis, &overrides)
 	if _, isCompat := genesisErr.(*params.ConfigCompatError); genesisErr != nil && !isCompat {
 		return nil, genesisErr
 	}
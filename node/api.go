@@ -297,6 +297,54 @@ func (api *adminAPI) Peers() ([]*p2p.PeerInfo, error) {
 	return server.PeersInfo(), nil
 }
 
+// PeerLimits retrieves the current connection limits of the p2p server.
+func (api *adminAPI) PeerLimits() (*p2p.PeerLimits, error) {
+	server := api.node.Server()
+	if server == nil {
+		return nil, ErrNodeStopped
+	}
+	limits := server.PeerLimits()
+	return &limits, nil
+}
+
+// SetPeerLimits changes the connection limits of the p2p server. Peers exceeding
+// the new limits are disconnected.
+func (api *adminAPI) SetPeerLimits(limits p2p.PeerLimits) (bool, error) {
+	server := api.node.Server()
+	if server == nil {
+		return false, ErrNodeStopped
+	}
+	if err := server.SetPeerLimits(limits); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// SetMaxPeers changes the maximum number of peers of the p2p server, keeping
+// its other connection limits.
+func (api *adminAPI) SetMaxPeers(maxPeers int) (bool, error) {
+	server := api.node.Server()
+	if server == nil {
+		return false, ErrNodeStopped
+	}
+	limits := server.PeerLimits()
+	limits.MaxPeers = maxPeers
+	if err := server.SetPeerLimits(limits); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// PeerScores retrieves the reputation of all the remote nodes known, including
+// the disconnected and banned ones.
+func (api *adminAPI) PeerScores() ([]*p2p.PeerReputation, error) {
+	server := api.node.Server()
+	if server == nil {
+		return nil, ErrNodeStopped
+	}
+	return server.PeerScores(), nil
+}
+
 // NodeInfo retrieves all the information we know about the host node at the
 // protocol granularity.
 func (api *adminAPI) NodeInfo() (*p2p.NodeInfo, error) {
@@ -199,6 +199,16 @@ type Config struct {
 
 	// JWTSecret is the path to the hex-encoded jwt secret.
 	JWTSecret string `toml:",omitempty"`
+
+	// DBEngine is the database engine to use, either "leveldb" or "pebble". If
+	// empty, the engine of an existing database is used, or leveldb for new ones.
+	DBEngine string `toml:",omitempty"`
+
+	// AncientTables maps chain freezer tables to the directories they are stored
+	// in, allowing to place them on different storage tiers. Relative paths are
+	// resolved like the ancient directory. Unlisted tables stay in the ancient
+	// directory.
+	AncientTables map[string]string `toml:",omitempty"`
 }
 
 // IPCEndpoint resolves an IPC endpoint based on a configured value, taking into
@@ -346,6 +356,31 @@ func (c *Config) ResolvePath(path string) string {
 	return filepath.Join(c.instanceDir(), path)
 }
 
+// ResolveAncient returns the absolute path of the root ancient directory of
+// the named database.
+func (c *Config) ResolveAncient(name string, ancient string) string {
+	switch {
+	case ancient == "":
+		ancient = filepath.Join(c.ResolvePath(name), "ancient")
+	case !filepath.IsAbs(ancient):
+		ancient = c.ResolvePath(ancient)
+	}
+	return ancient
+}
+
+// ResolveAncientTables returns the absolute paths of the directories of the
+// chain freezer tables stored outside of the ancient directory.
+func (c *Config) ResolveAncientTables() map[string]string {
+	if len(c.AncientTables) == 0 {
+		return nil
+	}
+	dirs := make(map[string]string, len(c.AncientTables))
+	for table, dir := range c.AncientTables {
+		dirs[table] = c.ResolvePath(dir)
+	}
+	return dirs
+}
+
 func (c *Config) instanceDir() string {
 	if c.DataDir == "" {
 		return ""
@@ -697,7 +697,14 @@ func (n *Node) OpenDatabase(name string, cache, handles int, namespace string, r
 	if n.config.DataDir == "" {
 		db = rawdb.NewMemoryDatabase()
 	} else {
-		db, err = rawdb.NewLevelDBDatabase(n.ResolvePath(name), cache, handles, namespace, readonly)
+		db, err = rawdb.Open(rawdb.OpenOptions{
+			Type:      n.config.DBEngine,
+			Directory: n.ResolvePath(name),
+			Namespace: namespace,
+			Cache:     cache,
+			Handles:   handles,
+			ReadOnly:  readonly,
+		})
 	}
 
 	if err == nil {
@@ -723,7 +730,16 @@ func (n *Node) OpenDatabaseWithFreezer(name string, cache, handles int, ancient
 	if n.config.DataDir == "" {
 		db = rawdb.NewMemoryDatabase()
 	} else {
-		db, err = rawdb.NewLevelDBDatabaseWithFreezer(n.ResolvePath(name), cache, handles, n.ResolveAncient(name, ancient), namespace, readonly)
+		db, err = rawdb.Open(rawdb.OpenOptions{
+			Type:              n.config.DBEngine,
+			Directory:         n.ResolvePath(name),
+			AncientsDirectory: n.ResolveAncient(name, ancient),
+			AncientTables:     n.ResolveAncientTables(),
+			Namespace:         namespace,
+			Cache:             cache,
+			Handles:           handles,
+			ReadOnly:          readonly,
+		})
 	}
 
 	if err == nil {
@@ -739,13 +755,13 @@ func (n *Node) ResolvePath(x string) string {
 
 // ResolveAncient returns the absolute path of the root ancient directory.
 func (n *Node) ResolveAncient(name string, ancient string) string {
-	switch {
-	case ancient == "":
-		ancient = filepath.Join(n.ResolvePath(name), "ancient")
-	case !filepath.IsAbs(ancient):
-		ancient = n.ResolvePath(ancient)
-	}
-	return ancient
+	return n.config.ResolveAncient(name, ancient)
+}
+
+// ResolveAncientTables returns the absolute paths of the directories of the
+// chain freezer tables stored outside of the ancient directory.
+func (n *Node) ResolveAncientTables() map[string]string {
+	return n.config.ResolveAncientTables()
 }
 
 // closeTrackingDB wraps the Close metxod of a database. When the database is closed by the
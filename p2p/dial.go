@@ -79,6 +79,7 @@ var (
 	errRecentlyDialed   = errors.New("recently dialed")
 	errNetRestrict      = errors.New("not contained in netrestrict list")
 	errNoPort           = errors.New("node does not provide TCP port")
+	errLowReputation    = errors.New("reputation too low")
 )
 
 // dialer creates outbound connections and submits them into Server.
@@ -102,6 +103,7 @@ type dialScheduler struct {
 	remStaticCh chan *enode.Node
 	addPeerCh   chan *conn
 	remPeerCh   chan *conn
+	setMaxCh    chan int
 
 	// Everything below here belongs to loop and
 	// should only be accessed by code on the loop goroutine.
@@ -135,6 +137,7 @@ type dialConfig struct {
 	netRestrict    *netutil.Netlist // IP netrestrict list, disabled if nil
 	resolver       nodeResolver
 	dialer         NodeDialer
+	reputable      func(enode.ID) bool // reports whetxer a node may be dialed dynamically
 	log            log.Logger
 	clock          mclock.Clock
 	rand           *mrand.Rand
@@ -172,6 +175,7 @@ func newDialScheduler(config dialConfig, it enode.Iterator, setupFunc dialSetupF
 		remStaticCh: make(chan *enode.Node),
 		addPeerCh:   make(chan *conn),
 		remPeerCh:   make(chan *conn),
+		setMaxCh:    make(chan int),
 	}
 	d.lastStatsLog = d.clock.Now()
 	d.ctx, d.cancel = context.WithCancel(context.Background())
@@ -211,6 +215,14 @@ func (d *dialScheduler) peerAdded(c *conn) {
 	}
 }
 
+// setMaxDialPeers changes the maximum number of dialed peers.
+func (d *dialScheduler) setMaxDialPeers(n int) {
+	select {
+	case d.setMaxCh <- n:
+	case <-d.ctx.Done():
+	}
+}
+
 // peerRemoved updates the peer set.
 func (d *dialScheduler) peerRemoved(c *conn) {
 	select {
@@ -241,7 +253,7 @@ loop:
 
 		select {
 		case node := <-nodesCh:
-			if err := d.checkDial(node); err != nil {
+			if err := d.checkDynDial(node); err != nil {
 				d.log.Trace("Discarding dial candidate", "id", node.ID(), "ip", node.IP(), "reason", err)
 			} else {
 				d.startDial(newDialTask(node, dynDialedConn))
@@ -297,6 +309,10 @@ loop:
 				}
 			}
 
+		case n := <-d.setMaxCh:
+			d.log.Trace("Changing dialed peer limit", "max", n)
+			d.maxDialPeers = n
+
 		case <-historyExp:
 			d.expireHistory()
 
@@ -409,6 +425,18 @@ func (d *dialScheduler) checkDial(n *enode.Node) error {
 	return nil
 }
 
+// checkDynDial returns an error if node n should not be dialed as a dynamic
+// peer. In contrast to static nodes, nodes of low reputation are avoided.
+func (d *dialScheduler) checkDynDial(n *enode.Node) error {
+	if err := d.checkDial(n); err != nil {
+		return err
+	}
+	if d.reputable != nil && !d.reputable(n.ID()) {
+		return errLowReputation
+	}
+	return nil
+}
+
 // startStaticDials starts n static dial tasks.
 func (d *dialScheduler) startStaticDials(n int) (started int) {
 	for started = 0; started < n && len(d.staticPool) > 0; started++ {
@@ -152,6 +152,39 @@ func TestDialSchedNetRestrict(t *testing.T) {
 	})
 }
 
+// This test checks that candidates of low reputation are not dialed dynamically,
+// while static dials ignore the reputation.
+func TestDialSchedReputation(t *testing.T) {
+	t.Parallel()
+
+	nodes := []*enode.Node{
+		newNode(uintID(0x01), "127.0.0.1:30303"),
+		newNode(uintID(0x02), "127.0.0.2:30303"),
+		newNode(uintID(0x03), "127.0.0.3:30303"),
+		newNode(uintID(0x04), "127.0.0.4:30303"),
+	}
+	config := dialConfig{
+		maxActiveDials: 10,
+		maxDialPeers:   10,
+		reputable: func(id enode.ID) bool {
+			return id != uintID(0x02) && id != uintID(0x04)
+		},
+	}
+	runDialTest(t, config, []dialTestRound{
+		{
+			discovered:   nodes,
+			wantNewDials: []*enode.Node{nodes[0], nodes[2]},
+		},
+		{
+			succeeded: []enode.ID{nodes[0].ID(), nodes[2].ID()},
+			update: func(d *dialScheduler) {
+				d.addStatic(nodes[3])
+			},
+			wantNewDials: []*enode.Node{nodes[3]},
+		},
+	})
+}
+
 // This test checks that static dials work and obey the limits.
 func TestDialSchedStaticDial(t *testing.T) {
 	t.Parallel()
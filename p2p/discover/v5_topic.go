@@ -0,0 +1,458 @@
+// Copyright 2022 The go-ETX Authors
+// This file is part of the go-ETX library.
+//
+// The go-ETX library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ETX library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ETX library. If not, see <http://www.gnu.org/licenses/>.
+
+package discover
+
+import (
+	"context"
+	"crypto/hmac"
+	crand "crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/ETX/go-ETX/common"
+	"github.com/ETX/go-ETX/common/mclock"
+	"github.com/ETX/go-ETX/crypto"
+	"github.com/ETX/go-ETX/p2p/discover/v5wire"
+	"github.com/ETX/go-ETX/p2p/enode"
+	"github.com/ETX/go-ETX/p2p/netutil"
+	"github.com/ETX/go-ETX/rlp"
+)
+
+const (
+	topicRegTime       = 10 * time.Minute // lifetime of a registration
+	topicRegWindow     = 10 * time.Second // time after the waiting time in which a ticket can be used
+	topicMaxWaitTime   = topicRegTime     // tickets with longer waiting times are not used
+	topicQueueLength   = 50               // max registrations per topic
+	topicTableLimit    = 500              // max registrations in total
+	topicRegistrars    = 8                // number of nodes a topic is registered at
+	topicQueryLimit    = 16               // max nodes in TOPICQUERY responses
+	topicQueryInterval = 30 * time.Second // pause between topic search rounds
+)
+
+var (
+	errInvalidTicket = errors.New("invalid ticket")
+	errTicketTime    = errors.New("ticket used outside of its registration window")
+	errTopicFull     = errors.New("topic queue full")
+)
+
+// Topic identifies a service advertised in the DHT.
+type Topic common.Hash
+
+// NewTopic creates the topic of a service name.
+func NewTopic(name string) Topic {
+	return Topic(crypto.Keccak256Hash([]byte(name)))
+}
+
+func (t Topic) String() string {
+	return common.Hash(t).String()
+}
+
+// topicTicket is the content of a ticket handed out by a registrar. The ticket is
+// authenticated by the registrar, so it doesn't need to remember issued tickets.
+type topicTicket struct {
+	Topic  Topic
+	Node   enode.ID
+	IP     net.IP
+	Issued uint64 // mclock.AbsTime of the registrar
+	Wait   uint64 // waiting time in nanoseconds
+}
+
+// topicReg is a registration in a topic queue.
+type topicReg struct {
+	node    *enode.Node
+	expires mclock.AbsTime
+}
+
+// topicTable holds the registrations made at the local node.
+type topicTable struct {
+	clock  mclock.Clock
+	key    []byte // ticket authentication key
+	mu     sync.Mutex
+	queues map[Topic][]topicReg // registrations by topic, ordered by expiry
+	count  int
+}
+
+func newTopicTable(clock mclock.Clock) *topicTable {
+	key := make([]byte, 32)
+	crand.Read(key)
+	return &topicTable{clock: clock, key: key, queues: make(map[Topic][]topicReg)}
+}
+
+// expire removes the expired registrations. The lock must be held.
+func (tab *topicTable) expire() {
+	now := tab.clock.Now()
+	for topic, queue := range tab.queues {
+		i := 0
+		for i < len(queue) && queue[i].expires <= now {
+			i++
+		}
+		tab.count -= i
+		if i == len(queue) {
+			delete(tab.queues, topic)
+		} else {
+			tab.queues[topic] = queue[i:]
+		}
+	}
+}
+
+// waitTime returns the time until a registration slot for the topic frees up.
+// The lock must be held.
+func (tab *topicTable) waitTime(topic Topic) time.Duration {
+	var (
+		now    = tab.clock.Now()
+		queue  = tab.queues[topic]
+		expiry mclock.AbsTime
+	)
+	switch {
+	case len(queue) >= topicQueueLength:
+		expiry = queue[0].expires
+	case tab.count >= topicTableLimit:
+		for _, q := range tab.queues {
+			if expiry == 0 || q[0].expires < expiry {
+				expiry = q[0].expires
+			}
+		}
+	default:
+		return 0
+	}
+	return time.Duration(expiry - now)
+}
+
+// ticket creates a ticket for a node requesting a registration.
+func (tab *topicTable) ticket(topic Topic, id enode.ID, ip net.IP) ([]byte, time.Duration) {
+	tab.mu.Lock()
+	defer tab.mu.Unlock()
+
+	tab.expire()
+	wait := tab.waitTime(topic)
+	enc, _ := rlp.EncodeToBytes(&topicTicket{
+		Topic:  topic,
+		Node:   id,
+		IP:     ip,
+		Issued: uint64(tab.clock.Now()),
+		Wait:   uint64(wait),
+	})
+	return append(enc, tab.mac(enc)...), wait
+}
+
+func (tab *topicTable) mac(data []byte) []byte {
+	h := hmac.New(sha256.New, tab.key)
+	h.Write(data)
+	return h.Sum(nil)
+}
+
+// register adds a node to a topic queue using a ticket.
+func (tab *topicTable) register(ticket []byte, n *enode.Node, ip net.IP) (Topic, error) {
+	if len(ticket) < sha256.Size {
+		return Topic{}, errInvalidTicket
+	}
+	enc, mac := ticket[:len(ticket)-sha256.Size], ticket[len(ticket)-sha256.Size:]
+	if !hmac.Equal(mac, tab.mac(enc)) {
+		return Topic{}, errInvalidTicket
+	}
+	var t topicTicket
+	if err := rlp.DecodeBytes(enc, &t); err != nil {
+		return Topic{}, errInvalidTicket
+	}
+	if t.Node != n.ID() || !t.IP.Equal(ip) {
+		return Topic{}, errInvalidTicket
+	}
+	tab.mu.Lock()
+	defer tab.mu.Unlock()
+
+	now := tab.clock.Now()
+	start := mclock.AbsTime(t.Issued + t.Wait)
+	if now < start || now > start.Add(topicRegWindow) {
+		return t.Topic, errTicketTime
+	}
+	tab.expire()
+
+	// Refresh an existing registration, or add a new one if there's space.
+	queue := tab.queues[t.Topic]
+	for i, reg := range queue {
+		if reg.node.ID() == n.ID() {
+			queue = append(queue[:i:i], queue[i+1:]...)
+			tab.count--
+			break
+		}
+	}
+	if len(queue) >= topicQueueLength || tab.count >= topicTableLimit {
+		tab.queues[t.Topic] = queue
+		return t.Topic, errTopicFull
+	}
+	tab.queues[t.Topic] = append(queue, topicReg{node: n, expires: now.Add(topicRegTime)})
+	tab.count++
+	return t.Topic, nil
+}
+
+// nodes returns the most recently registered nodes of a topic.
+func (tab *topicTable) nodes(topic Topic, limit int) []*enode.Node {
+	tab.mu.Lock()
+	defer tab.mu.Unlock()
+
+	tab.expire()
+	queue := tab.queues[topic]
+	nodes := make([]*enode.Node, 0, min(len(queue), limit))
+	for i := len(queue) - 1; i >= 0 && len(nodes) < limit; i-- {
+		nodes = append(nodes, queue[i].node)
+	}
+	return nodes
+}
+
+// RegisterTopic starts advertising the local node under the given topic. The
+// registration is renewed periodically at the nodes closest to the topic until
+// StopRegisterTopic is called.
+func (t *UDPv5) RegisterTopic(topic Topic) {
+	t.topicLock.Lock()
+	defer t.topicLock.Unlock()
+
+	if _, ok := t.topicRegs[topic]; ok {
+		return
+	}
+	ctx, cancel := context.WithCancel(t.closeCtx)
+	t.topicRegs[topic] = cancel
+	go t.topicRegLoop(ctx, topic)
+}
+
+// StopRegisterTopic stops advertising the local node under the given topic.
+// Existing registrations expire eventually.
+func (t *UDPv5) StopRegisterTopic(topic Topic) {
+	t.topicLock.Lock()
+	defer t.topicLock.Unlock()
+
+	if cancel, ok := t.topicRegs[topic]; ok {
+		cancel()
+		delete(t.topicRegs, topic)
+	}
+}
+
+// topicRegLoop registers a topic at the nodes closest to it, renewing the
+// registrations before they expire.
+func (t *UDPv5) topicRegLoop(ctx context.Context, topic Topic) {
+	for {
+		nodes := t.newLookup(ctx, enode.ID(topic)).run()
+		if len(nodes) > topicRegistrars {
+			nodes = nodes[:topicRegistrars]
+		}
+		var wg sync.WaitGroup
+		for _, n := range nodes {
+			wg.Add(1)
+			go func(n *enode.Node) {
+				defer wg.Done()
+				if err := t.registerAt(ctx, n, topic); err != nil {
+					t.log.Debug("Topic registration failed", "topic", topic, "id", n.ID(), "err", err)
+				}
+			}(n)
+		}
+		wg.Wait()
+
+		select {
+		case <-t.clock.After(topicRegTime / 2):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// registerAt registers a topic at a single node, waiting as long as demanded by
+// the ticket.
+func (t *UDPv5) registerAt(ctx context.Context, n *enode.Node, topic Topic) error {
+	ticket, wait, err := t.RequestTicket(n, topic)
+	if err != nil {
+		return err
+	}
+	if wait > topicMaxWaitTime {
+		return errTopicFull
+	}
+	select {
+	case <-t.clock.After(wait):
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	registered, err := t.Regtopic(n, ticket)
+	if err != nil {
+		return err
+	}
+	if !registered {
+		return errTopicFull
+	}
+	t.log.Trace("Registered topic", "topic", topic, "id", n.ID())
+	return nil
+}
+
+// RequestTicket calls REQTICKET on a node, returning the ticket and the time to
+// wait before it can be used.
+func (t *UDPv5) RequestTicket(n *enode.Node, topic Topic) ([]byte, time.Duration, error) {
+	resp := t.call(n, v5wire.TicketMsg, &v5wire.RequestTicket{Topic: topic[:]})
+	defer t.callDone(resp)
+
+	select {
+	case p := <-resp.ch:
+		ticket := p.(*v5wire.Ticket)
+		return ticket.Ticket, time.Duration(ticket.WaitTime) * time.Millisecond, nil
+	case err := <-resp.err:
+		return nil, 0, err
+	}
+}
+
+// Regtopic calls REGTOPIC on a node, reporting whetxer the registration was
+// accepted.
+func (t *UDPv5) Regtopic(n *enode.Node, ticket []byte) (bool, error) {
+	req := &v5wire.Regtopic{Ticket: ticket, ENR: t.localNode.Node().Record()}
+	resp := t.call(n, v5wire.RegconfirmationMsg, req)
+	defer t.callDone(resp)
+
+	select {
+	case p := <-resp.ch:
+		return p.(*v5wire.Regconfirmation).Registered, nil
+	case err := <-resp.err:
+		return false, err
+	}
+}
+
+// TopicQuery calls TOPICQUERY on a node and waits for the registered nodes.
+func (t *UDPv5) TopicQuery(n *enode.Node, topic Topic) ([]*enode.Node, error) {
+	resp := t.call(n, v5wire.NodesMsg, &v5wire.TopicQuery{Topic: topic[:]})
+	return t.waitForNodes(resp, nil)
+}
+
+// TopicNodes returns an iterator over the nodes registered under the given
+// topic. It repeatedly searches the nodes closest to the topic for registrations.
+func (t *UDPv5) TopicNodes(topic Topic) enode.Iterator {
+	ctx, cancel := context.WithCancel(t.closeCtx)
+	it := &topicIterator{ch: make(chan *enode.Node), ctx: ctx, cancel: cancel}
+	go it.loop(t, topic)
+	return it
+}
+
+// topicIterator is the iterator returned by TopicNodes.
+type topicIterator struct {
+	cur    *enode.Node
+	ch     chan *enode.Node
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+func (it *topicIterator) Next() bool {
+	select {
+	case it.cur = <-it.ch:
+		return true
+	case <-it.ctx.Done():
+		it.cur = nil
+		return false
+	}
+}
+
+func (it *topicIterator) Node() *enode.Node {
+	return it.cur
+}
+
+func (it *topicIterator) Close() {
+	it.cancel()
+}
+
+// loop queries the nodes closest to the topic for registrations.
+func (it *topicIterator) loop(t *UDPv5, topic Topic) {
+	for {
+		seen := make(map[enode.ID]struct{})
+		for _, n := range t.newLookup(it.ctx, enode.ID(topic)).run() {
+			nodes, err := t.TopicQuery(n, topic)
+			if err != nil {
+				t.log.Trace("Topic query failed", "topic", topic, "id", n.ID(), "err", err)
+			}
+			for _, rn := range nodes {
+				if _, ok := seen[rn.ID()]; ok || rn.ID() == t.Self().ID() {
+					continue
+				}
+				seen[rn.ID()] = struct{}{}
+				select {
+				case it.ch <- rn:
+				case <-it.ctx.Done():
+					return
+				}
+			}
+		}
+		select {
+		case <-t.clock.After(topicQueryInterval):
+		case <-it.ctx.Done():
+			return
+		}
+	}
+}
+
+// handleRequestTicket hands out a ticket for a topic registration.
+func (t *UDPv5) handleRequestTicket(p *v5wire.RequestTicket, fromID enode.ID, fromAddr *net.UDPAddr) {
+	if len(p.Topic) != len(Topic{}) {
+		t.log.Debug("Invalid topic in "+p.Name(), "id", fromID, "addr", fromAddr)
+		return
+	}
+	ticket, wait := t.topics.ticket(Topic(common.BytesToHash(p.Topic)), fromID, fromAddr.IP)
+	t.sendResponse(fromID, fromAddr, &v5wire.Ticket{
+		ReqID:    p.ReqID,
+		Ticket:   ticket,
+		WaitTime: uint64(wait / time.Millisecond),
+	})
+}
+
+// handleRegtopic registers the sender in a topic queue if its ticket is valid.
+func (t *UDPv5) handleRegtopic(p *v5wire.Regtopic, fromID enode.ID, fromAddr *net.UDPAddr) {
+	registered, err := t.regtopic(p, fromID, fromAddr)
+	if err != nil {
+		t.log.Debug("Rejected "+p.Name(), "id", fromID, "addr", fromAddr, "err", err)
+	}
+	t.sendResponse(fromID, fromAddr, &v5wire.Regconfirmation{ReqID: p.ReqID, Registered: registered})
+}
+
+func (t *UDPv5) regtopic(p *v5wire.Regtopic, fromID enode.ID, fromAddr *net.UDPAddr) (bool, error) {
+	if p.ENR == nil {
+		return false, errors.New("missing record")
+	}
+	n, err := enode.New(t.validSchemes, p.ENR)
+	if err != nil {
+		return false, err
+	}
+	if n.ID() != fromID {
+		return false, errors.New("record of another node")
+	}
+	if err := netutil.CheckRelayIP(fromAddr.IP, n.IP()); err != nil {
+		return false, err
+	}
+	if _, err := t.topics.register(p.Ticket, n, fromAddr.IP); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// handleTopicQuery returns the nodes registered under a topic.
+func (t *UDPv5) handleTopicQuery(p *v5wire.TopicQuery, fromID enode.ID, fromAddr *net.UDPAddr) {
+	if len(p.Topic) != len(Topic{}) {
+		t.log.Debug("Invalid topic in "+p.Name(), "id", fromID, "addr", fromAddr)
+		return
+	}
+	var nodes []*enode.Node
+	for _, n := range t.topics.nodes(Topic(common.BytesToHash(p.Topic)), topicQueryLimit) {
+		if netutil.CheckRelayIP(fromAddr.IP, n.IP()) == nil {
+			nodes = append(nodes, n)
+		}
+	}
+	for _, resp := range packNodes(p.ReqID, nodes) {
+		t.sendResponse(fromID, fromAddr, resp)
+	}
+}
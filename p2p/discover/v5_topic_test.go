@@ -0,0 +1,130 @@
+// Copyright 2022 The go-ETX Authors
+// This file is part of the go-ETX library.
+//
+// The go-ETX library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ETX library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ETX library. If not, see <http://www.gnu.org/licenses/>.
+
+package discover
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/ETX/go-ETX/common/mclock"
+	"github.com/ETX/go-ETX/p2p/discover/v5wire"
+	"github.com/ETX/go-ETX/p2p/enode"
+	"github.com/ETX/go-ETX/p2p/enr"
+)
+
+// This test checks that REQTICKET, REGTOPIC and TOPICQUERY are handled correctly.
+func TestUDPv5_topicHandling(t *testing.T) {
+	t.Parallel()
+	test := newUDPV5Test(t)
+	defer test.close()
+
+	var (
+		topic  = NewTopic("test")
+		remote = test.getNode(test.remotekey, test.remoteaddr).Node()
+		ticket []byte
+	)
+	test.packetIn(&v5wire.RequestTicket{ReqID: []byte("1"), Topic: topic[:]})
+	test.waitPacketOut(func(p *v5wire.Ticket, addr *net.UDPAddr, _ v5wire.Nonce) {
+		if !bytes.Equal(p.ReqID, []byte("1")) {
+			t.Error("wrong request ID in response:", p.ReqID)
+		}
+		if p.WaitTime != 0 {
+			t.Errorf("non-zero waiting time %d for empty topic", p.WaitTime)
+		}
+		ticket = p.Ticket
+	})
+
+	// A forged ticket must be rejected.
+	forged := append([]byte{}, ticket...)
+	forged[0]++
+	test.packetIn(&v5wire.Regtopic{ReqID: []byte("2"), Ticket: forged, ENR: remote.Record()})
+	test.waitPacketOut(func(p *v5wire.Regconfirmation, addr *net.UDPAddr, _ v5wire.Nonce) {
+		if p.Registered {
+			t.Error("registration with forged ticket accepted")
+		}
+	})
+
+	test.packetIn(&v5wire.Regtopic{ReqID: []byte("3"), Ticket: ticket, ENR: remote.Record()})
+	test.waitPacketOut(func(p *v5wire.Regconfirmation, addr *net.UDPAddr, _ v5wire.Nonce) {
+		if !bytes.Equal(p.ReqID, []byte("3")) {
+			t.Error("wrong request ID in response:", p.ReqID)
+		}
+		if !p.Registered {
+			t.Error("registration with valid ticket rejected")
+		}
+	})
+
+	test.packetIn(&v5wire.TopicQuery{ReqID: []byte("4"), Topic: topic[:]})
+	test.expectNodes([]byte("4"), 1, []*enode.Node{remote})
+
+	other := NewTopic("other")
+	test.packetIn(&v5wire.TopicQuery{ReqID: []byte("5"), Topic: other[:]})
+	test.expectNodes([]byte("5"), 1, nil)
+}
+
+// This test checks the waiting times and registration windows of tickets.
+func TestTopicTableTickets(t *testing.T) {
+	var (
+		clock = new(mclock.Simulated)
+		tab   = newTopicTable(clock)
+		topic = NewTopic("test")
+		ip    = net.IP{10, 0, 0, 1}
+	)
+	register := func(n *enode.Node) error {
+		ticket, wait := tab.ticket(topic, n.ID(), ip)
+		clock.Run(wait)
+		_, err := tab.register(ticket, n, ip)
+		return err
+	}
+	nodes := make([]*enode.Node, topicQueueLength+1)
+	for i := range nodes {
+		var r enr.Record
+		r.Set(enr.IP(ip))
+		nodes[i] = enode.SignNull(&r, enode.ID{byte(i), byte(i >> 8)})
+	}
+	// Fill the topic queue.
+	for _, n := range nodes[:topicQueueLength] {
+		if err := register(n); err != nil {
+			t.Fatalf("registration failed: %v", err)
+		}
+		clock.Run(time.Second)
+	}
+	// The next node must wait until the first registration expires.
+	ticket, wait := tab.ticket(topic, nodes[topicQueueLength].ID(), ip)
+	if want := topicRegTime - topicQueueLength*time.Second; wait != want {
+		t.Fatalf("wrong waiting time %v, want %v", wait, want)
+	}
+	if _, err := tab.register(ticket, nodes[topicQueueLength], ip); err != errTicketTime {
+		t.Fatalf("early registration: have %v, want %v", err, errTicketTime)
+	}
+	clock.Run(wait)
+	if _, err := tab.register(ticket, nodes[topicQueueLength], ip); err != nil {
+		t.Fatalf("registration after waiting failed: %v", err)
+	}
+	if found := tab.nodes(topic, topicQueryLimit); len(found) != topicQueryLimit || found[0].ID() != nodes[topicQueueLength].ID() {
+		t.Fatalf("wrong query result: %d nodes", len(found))
+	}
+	// Tickets expire after the registration window.
+	clock.Run(time.Second)
+	ticket, _ = tab.ticket(topic, nodes[1].ID(), ip)
+	clock.Run(topicRegWindow + time.Second)
+	if _, err := tab.register(ticket, nodes[1], ip); err != errTicketTime {
+		t.Fatalf("late registration: have %v, want %v", err, errTicketTime)
+	}
+}
@@ -40,6 +40,7 @@ const (
 	dbVersionKey   = "version" // Version of the database to flush if changes
 	dbNodePrefix   = "n:"      // Identifier to prefix node entries with
 	dbLocalPrefix  = "local:"
+	dbRepPrefix    = "rep:" // Identifier to prefix node reputation entries with
 	dbDiscoverRoot = "v4"
 	dbDiscv5Root   = "v5"
 
@@ -56,8 +57,9 @@ const (
 )
 
 const (
-	dbNodeExpiration = 24 * time.Hour // Time after which an unseen node should be dropped.
-	dbCleanupCycle   = time.Hour      // Time period for running the expiration task.
+	dbNodeExpiration = 24 * time.Hour     // Time after which an unseen node should be dropped.
+	dbRepExpiration  = 7 * 24 * time.Hour // Time after which an unchanged reputation should be dropped.
+	dbCleanupCycle   = time.Hour          // Time period for running the expiration task.
 	dbVersion        = 9
 )
 
@@ -315,6 +317,7 @@ func (db *DB) expirer() {
 		select {
 		case <-tick.C:
 			db.expireNodes()
+			db.expireReputations()
 		case <-db.quit:
 			return
 		}
@@ -442,6 +445,85 @@ func (db *DB) storeLocalSeq(id ID, n uint64) {
 	db.storeUint64(localItemKey(id, dbLocalSeq), n)
 }
 
+// Reputation is the locally accumulated reputation of a remote node.
+type Reputation struct {
+	Score   int64     // Score at the time of the last update
+	Updated time.Time // Time of the last update
+	Banned  time.Time // Time until which the node is banned
+}
+
+// reputationKey returns the database key for the reputation of a node.
+func reputationKey(id ID) []byte {
+	return append([]byte(dbRepPrefix), id[:]...)
+}
+
+// encodeReputation encodes a reputation into its database representation.
+func encodeReputation(rep Reputation) []byte {
+	blob := make([]byte, 3*binary.MaxVarintLen64)
+	n := binary.PutVarint(blob, rep.Score)
+	n += binary.PutVarint(blob[n:], rep.Updated.Unix())
+	n += binary.PutVarint(blob[n:], rep.Banned.Unix())
+	return blob[:n]
+}
+
+// decodeReputation decodes the database representation of a reputation.
+func decodeReputation(blob []byte) (Reputation, bool) {
+	var fields [3]int64
+	for i := range fields {
+		val, read := binary.Varint(blob)
+		if read <= 0 {
+			return Reputation{}, false
+		}
+		fields[i], blob = val, blob[read:]
+	}
+	return Reputation{Score: fields[0], Updated: time.Unix(fields[1], 0), Banned: time.Unix(fields[2], 0)}, true
+}
+
+// Reputation retrieves the reputation of a remote node.
+func (db *DB) Reputation(id ID) Reputation {
+	blob, err := db.lvl.Get(reputationKey(id), nil)
+	if err != nil {
+		return Reputation{}
+	}
+	rep, _ := decodeReputation(blob)
+	return rep
+}
+
+// UpdateReputation stores the reputation of a remote node.
+func (db *DB) UpdateReputation(id ID, rep Reputation) error {
+	return db.lvl.Put(reputationKey(id), encodeReputation(rep), nil)
+}
+
+// Reputations retrieves the reputation of all the remote nodes known.
+func (db *DB) Reputations() map[ID]Reputation {
+	it := db.lvl.NewIterator(util.BytesPrefix([]byte(dbRepPrefix)), nil)
+	defer it.Release()
+
+	reps := make(map[ID]Reputation)
+	for it.Next() {
+		var id ID
+		if len(it.Key()) != len(dbRepPrefix)+len(id) {
+			continue
+		}
+		copy(id[:], it.Key()[len(dbRepPrefix):])
+		if rep, ok := decodeReputation(it.Value()); ok {
+			reps[id] = rep
+		}
+	}
+	return reps
+}
+
+// expireReputations deletes the reputations which have not been updated for
+// some time and are not banned anymore.
+func (db *DB) expireReputations() {
+	threshold := time.Now().Add(-dbRepExpiration)
+	for id, rep := range db.Reputations() {
+		if rep.Updated.Before(threshold) && rep.Banned.Before(threshold) {
+			db.lvl.Delete(reputationKey(id), nil)
+		}
+	}
+}
+
 // QuerySeeds retrieves random nodes to be used as potential seed nodes
 // for bootstrapping.
 func (db *DB) QuerySeeds(n int, maxAge time.Duration) []*Node {
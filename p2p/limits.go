@@ -0,0 +1,237 @@
+// Copyright 2022 The go-ETX Authors
+// This file is part of the go-ETX library.
+//
+// The go-ETX library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ETX library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ETX library. If not, see <http://www.gnu.org/licenses/>.
+
+package p2p
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+
+	"github.com/ETX/go-ETX/event"
+	"github.com/ETX/go-ETX/p2p/enode"
+	"github.com/ETX/go-ETX/p2p/netutil"
+)
+
+// PeerLimits are the connection limits of the server. They are initialized from
+// the configuration and can be changed while the server is running.
+type PeerLimits struct {
+	MaxPeers          int            `json:"maxPeers"`                // Maximum number of peers
+	MaxInbound        int            `json:"maxInbound"`              // Maximum number of inbound peers, derived from MaxPeers if zero
+	MaxOutbound       int            `json:"maxOutbound"`             // Maximum number of dialed peers, derived from MaxPeers if zero
+	ReservedSlots     map[string]int `json:"reservedSlots,omitempty"` // Slots reserved for peers supporting a protocol
+	MaxPeersPerSubnet int            `json:"maxPeersPerSubnet"`       // Maximum number of peers per subnet, unlimited if zero
+}
+
+// copy returns a deep copy of the limits.
+func (l PeerLimits) copy() PeerLimits {
+	cpy := l
+	if l.ReservedSlots != nil {
+		cpy.ReservedSlots = make(map[string]int, len(l.ReservedSlots))
+		for name, slots := range l.ReservedSlots {
+			cpy.ReservedSlots[name] = slots
+		}
+	}
+	return cpy
+}
+
+// validate checks the limits for sanity.
+func (l PeerLimits) validate() error {
+	if l.MaxPeers < 0 || l.MaxInbound < 0 || l.MaxOutbound < 0 || l.MaxPeersPerSubnet < 0 {
+		return errors.New("negative peer limit")
+	}
+	reserved := 0
+	for name, slots := range l.ReservedSlots {
+		if slots < 0 {
+			return fmt.Errorf("negative reserved slots for %q", name)
+		}
+		reserved += slots
+	}
+	if reserved > l.MaxPeers {
+		return fmt.Errorf("reserved slots (%d) exceed the peer limit (%d)", reserved, l.MaxPeers)
+	}
+	return nil
+}
+
+// configPeerLimits returns the limits set in the server configuration.
+func (srv *Server) configPeerLimits() PeerLimits {
+	limits := PeerLimits{
+		MaxPeers:          srv.MaxPeers,
+		MaxInbound:        srv.MaxInboundPeers,
+		MaxOutbound:       srv.MaxOutboundPeers,
+		ReservedSlots:     srv.ReservedSlots,
+		MaxPeersPerSubnet: srv.MaxPeersPerSubnet,
+	}
+	return limits.copy()
+}
+
+// PeerLimits returns the current connection limits of the server.
+func (srv *Server) PeerLimits() PeerLimits {
+	srv.limitsLock.RLock()
+	defer srv.limitsLock.RUnlock()
+
+	return srv.limits.copy()
+}
+
+// SetPeerLimits changes the connection limits of the running server. Peers
+// exceeding the new limits are disconnected, trusted ones excepted.
+func (srv *Server) SetPeerLimits(limits PeerLimits) error {
+	if err := limits.validate(); err != nil {
+		return err
+	}
+	srv.lock.Lock()
+	running := srv.running
+	srv.lock.Unlock()
+	if !running {
+		return errServerStopped
+	}
+	limits = limits.copy()
+
+	srv.limitsLock.Lock()
+	srv.limits = limits
+	srv.limitsLock.Unlock()
+
+	srv.log.Info("Changed peer limits", "max", limits.MaxPeers, "inbound", srv.maxInboundConns(limits),
+		"outbound", srv.maxDialedConns(limits), "reserved", limits.ReservedSlots, "subnet", limits.MaxPeersPerSubnet)
+	srv.dialsched.setMaxDialPeers(srv.maxDialedConns(limits))
+	srv.doPeerOp(func(peers map[enode.ID]*Peer) {
+		srv.trimPeers(peers, limits)
+	})
+	srv.limitsFeed.Send(limits.copy())
+	return nil
+}
+
+// SubscribePeerLimits subscribes the given channel to changes of the connection
+// limits.
+func (srv *Server) SubscribePeerLimits(ch chan<- PeerLimits) event.Subscription {
+	return srv.limitsFeed.Subscribe(ch)
+}
+
+// trimPeers disconnects the most recently connected peers exceeding the limits,
+// apart from the trusted ones.
+func (srv *Server) trimPeers(peers map[enode.ID]*Peer, limits PeerLimits) {
+	var (
+		candidates []*Peer
+		inbound    int
+	)
+	for _, p := range peers {
+		if p.Inbound() {
+			inbound++
+		}
+		if !p.rw.is(trustedConn) {
+			candidates = append(candidates, p)
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].created > candidates[j].created
+	})
+	var (
+		total       = len(peers)
+		maxInbound  = srv.maxInboundConns(limits)
+		maxOutbound = srv.maxDialedConns(limits)
+	)
+	for _, p := range candidates {
+		var drop bool
+		switch {
+		case total > limits.MaxPeers:
+			drop = true
+		case p.Inbound() && inbound > maxInbound:
+			drop = true
+		case !p.Inbound() && total-inbound > maxOutbound:
+			drop = true
+		}
+		if !drop {
+			continue
+		}
+		p.log.Debug("Disconnecting peer exceeding limits")
+		p.Disconnect(DiscTooManyPeers)
+		if p.Inbound() {
+			inbound--
+		}
+		total--
+	}
+}
+
+// subnetFull reports whetxer the subnet of the connection already holds the
+// maximum number of peers. LAN addresses are exempt from the limit.
+func (srv *Server) subnetFull(peers map[enode.ID]*Peer, limits PeerLimits, c *conn) bool {
+	if limits.MaxPeersPerSubnet == 0 {
+		return false
+	}
+	ip := connIP(c)
+	if ip == nil || netutil.IsLAN(ip) {
+		return false
+	}
+	subnet := subnetOf(ip)
+	count := 0
+	for _, p := range peers {
+		if pip := connIP(p.rw); pip != nil && subnet.Contains(pip) {
+			count++
+		}
+	}
+	return count >= limits.MaxPeersPerSubnet
+}
+
+// reservedFull reports whetxer admitting the connection would occupy slots
+// reserved for the peers of protocols it doesn't support.
+func (srv *Server) reservedFull(peers map[enode.ID]*Peer, limits PeerLimits, c *conn) bool {
+	free := 0
+	for name, slots := range limits.ReservedSlots {
+		if hasCap(c.caps, name) {
+			continue
+		}
+		used := 0
+		for _, p := range peers {
+			if hasCap(p.rw.caps, name) {
+				used++
+			}
+		}
+		if used < slots {
+			free += slots - used
+		}
+	}
+	return len(peers)+free >= limits.MaxPeers
+}
+
+// hasCap reports whetxer the capabilities contain the named protocol.
+func hasCap(caps []Cap, name string) bool {
+	for _, cap := range caps {
+		if cap.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// connIP returns the remote IP address of a connection, if it's a TCP one.
+func connIP(c *conn) net.IP {
+	if addr, ok := c.fd.RemoteAddr().(*net.TCPAddr); ok {
+		return addr.IP
+	}
+	return nil
+}
+
+// subnetOf returns the /24 subnet of an IPv4 address or the /64 subnet of an
+// IPv6 one.
+func subnetOf(ip net.IP) *net.IPNet {
+	if ip4 := ip.To4(); ip4 != nil {
+		mask := net.CIDRMask(24, 32)
+		return &net.IPNet{IP: ip4.Mask(mask), Mask: mask}
+	}
+	mask := net.CIDRMask(64, 128)
+	return &net.IPNet{IP: ip.Mask(mask), Mask: mask}
+}
@@ -114,6 +114,9 @@ type Peer struct {
 	closed   chan struct{}
 	disc     chan DiscReason
 
+	// reputation receives the protocol events of the peer if set
+	reputation *reputation
+
 	// events receives message send / receive events if set
 	events   *event.Feed
 	testPipe *MsgPipeRW // for testing
@@ -238,6 +241,16 @@ func newPeer(log log.Logger, conn *conn, protocols []Protocol) *Peer {
 	return p
 }
 
+// Report applies a protocol event to the reputation of the peer. Peers whose
+// reputation falls below the ban threshold are banned and disconnected, unless
+// they are trusted.
+func (p *Peer) Report(event PeerScoreEvent) {
+	if p.reputation.report(p.ID(), event) && !p.rw.is(trustedConn) {
+		p.log.Debug("Disconnecting banned peer", "event", event)
+		p.Disconnect(DiscUselessPeer)
+	}
+}
+
 func (p *Peer) Log() log.Logger {
 	return p.log
 }
@@ -451,6 +464,10 @@ func (rw *protoRW) WriteMsg(msg Msg) (err error) {
 	}
 	msg.meterCap = rw.cap()
 	msg.meterCode = msg.Code
+	msg.stream = rw.offset << 8
+	if rw.Stream != nil {
+		msg.stream |= uint64(rw.Stream(msg.Code))
+	}
 
 	msg.Code += rw.offset
 
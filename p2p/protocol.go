@@ -61,6 +61,12 @@ type Protocol struct {
 
 	// Attributes contains protocol specific information for the node record.
 	Attributes []enr.Entry
+
+	// Stream optionally assigns the messages of the protocol to independent
+	// streams if the connection uses the multiplexing transport, so that large
+	// messages don't delay small ones on other streams. Message order is only
+	// preserved within a stream. If nil, all messages use a single stream.
+	Stream func(code uint64) uint8
 }
 
 func (p Protocol) cap() Cap {
@@ -0,0 +1,279 @@
+// Copyright 2022 The go-ETX Authors
+// This file is part of the go-ETX library.
+//
+// The go-ETX library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ETX library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ETX library. If not, see <http://www.gnu.org/licenses/>.
+
+package p2p
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ETX/go-ETX/log"
+	"github.com/ETX/go-ETX/p2p/enode"
+)
+
+const (
+	// reputationHalfLife is the time after which a score decays to half of its
+	// value, letting nodes recover from past misbehavior and forgetting past
+	// merits.
+	reputationHalfLife = 6 * time.Hour
+
+	// reputationMax and reputationMin bound the score of a node, so neither a
+	// long history of good service nor of misbehavior dominates forever.
+	reputationMax = 1000
+	reputationMin = -1000
+
+	// reputationDialThreshold is the score below which a node is not dialed
+	// anymore, though still accepted if it connects by itself.
+	reputationDialThreshold = -20
+
+	// reputationBanThreshold is the score below which a node is banned, i.e.
+	// neither dialed nor accepted for a while.
+	reputationBanThreshold = -100
+
+	// reputationBanDuration is the duration of a ban.
+	reputationBanDuration = time.Hour
+
+	// reputationFlushInterval is the interval at which changed scores are
+	// written to the node database.
+	reputationFlushInterval = time.Minute
+)
+
+// PeerScoreEvent is a protocol event affecting the reputation of a peer.
+type PeerScoreEvent int
+
+const (
+	PeerGoodResponse    PeerScoreEvent = iota // Request served with useful data
+	PeerUselessResponse                       // Request served with empty or useless data
+	PeerTimeout                               // Request not served in time
+	PeerMisbehaved                            // Protocol violation, e.g. invalid data
+	PeerInvalidBlock                          // Invalid block propagated
+)
+
+// peerEventScores are the score changes caused by the protocol events.
+var peerEventScores = map[PeerScoreEvent]int64{
+	PeerGoodResponse:    1,
+	PeerUselessResponse: -2,
+	PeerTimeout:         -5,
+	PeerMisbehaved:      -50,
+	PeerInvalidBlock:    -100,
+}
+
+func (e PeerScoreEvent) String() string {
+	switch e {
+	case PeerGoodResponse:
+		return "good response"
+	case PeerUselessResponse:
+		return "useless response"
+	case PeerTimeout:
+		return "timeout"
+	case PeerMisbehaved:
+		return "misbehaved"
+	case PeerInvalidBlock:
+		return "invalid block"
+	default:
+		return fmt.Sprintf("unknown event %d", int(e))
+	}
+}
+
+// PeerReputation is the reputation of a remote node.
+type PeerReputation struct {
+	ID     enode.ID   `json:"id"`
+	Score  int64      `json:"score"`
+	Banned *time.Time `json:"banned,omitempty"` // Time until which the node is banned
+}
+
+// reputation tracks the reputation of the remote nodes. The scores are kept in
+// memory and written back to the node database periodically, so reporting
+// protocol events doesn't hit the disk.
+type reputation struct {
+	db  *enode.DB
+	now func() time.Time
+
+	lock  sync.Mutex
+	reps  map[enode.ID]enode.Reputation // Known reputations, decayed lazily
+	dirty map[enode.ID]struct{}         // Reputations changed since the last flush
+
+	flushLock sync.Mutex // Serializes the database writes
+	closed    bool       // Set after the final flush, guarded by flushLock
+}
+
+func newReputation(db *enode.DB) *reputation {
+	return &reputation{
+		db:    db,
+		now:   time.Now,
+		reps:  db.Reputations(),
+		dirty: make(map[enode.ID]struct{}),
+	}
+}
+
+// decayed returns the score of a reputation at the given time.
+func decayed(rep enode.Reputation, now time.Time) int64 {
+	elapsed := now.Sub(rep.Updated)
+	if elapsed <= 0 || rep.Score == 0 {
+		return rep.Score
+	}
+	factor := math.Pow(0.5, float64(elapsed)/float64(reputationHalfLife))
+	return int64(math.Trunc(float64(rep.Score) * factor))
+}
+
+// report applies a protocol event to the score of a node, returning whetxer
+// the node got banned by it.
+func (r *reputation) report(id enode.ID, event PeerScoreEvent) bool {
+	if r == nil {
+		return false
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	var (
+		now   = r.now()
+		rep   = r.reps[id]
+		score = decayed(rep, now) + peerEventScores[event]
+	)
+	if score > reputationMax {
+		score = reputationMax
+	}
+	if score < reputationMin {
+		score = reputationMin
+	}
+	rep.Score, rep.Updated = score, now
+
+	banned := score < reputationBanThreshold && !rep.Banned.After(now)
+	if banned {
+		rep.Banned = now.Add(reputationBanDuration)
+		log.Debug("Banning peer", "id", id, "score", score, "event", event, "until", rep.Banned)
+	}
+	r.reps[id] = rep
+	r.dirty[id] = struct{}{}
+	return banned
+}
+
+// banned reports whetxer a node is currently banned.
+func (r *reputation) banned(id enode.ID) bool {
+	if r == nil {
+		return false
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	return r.reps[id].Banned.After(r.now())
+}
+
+// dialable reports whetxer a node's reputation permits dialing it.
+func (r *reputation) dialable(id enode.ID) bool {
+	if r == nil {
+		return true
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	now := r.now()
+	rep := r.reps[id]
+	return !rep.Banned.After(now) && decayed(rep, now) >= reputationDialThreshold
+}
+
+// scores returns the current reputation of all the known nodes, ordered by
+// ascending score.
+func (r *reputation) scores() []*PeerReputation {
+	if r == nil {
+		return nil
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	var (
+		now  = r.now()
+		reps []*PeerReputation
+	)
+	for id, rep := range r.reps {
+		entry := &PeerReputation{ID: id, Score: decayed(rep, now)}
+		if rep.Banned.After(now) {
+			banned := rep.Banned
+			entry.Banned = &banned
+		}
+		reps = append(reps, entry)
+	}
+	sort.Slice(reps, func(i, j int) bool {
+		if reps[i].Score != reps[j].Score {
+			return reps[i].Score < reps[j].Score
+		}
+		return reps[i].ID.String() < reps[j].ID.String()
+	})
+	return reps
+}
+
+// flush writes the changed reputations to the node database. Reputations which
+// decayed to zero are dropped from memory afterwards.
+func (r *reputation) flush() {
+	r.flushLock.Lock()
+	defer r.flushLock.Unlock()
+
+	if r.closed {
+		return
+	}
+	r.lock.Lock()
+	var (
+		now     = r.now()
+		changed = make(map[enode.ID]enode.Reputation, len(r.dirty))
+	)
+	for id := range r.dirty {
+		changed[id] = r.reps[id]
+	}
+	r.dirty = make(map[enode.ID]struct{})
+	for id, rep := range r.reps {
+		if _, ok := changed[id]; !ok && !rep.Banned.After(now) && decayed(rep, now) == 0 {
+			delete(r.reps, id)
+		}
+	}
+	r.lock.Unlock()
+
+	for id, rep := range changed {
+		if err := r.db.UpdateReputation(id, rep); err != nil {
+			log.Warn("Failed to store peer reputation", "id", id, "err", err)
+		}
+	}
+}
+
+// flushLoop flushes the changed reputations periodically until quit is closed.
+func (r *reputation) flushLoop(quit <-chan struct{}) {
+	flush := time.NewTicker(reputationFlushInterval)
+	defer flush.Stop()
+
+	for {
+		select {
+		case <-flush.C:
+			r.flush()
+		case <-quit:
+			return
+		}
+	}
+}
+
+// close flushes the changed reputations a final time. The node database may be
+// closed afterwards.
+func (r *reputation) close() {
+	if r == nil {
+		return
+	}
+	r.flush()
+
+	r.flushLock.Lock()
+	r.closed = true
+	r.flushLock.Unlock()
+}
@@ -0,0 +1,98 @@
+// Copyright 2022 The go-ETX Authors
+// This file is part of the go-ETX library.
+//
+// The go-ETX library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ETX library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ETX library. If not, see <http://www.gnu.org/licenses/>.
+
+package p2p
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ETX/go-ETX/p2p/enode"
+)
+
+func TestReputation(t *testing.T) {
+	dir := t.TempDir()
+	db, err := enode.OpenDB(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		now  = time.Unix(1000000, 0)
+		rep  = newReputation(db)
+		good = enode.ID{1}
+		bad  = enode.ID{2}
+	)
+	rep.now = func() time.Time { return now }
+
+	for i := 0; i < 10; i++ {
+		rep.report(good, PeerGoodResponse)
+	}
+	if rep.report(bad, PeerMisbehaved) {
+		t.Fatal("peer banned after a single misbehavior")
+	}
+	if rep.dialable(bad) {
+		t.Fatal("misbehaving peer still dialable")
+	}
+	if !rep.dialable(good) || !rep.dialable(enode.ID{3}) {
+		t.Fatal("good or unknown peer not dialable")
+	}
+	// Invalid blocks on top of the misbehavior should ban the peer
+	if !rep.report(bad, PeerInvalidBlock) {
+		t.Fatal("peer not banned")
+	}
+	if !rep.banned(bad) || rep.banned(good) {
+		t.Fatal("ban state mismatch")
+	}
+	scores := rep.scores()
+	if len(scores) != 2 || scores[0].ID != bad || scores[0].Banned == nil || scores[1].Score != 10 {
+		t.Fatalf("unexpected scores: %+v %+v", scores[0], scores[1])
+	}
+	// The ban expires after a while, and the score decays eventually
+	now = now.Add(reputationBanDuration + time.Second)
+	if rep.banned(bad) {
+		t.Fatal("ban not expired")
+	}
+	if rep.dialable(bad) {
+		t.Fatal("peer dialable before its score decayed")
+	}
+	now = now.Add(4 * reputationHalfLife)
+	if !rep.dialable(bad) {
+		t.Fatal("peer not dialable after its score decayed")
+	}
+	// Scores are only written to the node database when flushed
+	if db.Reputation(bad).Score != 0 {
+		t.Fatal("reputation written before flush")
+	}
+	rep.close()
+	rep.report(good, PeerGoodResponse) // must not be written after close
+	db.Close()
+
+	// The reputation must survive reopening the node database
+	if db, err = enode.OpenDB(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	reopened := newReputation(db)
+	reopened.now = rep.now
+	scores = reopened.scores()
+	if len(scores) != 2 || scores[0].ID != bad || scores[0].Score >= 0 {
+		t.Fatalf("reputation not persisted: %+v", scores)
+	}
+	if stored := db.Reputation(good); stored.Score != 10 {
+		t.Fatalf("wrong stored score %d for good peer, want 10", stored.Score)
+	}
+}
@@ -85,6 +85,19 @@ type Config struct {
 	// Setting DialRatio to zero defaults it to 3.
 	DialRatio int `toml:",omitempty"`
 
+	// MaxInboundPeers and MaxOutboundPeers limit the number of inbound and
+	// dialed connections separately. Zero derives them from MaxPeers and DialRatio.
+	MaxInboundPeers  int `toml:",omitempty"`
+	MaxOutboundPeers int `toml:",omitempty"`
+
+	// ReservedSlots reserves connection slots for peers supporting the given
+	// protocols, e.g. "snap". Other peers can't occupy these slots.
+	ReservedSlots map[string]int `toml:",omitempty"`
+
+	// MaxPeersPerSubnet limits the number of peers from the same /24 (IPv4) or
+	// /64 (IPv6) subnet. LAN and trusted peers are exempt. Zero disables the limit.
+	MaxPeersPerSubnet int `toml:",omitempty"`
+
 	// NoDiscovery can be used to disable the peer discovery mechanism.
 	// Disabling is useful for protocol debugging (manual topology).
 	NoDiscovery bool
@@ -93,6 +106,16 @@ type Config struct {
 	// protocol should be started or not.
 	DiscoveryV5 bool `toml:",omitempty"`
 
+	// DiscoveryV5Topics are the discovery v5 topics the node advertises itself
+	// under. Nodes registered under them are also used as dial candidates.
+	DiscoveryV5Topics []string `toml:",omitempty"`
+
+	// MuxTransport enables the experimental multiplexing transport, which
+	// carries the messages of independent streams over a single connection
+	// without head-of-line blocking between them. It is advertised in the node
+	// record and only used with peers supporting it, others use plain RLPx.
+	MuxTransport bool `toml:",omitempty"`
+
 	// Name sets the node name of this server.
 	// Use common.MakeName to create a name that follows existing conventions.
 	Name string `toml:"-"`
@@ -182,12 +205,13 @@ type Server struct {
 	peerFeed     event.Feed
 	log          log.Logger
 
-	nodedb    *enode.DB
-	localnode *enode.LocalNode
-	ntab      *discover.UDPv4
-	DiscV5    *discover.UDPv5
-	discmix   *enode.FairMix
-	dialsched *dialScheduler
+	nodedb     *enode.DB
+	reputation *reputation
+	localnode  *enode.LocalNode
+	ntab       *discover.UDPv4
+	DiscV5     *discover.UDPv5
+	discmix    *enode.FairMix
+	dialsched  *dialScheduler
 
 	// Channels into the run loop.
 	quit                    chan struct{}
@@ -201,6 +225,11 @@ type Server struct {
 
 	// State of run loop and listenLoop.
 	inboundHistory expHeap
+
+	// Connection limits, adjustable at runtime.
+	limits     PeerLimits
+	limitsLock sync.RWMutex
+	limitsFeed event.Feed
 }
 
 type peerOpFunc func(map[enode.ID]*Peer)
@@ -461,10 +490,21 @@ func (srv *Server) Start() (err error) {
 	}
 	if srv.newTransport == nil {
 		srv.newTransport = newRLPX
+		if srv.MuxTransport {
+			srv.newTransport = newMuxTransport
+		}
 	}
 	if srv.listenFunc == nil {
 		srv.listenFunc = net.Listen
 	}
+	limits := srv.configPeerLimits()
+	if err := limits.validate(); err != nil {
+		return err
+	}
+	srv.limitsLock.Lock()
+	srv.limits = limits
+	srv.limitsLock.Unlock()
+
 	srv.quit = make(chan struct{})
 	srv.delpeer = make(chan peerDrop)
 	srv.checkpointPostHandshake = make(chan *conn)
@@ -487,8 +527,12 @@ func (srv *Server) Start() (err error) {
 	}
 	srv.setupDialScheduler()
 
-	srv.loopWG.Add(1)
+	srv.loopWG.Add(2)
 	go srv.run()
+	go func() {
+		defer srv.loopWG.Done()
+		srv.reputation.flushLoop(srv.quit)
+	}()
 	return nil
 }
 
@@ -499,6 +543,9 @@ func (srv *Server) setupLocalNode() error {
 	for _, p := range srv.Protocols {
 		srv.ourHandshake.Caps = append(srv.ourHandshake.Caps, p.cap())
 	}
+	if srv.MuxTransport {
+		srv.ourHandshake.Caps = append(srv.ourHandshake.Caps, muxCap)
+	}
 	sort.Sort(capsByNameAndVersion(srv.ourHandshake.Caps))
 
 	// Create the local node.
@@ -507,6 +554,7 @@ func (srv *Server) setupLocalNode() error {
 		return err
 	}
 	srv.nodedb = db
+	srv.reputation = newReputation(db)
 	srv.localnode = enode.NewLocalNode(db, srv.PrivateKey)
 	srv.localnode.SetFallbackIP(net.IP{127, 0, 0, 1})
 	// TODO: check conflicts
@@ -515,6 +563,9 @@ func (srv *Server) setupLocalNode() error {
 			srv.localnode.Set(e)
 		}
 	}
+	if srv.MuxTransport {
+		srv.localnode.Set(muxEntry(muxVersion))
+	}
 	switch srv.NAT.(type) {
 	case nil:
 		// No NAT interface, do nothing.
@@ -622,6 +673,11 @@ func (srv *Server) setupDiscovery() error {
 		if err != nil {
 			return err
 		}
+		for _, name := range srv.DiscoveryV5Topics {
+			topic := discover.NewTopic(name)
+			srv.DiscV5.RegisterTopic(topic)
+			srv.discmix.AddSource(srv.DiscV5.TopicNodes(topic))
+		}
 	}
 	return nil
 }
@@ -629,11 +685,12 @@ func (srv *Server) setupDiscovery() error {
 func (srv *Server) setupDialScheduler() {
 	config := dialConfig{
 		self:           srv.localnode.ID(),
-		maxDialPeers:   srv.maxDialedConns(),
+		maxDialPeers:   srv.maxDialedConns(srv.PeerLimits()),
 		maxActiveDials: srv.MaxPendingPeers,
 		log:            srv.Logger,
 		netRestrict:    srv.NetRestrict,
 		dialer:         srv.Dialer,
+		reputable:      srv.reputation.dialable,
 		clock:          srv.clock,
 	}
 	if srv.ntab != nil {
@@ -648,18 +705,30 @@ func (srv *Server) setupDialScheduler() {
 	}
 }
 
-func (srv *Server) maxInboundConns() int {
-	return srv.MaxPeers - srv.maxDialedConns()
+func (srv *Server) maxInboundConns(limits PeerLimits) int {
+	if limits.MaxInbound > 0 {
+		if limits.MaxInbound < limits.MaxPeers {
+			return limits.MaxInbound
+		}
+		return limits.MaxPeers
+	}
+	return limits.MaxPeers - srv.maxDialedConns(limits)
 }
 
-func (srv *Server) maxDialedConns() (limit int) {
-	if srv.NoDial || srv.MaxPeers == 0 {
+func (srv *Server) maxDialedConns(limits PeerLimits) (limit int) {
+	if srv.NoDial || limits.MaxPeers == 0 {
 		return 0
 	}
+	if limits.MaxOutbound > 0 {
+		if limits.MaxOutbound < limits.MaxPeers {
+			return limits.MaxOutbound
+		}
+		return limits.MaxPeers
+	}
 	if srv.DialRatio == 0 {
-		limit = srv.MaxPeers / defaultDialRatio
+		limit = limits.MaxPeers / defaultDialRatio
 	} else {
-		limit = srv.MaxPeers / srv.DialRatio
+		limit = limits.MaxPeers / srv.DialRatio
 	}
 	if limit == 0 {
 		limit = 1
@@ -707,6 +776,7 @@ func (srv *Server) run() {
 	srv.log.Info("Started P2P networking", "self", srv.localnode.Node().URLv4())
 	defer srv.loopWG.Done()
 	defer srv.nodedb.Close()
+	defer srv.reputation.close()
 	defer srv.discmix.Close()
 	defer srv.dialsched.stop()
 
@@ -813,15 +883,20 @@ running:
 }
 
 func (srv *Server) postHandshakeChecks(peers map[enode.ID]*Peer, inboundCount int, c *conn) error {
+	limits := srv.PeerLimits()
 	switch {
-	case !c.is(trustedConn) && len(peers) >= srv.MaxPeers:
+	case !c.is(trustedConn) && len(peers) >= limits.MaxPeers:
+		return DiscTooManyPeers
+	case !c.is(trustedConn) && c.is(inboundConn) && inboundCount >= srv.maxInboundConns(limits):
 		return DiscTooManyPeers
-	case !c.is(trustedConn) && c.is(inboundConn) && inboundCount >= srv.maxInboundConns():
+	case !c.is(trustedConn) && srv.subnetFull(peers, limits, c):
 		return DiscTooManyPeers
 	case peers[c.node.ID()] != nil:
 		return DiscAlreadyConnected
 	case c.node.ID() == srv.localnode.ID():
 		return DiscSelf
+	case !c.is(trustedConn) && c.is(inboundConn) && srv.reputation.banned(c.node.ID()):
+		return DiscUselessPeer
 	default:
 		return nil
 	}
@@ -834,7 +909,15 @@ func (srv *Server) addPeerChecks(peers map[enode.ID]*Peer, inboundCount int, c *
 	}
 	// Repeat the post-handshake checks because the
 	// peer set might have changed since those checks were performed.
-	return srv.postHandshakeChecks(peers, inboundCount, c)
+	if err := srv.postHandshakeChecks(peers, inboundCount, c); err != nil {
+		return err
+	}
+	// The capabilities are known now, keep the reserved slots free for
+	// the peers of their protocols.
+	if !c.is(trustedConn) && srv.reservedFull(peers, srv.PeerLimits(), c) {
+		return DiscTooManyPeers
+	}
+	return nil
 }
 
 // listenLoop runs in its own goroutine and accepts
@@ -1025,6 +1108,7 @@ func (srv *Server) checkpoint(c *conn, stage chan<- *conn) error {
 
 func (srv *Server) launchPeer(c *conn) *Peer {
 	p := newPeer(srv.log, c, srv.Protocols)
+	p.reputation = srv.reputation
 	if srv.EnableMsgEvents {
 		// If message events are enabled, pass the peerFeed
 		// to the peer.
@@ -1111,6 +1195,12 @@ func (srv *Server) NodeInfo() *NodeInfo {
 	return info
 }
 
+// PeerScores returns the reputation of all the known remote nodes, ordered by
+// ascending score.
+func (srv *Server) PeerScores() []*PeerReputation {
+	return srv.reputation.scores()
+}
+
 // PeersInfo returns an array of metadata objects describing connected peers.
 func (srv *Server) PeersInfo() []*PeerInfo {
 	// Gather all the generic and sub-protocol specific infos
@@ -300,6 +300,62 @@ func TestServerAtCap(t *testing.T) {
 	}
 }
 
+// Tests that inbound connections from banned peers are rejected, unless the peer
+// is trusted or dialed by us, and that the scores are persisted on shutdown.
+func TestServerBannedInbound(t *testing.T) {
+	trustedNode := newkey()
+	trustedID := enode.PubkeyToIDV4(&trustedNode.PublicKey)
+	dbpath := t.TempDir()
+	srv := &Server{
+		Config: Config{
+			PrivateKey:   newkey(),
+			MaxPeers:     10,
+			NoDial:       true,
+			NoDiscovery:  true,
+			NodeDatabase: dbpath,
+			TrustedNodes: []*enode.Node{newNode(trustedID, "")},
+			Logger:       testlog.Logger(t, log.LvlTrace),
+		},
+	}
+	if err := srv.Start(); err != nil {
+		t.Fatalf("could not start: %v", err)
+	}
+	newconn := func(id enode.ID, flags connFlag) *conn {
+		fd, _ := net.Pipe()
+		tx := newTestTransport(&trustedNode.PublicKey, fd, nil)
+		node := enode.SignNull(new(enr.Record), id)
+		return &conn{fd: fd, transport: tx, flags: flags, node: node, cont: make(chan error)}
+	}
+	bannedID := randomID()
+	for _, id := range []enode.ID{bannedID, trustedID} {
+		srv.reputation.report(id, PeerMisbehaved)
+		srv.reputation.report(id, PeerInvalidBlock)
+	}
+	if err := srv.checkpoint(newconn(bannedID, inboundConn), srv.checkpointPostHandshake); err != DiscUselessPeer {
+		t.Error("wrong error for banned inbound conn:", err)
+	}
+	if err := srv.checkpoint(newconn(bannedID, dynDialedConn), srv.checkpointPostHandshake); err != nil {
+		t.Error("unexpected error for banned outbound conn:", err)
+	}
+	if err := srv.checkpoint(newconn(trustedID, inboundConn), srv.checkpointPostHandshake); err != nil {
+		t.Error("unexpected error for banned trusted conn:", err)
+	}
+	if err := srv.checkpoint(newconn(randomID(), inboundConn), srv.checkpointPostHandshake); err != nil {
+		t.Error("unexpected error for unknown inbound conn:", err)
+	}
+	srv.Stop()
+
+	// The ban must have been flushed to the node database on shutdown.
+	db, err := enode.OpenDB(dbpath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if rep := db.Reputation(bannedID); rep.Banned.IsZero() {
+		t.Error("ban not persisted on shutdown")
+	}
+}
+
 func TestServerPeerLimits(t *testing.T) {
 	srvkey := newkey()
 	clientkey := newkey()
@@ -365,6 +421,89 @@ func TestServerPeerLimits(t *testing.T) {
 	conn.Close()
 }
 
+// Tests that the peer limits can be changed while the server is running.
+func TestServerSetPeerLimits(t *testing.T) {
+	srvkey := newkey()
+	clientkey := newkey()
+	clientnode := enode.NewV4(&clientkey.PublicKey, nil, 0, 0)
+
+	var tp = &setupTransport{
+		pubkey: &clientkey.PublicKey,
+		phs: protoHandshake{
+			ID: crypto.FromECDSAPub(&clientkey.PublicKey)[1:],
+		},
+	}
+	srv := &Server{
+		Config: Config{
+			PrivateKey:  srvkey,
+			MaxPeers:    0,
+			NoDial:      true,
+			NoDiscovery: true,
+			Protocols:   []Protocol{discard},
+			Logger:      testlog.Logger(t, log.LvlTrace),
+		},
+		newTransport: func(fd net.Conn, dialDest *ecdsa.PublicKey) transport { return tp },
+	}
+	if err := srv.Start(); err != nil {
+		t.Fatalf("couldn't start server: %v", err)
+	}
+	defer srv.Stop()
+
+	conn, _ := net.Pipe()
+	srv.SetupConn(conn, dynDialedConn, clientnode)
+	if tp.closeErr != DiscTooManyPeers {
+		t.Errorf("unexpected close error: %q", tp.closeErr)
+	}
+	conn.Close()
+
+	// Invalid limits must be rejected
+	if err := srv.SetPeerLimits(PeerLimits{MaxPeers: 1, ReservedSlots: map[string]int{"snap": 2}}); err == nil {
+		t.Fatal("reserved slots exceeding the peer limit accepted")
+	}
+	// Raise the limit, the peer must pass the slot checks now
+	if err := srv.SetPeerLimits(PeerLimits{MaxPeers: 10}); err != nil {
+		t.Fatalf("failed to set peer limits: %v", err)
+	}
+	if limits := srv.PeerLimits(); limits.MaxPeers != 10 {
+		t.Fatalf("peer limit mismatch: have %d, want 10", limits.MaxPeers)
+	}
+	conn, _ = net.Pipe()
+	srv.SetupConn(conn, dynDialedConn, clientnode)
+	if tp.closeErr != DiscUselessPeer {
+		t.Errorf("unexpected close error: %q", tp.closeErr)
+	}
+	conn.Close()
+
+	// Reserve all the slots for another protocol, the peer must be rejected
+	tp.phs.Caps = []Cap{discard.cap()}
+	if err := srv.SetPeerLimits(PeerLimits{MaxPeers: 10, ReservedSlots: map[string]int{"snap": 10}}); err != nil {
+		t.Fatalf("failed to set peer limits: %v", err)
+	}
+	conn, _ = net.Pipe()
+	srv.SetupConn(conn, dynDialedConn, clientnode)
+	if tp.closeErr != DiscTooManyPeers {
+		t.Errorf("unexpected close error: %q", tp.closeErr)
+	}
+	conn.Close()
+}
+
+func TestSubnetOf(t *testing.T) {
+	tests := []struct {
+		ip, other string
+		same      bool
+	}{
+		{"1.2.3.4", "1.2.3.200", true},
+		{"1.2.3.4", "1.2.4.4", false},
+		{"2001:db8::1", "2001:db8::ffff:1", true},
+		{"2001:db8::1", "2001:db8:0:1::1", false},
+	}
+	for _, test := range tests {
+		if have := subnetOf(net.ParseIP(test.ip)).Contains(net.ParseIP(test.other)); have != test.same {
+			t.Errorf("%s vs %s: have %v, want %v", test.ip, test.other, have, test.same)
+		}
+	}
+}
+
 func TestServerSetupConn(t *testing.T) {
 	var (
 		clientkey, srvkey = newkey(), newkey()
@@ -0,0 +1,372 @@
+// Copyright 2022 The go-ETX Authors
+// This file is part of the go-ETX library.
+//
+// The go-ETX library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ETX library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ETX library. If not, see <http://www.gnu.org/licenses/>.
+
+package p2p
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/ETX/go-ETX/common"
+	"github.com/ETX/go-ETX/metrics"
+)
+
+const (
+	// muxVersion is the version of the multiplexing transport announced in the
+	// protocol handshake and the node record.
+	muxVersion = 1
+
+	// muxCapName is the name of the capability announcing the multiplexing
+	// transport in the protocol handshake. It doesn't match any subprotocol, so
+	// peers unaware of it ignore the capability.
+	muxCapName = "mux"
+
+	// muxFrameMsg is the base protocol message code carrying the frames of the
+	// multiplexed streams.
+	muxFrameMsg = 0x0f
+
+	muxChunkSize   = 16 * 1024                   // Maximum payload of a single frame
+	muxMaxMsgSize  = 16 * 1024 * 1024            // Maximum size of a reassembled message
+	muxMaxPartial  = 64                          // Maximum number of partially received messages
+	muxMaxBuffered = 2 * muxMaxMsgSize           // Maximum total size of the partially received messages
+	muxQueueLimit  = 32 * 1024 * 1024            // Queued outgoing bytes before writes block
+	muxFirstChunk  = 0x01                        // Frame flag of the first chunk of a message
+	muxFrameHeader = 3*binary.MaxVarintLen64 + 1 // Maximum size of a frame header
+)
+
+// muxCap is the capability announcing the multiplexing transport.
+var muxCap = Cap{Name: muxCapName, Version: muxVersion}
+
+var (
+	errMuxUnexpectedChunk = errors.New("unexpected mux chunk")
+	errMuxTooLarge        = errors.New("mux message too large")
+	errMuxTooManyStreams  = errors.New("too many partial mux messages")
+	errMuxBufferFull      = errors.New("too much partial mux data")
+	errMuxClosed          = errors.New("mux transport closed")
+)
+
+// muxEntry is the node record entry advertising support of the multiplexing
+// transport.
+type muxEntry uint
+
+func (muxEntry) ENRKey() string { return "mux" }
+
+// muxTransport is an experimental transport multiplexing independent message
+// streams over a single RLPx connection. Messages are split into chunks, which
+// are interleaved between the streams, so large messages (e.g. block bodies or
+// snap responses) don't delay small latency sensitive ones on other streams.
+//
+// Multiplexing is only used if both sides announce the mux capability in the
+// protocol handshake. Otherwise the transport falls back to plain RLPx.
+type muxTransport struct {
+	*rlpxTransport
+	enabled bool // Set during the protocol handshake, before concurrent use
+
+	// Read side, guarded by rmu of the RLPx transport.
+	partial  map[uint64]*muxPartial
+	buffered int // Total size of the partially received messages
+
+	// Write side, queued messages are written out by writeLoop.
+	lock    sync.Mutex
+	cond    *sync.Cond
+	streams map[uint64]*muxStream
+	ready   []*muxStream // Streams with queued messages, in round-robin order
+	queued  int          // Total size of the queued messages
+	err     error        // Write error or errMuxClosed, terminates the transport
+}
+
+// muxStream is the outgoing queue of a stream.
+type muxStream struct {
+	id    uint64
+	queue []*muxMsg
+}
+
+// muxMsg is a queued outgoing message.
+type muxMsg struct {
+	code      uint64
+	data      []byte
+	sent      int
+	meterCap  Cap
+	meterCode uint64
+}
+
+// muxPartial is a partially received message.
+type muxPartial struct {
+	code     uint64
+	size     int
+	data     []byte
+	wireSize int
+}
+
+func newMuxTransport(conn net.Conn, dialDest *ecdsa.PublicKey) transport {
+	t := &muxTransport{
+		rlpxTransport: newRLPX(conn, dialDest).(*rlpxTransport),
+		streams:       make(map[uint64]*muxStream),
+	}
+	t.cond = sync.NewCond(&t.lock)
+	return t
+}
+
+func (t *muxTransport) doProtoHandshake(our *protoHandshake) (*protoHandshake, error) {
+	their, err := t.rlpxTransport.doProtoHandshake(our)
+	if err != nil {
+		return nil, err
+	}
+	if hasMuxCap(our.Caps) && hasMuxCap(their.Caps) {
+		t.enabled = true
+		t.partial = make(map[uint64]*muxPartial)
+		go t.writeLoop()
+	}
+	return their, nil
+}
+
+// hasMuxCap reports whetxer the capabilities include the supported version of
+// the multiplexing transport.
+func hasMuxCap(caps []Cap) bool {
+	for _, cap := range caps {
+		if cap == muxCap {
+			return true
+		}
+	}
+	return false
+}
+
+func (t *muxTransport) ReadMsg() (Msg, error) {
+	if !t.enabled {
+		return t.rlpxTransport.ReadMsg()
+	}
+	t.rmu.Lock()
+	defer t.rmu.Unlock()
+
+	for {
+		t.conn.SetReadDeadline(time.Now().Add(frameReadTimeout))
+		code, data, wireSize, err := t.conn.Read()
+		if err != nil {
+			return Msg{}, err
+		}
+		// Base protocol messages, e.g. disconnects, may be sent outside of the streams.
+		if code != muxFrameMsg {
+			data = common.CopyBytes(data)
+			return Msg{
+				ReceivedAt: time.Now(),
+				Code:       code,
+				Size:       uint32(len(data)),
+				meterSize:  uint32(wireSize),
+				Payload:    bytes.NewReader(data),
+			}, nil
+		}
+		msg, done, err := t.readChunk(data, wireSize)
+		if err != nil {
+			return Msg{}, err
+		}
+		if done {
+			return msg, nil
+		}
+	}
+}
+
+// readChunk adds a received frame to the partial message of its stream,
+// returning the message if it's complete.
+func (t *muxTransport) readChunk(frame []byte, wireSize int) (Msg, bool, error) {
+	r := bytes.NewReader(frame)
+	stream, err := binary.ReadUvarint(r)
+	if err != nil {
+		return Msg{}, false, err
+	}
+	flags, err := r.ReadByte()
+	if err != nil {
+		return Msg{}, false, err
+	}
+	p := t.partial[stream]
+	if flags&muxFirstChunk != 0 {
+		if p != nil {
+			return Msg{}, false, errMuxUnexpectedChunk
+		}
+		if len(t.partial) >= muxMaxPartial {
+			return Msg{}, false, errMuxTooManyStreams
+		}
+		code, err := binary.ReadUvarint(r)
+		if err != nil {
+			return Msg{}, false, err
+		}
+		size, err := binary.ReadUvarint(r)
+		if err != nil {
+			return Msg{}, false, err
+		}
+		if size > muxMaxMsgSize {
+			return Msg{}, false, errMuxTooLarge
+		}
+		p = &muxPartial{code: code, size: int(size)}
+		t.partial[stream] = p
+	} else if p == nil {
+		return Msg{}, false, errMuxUnexpectedChunk
+	}
+	if len(p.data)+r.Len() > p.size {
+		return Msg{}, false, errMuxTooLarge
+	}
+	if t.buffered+r.Len() > muxMaxBuffered {
+		return Msg{}, false, errMuxBufferFull
+	}
+	t.buffered += r.Len()
+	p.data = append(p.data, frame[len(frame)-r.Len():]...)
+	p.wireSize += wireSize
+	if len(p.data) < p.size {
+		return Msg{}, false, nil
+	}
+	delete(t.partial, stream)
+	t.buffered -= len(p.data)
+	return Msg{
+		ReceivedAt: time.Now(),
+		Code:       p.code,
+		Size:       uint32(p.size),
+		meterSize:  uint32(p.wireSize),
+		Payload:    bytes.NewReader(p.data),
+	}, true, nil
+}
+
+func (t *muxTransport) WriteMsg(msg Msg) error {
+	if !t.enabled {
+		return t.rlpxTransport.WriteMsg(msg)
+	}
+	data := make([]byte, msg.Size)
+	if _, err := io.ReadFull(msg.Payload, data); err != nil {
+		return err
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	for t.err == nil && t.queued > 0 && t.queued+len(data) > muxQueueLimit {
+		t.cond.Wait()
+	}
+	if t.err != nil {
+		return t.err
+	}
+	s := t.streams[msg.stream]
+	if s == nil {
+		s = &muxStream{id: msg.stream}
+		t.streams[msg.stream] = s
+	}
+	if len(s.queue) == 0 {
+		t.ready = append(t.ready, s)
+	}
+	s.queue = append(s.queue, &muxMsg{code: msg.Code, data: data, meterCap: msg.meterCap, meterCode: msg.meterCode})
+	t.queued += len(data)
+	t.cond.Broadcast()
+	return nil
+}
+
+// writeLoop writes out the queued messages, interleaving the chunks of the
+// streams in round-robin order.
+func (t *muxTransport) writeLoop() {
+	var (
+		header [muxFrameHeader]byte
+		frame  []byte
+	)
+	for {
+		t.lock.Lock()
+		for t.err == nil && len(t.ready) == 0 {
+			t.cond.Wait()
+		}
+		if t.err != nil {
+			t.lock.Unlock()
+			return
+		}
+		s := t.ready[0]
+		t.ready = t.ready[1:]
+		msg := s.queue[0]
+		t.lock.Unlock()
+
+		// Encode and write the next chunk of the stream.
+		end := msg.sent + muxChunkSize
+		if end > len(msg.data) {
+			end = len(msg.data)
+		}
+		n := putMuxHeader(header[:], s.id, msg.sent == 0, msg.code, len(msg.data))
+		frame = append(append(frame[:0], header[:n]...), msg.data[msg.sent:end]...)
+
+		t.wmu.Lock()
+		t.conn.SetWriteDeadline(time.Now().Add(frameWriteTimeout))
+		size, err := t.conn.Write(muxFrameMsg, frame)
+		t.wmu.Unlock()
+
+		t.lock.Lock()
+		if err != nil {
+			t.fail(err)
+			t.lock.Unlock()
+			t.conn.Close()
+			return
+		}
+		msg.sent = end
+		if msg.sent == len(msg.data) {
+			s.queue = s.queue[1:]
+			t.queued -= len(msg.data)
+			t.cond.Broadcast()
+		}
+		if len(s.queue) > 0 {
+			t.ready = append(t.ready, s)
+		}
+		t.lock.Unlock()
+
+		if metrics.Enabled && msg.meterCap.Name != "" {
+			m := fmt.Sprintf("%s/%s/%d/%#02x", egressMeterName, msg.meterCap.Name, msg.meterCap.Version, msg.meterCode)
+			metrics.GetOrRegisterMeter(m, nil).Mark(int64(size))
+			if msg.sent == len(msg.data) {
+				metrics.GetOrRegisterMeter(m+"/packets", nil).Mark(1)
+			}
+		}
+	}
+}
+
+// fail terminates the write side, dropping all queued messages. The lock must
+// be held.
+func (t *muxTransport) fail(err error) {
+	if t.err == nil {
+		t.err = err
+	}
+	t.streams = make(map[uint64]*muxStream)
+	t.ready, t.queued = nil, 0
+	t.cond.Broadcast()
+}
+
+func (t *muxTransport) close(err error) {
+	t.lock.Lock()
+	t.fail(errMuxClosed)
+	t.lock.Unlock()
+
+	t.rlpxTransport.close(err)
+}
+
+// putMuxHeader encodes the header of a frame into buf, returning its size. The
+// message code and size are only included in the first chunk of a message.
+func putMuxHeader(buf []byte, stream uint64, first bool, code uint64, size int) int {
+	n := binary.PutUvarint(buf, stream)
+	if !first {
+		buf[n] = 0
+		return n + 1
+	}
+	buf[n] = muxFirstChunk
+	n++
+	n += binary.PutUvarint(buf[n:], code)
+	n += binary.PutUvarint(buf[n:], uint64(size))
+	return n
+}
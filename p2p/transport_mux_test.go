@@ -0,0 +1,139 @@
+// Copyright 2022 The go-ETX Authors
+// This file is part of the go-ETX library.
+//
+// The go-ETX library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ETX library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ETX library. If not, see <http://www.gnu.org/licenses/>.
+
+package p2p
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/ETX/go-ETX/crypto"
+)
+
+// muxPipe connects two multiplexing transports, announcing the given capabilities
+// in the protocol handshakes.
+func muxPipe(t *testing.T, caps0, caps1 []Cap) (*muxTransport, *muxTransport) {
+	var (
+		prv0, _  = crypto.GenerateKey()
+		prv1, _  = crypto.GenerateKey()
+		fd0, fd1 = net.Pipe()
+		t0       = newMuxTransport(fd0, &prv1.PublicKey).(*muxTransport)
+		t1       = newMuxTransport(fd1, nil).(*muxTransport)
+		errc     = make(chan error, 1)
+	)
+	go func() {
+		if _, err := t1.doEncHandshake(prv1); err != nil {
+			errc <- err
+			return
+		}
+		_, err := t1.doProtoHandshake(&protoHandshake{Version: baseProtocolVersion, ID: crypto.FromECDSAPub(&prv1.PublicKey)[1:], Caps: caps1})
+		errc <- err
+	}()
+	if _, err := t0.doEncHandshake(prv0); err != nil {
+		t.Fatalf("enc handshake failed: %v", err)
+	}
+	if _, err := t0.doProtoHandshake(&protoHandshake{Version: baseProtocolVersion, ID: crypto.FromECDSAPub(&prv0.PublicKey)[1:], Caps: caps0}); err != nil {
+		t.Fatalf("proto handshake failed: %v", err)
+	}
+	if err := <-errc; err != nil {
+		t.Fatalf("remote handshake failed: %v", err)
+	}
+	return t0, t1
+}
+
+func TestMuxTransport(t *testing.T) {
+	t0, t1 := muxPipe(t, []Cap{muxCap}, []Cap{muxCap})
+	defer t0.close(DiscNetworkError)
+	defer t1.close(DiscNetworkError)
+
+	if !t0.enabled || !t1.enabled {
+		t.Fatal("multiplexing not negotiated")
+	}
+	// Queue a large message before a small one on another stream. The small
+	// one must overtake the large one.
+	large := bytes.Repeat([]byte{0xaa}, 8*muxChunkSize)
+	if err := t0.WriteMsg(Msg{Code: 0x10, Size: uint32(len(large)), Payload: bytes.NewReader(large), stream: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := t0.WriteMsg(Msg{Code: 0x11, Size: 3, Payload: bytes.NewReader([]byte{1, 2, 3}), stream: 2}); err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range []struct {
+		code uint64
+		data []byte
+	}{{0x11, []byte{1, 2, 3}}, {0x10, large}} {
+		msg, err := t1.ReadMsg()
+		if err != nil {
+			t.Fatalf("message %d: read failed: %v", i, err)
+		}
+		data, _ := io.ReadAll(msg.Payload)
+		if msg.Code != want.code || !bytes.Equal(data, want.data) {
+			t.Fatalf("message %d: mismatch: code %#x, %d bytes", i, msg.Code, len(data))
+		}
+	}
+}
+
+func TestMuxTransportFallback(t *testing.T) {
+	t0, t1 := muxPipe(t, []Cap{muxCap}, []Cap{{Name: muxCapName, Version: muxVersion + 1}})
+	defer t0.close(DiscNetworkError)
+	defer t1.close(DiscNetworkError)
+
+	if t0.enabled || t1.enabled {
+		t.Fatal("multiplexing enabled without support of the remote side")
+	}
+	go Send(t0, 0x10, []uint{1, 2, 3})
+	if err := ExpectMsg(t1, 0x10, []uint{1, 2, 3}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMuxTransportInvalidFrames(t *testing.T) {
+	large := make([]byte, muxMaxMsgSize-1)
+	tests := []struct {
+		frames [][]byte
+		err    error
+	}{
+		{[][]byte{muxFrame(1, false, 0, 0, []byte{1})}, errMuxUnexpectedChunk},
+		{[][]byte{muxFrame(1, true, 0x10, 4, []byte{1}), muxFrame(1, true, 0x10, 4, []byte{1})}, errMuxUnexpectedChunk},
+		{[][]byte{muxFrame(1, true, 0x10, muxMaxMsgSize+1, nil)}, errMuxTooLarge},
+		{[][]byte{muxFrame(1, true, 0x10, 1, []byte{1, 2})}, errMuxTooLarge},
+		{[][]byte{
+			muxFrame(1, true, 0x10, muxMaxMsgSize, large),
+			muxFrame(2, true, 0x10, muxMaxMsgSize, large),
+			muxFrame(3, true, 0x10, 4, []byte{1, 2, 3}),
+		}, errMuxBufferFull},
+	}
+	for i, test := range tests {
+		tr := &muxTransport{partial: make(map[uint64]*muxPartial)}
+		var err error
+		for _, frame := range test.frames {
+			if _, _, err = tr.readChunk(frame, len(frame)); err != nil {
+				break
+			}
+		}
+		if err != test.err {
+			t.Errorf("test %d: error mismatch: have %v, want %v", i, err, test.err)
+		}
+	}
+}
+
+func muxFrame(stream uint64, first bool, code uint64, size int, data []byte) []byte {
+	var header [muxFrameHeader]byte
+	n := putMuxHeader(header[:], stream, first, code, size)
+	return append(header[:n:n], data...)
+}
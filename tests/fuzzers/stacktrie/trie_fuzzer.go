@@ -25,6 +25,7 @@ import (
 	"io"
 	"sort"
 
+	"github.com/ETX/go-ETX/core/types"
 	"github.com/ETX/go-ETX/etxdb"
 	"github.com/ETX/go-ETX/trie"
 	"golang.org/x/crypto/sha3"
@@ -181,7 +182,7 @@ func (f *fuzzer) fuzz() int {
 		panic(err)
 	}
 	if nodes != nil {
-		dbA.Update(trie.NewWithNodeSet(nodes))
+		dbA.Update(rootA, types.EmptyRootHash, trie.NewWithNodeSet(nodes))
 	}
 	// Flush memdb -> disk (sponge)
 	dbA.Commit(rootA, false, nil)
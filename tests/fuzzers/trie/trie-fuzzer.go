@@ -21,6 +21,7 @@ import (
 	"encoding/binary"
 	"fmt"
 
+	"github.com/ETX/go-ETX/core/types"
 	"github.com/ETX/go-ETX/etxdb/memorydb"
 	"github.com/ETX/go-ETX/trie"
 )
@@ -166,7 +167,7 @@ func runRandTest(rt randTest) error {
 				return err
 			}
 			if nodes != nil {
-				if err := triedb.Update(trie.NewWithNodeSet(nodes)); err != nil {
+				if err := triedb.Update(hash, types.EmptyRootHash, trie.NewWithNodeSet(nodes)); err != nil {
 					return err
 				}
 			}
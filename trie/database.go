@@ -87,6 +87,9 @@ type Database struct {
 	childrenSize common.StorageSize // Storage size of the external children tracking
 	preimages    *preimageStore     // The store for caching preimages
 
+	path    *pathDB           // Path-based node store, nil if the hash-based scheme is used
+	onFlush func(common.Hash) // Callback invoked before a dirty node is written to disk
+
 	lock sync.RWMutex
 }
 
@@ -268,6 +271,8 @@ type Config struct {
 	Cache     int    // Memory allowance (MB) to use for caching trie nodes in memory
 	Journal   string // Journal of clean cache to survive node restarts
 	Preimages bool   // Flag whetxer the preimage of trie key is recorded
+
+	PathDB *PathConfig // Settings of the path-based state scheme, nil selects the hash-based scheme
 }
 
 // NewDatabase creates a new trie database to store ephemeral trie content before
@@ -301,9 +306,30 @@ func NewDatabaseWithConfig(diskdb etxdb.KeyValueStore, config *Config) *Database
 		}},
 		preimages: preimage,
 	}
+	if config != nil && config.PathDB != nil {
+		db.path = newPathDB(diskdb, cleans, config.PathDB)
+	}
 	return db
 }
 
+// Scheme returns the node storage scheme used by the database.
+func (db *Database) Scheme() string {
+	if db.path != nil {
+		return rawdb.PathScheme
+	}
+	return rawdb.HashScheme
+}
+
+// SetFlushCallback registers a callback which is invoked with the hash of every
+// dirty trie node right before it's written to disk, either by an explicit commit
+// or by the memory cap. It's only meaningful in the hash-based scheme.
+//
+// Note, this metxod is a non-synchronized mutator. It is unsafe to call this
+// concurrently with other mutators.
+func (db *Database) SetFlushCallback(callback func(common.Hash)) {
+	db.onFlush = callback
+}
+
 // insert inserts a simplified trie node into the memory database.
 // All nodes inserted by this function will be reference tracked
 // and in theory should only used for **trie nodes** insertion.
@@ -384,6 +410,10 @@ func (db *Database) Node(hash common.Hash) ([]byte, error) {
 	if hash == (common.Hash{}) {
 		return nil, errors.New("not found")
 	}
+	// Trie nodes can't be located by hash alone in the path-based scheme
+	if db.path != nil {
+		return nil, errors.New("not supported in path scheme")
+	}
 	// Retrieve the node from the clean cache if available
 	if db.cleans != nil {
 		if enc := db.cleans.Get(nil, hash[:]); enc != nil {
@@ -438,6 +468,10 @@ func (db *Database) Nodes() []common.Hash {
 // and external node(e.g. storage trie root), all internal trie nodes
 // are referenced togetxer by database itself.
 func (db *Database) Reference(child common.Hash, parent common.Hash) {
+	// Trie nodes are not reference counted in the path-based scheme
+	if db.path != nil {
+		return
+	}
 	db.lock.Lock()
 	defer db.lock.Unlock()
 
@@ -467,6 +501,10 @@ func (db *Database) reference(child common.Hash, parent common.Hash) {
 
 // Dereference removes an existing reference from a root node.
 func (db *Database) Dereference(root common.Hash) {
+	// Stale trie nodes are overwritten in place in the path-based scheme
+	if db.path != nil {
+		return
+	}
 	// Sanity check to ensure that the meta-root is not removed
 	if root == (common.Hash{}) {
 		log.Error("Attempted to dereference the trie cache meta root")
@@ -546,6 +584,10 @@ func (db *Database) dereference(child common.Hash, parent common.Hash) {
 // Note, this metxod is a non-synchronized mutator. It is unsafe to call this
 // concurrently with other mutators.
 func (db *Database) Cap(limit common.StorageSize) error {
+	// The diff layers are capped on update in the path-based scheme
+	if db.path != nil {
+		return nil
+	}
 	// Create a database batch to flush persistent data out. It is important that
 	// outside code doesn't see an inconsistent state (referenced data removed from
 	// memory cache during commit but not yet in persistent storage). This is ensured
@@ -571,6 +613,9 @@ func (db *Database) Cap(limit common.StorageSize) error {
 	for size > limit && oldest != (common.Hash{}) {
 		// Fetch the oldest referenced node and push into the batch
 		node := db.dirties[oldest]
+		if db.onFlush != nil {
+			db.onFlush(oldest)
+		}
 		rawdb.WriteTrieNode(batch, oldest, node.rlp())
 
 		// If we exceeded the ideal batch size, commit and reset
@@ -633,6 +678,16 @@ func (db *Database) Cap(limit common.StorageSize) error {
 // Note, this metxod is a non-synchronized mutator. It is unsafe to call this
 // concurrently with other mutators.
 func (db *Database) Commit(node common.Hash, report bool, callback func(common.Hash)) error {
+	// Merge all the diff layers of the state into the disk layer in the
+	// path-based scheme, along with the accumulated preimages.
+	if db.path != nil {
+		if db.preimages != nil {
+			if err := db.preimages.commit(true); err != nil {
+				return err
+			}
+		}
+		return db.path.commit(node, report)
+	}
 	// Create a database batch to flush persistent data out. It is important that
 	// outside code doesn't see an inconsistent state (referenced data removed from
 	// memory cache during commit but not yet in persistent storage). This is ensured
@@ -703,6 +758,9 @@ func (db *Database) commit(hash common.Hash, batch etxdb.Batch, uncacher *cleane
 		return err
 	}
 	// If we've reached an optimal batch size, commit and start over
+	if db.onFlush != nil {
+		db.onFlush(hash)
+	}
 	rawdb.WriteTrieNode(batch, hash, node.rlp())
 	if callback != nil {
 		callback(hash)
@@ -772,8 +830,13 @@ func (c *cleaner) Delete(key []byte) error {
 }
 
 // Update inserts the dirty nodes in provided nodeset into database and
-// link the account trie with multiple storage tries if necessary.
-func (db *Database) Update(nodes *MergedNodeSet) error {
+// link the account trie with multiple storage tries if necessary. The
+// state roots are only used by the path-based scheme, where the nodes
+// form a new diff layer of the root state on top of the parent one.
+func (db *Database) Update(root common.Hash, parent common.Hash, nodes *MergedNodeSet) error {
+	if db.path != nil {
+		return db.path.update(root, parent, nodes)
+	}
 	db.lock.Lock()
 	defer db.lock.Unlock()
 
@@ -822,6 +885,13 @@ func (db *Database) Update(nodes *MergedNodeSet) error {
 // Size returns the current storage size of the memory cache in front of the
 // persistent database layer.
 func (db *Database) Size() (common.StorageSize, common.StorageSize) {
+	if db.path != nil {
+		var preimageSize common.StorageSize
+		if db.preimages != nil {
+			preimageSize = db.preimages.size()
+		}
+		return db.path.size(), preimageSize
+	}
 	db.lock.RLock()
 	defer db.lock.RUnlock()
 
@@ -838,10 +908,40 @@ func (db *Database) Size() (common.StorageSize, common.StorageSize) {
 }
 
 // GetReader retrieves a node reader belonging to the given state root.
+// Nil is returned in the path-based scheme if the state is not available.
 func (db *Database) GetReader(root common.Hash) Reader {
+	if db.path != nil {
+		l := db.path.layer(root)
+		if l == nil {
+			return nil
+		}
+		return &pathReader{layer: l}
+	}
 	return newHashReader(db)
 }
 
+// Recoverable reports whetxer the persistent state can be reverted to the
+// given one with the stored reverse diffs. It's always false in the hash-based
+// scheme, where the historic states are never discarded.
+func (db *Database) Recoverable(root common.Hash) bool {
+	if db.path == nil {
+		return false
+	}
+	db.path.lock.RLock()
+	defer db.path.lock.RUnlock()
+
+	return db.path.recoverable(root)
+}
+
+// Recover reverts the persistent state to the given one in the path-based
+// scheme, discarding all the in-memory states.
+func (db *Database) Recover(root common.Hash) error {
+	if db.path == nil {
+		return errors.New("not supported in hash scheme")
+	}
+	return db.path.recover(root)
+}
+
 // hashReader is reader of hashDatabase which implements the Reader interface.
 type hashReader struct {
 	db *Database
@@ -46,7 +46,6 @@ func (n *memoryNode) memorySize(key int) int {
 
 // rlp returns the raw rlp encoded blob of the cached trie node, either directly
 // from the cache, or by regenerating it from the collapsed node.
-// nolint:unused
 func (n *memoryNode) rlp() []byte {
 	if node, ok := n.node.(rawNode); ok {
 		return node
@@ -113,7 +112,6 @@ func NewNodeSet(owner common.Hash) *NodeSet {
 	}
 }
 
-/*
 // NewNodeSetWithDeletion initializes the nodeset with provided deletion set.
 func NewNodeSetWithDeletion(owner common.Hash, paths [][]byte, prev [][]byte) *NodeSet {
 	set := NewNodeSet(owner)
@@ -122,7 +120,6 @@ func NewNodeSetWithDeletion(owner common.Hash, paths [][]byte, prev [][]byte) *N
 	}
 	return set
 }
-*/
 
 // markUpdated marks the node as dirty(newly-inserted or updated) with provided
 // node path, node object along with its previous value.
@@ -139,6 +136,25 @@ func (set *NodeSet) markDeleted(path []byte, prev []byte) {
 	set.deletes[string(path)] = prev
 }
 
+// MergeDeletion merges the deleted nodes of another set belonging to the same
+// trie, e.g. the wiped storage of a destructed account which got recreated.
+// The nodes updated in this set take precedence over the deleted ones.
+func (set *NodeSet) MergeDeletion(other *NodeSet) error {
+	if set.owner != other.owner {
+		return fmt.Errorf("nodesets belong to different owner are not mergeable %x-%x", set.owner, other.owner)
+	}
+	for path, prev := range other.deletes {
+		if _, ok := set.updates.nodes[path]; ok {
+			continue
+		}
+		if _, ok := set.deletes[path]; ok {
+			continue
+		}
+		set.deletes[path] = prev
+	}
+	return nil
+}
+
 // addLeaf collects the provided leaf node into set.
 func (set *NodeSet) addLeaf(node *leaf) {
 	set.leaves = append(set.leaves, node)
@@ -0,0 +1,437 @@
+// Copyright 2022 The go-ETX Authors
+// This file is part of the go-ETX library.
+//
+// The go-ETX library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ETX library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ETX library. If not, see <http://www.gnu.org/licenses/>.
+
+package trie
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ETX/go-ETX/common"
+	"github.com/ETX/go-ETX/core/rawdb"
+	"github.com/ETX/go-ETX/etxdb"
+	"github.com/ETX/go-ETX/log"
+	"github.com/ETX/go-ETX/metrics"
+	"github.com/VictoriaMetrics/fastcache"
+)
+
+// maxDiffLayers is the maximum number of diff layers kept in memory on top of
+// the disk layer, matching the number of recent states the hash-based scheme
+// retains in memory. Layers beyond it are merged into the disk layer.
+const maxDiffLayers = 128
+
+var (
+	// errLayerStale is returned from data accessors if the underlying layer
+	// had been invalidated due to the chain progressing forward far enough
+	// to not maintain the layer's original state.
+	errLayerStale = errors.New("layer stale")
+
+	// errUnexpectedNode is returned if the requested node with specified path
+	// is not hash matched with expectation.
+	errUnexpectedNode = errors.New("unexpected node")
+
+	// errStateUnrecoverable is returned if the requested state can't be reverted
+	// to, either because it's newer than the persistent state or the needed
+	// reverse diffs are already pruned.
+	errStateUnrecoverable = errors.New("state is unrecoverable")
+)
+
+var (
+	pathdbDirtyHitMeter  = metrics.NewRegisteredMeter("trie/pathdb/dirty/hit", nil)
+	pathdbDirtyReadMeter = metrics.NewRegisteredMeter("trie/pathdb/dirty/read", nil)
+
+	pathdbCleanHitMeter   = metrics.NewRegisteredMeter("trie/pathdb/clean/hit", nil)
+	pathdbCleanMissMeter  = metrics.NewRegisteredMeter("trie/pathdb/clean/miss", nil)
+	pathdbCleanReadMeter  = metrics.NewRegisteredMeter("trie/pathdb/clean/read", nil)
+	pathdbCleanWriteMeter = metrics.NewRegisteredMeter("trie/pathdb/clean/write", nil)
+
+	pathdbCommitTimeTimer  = metrics.NewRegisteredResettingTimer("trie/pathdb/commit/time", nil)
+	pathdbCommitNodesMeter = metrics.NewRegisteredMeter("trie/pathdb/commit/nodes", nil)
+	pathdbCommitSizeMeter  = metrics.NewRegisteredMeter("trie/pathdb/commit/size", nil)
+
+	pathdbHistoryWriteMeter = metrics.NewRegisteredMeter("trie/pathdb/history/write", nil)
+	pathdbHistoryPruneMeter = metrics.NewRegisteredMeter("trie/pathdb/history/prune", nil)
+)
+
+// PathConfig contains the settings of the path-based state scheme.
+type PathConfig struct {
+	StateHistory uint64 // Number of recent persisted states to keep reverse diffs for, 0 keeps all
+}
+
+// layer is the interface implemented by all state layers, which includes some
+// public methods and some additional methods for internal usage.
+type layer interface {
+	// node retrieves the RLP-encoded trie node with the provided trie identifier,
+	// node path and the corresponding node hash. An error is returned if the node
+	// is missing, not matched with the hash or the layer is stale.
+	node(owner common.Hash, path []byte, hash common.Hash) ([]byte, error)
+
+	// rootHash returns the root hash of the state the layer represents.
+	rootHash() common.Hash
+
+	// stateID returns the sequential id of the state the layer represents.
+	stateID() uint64
+
+	// parentLayer returns the layer the current one is built on, or nil for
+	// the disk layer.
+	parentLayer() layer
+
+	// markStale flags the layer as unusable, all further node accesses fail.
+	markStale()
+}
+
+// pathDB is the node store of the path-based state scheme. Trie nodes are keyed
+// by their owner and path on disk, so persisting a state overwrites the nodes
+// of the previous one in place and only the latest persisted state is kept on
+// disk. The recent states are maintained in memory as a tree of diff layers on
+// top of the disk layer, and a reverse diff is stored for every persisted state
+// to be able to roll the persistent state back on deep reorgs.
+type pathDB struct {
+	diskdb etxdb.KeyValueStore
+	cleans *fastcache.Cache // Clean node cache keyed by owner and path, shared with the hash scheme fields
+	config *PathConfig
+
+	layers map[common.Hash]layer // Known state layers, keyed by state root
+	lock   sync.RWMutex
+}
+
+// newPathDB opens the path-based node store on top of the given database, with
+// the latest persisted state as its only layer.
+func newPathDB(diskdb etxdb.KeyValueStore, cleans *fastcache.Cache, config *PathConfig) *pathDB {
+	disk := loadDiskLayer(diskdb, cleans)
+	return &pathDB{
+		diskdb: diskdb,
+		cleans: cleans,
+		config: config,
+		layers: map[common.Hash]layer{disk.root: disk},
+	}
+}
+
+// loadDiskLayer creates the disk layer of the state currently persisted in the
+// database, which is the empty state if there's none yet.
+func loadDiskLayer(diskdb etxdb.KeyValueStore, cleans *fastcache.Cache) *diskLayer {
+	root := emptyRoot
+	if blob, hash := rawdb.ReadAccountTrieNode(diskdb, nil); len(blob) != 0 {
+		root = hash
+	}
+	return newDiskLayer(root, rawdb.ReadPersistentStateID(diskdb), diskdb, cleans)
+}
+
+// layer retrieves the state layer with the given root, or nil if the state is
+// not available. The zero hash is treated as the empty state root.
+func (db *pathDB) layer(root common.Hash) layer {
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+
+	if root == (common.Hash{}) {
+		root = emptyRoot
+	}
+	if l := db.layers[root]; l != nil {
+		return l
+	}
+	// The empty state contains no trie nodes at all, it can be served by
+	// any layer even if it's not tracked explicitly.
+	if root == emptyRoot {
+		return db.disk()
+	}
+	return nil
+}
+
+// disk returns the current disk layer.
+//
+// Note, this metxod assumes the lock is held!
+func (db *pathDB) disk() *diskLayer {
+	for _, l := range db.layers {
+		for l.parentLayer() != nil {
+			l = l.parentLayer()
+		}
+		return l.(*diskLayer)
+	}
+	return nil
+}
+
+// update adds a new diff layer on top of the layer of the parent state, holding
+// the trie nodes changed by the state transition. The oldest diff layers are
+// merged into the disk layer if there are too many of them.
+func (db *pathDB) update(root common.Hash, parentRoot common.Hash, nodes *MergedNodeSet) error {
+	if parentRoot == (common.Hash{}) {
+		parentRoot = emptyRoot
+	}
+	// Reject noop updates to avoid self-loops in the layer tree
+	if root == parentRoot {
+		return errors.New("layer cycle")
+	}
+	db.lock.Lock()
+	defer db.lock.Unlock()
+
+	// The state may be reached through another path already (e.g. the block
+	// is imported again), in which case it's identical.
+	if _, ok := db.layers[root]; ok {
+		return nil
+	}
+	parent := db.layers[parentRoot]
+	if parent == nil {
+		return fmt.Errorf("triedb parent [%#x] layer missing", parentRoot)
+	}
+	var (
+		size  uint64
+		dirty = make(map[common.Hash]map[string]*memoryNode)
+	)
+	if nodes != nil {
+		for owner, set := range nodes.sets {
+			subset := make(map[string]*memoryNode)
+			for path := range set.deletes {
+				subset[path] = &memoryNode{}
+				size += uint64(len(path) + common.HashLength)
+			}
+			for path, n := range set.updates.nodes {
+				blob := n.rlp()
+				subset[path] = &memoryNode{hash: n.hash, size: uint16(len(blob)), node: rawNode(blob)}
+				size += uint64(len(path) + len(blob) + common.HashLength)
+			}
+			dirty[owner] = subset
+		}
+	}
+	db.layers[root] = newDiffLayer(parent, root, parent.stateID()+1, dirty, size)
+
+	// Keep 128 diff layers in the memory, persistent layer is 129th.
+	// - head layer is paired with HEAD state
+	// - head-1 layer is paired with HEAD-1 state
+	// - head-127 layer(bottom-most diff layer) is paired with HEAD-127 state
+	return db.cap(root, maxDiffLayers)
+}
+
+// cap merges the diff layers below the given root into the disk layer until at
+// most the given number of diff layers remain on top of it. The layers not
+// descending from the new disk layer are dropped.
+//
+// Note, this metxod assumes the lock is held!
+func (db *pathDB) cap(root common.Hash, layers int) error {
+	l := db.layers[root]
+	if l == nil {
+		return fmt.Errorf("triedb layer [%#x] missing", root)
+	}
+	// Collect the diff layers from the given one down to the disk layer
+	var chain []*diffLayer
+	for {
+		diff, ok := l.(*diffLayer)
+		if !ok {
+			break
+		}
+		chain = append(chain, diff)
+		l = diff.parentLayer()
+	}
+	if len(chain) <= layers {
+		return nil
+	}
+	var (
+		start = time.Now()
+		disk  = l.(*diskLayer)
+		nodes int
+		size  uint64
+	)
+	for i := len(chain) - 1; i >= layers; i-- {
+		ndisk, err := disk.commit(chain[i], db.config.StateHistory)
+		if err != nil {
+			return err
+		}
+		chain[i].markStale()
+		disk = ndisk
+
+		for _, subset := range chain[i].nodes {
+			nodes += len(subset)
+		}
+		size += chain[i].memory
+	}
+	// Rebuild the layer tree, dropping the layers not built on the new disk
+	// layer (the merged ones and the forks off them). The layers built on the
+	// topmost merged one are linked to the new disk layer instead.
+	remain := map[common.Hash]layer{disk.root: disk}
+	for root, l := range db.layers {
+		diff, ok := l.(*diffLayer)
+		if !ok || diff.stale() {
+			continue
+		}
+		var (
+			child = diff
+			base  = diff.parentLayer()
+		)
+		for {
+			parent, ok := base.(*diffLayer)
+			if !ok || parent.stale() {
+				break
+			}
+			child, base = parent, parent.parentLayer()
+		}
+		switch {
+		case base == disk:
+			remain[root] = diff
+		case base.rootHash() == disk.root:
+			child.setParent(disk)
+			remain[root] = diff
+		default:
+			diff.markStale()
+		}
+	}
+	db.layers = remain
+
+	pathdbCommitTimeTimer.Update(time.Since(start))
+	pathdbCommitNodesMeter.Mark(int64(nodes))
+	pathdbCommitSizeMeter.Mark(int64(size))
+
+	log.Debug("Persisted trie layers", "layers", len(chain)-layers, "nodes", nodes, "size", common.StorageSize(size), "root", disk.root, "id", disk.id, "elapsed", common.PrettyDuration(time.Since(start)))
+	return nil
+}
+
+// commit merges all the diff layers of the given state into the disk layer.
+func (db *pathDB) commit(root common.Hash, report bool) error {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+
+	start := time.Now()
+	if err := db.cap(root, 0); err != nil {
+		return err
+	}
+	logger := log.Info
+	if !report {
+		logger = log.Debug
+	}
+	logger("Persisted trie from memory database", "root", root, "id", db.layers[root].stateID(), "time", common.PrettyDuration(time.Since(start)))
+	return nil
+}
+
+// size returns the memory used by the diff layers.
+func (db *pathDB) size() common.StorageSize {
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+
+	var size uint64
+	for _, l := range db.layers {
+		if diff, ok := l.(*diffLayer); ok {
+			size += diff.memory
+		}
+	}
+	return common.StorageSize(size)
+}
+
+// recoverable reports whetxer the persistent state can be reverted to the
+// specified one.
+//
+// Note, this metxod assumes the lock is held!
+func (db *pathDB) recoverable(root common.Hash) bool {
+	id := rawdb.ReadStateID(db.diskdb, root)
+	if id == nil {
+		return false
+	}
+	// Only the states older than the persistent one are recoverable, as
+	// long as all the reverse diffs in between are still present.
+	if *id >= db.disk().id {
+		return false
+	}
+	return *id >= rawdb.ReadStateHistoryTail(db.diskdb)
+}
+
+// recover reverts the persistent state to the specified one by applying the
+// reverse diffs in order. All the in-memory diff layers are discarded.
+func (db *pathDB) recover(root common.Hash) (err error) {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+
+	if !db.recoverable(root) {
+		return errStateUnrecoverable
+	}
+	var (
+		start   = time.Now()
+		disk    = db.disk()
+		target  = *rawdb.ReadStateID(db.diskdb, root)
+		current = disk.id
+		batch   = db.diskdb.NewBatch()
+	)
+	for _, l := range db.layers {
+		l.markStale()
+	}
+	// The reverse diffs are flushed in chunks, reload whatever state ended up
+	// on disk if the revert fails halfway.
+	defer func() {
+		if err != nil {
+			if db.cleans != nil {
+				db.cleans.Reset()
+			}
+			disk := loadDiskLayer(db.diskdb, db.cleans)
+			db.layers = map[common.Hash]layer{disk.root: disk}
+		}
+	}()
+	for current > target {
+		diff, err := readReverseDiff(db.diskdb, current)
+		if err != nil {
+			return err
+		}
+		diff.apply(batch)
+		if id := rawdb.ReadStateID(db.diskdb, diff.Root); id != nil && *id == current {
+			rawdb.DeleteStateID(batch, diff.Root)
+		}
+		rawdb.DeleteReverseDiff(batch, current)
+		rawdb.WritePersistentStateID(batch, current-1)
+
+		// Flush the accumulated changes at the boundary of reverse diffs, so
+		// the persistent state id always matches the nodes on disk.
+		if batch.ValueSize() > etxdb.IdealBatchSize {
+			if err := batch.Write(); err != nil {
+				return err
+			}
+			batch.Reset()
+		}
+		current--
+		if current == target && diff.Parent != root {
+			return fmt.Errorf("inconsistent state history, want %#x, got %#x", root, diff.Parent)
+		}
+	}
+	if err := batch.Write(); err != nil {
+		return err
+	}
+	if db.cleans != nil {
+		db.cleans.Reset()
+	}
+	db.layers = map[common.Hash]layer{root: newDiskLayer(root, target, db.diskdb, db.cleans)}
+
+	log.Info("Reverted persistent state", "root", root, "id", target, "reverted", disk.id-target, "elapsed", common.PrettyDuration(time.Since(start)))
+	return nil
+}
+
+// pathReader is the node reader of the path-based scheme, which implements the
+// Reader interface for a specific state layer.
+type pathReader struct {
+	layer layer
+}
+
+// Node retrieves the trie node with the given node path and node hash. An
+// error is returned if the node is not found or the layer is stale.
+func (reader *pathReader) Node(owner common.Hash, path []byte, hash common.Hash) (node, error) {
+	blob, err := reader.layer.node(owner, path, hash)
+	if err != nil {
+		return nil, err
+	}
+	return mustDecodeNode(hash[:], blob), nil
+}
+
+// NodeBlob retrieves the RLP-encoded trie node blob with the given node path
+// and node hash. An error is returned if the node is not found or the layer is
+// stale.
+func (reader *pathReader) NodeBlob(owner common.Hash, path []byte, hash common.Hash) ([]byte, error) {
+	return reader.layer.node(owner, path, hash)
+}
@@ -0,0 +1,104 @@
+// Copyright 2022 The go-ETX Authors
+// This file is part of the go-ETX library.
+//
+// The go-ETX library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ETX library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ETX library. If not, see <http://www.gnu.org/licenses/>.
+
+package trie
+
+import (
+	"fmt"
+
+	"github.com/ETX/go-ETX/common"
+	"github.com/ETX/go-ETX/core/rawdb"
+	"github.com/ETX/go-ETX/etxdb"
+	"github.com/ETX/go-ETX/rlp"
+)
+
+// reverseDiffNode is the pre-value of a single trie node changed by a state
+// transition. An empty blob means the node didn't exist before.
+type reverseDiffNode struct {
+	Owner common.Hash
+	Path  []byte
+	Blob  []byte
+}
+
+// reverseDiff is the state history entry of the path-based scheme. It holds the
+// pre-values of all the trie nodes changed by the transition from the parent
+// state to the state with the same id, and reverts the transition when applied
+// on top of the persistent state.
+type reverseDiff struct {
+	Parent common.Hash       // Root hash of the state the diff reverts to
+	Root   common.Hash       // Root hash of the state the diff reverts from
+	Nodes  []reverseDiffNode // Pre-values of the changed trie nodes
+}
+
+// apply writes the pre-values of the trie nodes into the given batch.
+func (diff *reverseDiff) apply(batch etxdb.KeyValueWriter) {
+	for _, n := range diff.Nodes {
+		if len(n.Blob) == 0 {
+			deletePathNode(batch, n.Owner, n.Path)
+		} else {
+			writePathNode(batch, n.Owner, n.Path, n.Blob)
+		}
+	}
+}
+
+// readReverseDiff retrieves and decodes the reverse diff with the given id.
+func readReverseDiff(db etxdb.KeyValueReader, id uint64) (*reverseDiff, error) {
+	blob := rawdb.ReadReverseDiff(db, id)
+	if len(blob) == 0 {
+		return nil, fmt.Errorf("reverse diff %d is missing", id)
+	}
+	var diff reverseDiff
+	if err := rlp.DecodeBytes(blob, &diff); err != nil {
+		return nil, fmt.Errorf("reverse diff %d is corrupted: %v", id, err)
+	}
+	return &diff, nil
+}
+
+// writeReverseDiff encodes and stores the reverse diff with the given id.
+func writeReverseDiff(db etxdb.KeyValueWriter, id uint64, diff *reverseDiff) error {
+	blob, err := rlp.EncodeToBytes(diff)
+	if err != nil {
+		return err
+	}
+	rawdb.WriteReverseDiff(db, id, blob)
+	pathdbHistoryWriteMeter.Mark(int64(len(blob)))
+	return nil
+}
+
+// truncateReverseDiffs deletes the reverse diffs up to and including the given
+// id, along with the state lookups of the states they revert to, which aren't
+// recoverable anymore.
+func truncateReverseDiffs(db etxdb.KeyValueReader, batch etxdb.KeyValueWriter, tail uint64) error {
+	oldTail := rawdb.ReadStateHistoryTail(db)
+	if tail <= oldTail {
+		return nil
+	}
+	for id := oldTail + 1; id <= tail; id++ {
+		if blob := rawdb.ReadReverseDiff(db, id); len(blob) != 0 {
+			diff, err := readReverseDiff(db, id)
+			if err != nil {
+				return err
+			}
+			if sid := rawdb.ReadStateID(db, diff.Parent); sid != nil && *sid == id-1 {
+				rawdb.DeleteStateID(batch, diff.Parent)
+			}
+			pathdbHistoryPruneMeter.Mark(int64(len(blob)))
+		}
+		rawdb.DeleteReverseDiff(batch, id)
+	}
+	rawdb.WriteStateHistoryTail(batch, tail)
+	return nil
+}
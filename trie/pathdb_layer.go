@@ -0,0 +1,315 @@
+// Copyright 2022 The go-ETX Authors
+// This file is part of the go-ETX library.
+//
+// The go-ETX library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ETX library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ETX library. If not, see <http://www.gnu.org/licenses/>.
+
+package trie
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/ETX/go-ETX/common"
+	"github.com/ETX/go-ETX/core/rawdb"
+	"github.com/ETX/go-ETX/crypto"
+	"github.com/ETX/go-ETX/etxdb"
+	"github.com/VictoriaMetrics/fastcache"
+)
+
+// diffLayer represents a collection of modifications made to a state by a
+// state transition, on top of its parent layer. The trie nodes are kept in
+// memory, keyed by their owner and path. Deleted nodes are kept as empty
+// memory nodes to shadow the ones in the parent layers.
+type diffLayer struct {
+	root   common.Hash                            // Root hash of the state this layer represents
+	id     uint64                                 // Sequential id of the state
+	nodes  map[common.Hash]map[string]*memoryNode // Dirty trie nodes, keyed by owner and path
+	memory uint64                                 // Approximate memory used by the dirty nodes
+
+	parent  layer // Parent layer modified by this one, never nil
+	isStale bool  // Flag whetxer the layer has been merged into disk or dropped
+	lock    sync.RWMutex
+}
+
+// newDiffLayer creates a new diff layer on top of the given parent.
+func newDiffLayer(parent layer, root common.Hash, id uint64, nodes map[common.Hash]map[string]*memoryNode, memory uint64) *diffLayer {
+	return &diffLayer{
+		root:   root,
+		id:     id,
+		nodes:  nodes,
+		memory: memory,
+		parent: parent,
+	}
+}
+
+// rootHash implements the layer interface, returning the root hash of the
+// state this layer represents.
+func (dl *diffLayer) rootHash() common.Hash {
+	return dl.root
+}
+
+// stateID implements the layer interface, returning the id of the state.
+func (dl *diffLayer) stateID() uint64 {
+	return dl.id
+}
+
+// parentLayer implements the layer interface, returning the layer this one
+// was built on.
+func (dl *diffLayer) parentLayer() layer {
+	dl.lock.RLock()
+	defer dl.lock.RUnlock()
+
+	return dl.parent
+}
+
+// setParent replaces the parent layer, used when the original parent got
+// merged into the disk layer.
+func (dl *diffLayer) setParent(parent layer) {
+	dl.lock.Lock()
+	defer dl.lock.Unlock()
+
+	dl.parent = parent
+}
+
+// stale reports whetxer the layer is no longer usable.
+func (dl *diffLayer) stale() bool {
+	dl.lock.RLock()
+	defer dl.lock.RUnlock()
+
+	return dl.isStale
+}
+
+// markStale implements the layer interface, flagging the layer as unusable.
+func (dl *diffLayer) markStale() {
+	dl.lock.Lock()
+	defer dl.lock.Unlock()
+
+	dl.isStale = true
+}
+
+// node implements the layer interface, retrieving the trie node from this
+// layer or falling back to the parent ones.
+func (dl *diffLayer) node(owner common.Hash, path []byte, hash common.Hash) ([]byte, error) {
+	dl.lock.RLock()
+	if dl.isStale {
+		dl.lock.RUnlock()
+		return nil, errLayerStale
+	}
+	if subset, ok := dl.nodes[owner]; ok {
+		if n, ok := subset[string(path)]; ok {
+			dl.lock.RUnlock()
+
+			// If the trie node is not hash matched, or marked as deleted,
+			// returns an error here. It shouldn't happen at all.
+			if n.hash != hash {
+				return nil, fmt.Errorf("%w %x!=%x(%x %v)", errUnexpectedNode, n.hash, hash, owner, path)
+			}
+			blob := n.rlp()
+			pathdbDirtyHitMeter.Mark(1)
+			pathdbDirtyReadMeter.Mark(int64(len(blob)))
+			return blob, nil
+		}
+	}
+	parent := dl.parent
+	dl.lock.RUnlock()
+
+	return parent.node(owner, path, hash)
+}
+
+// diskLayer is the bottom layer of the state tree, representing the latest
+// state persisted in the database.
+type diskLayer struct {
+	diskdb etxdb.KeyValueStore // Key-value store containing the persistent state
+	cleans *fastcache.Cache    // Clean node cache to avoid hitting the disk for direct access
+	root   common.Hash         // Root hash of the persistent state
+	id     uint64              // Sequential id of the persistent state
+
+	isStale bool // Flag whetxer the layer has been superseded by a newer disk layer
+	lock    sync.RWMutex
+}
+
+// newDiskLayer creates a new disk layer for the given persistent state.
+func newDiskLayer(root common.Hash, id uint64, diskdb etxdb.KeyValueStore, cleans *fastcache.Cache) *diskLayer {
+	return &diskLayer{
+		diskdb: diskdb,
+		cleans: cleans,
+		root:   root,
+		id:     id,
+	}
+}
+
+// rootHash implements the layer interface, returning the root hash of the
+// persistent state.
+func (dl *diskLayer) rootHash() common.Hash {
+	return dl.root
+}
+
+// stateID implements the layer interface, returning the id of the persistent
+// state.
+func (dl *diskLayer) stateID() uint64 {
+	return dl.id
+}
+
+// parentLayer implements the layer interface, returning nil as there's no
+// layer below the disk one.
+func (dl *diskLayer) parentLayer() layer {
+	return nil
+}
+
+// markStale implements the layer interface, flagging the layer as unusable.
+func (dl *diskLayer) markStale() {
+	dl.lock.Lock()
+	defer dl.lock.Unlock()
+
+	dl.isStale = true
+}
+
+// node implements the layer interface, retrieving the trie node from the
+// clean cache or the database.
+func (dl *diskLayer) node(owner common.Hash, path []byte, hash common.Hash) ([]byte, error) {
+	dl.lock.RLock()
+	defer dl.lock.RUnlock()
+
+	if dl.isStale {
+		return nil, errLayerStale
+	}
+	key := cacheKey(owner, path)
+	if dl.cleans != nil {
+		if blob := dl.cleans.Get(nil, key); len(blob) > 0 && crypto.Keccak256Hash(blob) == hash {
+			pathdbCleanHitMeter.Mark(1)
+			pathdbCleanReadMeter.Mark(int64(len(blob)))
+			return blob, nil
+		}
+		pathdbCleanMissMeter.Mark(1)
+	}
+	var (
+		blob  []byte
+		nHash common.Hash
+	)
+	if owner == (common.Hash{}) {
+		blob, nHash = rawdb.ReadAccountTrieNode(dl.diskdb, path)
+	} else {
+		blob, nHash = rawdb.ReadStorageTrieNode(dl.diskdb, owner, path)
+	}
+	if nHash != hash {
+		return nil, fmt.Errorf("%w %x!=%x(%x %v)", errUnexpectedNode, nHash, hash, owner, path)
+	}
+	if dl.cleans != nil && len(blob) > 0 {
+		dl.cleans.Set(key, blob)
+		pathdbCleanWriteMeter.Mark(int64(len(blob)))
+	}
+	return blob, nil
+}
+
+// commit merges the given diff layer, built directly on top of this disk layer,
+// into the database and returns the new disk layer representing the merged
+// state. The reverse diff of the transition is stored alongside, and the state
+// history beyond the given limit is pruned. The current layer becomes stale.
+func (dl *diskLayer) commit(bottom *diffLayer, history uint64) (*diskLayer, error) {
+	dl.lock.Lock()
+	defer dl.lock.Unlock()
+
+	if dl.isStale {
+		return nil, errLayerStale
+	}
+	var (
+		batch = dl.diskdb.NewBatch()
+		diff  = &reverseDiff{Parent: dl.root, Root: bottom.root}
+	)
+	for owner, subset := range bottom.nodes {
+		for path, n := range subset {
+			// Record the persisted value as the pre-value, it's what the
+			// state has to be reverted to.
+			diff.Nodes = append(diff.Nodes, reverseDiffNode{
+				Owner: owner,
+				Path:  []byte(path),
+				Blob:  readPathNode(dl.diskdb, owner, []byte(path)),
+			})
+			if n.hash == (common.Hash{}) {
+				deletePathNode(batch, owner, []byte(path))
+			} else {
+				writePathNode(batch, owner, []byte(path), n.rlp())
+			}
+		}
+	}
+	if err := writeReverseDiff(batch, bottom.id, diff); err != nil {
+		return nil, err
+	}
+	rawdb.WriteStateID(batch, bottom.root, bottom.id)
+	rawdb.WritePersistentStateID(batch, bottom.id)
+
+	if history > 0 && bottom.id > history {
+		if err := truncateReverseDiffs(dl.diskdb, batch, bottom.id-history); err != nil {
+			return nil, err
+		}
+	}
+	if err := batch.Write(); err != nil {
+		return nil, err
+	}
+	// Update the clean cache with the new node values only after the write,
+	// the readers of this layer shouldn't see the new state.
+	if dl.cleans != nil {
+		for owner, subset := range bottom.nodes {
+			for path, n := range subset {
+				key := cacheKey(owner, []byte(path))
+				if n.hash == (common.Hash{}) {
+					dl.cleans.Del(key)
+				} else {
+					dl.cleans.Set(key, n.rlp())
+				}
+			}
+		}
+	}
+	dl.isStale = true
+	return newDiskLayer(bottom.root, bottom.id, dl.diskdb, dl.cleans), nil
+}
+
+// cacheKey constructs the key of the clean cache for the given trie node.
+func cacheKey(owner common.Hash, path []byte) []byte {
+	if owner == (common.Hash{}) {
+		return path
+	}
+	return append(owner.Bytes(), path...)
+}
+
+// readPathNode retrieves the trie node from the database with the given owner
+// and path, regardless of its hash.
+func readPathNode(db etxdb.KeyValueReader, owner common.Hash, path []byte) []byte {
+	if owner == (common.Hash{}) {
+		blob, _ := rawdb.ReadAccountTrieNode(db, path)
+		return blob
+	}
+	blob, _ := rawdb.ReadStorageTrieNode(db, owner, path)
+	return blob
+}
+
+// writePathNode writes the trie node into the database with the given owner
+// and path.
+func writePathNode(db etxdb.KeyValueWriter, owner common.Hash, path []byte, blob []byte) {
+	if owner == (common.Hash{}) {
+		rawdb.WriteAccountTrieNode(db, path, blob)
+	} else {
+		rawdb.WriteStorageTrieNode(db, owner, path, blob)
+	}
+}
+
+// deletePathNode deletes the trie node from the database with the given owner
+// and path.
+func deletePathNode(db etxdb.KeyValueWriter, owner common.Hash, path []byte) {
+	if owner == (common.Hash{}) {
+		rawdb.DeleteAccountTrieNode(db, path)
+	} else {
+		rawdb.DeleteStorageTrieNode(db, owner, path)
+	}
+}
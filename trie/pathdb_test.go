@@ -0,0 +1,187 @@
+// Copyright 2022 The go-ETX Authors
+// This file is part of the go-ETX library.
+//
+// The go-ETX library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ETX library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ETX library. If not, see <http://www.gnu.org/licenses/>.
+
+package trie
+
+import (
+	"testing"
+
+	"github.com/ETX/go-ETX/common"
+	"github.com/ETX/go-ETX/core/rawdb"
+	"github.com/ETX/go-ETX/etxdb"
+	"github.com/ETX/go-ETX/etxdb/memorydb"
+)
+
+// newPathTestDB creates a path-based trie database on top of a memory store.
+func newPathTestDB(history uint64) (*Database, etxdb.KeyValueStore) {
+	diskdb := memorydb.New()
+	return NewDatabaseWithConfig(diskdb, &Config{PathDB: &PathConfig{StateHistory: history}}), diskdb
+}
+
+// updatePathTrie applies the given changes on top of the parent state and
+// inserts the dirty nodes as a new layer. Empty values are deleted.
+func updatePathTrie(t *testing.T, db *Database, parent common.Hash, changes map[string]string) common.Hash {
+	tr, err := New(TrieID(parent), db)
+	if err != nil {
+		t.Fatalf("Failed to open trie %x: %v", parent, err)
+	}
+	for key, val := range changes {
+		if val == "" {
+			tr.Delete([]byte(key))
+		} else {
+			tr.Update([]byte(key), []byte(val))
+		}
+	}
+	root, nodes, err := tr.Commit(false)
+	if err != nil {
+		t.Fatalf("Failed to commit trie: %v", err)
+	}
+	merged := NewMergedNodeSet()
+	if nodes != nil {
+		merged.Merge(nodes)
+	}
+	if err := db.Update(root, parent, merged); err != nil {
+		t.Fatalf("Failed to update database: %v", err)
+	}
+	return root
+}
+
+// checkPathTrie verifies the content of the trie with the given root.
+func checkPathTrie(t *testing.T, db *Database, root common.Hash, want map[string]string) {
+	tr, err := New(TrieID(root), db)
+	if err != nil {
+		t.Fatalf("Failed to open trie %x: %v", root, err)
+	}
+	for key, val := range want {
+		got, err := tr.TryGet([]byte(key))
+		if err != nil {
+			t.Fatalf("Failed to read %q from %x: %v", key, root, err)
+		}
+		if string(got) != val {
+			t.Fatalf("Value mismatch for %q in %x, want %q, got %q", key, root, val, got)
+		}
+	}
+}
+
+func TestPathDBLayers(t *testing.T) {
+	db, diskdb := newPathTestDB(0)
+
+	root1 := updatePathTrie(t, db, emptyRoot, map[string]string{"doe": "reindeer", "dog": "puppy", "dogglesworth": "cat"})
+	root2 := updatePathTrie(t, db, root1, map[string]string{"dog": "hound", "dogglesworth": ""})
+	root3 := updatePathTrie(t, db, root2, map[string]string{"horse": "stallion"})
+
+	// All the states are served from memory, nothing is persisted yet
+	checkPathTrie(t, db, root1, map[string]string{"doe": "reindeer", "dog": "puppy", "dogglesworth": "cat"})
+	checkPathTrie(t, db, root2, map[string]string{"doe": "reindeer", "dog": "hound", "dogglesworth": ""})
+	checkPathTrie(t, db, root3, map[string]string{"doe": "reindeer", "dog": "hound", "horse": "stallion"})
+	if blob, _ := rawdb.ReadAccountTrieNode(diskdb, nil); len(blob) != 0 {
+		t.Fatal("State persisted before commit")
+	}
+	// Persist the middle state, the older one becomes unavailable while the
+	// newer one is still served from memory
+	if err := db.Commit(root2, false, nil); err != nil {
+		t.Fatalf("Failed to commit state: %v", err)
+	}
+	if _, hash := rawdb.ReadAccountTrieNode(diskdb, nil); hash != root2 {
+		t.Fatalf("Persisted root mismatch, want %x, got %x", root2, hash)
+	}
+	if db.GetReader(root1) != nil {
+		t.Fatal("Stale state still available")
+	}
+	checkPathTrie(t, db, root2, map[string]string{"doe": "reindeer", "dog": "hound", "dogglesworth": ""})
+	checkPathTrie(t, db, root3, map[string]string{"doe": "reindeer", "dog": "hound", "horse": "stallion"})
+
+	// Reopen the database, only the persisted state must be available
+	db = NewDatabaseWithConfig(diskdb, &Config{PathDB: &PathConfig{}})
+	if db.GetReader(root3) != nil {
+		t.Fatal("Unpersisted state available after restart")
+	}
+	checkPathTrie(t, db, root2, map[string]string{"doe": "reindeer", "dog": "hound", "dogglesworth": ""})
+}
+
+func TestPathDBRecover(t *testing.T) {
+	db, _ := newPathTestDB(0)
+
+	var (
+		roots  []common.Hash
+		parent = emptyRoot
+	)
+	for _, changes := range []map[string]string{
+		{"doe": "reindeer", "dog": "puppy"},
+		{"dog": "hound", "dogglesworth": "cat"},
+		{"doe": "", "horse": "stallion"},
+	} {
+		parent = updatePathTrie(t, db, parent, changes)
+		if err := db.Commit(parent, false, nil); err != nil {
+			t.Fatalf("Failed to commit state: %v", err)
+		}
+		roots = append(roots, parent)
+	}
+	if db.Recoverable(roots[2]) {
+		t.Fatal("Persistent state reported as recoverable")
+	}
+	if !db.Recoverable(roots[0]) {
+		t.Fatal("Historic state reported as unrecoverable")
+	}
+	if err := db.Recover(roots[0]); err != nil {
+		t.Fatalf("Failed to revert state: %v", err)
+	}
+	checkPathTrie(t, db, roots[0], map[string]string{"doe": "reindeer", "dog": "puppy", "dogglesworth": "", "horse": ""})
+	if db.GetReader(roots[2]) != nil {
+		t.Fatal("Reverted state still available")
+	}
+	if db.Recoverable(roots[1]) || db.Recoverable(roots[2]) {
+		t.Fatal("Reverted state reported as recoverable")
+	}
+	// The chain must be able to progress on top of the reverted state
+	root := updatePathTrie(t, db, roots[0], map[string]string{"cow": "calf"})
+	if err := db.Commit(root, false, nil); err != nil {
+		t.Fatalf("Failed to commit state: %v", err)
+	}
+	checkPathTrie(t, db, root, map[string]string{"doe": "reindeer", "dog": "puppy", "cow": "calf"})
+}
+
+func TestPathDBHistoryPruning(t *testing.T) {
+	db, diskdb := newPathTestDB(1)
+
+	var (
+		roots  []common.Hash
+		parent = emptyRoot
+	)
+	for i, key := range []string{"doe", "dog", "horse"} {
+		parent = updatePathTrie(t, db, parent, map[string]string{key: string(rune('a' + i))})
+		if err := db.Commit(parent, false, nil); err != nil {
+			t.Fatalf("Failed to commit state: %v", err)
+		}
+		roots = append(roots, parent)
+	}
+	if tail := rawdb.ReadStateHistoryTail(diskdb); tail != 2 {
+		t.Fatalf("State history tail mismatch, want 2, got %d", tail)
+	}
+	if len(rawdb.ReadReverseDiff(diskdb, 2)) != 0 {
+		t.Fatal("Pruned reverse diff still present")
+	}
+	if db.Recoverable(roots[0]) {
+		t.Fatal("Pruned state reported as recoverable")
+	}
+	if !db.Recoverable(roots[1]) {
+		t.Fatal("Retained state reported as unrecoverable")
+	}
+	if err := db.Recover(roots[1]); err != nil {
+		t.Fatalf("Failed to revert state: %v", err)
+	}
+	checkPathTrie(t, db, roots[1], map[string]string{"doe": "a", "dog": "b", "horse": ""})
+}
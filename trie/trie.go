@@ -105,7 +105,11 @@ func New(id *ID, db NodeReader) (*Trie, error) {
 	trie := &Trie{
 		owner:  id.Owner,
 		reader: reader,
-		//tracer: newTracer(),
+	}
+	// The path-based scheme needs the previous values of the changed nodes,
+	// only track the changes if the trie is backed by it.
+	if tdb, ok := db.(*Database); ok && tdb.path != nil {
+		trie.tracer = newTracer()
 	}
 	if id.Root != (common.Hash{}) && id.Root != emptyRoot {
 		rootnode, err := trie.resolveAndTrack(id.Root[:], nil)
@@ -585,8 +589,21 @@ func (t *Trie) Hash() common.Hash {
 func (t *Trie) Commit(collectLeaf bool) (common.Hash, *NodeSet, error) {
 	defer t.tracer.reset()
 
+	// Trie is empty and can be classified into two types of situations:
+	// - The trie was empty and no update happens
+	// - The trie was non-empty and all nodes are dropped
 	if t.root == nil {
-		return emptyRoot, nil, nil
+		// Wrap tracked deletions as the return
+		set := NewNodeSet(t.owner)
+		for _, path := range t.tracer.deleteList() {
+			if prev := t.tracer.getPrev(path); len(prev) != 0 {
+				set.markDeleted(path, prev)
+			}
+		}
+		if _, deleted := set.Size(); deleted == 0 {
+			return emptyRoot, nil, nil
+		}
+		return emptyRoot, set, nil
 	}
 	// Derive the hash for all dirty nodes first. We hold the assumption
 	// in the following procedure that all nodes are hashed.
@@ -70,7 +70,7 @@ func TestTrieTracer(t *testing.T) {
 
 	// Commit the changes and re-create with new root
 	root, nodes, _ := trie.Commit(false)
-	if err := db.Update(NewWithNodeSet(nodes)); err != nil {
+	if err := db.Update(root, emptyRoot, NewWithNodeSet(nodes)); err != nil {
 		t.Fatal(err)
 	}
 	trie, _ = New(TrieID(root), db)
@@ -155,7 +155,7 @@ func TestTrieTracePrevValue(t *testing.T) {
 
 	// Commit the changes and re-create with new root
 	root, nodes, _ := trie.Commit(false)
-	if err := db.Update(NewWithNodeSet(nodes)); err != nil {
+	if err := db.Update(root, emptyRoot, NewWithNodeSet(nodes)); err != nil {
 		t.Fatal(err)
 	}
 	trie, _ = New(TrieID(root), db)